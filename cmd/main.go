@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/habuka036/menu-advisor/internal/service"
 	"github.com/habuka036/menu-advisor/internal/web"
@@ -23,14 +25,123 @@ func main() {
 		log.Println("Successfully loaded school lunch data")
 	}
 
+	// Add local dishes to the built-in dish dictionary
+	dishesPath := filepath.Join("data", "dishes.json")
+	if err := menuService.Dishes().LoadDishes(dishesPath); err != nil {
+		log.Printf("Warning: Could not load dishes: %v", err)
+	} else {
+		log.Printf("Loaded dish dictionary with %d dishes", len(menuService.Dishes().Dishes()))
+	}
+
+	// Recipes for local dishes, in addition to the built-in home recipes
+	recipesPath := filepath.Join("data", "recipes.json")
+	if _, err := os.Stat(recipesPath); err == nil {
+		if err := menuService.Recipes().LoadRecipes(recipesPath); err != nil {
+			log.Printf("Warning: Could not load recipes: %v", err)
+		}
+	}
+	log.Printf("Loaded %d recipes", len(menuService.Recipes().Recipes()))
+
+	// Closures and Saturday classes of the school year, used to check uploaded menus
+	calendarPath := filepath.Join("data", "school_calendar.json")
+	if err := menuService.Calendar().LoadCalendar(calendarPath); err != nil {
+		log.Printf("Warning: Could not load school calendar: %v", err)
+	} else {
+		log.Printf("Loaded school calendar with %d closures", len(menuService.Calendar().Get().Closures))
+	}
+
+	// The children and household size that suggestions and nutrition totals are for
+	householdPath := filepath.Join("data", "household.json")
+	if err := menuService.LoadHousehold(householdPath); err != nil {
+		log.Printf("Warning: Could not load household: %v", err)
+	} else {
+		log.Printf("Loaded household with %d children", len(menuService.Household().Children))
+	}
+
+	// What the household already has, used up first by suggestions and left off shopping lists
+	pantryPath := filepath.Join("data", "pantry.json")
+	if _, err := os.Stat(pantryPath); err == nil {
+		if err := menuService.LoadPantry(pantryPath); err != nil {
+			log.Printf("Warning: Could not load pantry: %v", err)
+		}
+	}
+
 	// Create HTTP handler
 	handler := web.NewHandler(menuService)
 
+	// API tokens of households and reverse proxies, used to count upload quotas
+	clientsPath := filepath.Join("data", "upload_clients.json")
+	if _, err := os.Stat(clientsPath); err == nil {
+		if err := handler.LoadUploadClients(clientsPath); err != nil {
+			log.Printf("Warning: Could not load upload clients: %v", err)
+		}
+	}
+
+	// Load extraction profiles for municipalities that publish menus as web pages
+	profilesPath := filepath.Join("data", "html_profiles.json")
+	if err := handler.DocumentProcessor().LoadHTMLProfiles(profilesPath); err != nil {
+		log.Printf("Warning: Could not load HTML profiles: %v", err)
+	} else {
+		log.Printf("Loaded %d HTML extraction profiles", len(handler.DocumentProcessor().HTMLProfiles()))
+	}
+
+	// Periodically download menus published at fixed URLs
+	sourcesPath := filepath.Join("data", "fetch_sources.json")
+	if _, err := os.Stat(sourcesPath); err == nil {
+		if err := handler.FetchScheduler().LoadSources(sourcesPath); err != nil {
+			log.Printf("Warning: Could not load fetch sources: %v", err)
+		} else {
+			interval := envDuration("FETCH_INTERVAL", 6*time.Hour)
+			log.Printf("Fetching %d menu sources every %s", len(handler.FetchScheduler().Status()), interval)
+			go handler.FetchScheduler().Run(context.Background(), interval)
+		}
+	}
+
+	// Ingest menus that schools send as email attachments
+	mailPath := filepath.Join("data", "mail.json")
+	if _, err := os.Stat(mailPath); err == nil {
+		if config, err := service.LoadMailConfig(mailPath); err != nil {
+			log.Printf("Warning: Could not load mail configuration: %v", err)
+		} else {
+			interval := envDuration("MAIL_INTERVAL", 15*time.Minute)
+			log.Printf("Checking mail from %d senders every %s", len(config.Senders), interval)
+			go service.NewMailIngester(handler.DocumentProcessor(), config).Run(context.Background(), interval)
+		}
+	}
+
 	// Set up routes
 	http.HandleFunc("/", handler.HomeHandler)
 	http.HandleFunc("/api/suggest", handler.SuggestHandler)
 	http.HandleFunc("/api/school-lunches", handler.SchoolLunchHandler)
+	http.HandleFunc("/api/school-lunches/{date}", handler.SchoolLunchItemHandler)
+	http.HandleFunc("/api/school-lunches/{date}/nutrition", handler.SchoolLunchNutritionHandler)
+	http.HandleFunc("/api/school-lunches/{date}/versions", handler.SchoolLunchVersionsHandler)
+	http.HandleFunc("/api/school-lunches/{date}/versions/diff", handler.SchoolLunchVersionDiffHandler)
+	http.HandleFunc("/api/school-lunches/{date}/rollback", handler.SchoolLunchRollbackHandler)
+	http.HandleFunc("/api/audit", handler.AuditHandler)
+	http.HandleFunc("/review", handler.ReviewPageHandler)
+	http.HandleFunc("/api/reviews", handler.ReviewsHandler)
+	http.HandleFunc("/api/reviews/{id}", handler.ReviewItemHandler)
+	http.HandleFunc("/api/reviews/{id}/approve", handler.ReviewApproveHandler)
+	http.HandleFunc("/api/reviews/{id}/reject", handler.ReviewRejectHandler)
+	http.HandleFunc("/api/reviews/{id}/fields/{field}/image", handler.ReviewFieldImageHandler)
 	http.HandleFunc("/api/upload", handler.UploadHandler)
+	http.HandleFunc("/api/upload/preview", handler.UploadPreviewHandler)
+	http.HandleFunc("/api/documents/{id}/trace", handler.DocumentTraceHandler)
+	http.HandleFunc("/api/dishes", handler.DishesHandler)
+	http.HandleFunc("/api/dishes/match", handler.DishMatchHandler)
+	http.HandleFunc("/api/recipes", handler.RecipesHandler)
+	http.HandleFunc("/api/recipes/{dish}", handler.RecipeHandler)
+	http.HandleFunc("/api/household", handler.HouseholdHandler)
+	http.HandleFunc("/api/meals", handler.HomeMealsHandler)
+	http.HandleFunc("/api/meals/{id}", handler.HomeMealItemHandler)
+	http.HandleFunc("/api/nutrition/daily", handler.DailyNutritionHandler)
+	http.HandleFunc("/api/nutrition/weekly", handler.WeeklyNutritionHandler)
+	http.HandleFunc("/api/shopping-list", handler.ShoppingListHandler)
+	http.HandleFunc("/api/pantry", handler.PantryHandler)
+	http.HandleFunc("/api/pantry/{id}", handler.PantryItemHandler)
+	http.HandleFunc("/api/fetch-sources", handler.FetchSourcesHandler)
+	http.HandleFunc("/api/fetch-sources/{name}/fetch", handler.FetchSourceFetchHandler)
 
 	// Serve static files if they exist
 	staticDir := "web/static"
@@ -49,10 +160,41 @@ func main() {
 	log.Printf("📱 Access the service at: http://localhost:%s", port)
 	log.Printf("🔗 API endpoints:")
 	log.Printf("   GET / - Main web interface")
-	log.Printf("   GET /api/suggest?date=YYYY-MM-DD&meal_type=breakfast|dinner")
+	log.Printf("   GET /api/suggest?date=YYYY-MM-DD&meal_type=breakfast|dinner[&grade=...|&child_id=...]")
 	log.Printf("   GET /api/school-lunches - All school lunch data")
+	log.Printf("   POST /api/school-lunches - Create a school lunch menu")
+	log.Printf("   GET|PUT|PATCH|DELETE /api/school-lunches/{date} - Single school lunch menu")
+	log.Printf("   GET /api/school-lunches/{date}/nutrition?grade=... - Published or estimated lunch nutrition")
+	log.Printf("   GET /api/school-lunches/{date}/versions[/diff?from=N&to=M] - Menu history")
+	log.Printf("   POST /api/school-lunches/{date}/rollback - Restore an earlier version")
+	log.Printf("   GET /api/audit?date=YYYY-MM-DD - Change audit log")
+	log.Printf("   GET /review - Review low-confidence OCR extractions")
+	log.Printf("   POST /api/upload/preview - Preview the column mapping of a CSV or Excel upload")
+	log.Printf("   GET /api/documents/{id}/trace - What each processing stage produced for a document")
+	log.Printf("   GET /api/dishes[/match?name=...] - Canonical dish dictionary and name matching")
+	log.Printf("   GET /api/recipes[/{dish}?servings=N] - Recipes of suggested dishes")
+	log.Printf("   GET|PUT /api/household - Household size and children")
+	log.Printf("   GET|POST /api/meals, GET|DELETE /api/meals/{id} - Accepted home meals")
+	log.Printf("   GET /api/nutrition/daily|weekly?date=YYYY-MM-DD&child_id=... - Nutrition totals against targets")
+	log.Printf("   GET /api/shopping-list?from=...&to=...&format=json|text|markdown - Ingredients to buy for planned meals")
+	log.Printf("   GET|PUT|POST /api/pantry, GET|PUT|DELETE /api/pantry/{id} - Ingredients at home and their expiry dates")
+	log.Printf("   GET /api/fetch-sources - Scheduled menu downloads and their last results")
+	log.Printf("   POST /api/fetch-sources/{name}/fetch - Download a menu source now")
 
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+	if err := http.ListenAndServe(":"+port, web.WithRequestID(http.DefaultServeMux)); err != nil {
 		log.Fatal("Server failed to start:", err)
 	}
-}
\ No newline at end of file
+}
+
+// envDuration reads a positive duration such as "30m" from an environment variable
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d
+	}
+	log.Printf("Warning: Invalid %s %q, using %s", name, v, def)
+	return def
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// AuditAction identifies the kind of change recorded in the audit log
+type AuditAction string
+
+const (
+	AuditActionCreate   AuditAction = "create"
+	AuditActionUpdate   AuditAction = "update"
+	AuditActionDelete   AuditAction = "delete"
+	AuditActionRollback AuditAction = "rollback"
+)
+
+// ChangeContext identifies who made a change to the school lunch data and,
+// for uploads, which document it came from. ActorLabel is a name the client
+// gave for itself that was not verified, kept alongside a verified Actor.
+type ChangeContext struct {
+	Actor      string `json:"actor"`
+	ActorLabel string `json:"actor_label,omitempty"`
+	SourceID   string `json:"source_id,omitempty"`
+}
+
+// FieldChange describes a single field that differs between two menu records
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// AuditEntry records a single change made to a school lunch menu
+type AuditEntry struct {
+	Timestamp  time.Time     `json:"timestamp"`
+	Actor      string        `json:"actor"`
+	ActorLabel string        `json:"actor_label,omitempty"`
+	SourceID   string        `json:"source_id,omitempty"`
+	Action     AuditAction   `json:"action"`
+	Date       string        `json:"date"`
+	Changes    []FieldChange `json:"changes,omitempty"`
+}
+
+// SchoolLunchVersion is a snapshot of a day's menu after a change. Menu is nil
+// when the change deleted the day.
+type SchoolLunchVersion struct {
+	Version    int              `json:"version"`
+	Date       string           `json:"date"`
+	Action     AuditAction      `json:"action"`
+	Actor      string           `json:"actor"`
+	ActorLabel string           `json:"actor_label,omitempty"`
+	SourceID   string           `json:"source_id,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Menu       *SchoolLunchMenu `json:"menu,omitempty"`
+}
+
+// SchoolLunchVersionDiff lists the field changes between two versions of a day
+type SchoolLunchVersionDiff struct {
+	Date    string        `json:"date"`
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// SchoolClosure is a period without school lunch, such as the summer break
+type SchoolClosure struct {
+	Name string `json:"name"`
+	From string `json:"from"` // First day, as 2006-01-02
+	To   string `json:"to"`   // Last day; the same as From for a single day
+}
+
+// SchoolCalendar lists the exceptions to a school's usual lunch days. Lunch
+// is served on weekdays that are not national holidays.
+type SchoolCalendar struct {
+	Closures []SchoolClosure `json:"closures,omitempty"`
+	// SchoolDays are weekend days or holidays with lunch, such as Saturday classes
+	SchoolDays []string `json:"school_days,omitempty"`
+}
+
+// Validate checks that every date is well formed and every closure ends after it starts
+func (c *SchoolCalendar) Validate() error {
+	var verrs ValidationErrors
+	for i, closure := range c.Closures {
+		field := fmt.Sprintf("closures[%d]", i)
+		from, err := time.Parse("2006-01-02", closure.From)
+		if err != nil {
+			verrs.Add(field+".from", "must be a date such as 2025-07-19")
+		}
+		to, err2 := time.Parse("2006-01-02", closure.To)
+		if err2 != nil {
+			verrs.Add(field+".to", "must be a date such as 2025-08-31")
+		}
+		if err == nil && err2 == nil && to.Before(from) {
+			verrs.Add(field+".to", "must not be before from")
+		}
+	}
+	for i, day := range c.SchoolDays {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			verrs.Add(fmt.Sprintf("school_days[%d]", i), "must be a date such as 2025-06-14")
+		}
+	}
+	return verrs.Err()
+}
+
+// IssueSeverity tells whether a problem found in a menu keeps it from being stored
+type IssueSeverity string
+
+const (
+	// IssueWarning marks a suspicious value; the day is still stored
+	IssueWarning IssueSeverity = "warning"
+	// IssueError marks a day that cannot be right; it is left out of the merge
+	IssueError IssueSeverity = "error"
+)
+
+// Menu issue codes
+const (
+	IssueMissingDate          = "missing_date"
+	IssueMissingMainDish      = "missing_main_dish"
+	IssueDuplicateDay         = "duplicate_day"
+	IssueNoSchool             = "no_school"             // Weekend, holiday or closure
+	IssueDateOutOfPeriod      = "date_out_of_period"    // Far from the document's date
+	IssueNutritionRange       = "nutrition_range"       // Unusual for a school lunch
+	IssueNutritionImplausible = "nutrition_implausible" // Impossible for a school lunch
+)
+
+// MenuIssue is a problem found in one parsed menu of a document
+type MenuIssue struct {
+	Index    int           `json:"index"` // Position of the menu in the document
+	Date     string        `json:"date,omitempty"`
+	Field    string        `json:"field,omitempty"`
+	Severity IssueSeverity `json:"severity"`
+	Code     string        `json:"code"`
+	Message  string        `json:"message"`
+}
+
+// MenuValidation collects the problems found in the menus of a document
+type MenuValidation struct {
+	Warnings []MenuIssue `json:"warnings"`
+	Errors   []MenuIssue `json:"errors"`
+}
+
+// Add records an issue under its severity
+func (v *MenuValidation) Add(issue MenuIssue) {
+	if issue.Severity == IssueError {
+		v.Errors = append(v.Errors, issue)
+	} else {
+		v.Warnings = append(v.Warnings, issue)
+	}
+}
+
+// Empty reports whether no issue was found
+func (v *MenuValidation) Empty() bool {
+	return len(v.Warnings) == 0 && len(v.Errors) == 0
+}
@@ -0,0 +1,138 @@
+package models
+
+import "fmt"
+
+// DishCategory is the part of a meal a dish fills
+type DishCategory string
+
+const (
+	DishCategoryMain    DishCategory = "main"
+	DishCategorySide    DishCategory = "side"
+	DishCategorySoup    DishCategory = "soup"
+	DishCategoryStaple  DishCategory = "staple"
+	DishCategoryDessert DishCategory = "dessert"
+	DishCategoryDrink   DishCategory = "drink"
+)
+
+// DishCategories lists all dish categories
+var DishCategories = []DishCategory{
+	DishCategoryMain, DishCategorySide, DishCategorySoup,
+	DishCategoryStaple, DishCategoryDessert, DishCategoryDrink,
+}
+
+// ProteinSource is the main protein of a dish
+type ProteinSource string
+
+const (
+	ProteinChicken ProteinSource = "chicken"
+	ProteinPork    ProteinSource = "pork"
+	ProteinBeef    ProteinSource = "beef"
+	ProteinMeat    ProteinSource = "meat" // Minced or mixed meat
+	ProteinFish    ProteinSource = "fish"
+	ProteinEgg     ProteinSource = "egg"
+	ProteinSoy     ProteinSource = "soy"
+)
+
+// ProteinSources lists all protein sources
+var ProteinSources = []ProteinSource{
+	ProteinChicken, ProteinPork, ProteinBeef, ProteinMeat, ProteinFish, ProteinEgg, ProteinSoy,
+}
+
+// IsMeat reports whether the protein comes from meat rather than fish, eggs or soy
+func (p ProteinSource) IsMeat() bool {
+	switch p {
+	case ProteinChicken, ProteinPork, ProteinBeef, ProteinMeat:
+		return true
+	}
+	return false
+}
+
+// Dish is an entry of the canonical dish dictionary. Schools write the same
+// dish in different ways; Aliases lists the other names it is known by.
+type Dish struct {
+	ID       string        `json:"id"`   // Stable identifier such as "chicken_teriyaki"
+	Name     string        `json:"name"` // Canonical name, such as "鶏肉の照り焼き"
+	Aliases  []string      `json:"aliases,omitempty"`
+	Category DishCategory  `json:"category"`
+	Protein  ProteinSource `json:"protein,omitempty"`
+	Tags     []string      `json:"tags,omitempty"` // Such as "curry" or "fried"
+	// Nutrition of one school portion for GradeElementaryMid
+	Nutrition *Nutrition `json:"nutrition,omitempty"`
+}
+
+// HasTag reports whether the dish carries the tag
+func (d *Dish) HasTag(tag string) bool {
+	for _, t := range d.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the dish has an ID, a name and known category and protein
+func (d *Dish) Validate() error {
+	var verrs ValidationErrors
+	if d.ID == "" {
+		verrs.Add("id", "required")
+	}
+	if d.Name == "" {
+		verrs.Add("name", "required")
+	}
+	known := false
+	for _, c := range DishCategories {
+		known = known || d.Category == c
+	}
+	if !known {
+		verrs.Add("category", fmt.Sprintf("unknown category %q", d.Category))
+	}
+	if d.Protein != "" {
+		known = false
+		for _, p := range ProteinSources {
+			known = known || d.Protein == p
+		}
+		if !known {
+			verrs.Add("protein", fmt.Sprintf("unknown protein %q", d.Protein))
+		}
+	}
+	if d.Nutrition != nil {
+		for _, n := range Nutrients {
+			if n.Value(*d.Nutrition) < 0 {
+				verrs.Add("nutrition."+n.Key, "must not be negative")
+			}
+		}
+	}
+	for i, alias := range d.Aliases {
+		if alias == "" {
+			verrs.Add(fmt.Sprintf("aliases[%d]", i), "must not be empty")
+		}
+	}
+	return verrs.Err()
+}
+
+// DishMatchMethod tells how a name was matched to a dish
+type DishMatchMethod string
+
+const (
+	DishMatchExact   DishMatchMethod = "exact"   // Same name once widths, kana and spaces are folded
+	DishMatchReading DishMatchMethod = "reading" // Same name apart from okurigana, as in 照焼 and 照り焼き
+	DishMatchFuzzy   DishMatchMethod = "fuzzy"   // Close enough by edit distance
+)
+
+// DishMatch is the dish a name was matched to. DishID is empty when the name
+// matched no dish in the dictionary.
+type DishMatch struct {
+	Input string `json:"input"`
+	// Variant is a parenthesized detail left out of matching, such as the
+	// "わかめ" of "味噌汁（わかめ）"
+	Variant string          `json:"variant,omitempty"`
+	DishID  string          `json:"dish_id,omitempty"`
+	Name    string          `json:"name,omitempty"` // Canonical name
+	Method  DishMatchMethod `json:"method,omitempty"`
+	Score   float64         `json:"score"` // 1 for an exact match
+}
+
+// Matched reports whether the name was matched to a dish
+func (m DishMatch) Matched() bool {
+	return m.DishID != ""
+}
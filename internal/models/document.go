@@ -2,6 +2,7 @@ package models
 
 import (
 	"mime/multipart"
+	"slices"
 	"time"
 )
 
@@ -13,18 +14,50 @@ const (
 	DocumentTypePDFText    DocumentType = "pdf_text"    // Text-extractable PDF
 	DocumentTypePDFImage   DocumentType = "pdf_image"   // Image-based PDF requiring OCR
 	DocumentTypeImage      DocumentType = "image"       // Photo/image file requiring OCR
+	DocumentTypeCSV        DocumentType = "csv"         // Comma-separated spreadsheet export
+	DocumentTypeXLSX       DocumentType = "xlsx"        // Excel workbook
+	DocumentTypeHTML       DocumentType = "html"        // Menu web page
 )
 
+// DocumentTypes lists all supported document types
+var DocumentTypes = []DocumentType{
+	DocumentTypeJSON, DocumentTypePDFText, DocumentTypePDFImage, DocumentTypeImage,
+	DocumentTypeCSV, DocumentTypeXLSX, DocumentTypeHTML,
+}
+
+// IsValid reports whether the type is one of DocumentTypes
+func (t DocumentType) IsValid() bool {
+	return slices.Contains(DocumentTypes, t)
+}
+
 // DocumentSource represents a document containing school lunch menu information
 type DocumentSource struct {
 	ID           string       `json:"id"`
 	Type         DocumentType `json:"type"`
 	OriginalName string       `json:"original_name"`
+	ContentType  string       `json:"content_type,omitempty"` // Detected from the file's content
 	FilePath     string       `json:"file_path,omitempty"`
 	UploadedAt   time.Time    `json:"uploaded_at"`
 	ProcessedAt  *time.Time   `json:"processed_at,omitempty"`
-	Status       string       `json:"status"` // pending, processing, completed, error
+	IssuedAt     *time.Time   `json:"issued_at,omitempty"` // Publication date printed on the document
+	Status       string       `json:"status"` // pending, processing, completed, preview, error
 	ErrorMessage string       `json:"error_message,omitempty"`
+	MergeReport  *MergeReport `json:"merge_report,omitempty"`
+	ReviewIDs    []string     `json:"review_ids,omitempty"` // Extractions waiting for confirmation
+	SchoolID     string       `json:"school_id,omitempty"`  // School the document was received from
+	ContentHash  string       `json:"content_hash,omitempty"` // SHA-256 of the uploaded bytes
+	// Duplicate is set on the earlier document returned for a repeated upload
+	Duplicate bool `json:"duplicate,omitempty"`
+	// Validation lists the problems found in the parsed menus
+	Validation *MenuValidation `json:"validation,omitempty"`
+}
+
+// EffectiveDate returns the date used to decide which of two documents is newer
+func (d *DocumentSource) EffectiveDate() time.Time {
+	if d.IssuedAt != nil {
+		return *d.IssuedAt
+	}
+	return d.UploadedAt
 }
 
 // DocumentProcessingRequest represents a request to process a document
@@ -32,15 +65,83 @@ type DocumentProcessingRequest struct {
 	File     multipart.File        `json:"-"`
 	Header   *multipart.FileHeader `json:"-"`
 	Type     DocumentType          `json:"type"`
-	DateFrom *time.Time            `json:"date_from,omitempty"`
-	DateTo   *time.Time            `json:"date_to,omitempty"`
+	DateFrom *time.Time            `json:"date_from,omitempty"` // Days before this are dropped
+	DateTo   *time.Time            `json:"date_to,omitempty"`   // Days after this are dropped
+	// Year and Month resolve dates printed without them, such as "13日(月)"
+	Year  int        `json:"year,omitempty"`
+	Month time.Month `json:"month,omitempty"`
+	Actor string     `json:"actor,omitempty"`
+	// ActorLabel is an unverified name for the uploader, see ChangeContext
+	ActorLabel string `json:"actor_label,omitempty"`
+	// IssuedAt is the publication date of the document, used by MergePolicyNewerDocumentWins
+	IssuedAt    *time.Time  `json:"issued_at,omitempty"`
+	MergePolicy MergePolicy `json:"merge_policy,omitempty"`
+	// DryRun computes the merge report without changing any menu
+	DryRun bool `json:"dry_run,omitempty"`
+	// ColumnMapping overrides how CSV and Excel columns map onto menu fields
+	ColumnMapping ColumnMapping `json:"column_mapping,omitempty"`
+	// HTMLProfile names the extraction profile for web pages; every profile
+	// is tried in name order when empty
+	HTMLProfile string `json:"html_profile,omitempty"`
+	// SchoolID identifies the school the document came from, when known
+	SchoolID string `json:"school_id,omitempty"`
+}
+
+// BoundingBox locates a region of a page image in pixels
+type BoundingBox struct {
+	Page   int `json:"page,omitempty"`
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// TextLine is a single line of recognized text with its confidence and position
+type TextLine struct {
+	Text       string       `json:"text"`
+	Confidence float64      `json:"confidence"`
+	Box        *BoundingBox `json:"box,omitempty"`
 }
 
 // ExtractedMenuData represents the raw extracted data from a document before parsing
 type ExtractedMenuData struct {
 	SourceID     string            `json:"source_id"`
 	RawText      string            `json:"raw_text"`
+	Lines        []TextLine        `json:"lines,omitempty"` // Per-line OCR results, when available
+	Rows         [][]string        `json:"rows,omitempty"`  // Cells of CSV and Excel documents
 	ExtractedAt  time.Time         `json:"extracted_at"`
 	Confidence   float64           `json:"confidence,omitempty"` // For OCR results
 	Metadata     map[string]string `json:"metadata,omitempty"`
-}
\ No newline at end of file
+}
+
+// BatchFileResult is the outcome of processing one file of a batch upload
+type BatchFileResult struct {
+	Filename string `json:"filename"`
+	// Archive names the zip archive the file was extracted from
+	Archive  string          `json:"archive,omitempty"`
+	Success  bool            `json:"success"`
+	Document *DocumentSource `json:"document,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// BatchUploadResult aggregates the results of a batch upload. A file that
+// fails does not stop the others from being processed.
+type BatchUploadResult struct {
+	Success   bool              `json:"success"` // Every file was processed
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Files     []BatchFileResult `json:"files"`
+}
+
+// Add records the result of one file
+func (r *BatchUploadResult) Add(file BatchFileResult) {
+	r.Files = append(r.Files, file)
+	r.Total++
+	if file.Success {
+		r.Succeeded++
+	} else {
+		r.Failed++
+	}
+	r.Success = r.Failed == 0
+}
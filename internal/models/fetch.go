@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// FetchSource is a menu document published at a fixed URL, such as the
+// monthly PDF on a school's web site, that is downloaded periodically
+type FetchSource struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Type forces the document type; it is detected from the content when empty
+	Type        DocumentType `json:"type,omitempty"`
+	HTMLProfile string       `json:"html_profile,omitempty"`
+	MergePolicy MergePolicy  `json:"merge_policy,omitempty"`
+}
+
+// Validate checks that the source has a name, an absolute http(s) URL and a
+// known document type, and that an HTML profile is only given for web pages
+func (s *FetchSource) Validate() error {
+	var verrs ValidationErrors
+	if s.Name == "" {
+		verrs.Add("name", "required")
+	}
+	if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		verrs.Add("url", "must be an absolute http or https URL")
+	}
+	if s.Type != "" && !s.Type.IsValid() {
+		verrs.Add("type", fmt.Sprintf("unsupported document type %q", s.Type))
+	}
+	if s.HTMLProfile != "" && s.Type != "" && s.Type != DocumentTypeHTML {
+		verrs.Add("html_profile", fmt.Sprintf("only applies to %s documents, not %s", DocumentTypeHTML, s.Type))
+	}
+	if _, err := ParseMergePolicy(string(s.MergePolicy)); err != nil {
+		verrs.Merge("merge_policy", err)
+	}
+	return verrs.Err()
+}
+
+// FetchOutcome describes what happened when a source was last checked
+type FetchOutcome string
+
+const (
+	// FetchOutcomeNew means a new version of the document was downloaded and processed
+	FetchOutcomeNew FetchOutcome = "new"
+	// FetchOutcomeUnchanged means the server or the content hash showed no change
+	FetchOutcomeUnchanged FetchOutcome = "unchanged"
+	// FetchOutcomeError means the download or processing failed
+	FetchOutcomeError FetchOutcome = "error"
+)
+
+// FetchStatus is what is known about a source from its last checks
+type FetchStatus struct {
+	Source        FetchSource  `json:"source"`
+	Outcome       FetchOutcome `json:"outcome,omitempty"`
+	LastCheckedAt *time.Time   `json:"last_checked_at,omitempty"`
+	LastChangedAt *time.Time   `json:"last_changed_at,omitempty"`
+	ETag          string       `json:"etag,omitempty"`
+	LastModified  string       `json:"last_modified,omitempty"`
+	ContentHash   string       `json:"content_hash,omitempty"` // SHA-256 of the last processed version
+	DocumentID    string       `json:"document_id,omitempty"`  // Document created from the last new version
+	Error         string       `json:"error,omitempty"`
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Child is a child of the household who eats school lunch
+type Child struct {
+	ID    string    `json:"id"` // Such as "taro"; used as child_id in the API
+	Name  string    `json:"name"`
+	Grade GradeBand `json:"grade"`
+}
+
+// Weekday is a day of the week as written in a time budget
+type Weekday string
+
+// Weekdays lists the days of the week from Sunday, in time.Weekday order
+var Weekdays = []Weekday{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+var weekdayLabels = []string{"日", "月", "火", "水", "木", "金", "土"}
+
+// IsValid reports whether the day is one of Weekdays
+func (d Weekday) IsValid() bool {
+	return slices.Contains(Weekdays, d)
+}
+
+// WeekdayOf returns the day of the week of a date
+func WeekdayOf(date time.Time) Weekday {
+	return Weekdays[date.Weekday()]
+}
+
+// TimeBudget limits how long the household can spend cooking a meal on
+// some days of the week, such as 10 minutes for breakfast on school days
+type TimeBudget struct {
+	Days     []Weekday `json:"days,omitempty"` // Every day when empty
+	MealType MealType  `json:"meal_type"`
+	// MaxMinutes limits the time from starting to cook to serving
+	MaxMinutes int `json:"max_minutes,omitempty"`
+	// MaxActiveMinutes limits the hands-on time
+	MaxActiveMinutes int `json:"max_active_minutes,omitempty"`
+}
+
+// Applies reports whether the budget is for the meal on date
+func (b *TimeBudget) Applies(date time.Time, mealType MealType) bool {
+	return b.MealType == mealType && (len(b.Days) == 0 || slices.Contains(b.Days, WeekdayOf(date)))
+}
+
+// DaysLabel returns the days of the budget in Japanese, such as "平日" or
+// "月・水曜日"
+func (b *TimeBudget) DaysLabel() string {
+	days := slices.Clone(b.Days)
+	slices.SortFunc(days, func(x, y Weekday) int { return slices.Index(Weekdays, x) - slices.Index(Weekdays, y) })
+	days = slices.Compact(days)
+	switch {
+	case len(days) == 0 || len(days) == len(Weekdays):
+		return "毎日"
+	case slices.Equal(days, []Weekday{"mon", "tue", "wed", "thu", "fri"}):
+		return "平日"
+	case slices.Equal(days, []Weekday{"sun", "sat"}):
+		return "週末"
+	}
+	labels := make([]string, len(days))
+	for i, day := range days {
+		labels[i] = weekdayLabels[slices.Index(Weekdays, day)]
+	}
+	return strings.Join(labels, "・") + "曜日"
+}
+
+// Household describes the family the home meals are cooked for
+type Household struct {
+	// Size is the number of people eating home meals, children included
+	Size     int     `json:"size"`
+	Children []Child `json:"children"`
+	// TimeBudgets limit the cooking time of home meals; the first that
+	// applies to a meal is used
+	TimeBudgets []TimeBudget `json:"time_budgets,omitempty"`
+	// Equipment lists the appliances of the kitchen; every appliance when empty
+	Equipment []Equipment `json:"equipment,omitempty"`
+	// Skill is the hardest recipe level the household cooks; every level when empty
+	Skill SkillLevel `json:"skill,omitempty"`
+}
+
+// Validate checks that every child has a unique ID and a known grade band,
+// and that time budgets, equipment and skill use known values
+func (h *Household) Validate() error {
+	var verrs ValidationErrors
+	if h.Size < 0 {
+		verrs.Add("size", "must not be negative")
+	}
+	if h.Size > 0 && h.Size < len(h.Children) {
+		verrs.Add("size", "must include every child")
+	}
+	seen := make(map[string]bool)
+	for i, child := range h.Children {
+		field := fmt.Sprintf("children[%d]", i)
+		if child.ID == "" {
+			verrs.Add(field+".id", "required")
+		} else if seen[child.ID] {
+			verrs.Add(field+".id", fmt.Sprintf("duplicate child %q", child.ID))
+		}
+		seen[child.ID] = true
+		if _, err := ParseGradeBand(string(child.Grade)); err != nil || child.Grade == "" {
+			verrs.Add(field+".grade", fmt.Sprintf("unsupported grade %q", child.Grade))
+		}
+	}
+	for i, budget := range h.TimeBudgets {
+		field := fmt.Sprintf("time_budgets[%d]", i)
+		for j, day := range budget.Days {
+			if !day.IsValid() {
+				verrs.Add(fmt.Sprintf("%s.days[%d]", field, j), fmt.Sprintf("unsupported day %q (expected sun, mon, tue, wed, thu, fri or sat)", day))
+			}
+		}
+		if !budget.MealType.IsValid() {
+			verrs.Add(field+".meal_type", fmt.Sprintf("unsupported meal type %q (expected breakfast or dinner)", budget.MealType))
+		}
+		if budget.MaxMinutes < 0 {
+			verrs.Add(field+".max_minutes", "must not be negative")
+		}
+		if budget.MaxActiveMinutes < 0 {
+			verrs.Add(field+".max_active_minutes", "must not be negative")
+		}
+		if budget.MaxMinutes == 0 && budget.MaxActiveMinutes == 0 {
+			verrs.Add(field, "max_minutes or max_active_minutes is required")
+		}
+	}
+	for i, e := range h.Equipment {
+		if !e.IsValid() {
+			verrs.Add(fmt.Sprintf("equipment[%d]", i), fmt.Sprintf("unknown equipment %q", e))
+		}
+	}
+	if h.Skill != "" && !h.Skill.IsValid() {
+		verrs.Add("skill", fmt.Sprintf("unsupported skill %q (expected basic, intermediate or advanced)", h.Skill))
+	}
+	return verrs.Err()
+}
+
+// TimeBudget returns the first time budget that applies to the meal on date
+func (h *Household) TimeBudget(date time.Time, mealType MealType) (TimeBudget, bool) {
+	for _, budget := range h.TimeBudgets {
+		if budget.Applies(date, mealType) {
+			return budget, true
+		}
+	}
+	return TimeBudget{}, false
+}
+
+// HasEquipment reports whether the kitchen has an appliance
+func (h *Household) HasEquipment(e Equipment) bool {
+	return len(h.Equipment) == 0 || slices.Contains(h.Equipment, e)
+}
+
+// Child returns the child with the given ID
+func (h *Household) Child(id string) (Child, bool) {
+	for _, child := range h.Children {
+		if child.ID == id {
+			return child, true
+		}
+	}
+	return Child{}, false
+}
+
+// HomeMeal is a home meal the household decided to cook, usually a
+// suggestion they accepted. It counts towards the nutrition of the day.
+type HomeMeal struct {
+	ID         string    `json:"id"`
+	Date       time.Time `json:"date"`
+	MealType   MealType  `json:"meal_type"`
+	MainDish   string    `json:"main_dish"`
+	SideDishes []string  `json:"side_dishes"`
+	Soup       string    `json:"soup,omitempty"`
+	// Nutrition of one portion for GradeElementaryMid, when known better
+	// than the estimate from the dish names
+	Nutrition  *Nutrition `json:"nutrition,omitempty"`
+	AcceptedAt time.Time  `json:"accepted_at"`
+}
+
+// Dishes returns the names of the meal's dishes in serving order
+func (m *HomeMeal) Dishes() []string {
+	dishes := append([]string{m.MainDish}, m.SideDishes...)
+	if m.Soup != "" {
+		dishes = append(dishes, m.Soup)
+	}
+	return dishes
+}
+
+// Validate checks that the meal has a date, a known meal type and a main dish
+func (m *HomeMeal) Validate() error {
+	var verrs ValidationErrors
+	if m.Date.IsZero() {
+		verrs.Add("date", "required")
+	}
+	if !m.MealType.IsValid() {
+		verrs.Add("meal_type", fmt.Sprintf("unsupported meal type %q (expected breakfast or dinner)", m.MealType))
+	}
+	if m.MainDish == "" {
+		verrs.Add("main_dish", "required")
+	}
+	if m.Nutrition != nil {
+		for _, n := range Nutrients {
+			if n.Value(*m.Nutrition) < 0 {
+				verrs.Add("nutrition."+n.Key, "must not be negative")
+			}
+		}
+	}
+	return verrs.Err()
+}
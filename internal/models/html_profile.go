@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+// HTMLProfile describes where a municipality's menu web page puts each day's
+// menu, as CSS selectors. Day selects one element per school day; Date and
+// Fields are evaluated within that element. Profiles apply to UTF-8 pages
+// only; Shift_JIS and EUC-JP pages must be converted first.
+type HTMLProfile struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Title selects text carrying the year and month, such as "2025年1月の献立"
+	Title string `json:"title,omitempty"`
+	Day   string `json:"day"`
+	// Date selects the date within a day; the day's own text is used when empty
+	Date string `json:"date,omitempty"`
+	// Fields maps menu fields (see MenuColumnFields) onto selectors
+	Fields map[string]string `json:"fields"`
+}
+
+// Validate checks that the profile names a day selector and known fields
+func (p *HTMLProfile) Validate() error {
+	var verrs ValidationErrors
+	if p.Name == "" {
+		verrs.Add("name", "required")
+	}
+	if p.Day == "" {
+		verrs.Add("day", "required")
+	}
+	if len(p.Fields) == 0 {
+		verrs.Add("fields", "at least one field is required")
+	}
+	fields := make([]string, 0, len(p.Fields))
+	for field := range p.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		switch {
+		case field == "date" || !IsMenuColumnField(field):
+			verrs.Add("fields."+field, fmt.Sprintf("unknown field %q", field))
+		case p.Fields[field] == "":
+			verrs.Add("fields."+field, "selector is required")
+		}
+	}
+	return verrs.Err()
+}
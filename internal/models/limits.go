@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// UploadLimits bounds the size and content of uploaded documents, so that a
+// small file cannot expand into an unbounded amount of work or memory
+type UploadLimits struct {
+	// MaxBodyBytes bounds a whole upload request, all files included
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+	// MaxFileBytes bounds one document of each type; types that are not
+	// listed are bounded by MaxBodyBytes only
+	MaxFileBytes map[DocumentType]int64 `json:"max_file_bytes"`
+	// MaxImagePixels bounds the width times height of an image or TIFF page,
+	// and of the images embedded in a PDF
+	MaxImagePixels int64 `json:"max_image_pixels"`
+	// MaxPages bounds the pages of a PDF or multi-page TIFF
+	MaxPages int `json:"max_pages"`
+	// MaxArchiveEntries bounds the files in a zip archive
+	MaxArchiveEntries int `json:"max_archive_entries"`
+	// MaxArchiveBytes bounds the total uncompressed size of a zip archive
+	MaxArchiveBytes int64 `json:"max_archive_bytes"`
+	// MaxCompressionRatio bounds how much one archived file may inflate
+	MaxCompressionRatio int64 `json:"max_compression_ratio"`
+}
+
+// UploadQuota bounds how much one household may upload per window
+type UploadQuota struct {
+	MaxDocuments int           `json:"max_documents"`
+	MaxBytes     int64         `json:"max_bytes"`
+	Window       time.Duration `json:"window"`
+}
+
+// UploadClients tells the server which household or client an upload counts
+// against for quotas
+type UploadClients struct {
+	// Households maps API tokens, sent as "Authorization: Bearer <token>",
+	// onto household IDs. Uploads with a known token count against the
+	// household, others against the client address.
+	Households map[string]string `json:"households,omitempty"`
+	// TrustedProxies lists the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For header tells the client address
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+}
+
+// QuotaUsage is what a household has uploaded in its current window
+type QuotaUsage struct {
+	HouseholdID string    `json:"household_id"`
+	Documents   int       `json:"documents"`
+	Bytes       int64     `json:"bytes"`
+	ResetAt     time.Time `json:"reset_at"` // When the window ends and the usage is cleared
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// MailConfig configures the ingestion of menus sent by email
+type MailConfig struct {
+	// Senders maps sender addresses, or "@domain" for a whole domain, onto
+	// school IDs. Mail from other senders is ignored. School lunches are kept
+	// per day, not per school, so all senders must map to the same school.
+	Senders map[string]string `json:"senders"`
+	IMAP    *IMAPConfig       `json:"imap,omitempty"`
+	// Maildir is a local Maildir whose new messages are ingested
+	Maildir string `json:"maildir,omitempty"`
+	// Mbox is a local mbox file whose appended messages are ingested
+	Mbox string `json:"mbox,omitempty"`
+}
+
+// Validate checks that every sender maps to one and the same school
+func (c *MailConfig) Validate() error {
+	var verrs ValidationErrors
+	school := ""
+	for sender, id := range c.Senders {
+		field := fmt.Sprintf("senders[%q]", sender)
+		switch {
+		case id == "":
+			verrs.Add(field, "must name a school")
+		case school == "":
+			school = id
+		case id != school:
+			verrs.Add("senders", "must all map to the same school")
+			return verrs.Err()
+		}
+	}
+	return verrs.Err()
+}
+
+// IMAPConfig is a mailbox polled for new messages
+type IMAPConfig struct {
+	Addr     string `json:"addr"` // host:port
+	TLS      bool   `json:"tls"`
+	Username string `json:"username"`
+	// PasswordEnv names the environment variable holding the password, so
+	// that it is not stored in the configuration file
+	PasswordEnv string `json:"password_env,omitempty"`
+	Password    string `json:"-"`
+	Mailbox     string `json:"mailbox,omitempty"` // INBOX when empty
+}
+
+// MailAttachmentStatus describes what happened to one attachment of a message
+type MailAttachmentStatus string
+
+const (
+	MailAttachmentProcessed MailAttachmentStatus = "processed"
+	MailAttachmentSkipped   MailAttachmentStatus = "skipped"
+	MailAttachmentFailed    MailAttachmentStatus = "failed"
+)
+
+// MailAttachmentResult is the outcome of submitting one attachment
+type MailAttachmentResult struct {
+	Filename    string               `json:"filename"`
+	ContentType string               `json:"content_type,omitempty"`
+	Status      MailAttachmentStatus `json:"status"`
+	DocumentID  string               `json:"document_id,omitempty"`
+	Error       string               `json:"error,omitempty"`
+}
+
+// MailIngestResult is the outcome of ingesting one message
+type MailIngestResult struct {
+	MessageID   string                 `json:"message_id"`
+	From        string                 `json:"from"`
+	Subject     string                 `json:"subject,omitempty"`
+	Date        *time.Time             `json:"date,omitempty"`
+	SchoolID    string                 `json:"school_id,omitempty"`
+	Skipped     string                 `json:"skipped,omitempty"` // Why the whole message was ignored
+	Attachments []MailAttachmentResult `json:"attachments,omitempty"`
+	Retry       bool                   `json:"retry,omitempty"` // Attachments failed; ingested again on the next poll
+}
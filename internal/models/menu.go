@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // SchoolLunchMenu represents a school lunch menu for a specific day
 type SchoolLunchMenu struct {
@@ -10,20 +14,94 @@ type SchoolLunchMenu struct {
 	Soup        string    `json:"soup,omitempty"`
 	Dessert     string    `json:"dessert,omitempty"`
 	Nutrition   Nutrition `json:"nutrition"`
+	// DishIDs maps the dish names the dish dictionary recognized when the
+	// menu was imported onto their canonical dish IDs. It is derived from the
+	// names, so it is not compared when menus are merged or diffed.
+	DishIDs     map[string]string `json:"dish_ids,omitempty" diff:"-"`
+}
+
+// Validate checks that the menu has the fields required to be stored
+func (m SchoolLunchMenu) Validate() error {
+	var verrs ValidationErrors
+	if m.Date.IsZero() {
+		verrs.Add("date", "required")
+	}
+	if strings.TrimSpace(m.MainDish) == "" {
+		verrs.Add("main_dish", "must not be empty")
+	}
+	for _, nutrient := range Nutrients {
+		if nutrient.Value(m.Nutrition) < 0 {
+			verrs.Add("nutrition."+nutrient.Key, "must not be negative")
+		}
+	}
+	return verrs.Err()
 }
 
 // HomeMenuSuggestion represents a suggested home menu
 type HomeMenuSuggestion struct {
 	Date           time.Time `json:"date"`
-	MealType       string    `json:"meal_type"` // breakfast, dinner
+	MealType       MealType  `json:"meal_type"`
 	MainDish       string    `json:"main_dish"`
 	SideDishes     []string  `json:"side_dishes"`
 	Soup           string    `json:"soup,omitempty"`
 	Reason         string    `json:"reason"`
 	SchoolLunchRef string    `json:"school_lunch_ref"`
+	// LunchNutrition is the school lunch nutrition the suggestion was based on
+	LunchNutrition *LunchNutrition `json:"lunch_nutrition,omitempty"`
+	// Recipes summarizes how to cook the suggested dishes that have a recipe
+	Recipes []RecipeSummary `json:"recipes,omitempty"`
+	// MissingIngredients lists what the pantry lacks to cook the suggestion;
+	// it is left out when the household keeps no pantry
+	MissingIngredients []string `json:"missing_ingredients,omitempty"`
+	// ActiveMinutes and TotalMinutes are the hands-on and start-to-serve
+	// time of cooking the suggested dishes together
+	ActiveMinutes int `json:"active_minutes,omitempty"`
+	TotalMinutes  int `json:"total_minutes,omitempty"`
+	// TimeBudget is the household's limit for the meal, if it has one
+	TimeBudget *TimeBudget `json:"time_budget,omitempty"`
+}
+
+// MealType represents the home meal a suggestion is made for
+type MealType string
+
+const (
+	MealTypeBreakfast MealType = "breakfast"
+	MealTypeDinner    MealType = "dinner"
+)
+
+// MealTypes lists all supported meal types
+var MealTypes = []MealType{MealTypeBreakfast, MealTypeDinner}
+
+// IsValid reports whether the meal type is one of the supported values
+func (m MealType) IsValid() bool {
+	for _, t := range MealTypes {
+		if m == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Label returns the meal's name in Japanese
+func (m MealType) Label() string {
+	if m == MealTypeBreakfast {
+		return "朝食"
+	}
+	return "夕食"
+}
+
+// ParseMealType converts a raw string into a MealType, rejecting unknown values
+func ParseMealType(s string) (MealType, error) {
+	m := MealType(s)
+	if !m.IsValid() {
+		return "", ValidationErrors{{Field: "meal_type", Message: fmt.Sprintf("unsupported meal type %q (expected breakfast or dinner)", s)}}
+	}
+	return m, nil
 }
 
-// Nutrition represents nutritional information
+// Nutrition represents nutritional information. The fields after Vegetables
+// are left out of the JSON when zero, so documents written before they were
+// added read and write the same.
 type Nutrition struct {
 	Calories     int     `json:"calories"`
 	Protein      float64 `json:"protein_g"`
@@ -32,6 +110,15 @@ type Nutrition struct {
 	Fiber        float64 `json:"fiber_g"`
 	Sodium       float64 `json:"sodium_mg"`
 	Vegetables   int     `json:"vegetables_servings"`
+	Calcium      float64 `json:"calcium_mg,omitempty"`
+	Iron         float64 `json:"iron_mg,omitempty"`
+	VitaminA     float64 `json:"vitamin_a_ug,omitempty"` // Retinol activity equivalents
+	VitaminB1    float64 `json:"vitamin_b1_mg,omitempty"`
+	VitaminB2    float64 `json:"vitamin_b2_mg,omitempty"`
+	VitaminC     float64 `json:"vitamin_c_mg,omitempty"`
+	Magnesium    float64 `json:"magnesium_mg,omitempty"`
+	Zinc         float64 `json:"zinc_mg,omitempty"`
+	Salt         float64 `json:"salt_g,omitempty"` // Salt equivalent (食塩相当量)
 }
 
 // FoodCategory represents different food categories for balancing
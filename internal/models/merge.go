@@ -0,0 +1,82 @@
+package models
+
+import "fmt"
+
+// MergePolicy decides what happens when an uploaded document contains a day
+// that already has a school lunch menu
+type MergePolicy string
+
+const (
+	// MergePolicyReplace overwrites the existing day with the uploaded menu
+	MergePolicyReplace MergePolicy = "replace"
+	// MergePolicyKeepExisting keeps the existing day and reports differences as conflicts
+	MergePolicyKeepExisting MergePolicy = "keep_existing"
+	// MergePolicyMergeFields fills fields that are empty in the existing day and
+	// reports fields where both sides have different values as conflicts
+	MergePolicyMergeFields MergePolicy = "merge_fields"
+	// MergePolicyNewerDocumentWins replaces the day only when the uploaded document
+	// is newer than the document (or manual edit) the existing day came from
+	MergePolicyNewerDocumentWins MergePolicy = "newer_document_wins"
+)
+
+// MergePolicies lists all supported merge policies
+var MergePolicies = []MergePolicy{MergePolicyReplace, MergePolicyKeepExisting, MergePolicyMergeFields, MergePolicyNewerDocumentWins}
+
+// ParseMergePolicy converts a raw string into a MergePolicy. An empty string
+// selects MergePolicyReplace.
+func ParseMergePolicy(s string) (MergePolicy, error) {
+	if s == "" {
+		return MergePolicyReplace, nil
+	}
+	for _, p := range MergePolicies {
+		if MergePolicy(s) == p {
+			return p, nil
+		}
+	}
+	return "", ValidationErrors{{Field: "merge_policy", Message: fmt.Sprintf("unsupported merge policy %q", s)}}
+}
+
+// DayMergeStatus describes the outcome of merging one day of an upload
+type DayMergeStatus string
+
+const (
+	DayMergeAdded     DayMergeStatus = "added"
+	DayMergeUpdated   DayMergeStatus = "updated"
+	DayMergeUnchanged DayMergeStatus = "unchanged"
+	DayMergeConflict  DayMergeStatus = "conflict"
+	// DayMergePartial marks a day where some fields were applied and others
+	// were left as conflicts
+	DayMergePartial DayMergeStatus = "partial"
+	// DayMergeNeedsReview marks a day held back until a person confirms its extraction
+	DayMergeNeedsReview DayMergeStatus = "needs_review"
+	// DayMergeOutOfRange marks a day dropped because it is outside the requested date range
+	DayMergeOutOfRange DayMergeStatus = "out_of_range"
+	// DayMergeInvalid marks a day dropped because validation found an error in it
+	DayMergeInvalid DayMergeStatus = "invalid"
+)
+
+// DayMergeResult reports what happened (or, in a dry run, would happen) to one day
+type DayMergeResult struct {
+	Date      string         `json:"date"`
+	Status    DayMergeStatus `json:"status"`
+	Changes   []FieldChange  `json:"changes,omitempty"`   // Changes applied to the stored day
+	Conflicts []FieldChange  `json:"conflicts,omitempty"` // Differences that were not applied
+	Message   string         `json:"message,omitempty"`   // Why an invalid day was dropped
+}
+
+// MergeReport is the per-day result of merging an uploaded document
+type MergeReport struct {
+	Policy  MergePolicy            `json:"policy"`
+	DryRun  bool                   `json:"dry_run"`
+	Days    []DayMergeResult       `json:"days"`
+	Summary map[DayMergeStatus]int `json:"summary"`
+}
+
+// Add appends a day result and updates the summary counts
+func (r *MergeReport) Add(day DayMergeResult) {
+	if r.Summary == nil {
+		r.Summary = make(map[DayMergeStatus]int)
+	}
+	r.Days = append(r.Days, day)
+	r.Summary[day.Status]++
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+// Nutrient describes one field of Nutrition
+type Nutrient struct {
+	Key   string // JSON name, such as "protein_g"
+	Label string // Name printed on Japanese menus, such as "たんぱく質"
+	Unit  string
+	get   func(n *Nutrition) float64
+	set   func(n *Nutrition, v float64)
+}
+
+// Value returns the nutrient's value in n
+func (d Nutrient) Value(n Nutrition) float64 {
+	return d.get(&n)
+}
+
+// SetValue sets the nutrient's value in n, rounding whole-number fields
+func (d Nutrient) SetValue(n *Nutrition, v float64) {
+	d.set(n, v)
+}
+
+// Nutrients lists the fields of Nutrition in display order
+var Nutrients = []Nutrient{
+	{"calories", "エネルギー", "kcal", func(n *Nutrition) float64 { return float64(n.Calories) }, func(n *Nutrition, v float64) { n.Calories = int(math.Round(v)) }},
+	{"protein_g", "たんぱく質", "g", func(n *Nutrition) float64 { return n.Protein }, func(n *Nutrition, v float64) { n.Protein = v }},
+	{"carbs_g", "炭水化物", "g", func(n *Nutrition) float64 { return n.Carbs }, func(n *Nutrition, v float64) { n.Carbs = v }},
+	{"fat_g", "脂質", "g", func(n *Nutrition) float64 { return n.Fat }, func(n *Nutrition, v float64) { n.Fat = v }},
+	{"fiber_g", "食物繊維", "g", func(n *Nutrition) float64 { return n.Fiber }, func(n *Nutrition, v float64) { n.Fiber = v }},
+	{"sodium_mg", "ナトリウム", "mg", func(n *Nutrition) float64 { return n.Sodium }, func(n *Nutrition, v float64) { n.Sodium = v }},
+	{"vegetables_servings", "野菜", "皿", func(n *Nutrition) float64 { return float64(n.Vegetables) }, func(n *Nutrition, v float64) { n.Vegetables = int(math.Round(v)) }},
+	{"calcium_mg", "カルシウム", "mg", func(n *Nutrition) float64 { return n.Calcium }, func(n *Nutrition, v float64) { n.Calcium = v }},
+	{"iron_mg", "鉄", "mg", func(n *Nutrition) float64 { return n.Iron }, func(n *Nutrition, v float64) { n.Iron = v }},
+	{"vitamin_a_ug", "ビタミンA", "µgRAE", func(n *Nutrition) float64 { return n.VitaminA }, func(n *Nutrition, v float64) { n.VitaminA = v }},
+	{"vitamin_b1_mg", "ビタミンB1", "mg", func(n *Nutrition) float64 { return n.VitaminB1 }, func(n *Nutrition, v float64) { n.VitaminB1 = v }},
+	{"vitamin_b2_mg", "ビタミンB2", "mg", func(n *Nutrition) float64 { return n.VitaminB2 }, func(n *Nutrition, v float64) { n.VitaminB2 = v }},
+	{"vitamin_c_mg", "ビタミンC", "mg", func(n *Nutrition) float64 { return n.VitaminC }, func(n *Nutrition, v float64) { n.VitaminC = v }},
+	{"magnesium_mg", "マグネシウム", "mg", func(n *Nutrition) float64 { return n.Magnesium }, func(n *Nutrition, v float64) { n.Magnesium = v }},
+	{"zinc_mg", "亜鉛", "mg", func(n *Nutrition) float64 { return n.Zinc }, func(n *Nutrition, v float64) { n.Zinc = v }},
+	{"salt_g", "食塩相当量", "g", func(n *Nutrition) float64 { return n.Salt }, func(n *Nutrition, v float64) { n.Salt = v }},
+}
+
+// SaltToSodium converts a salt equivalent in grams into milligrams of sodium
+func SaltToSodium(saltG float64) float64 {
+	return saltG * 1000 / 2.54
+}
+
+// SodiumToSalt converts milligrams of sodium into a salt equivalent in grams
+func SodiumToSalt(sodiumMG float64) float64 {
+	return sodiumMG * 2.54 / 1000
+}
+
+// NutrientByKey returns the nutrient with the given JSON name
+func NutrientByKey(key string) (Nutrient, bool) {
+	for _, d := range Nutrients {
+		if d.Key == key {
+			return d, true
+		}
+	}
+	return Nutrient{}, false
+}
+
+// Plus returns the sum of n and o
+func (n Nutrition) Plus(o Nutrition) Nutrition {
+	for _, d := range Nutrients {
+		d.set(&n, d.get(&n)+d.get(&o))
+	}
+	return n
+}
+
+// Scale returns n with every value multiplied by factor
+func (n Nutrition) Scale(factor float64) Nutrition {
+	for _, d := range Nutrients {
+		d.set(&n, d.get(&n)*factor)
+	}
+	return n
+}
+
+// Round returns n with every value rounded to one decimal place, or two for
+// values below one such as vitamin B1
+func (n Nutrition) Round() Nutrition {
+	for _, d := range Nutrients {
+		v := d.get(&n)
+		if math.Abs(v) < 1 {
+			d.set(&n, math.Round(v*100)/100)
+		} else {
+			d.set(&n, math.Round(v*10)/10)
+		}
+	}
+	return n
+}
+
+// GradeBand groups school grades that are served the same portions
+type GradeBand string
+
+const (
+	GradeElementaryLow  GradeBand = "elementary_low"  // 小学1・2年
+	GradeElementaryMid  GradeBand = "elementary_mid"  // 小学3・4年
+	GradeElementaryHigh GradeBand = "elementary_high" // 小学5・6年
+	GradeJuniorHigh     GradeBand = "junior_high"     // 中学生
+)
+
+// GradeBands lists all grade bands from the youngest
+var GradeBands = []GradeBand{GradeElementaryLow, GradeElementaryMid, GradeElementaryHigh, GradeJuniorHigh}
+
+// ParseGradeBand converts a raw string into a GradeBand. An empty string
+// selects GradeElementaryMid, the grades published menus are usually written for.
+func ParseGradeBand(s string) (GradeBand, error) {
+	if s == "" {
+		return GradeElementaryMid, nil
+	}
+	for _, g := range GradeBands {
+		if GradeBand(s) == g {
+			return g, nil
+		}
+	}
+	return "", ValidationErrors{{Field: "grade", Message: fmt.Sprintf("unsupported grade %q", s)}}
+}
+
+// lunchTargets follow the school lunch intake standards (学校給食摂取基準):
+// energy, protein at 16.5% and fat at 27.5% of it, fiber, minerals and
+// vitamins, and sodium and salt at their upper limits. Two of the five daily
+// servings of vegetables are expected from lunch.
+var lunchTargets = map[GradeBand]Nutrition{
+	GradeElementaryLow: {Calories: 530, Protein: 21.9, Carbs: 72.9, Fat: 16.2, Fiber: 4, Sodium: 590, Vegetables: 2,
+		Calcium: 290, Iron: 2, VitaminA: 160, VitaminB1: 0.3, VitaminB2: 0.4, VitaminC: 20, Magnesium: 40, Zinc: 2, Salt: 1.5},
+	GradeElementaryMid: {Calories: 650, Protein: 26.8, Carbs: 89.4, Fat: 19.9, Fiber: 5, Sodium: 790, Vegetables: 2,
+		Calcium: 350, Iron: 3, VitaminA: 200, VitaminB1: 0.4, VitaminB2: 0.4, VitaminC: 25, Magnesium: 50, Zinc: 2, Salt: 2},
+	GradeElementaryHigh: {Calories: 780, Protein: 32.2, Carbs: 107.3, Fat: 23.8, Fiber: 5, Sodium: 790, Vegetables: 2,
+		Calcium: 360, Iron: 3.5, VitaminA: 240, VitaminB1: 0.5, VitaminB2: 0.5, VitaminC: 30, Magnesium: 70, Zinc: 2, Salt: 2},
+	GradeJuniorHigh: {Calories: 830, Protein: 34.2, Carbs: 114.1, Fat: 25.4, Fiber: 7, Sodium: 980, Vegetables: 2,
+		Calcium: 450, Iron: 4.5, VitaminA: 300, VitaminB1: 0.5, VitaminB2: 0.6, VitaminC: 35, Magnesium: 120, Zinc: 3, Salt: 2.5},
+}
+
+// LunchTarget returns what one school lunch should provide for the grade band
+func (g GradeBand) LunchTarget() Nutrition {
+	return lunchTargets[g]
+}
+
+// PortionFactor returns the size of the grade band's portions relative to
+// those of GradeElementaryMid
+func (g GradeBand) PortionFactor() float64 {
+	return float64(lunchTargets[g].Calories) / float64(lunchTargets[GradeElementaryMid].Calories)
+}
+
+// NutritionSource tells where a nutrition value came from
+type NutritionSource string
+
+const (
+	// NutritionPublished values were printed on the menu
+	NutritionPublished NutritionSource = "published"
+	// NutritionEstimated values were estimated from the dish names
+	NutritionEstimated NutritionSource = "estimated"
+	// NutritionMixed is used for a whole menu whose values come from both
+	NutritionMixed NutritionSource = "mixed"
+)
+
+// DishNutrition is the estimated nutrition of one dish of a school lunch
+type DishNutrition struct {
+	Name      string    `json:"name"` // As printed on the menu
+	DishID    string    `json:"dish_id"`
+	Nutrition Nutrition `json:"nutrition"`
+}
+
+// LunchNutrition is the nutrition of a school lunch for one grade band.
+// Values printed on the menu are used as they are; the others are
+// estimated from the dishes.
+type LunchNutrition struct {
+	Date      string                     `json:"date"`
+	Grade     GradeBand                  `json:"grade"`
+	Nutrition Nutrition                  `json:"nutrition"`
+	Source    NutritionSource            `json:"source,omitempty"`    // Empty when nothing is known
+	Sources   map[string]NutritionSource `json:"sources"`             // By nutrient key
+	Dishes    []DishNutrition            `json:"dishes"`              // Dishes the estimate is based on
+	Unmatched []string                   `json:"unmatched,omitempty"` // Dishes left out of the estimate
+}
+
+// Known reports whether the nutrient's value was published or estimated
+func (l *LunchNutrition) Known(key string) bool {
+	return l.Sources[key] != ""
+}
+
+// dailyTargets follow the dietary reference intakes for Japanese (日本人の食事
+// 摂取基準 2020), averaged over boys and girls of the ages in the grade band:
+// the estimated energy requirement at a moderate activity level, the
+// recommended allowances, fiber and salt goals, and five servings of
+// vegetables. Sodium follows from the salt goal.
+var dailyTargets = map[GradeBand]Nutrition{
+	GradeElementaryLow: {Calories: 1450, Protein: 30, Carbs: 210, Fat: 40, Fiber: 10, Sodium: 1770, Vegetables: 5,
+		Calcium: 600, Iron: 5.5, VitaminA: 450, VitaminB1: 0.8, VitaminB2: 0.9, VitaminC: 50, Magnesium: 130, Zinc: 5, Salt: 4.5},
+	GradeElementaryMid: {Calories: 1750, Protein: 40, Carbs: 250, Fat: 49, Fiber: 11, Sodium: 1970, Vegetables: 5,
+		Calcium: 700, Iron: 7.5, VitaminA: 500, VitaminB1: 1, VitaminB2: 1.1, VitaminC: 60, Magnesium: 160, Zinc: 6, Salt: 5},
+	GradeElementaryHigh: {Calories: 2150, Protein: 45, Carbs: 310, Fat: 60, Fiber: 13, Sodium: 2360, Vegetables: 5,
+		Calcium: 700, Iron: 9.5, VitaminA: 600, VitaminB1: 1.2, VitaminB2: 1.4, VitaminC: 70, Magnesium: 220, Zinc: 7, Salt: 6},
+	GradeJuniorHigh: {Calories: 2500, Protein: 60, Carbs: 360, Fat: 69, Fiber: 17, Sodium: 2760, Vegetables: 5,
+		Calcium: 900, Iron: 10.5, VitaminA: 750, VitaminB1: 1.4, VitaminB2: 1.6, VitaminC: 90, Magnesium: 290, Zinc: 9, Salt: 7},
+}
+
+// DailyTarget returns what a child of the grade band should eat in a day
+func (g GradeBand) DailyTarget() Nutrition {
+	return dailyTargets[g]
+}
+
+// IsLimit reports whether the nutrient's target is an upper limit rather
+// than an amount to reach, as for sodium and salt
+func (d Nutrient) IsLimit() bool {
+	return d.Key == "sodium_mg" || d.Key == "salt_g"
+}
+
+// NutrientStatus compares an intake with its target
+type NutrientStatus string
+
+const (
+	NutrientUnder NutrientStatus = "under"
+	NutrientOK    NutrientStatus = "ok"
+	NutrientOver  NutrientStatus = "over"
+	// NutrientUnknown is used when a meal gives no value for the nutrient, or
+	// there are no meals
+	NutrientUnknown NutrientStatus = "unknown"
+)
+
+// Shares of a target below which an intake is under it, and above which it
+// is over it. Upper limits are over as soon as they are exceeded.
+const (
+	underShare = 0.8
+	overShare  = 1.2
+)
+
+// NutrientTotal is the intake of one nutrient against its target
+type NutrientTotal struct {
+	Key     string          `json:"key"`
+	Label   string          `json:"label"`
+	Unit    string          `json:"unit"`
+	Value   float64         `json:"value"`
+	Target  float64         `json:"target"`
+	Percent int             `json:"percent"` // Of the target
+	Status  NutrientStatus  `json:"status"`
+	Source  NutritionSource `json:"source,omitempty"`
+}
+
+// CompareNutrition reports every nutrient of total against target, in the
+// order of Nutrients. Nutrients missing from sources are unknown rather than
+// under their target, as a missing value is not an intake of zero.
+func CompareNutrition(total, target Nutrition, sources map[string]NutritionSource) []NutrientTotal {
+	totals := make([]NutrientTotal, 0, len(Nutrients))
+	for _, d := range Nutrients {
+		t := NutrientTotal{Key: d.Key, Label: d.Label, Unit: d.Unit, Value: d.Value(total), Target: d.Value(target), Status: NutrientOK, Source: sources[d.Key]}
+		if t.Source == "" {
+			t.Status = NutrientUnknown
+		} else if t.Target > 0 {
+			share := t.Value / t.Target
+			t.Percent = int(math.Round(share * 100))
+			switch {
+			case d.IsLimit() && share > 1, !d.IsLimit() && share > overShare:
+				t.Status = NutrientOver
+			case !d.IsLimit() && share < underShare:
+				t.Status = NutrientUnder
+			}
+		}
+		totals = append(totals, t)
+	}
+	return totals
+}
+
+// MealNutrition is the nutrition of one meal of a day
+type MealNutrition struct {
+	Meal      string                     `json:"meal"` // "lunch", or the MealType of a home meal
+	MealID    string                     `json:"meal_id,omitempty"`
+	Dishes    []string                   `json:"dishes"`
+	Nutrition Nutrition                  `json:"nutrition"`
+	Source    NutritionSource            `json:"source,omitempty"`
+	Sources   map[string]NutritionSource `json:"sources"`             // By nutrient key
+	Unmatched []string                   `json:"unmatched,omitempty"` // Dishes left out of the estimate
+}
+
+// DailyNutrition is what a child ate in one day: the school lunch and the
+// home meals of the household. A nutrient is known for the day only when
+// every meal gives it.
+type DailyNutrition struct {
+	Date      string                     `json:"date"`
+	ChildID   string                     `json:"child_id,omitempty"`
+	Grade     GradeBand                  `json:"grade"`
+	Meals     []MealNutrition            `json:"meals"`
+	Total     Nutrition                  `json:"total"`
+	Target    Nutrition                  `json:"target"`
+	Sources   map[string]NutritionSource `json:"sources"` // By nutrient key, for the known nutrients
+	Nutrients []NutrientTotal            `json:"nutrients"`
+}
+
+// WeeklyNutrition adds up the days of a week from Monday to Sunday. Each
+// nutrient is compared over the days it is known for, against as many days
+// of its target.
+type WeeklyNutrition struct {
+	From      string                     `json:"from"`
+	To        string                     `json:"to"`
+	ChildID   string                     `json:"child_id,omitempty"`
+	Grade     GradeBand                  `json:"grade"`
+	Days      []DailyNutrition           `json:"days"`
+	Total     Nutrition                  `json:"total"`
+	Target    Nutrition                  `json:"target"`
+	Sources   map[string]NutritionSource `json:"sources"` // By nutrient key, for nutrients known on some day
+	Nutrients []NutrientTotal            `json:"nutrients"`
+}
+
+// CombineSources returns the source of a value added up from values of
+// sources a and b, either of which may be empty for no value yet
+func CombineSources(a, b NutritionSource) NutritionSource {
+	switch {
+	case a == "" || a == b:
+		return b
+	case b == "":
+		return a
+	}
+	return NutritionMixed
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Storage is where an ingredient is kept at home
+type Storage string
+
+const (
+	StorageFridge  Storage = "fridge"  // 冷蔵庫
+	StorageFreezer Storage = "freezer" // 冷凍庫
+	StorageShelf   Storage = "shelf"   // 常温
+)
+
+var storageLabels = map[Storage]string{
+	StorageFridge:  "冷蔵庫",
+	StorageFreezer: "冷凍庫",
+	StorageShelf:   "買い置き",
+}
+
+// IsValid reports whether the storage is one of the supported values
+func (s Storage) IsValid() bool {
+	_, ok := storageLabels[s]
+	return ok
+}
+
+// Label returns where the ingredient is, as said in a suggestion's reason
+func (s Storage) Label() string {
+	if label, ok := storageLabels[s]; ok {
+		return label
+	}
+	return "家"
+}
+
+// PantryItem is an ingredient the household already has
+type PantryItem struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit,omitempty"` // Such as "g", "本" or "パック"
+	Storage  Storage `json:"storage,omitempty"`
+	// ExpiresOn is the use-by or best-before date as YYYY-MM-DD
+	ExpiresOn string `json:"expires_on,omitempty"`
+}
+
+// Expires returns the expiry date, if the item has one
+func (i PantryItem) Expires() (time.Time, bool) {
+	date, err := time.Parse("2006-01-02", i.ExpiresOn)
+	return date, err == nil
+}
+
+// DaysLeft returns the number of days from date to the expiry date, negative
+// once it has passed, and whether the item has an expiry date
+func (i PantryItem) DaysLeft(date time.Time) (int, bool) {
+	expires, ok := i.Expires()
+	if !ok {
+		return 0, false
+	}
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return int(expires.Sub(day).Hours() / 24), true
+}
+
+// Validate checks that the item has a name, a quantity that is not negative,
+// a known storage and an expiry date in YYYY-MM-DD form
+func (i PantryItem) Validate() error {
+	var verrs ValidationErrors
+	i.validate("", &verrs)
+	return verrs.Err()
+}
+
+func (i PantryItem) validate(prefix string, verrs *ValidationErrors) {
+	if i.Name == "" {
+		verrs.Add(prefix+"name", "required")
+	}
+	if i.Quantity < 0 {
+		verrs.Add(prefix+"quantity", "must not be negative")
+	}
+	if i.Storage != "" && !i.Storage.IsValid() {
+		verrs.Add(prefix+"storage", fmt.Sprintf("unsupported storage %q (expected fridge, freezer or shelf)", i.Storage))
+	}
+	if _, ok := i.Expires(); i.ExpiresOn != "" && !ok {
+		verrs.Add(prefix+"expires_on", "invalid date format, use YYYY-MM-DD")
+	}
+}
+
+// ValidatePantry checks every item, and that an ingredient is listed once
+// per unit and expiry date
+func ValidatePantry(items []PantryItem) error {
+	var verrs ValidationErrors
+	seen := make(map[string]bool)
+	ids := make(map[string]bool)
+	for i, item := range items {
+		field := fmt.Sprintf("items[%d]", i)
+		item.validate(field+".", &verrs)
+		if key := item.Name + "\x00" + item.Unit + "\x00" + item.ExpiresOn; seen[key] {
+			verrs.Add(field, fmt.Sprintf("%s in %q is listed twice", item.Name, item.Unit))
+		} else {
+			seen[key] = true
+		}
+		if item.ID != "" && ids[item.ID] {
+			verrs.Add(field+".id", fmt.Sprintf("duplicate id %q", item.ID))
+		}
+		ids[item.ID] = true
+	}
+	return verrs.Err()
+}
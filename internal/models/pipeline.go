@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PipelineStageName identifies a step of document processing
+type PipelineStageName string
+
+const (
+	StageDetect     PipelineStageName = "detect"     // Identify the document from its content
+	StagePreprocess PipelineStageName = "preprocess" // Clean up the bytes before reading them
+	StageExtract    PipelineStageName = "extract"    // Read text, lines or cells
+	StageLayout     PipelineStageName = "layout"     // Put recognized lines in reading order
+	StageParse      PipelineStageName = "parse"      // Turn text and cells into menus
+	StageValidate   PipelineStageName = "validate"   // Drop or flag menus that cannot be stored
+	StageNormalize  PipelineStageName = "normalize"  // Clean up dish names
+	StageCommit     PipelineStageName = "commit"     // Hold for review and merge into the calendar
+)
+
+// PipelineStageNames lists the stages in the order they run
+var PipelineStageNames = []PipelineStageName{
+	StageDetect, StagePreprocess, StageExtract, StageLayout,
+	StageParse, StageValidate, StageNormalize, StageCommit,
+}
+
+// StageStatus is the outcome of one stage
+type StageStatus string
+
+const (
+	StageStatusOK      StageStatus = "ok"
+	StageStatusSkipped StageStatus = "skipped" // Nothing to do for this document
+	StageStatusFailed  StageStatus = "failed"
+)
+
+// StageTrace records what one stage did to a document
+type StageTrace struct {
+	Stage      PipelineStageName `json:"stage"`
+	Status     StageStatus       `json:"status"`
+	StartedAt  time.Time         `json:"started_at"`
+	DurationMS float64           `json:"duration_ms"`
+	Note       string            `json:"note,omitempty"` // Why the stage was skipped
+	Error      string            `json:"error,omitempty"`
+	// Output is what the stage produced, captured when it finished
+	Output json.RawMessage `json:"output,omitempty"`
+}
+
+// DocumentTrace records how a document went through the pipeline. Stages
+// after a failed one are not run and do not appear.
+type DocumentTrace struct {
+	DocumentID string       `json:"document_id"`
+	Status     string       `json:"status"`
+	StartedAt  time.Time    `json:"started_at"`
+	DurationMS float64      `json:"duration_ms"`
+	Stages     []StageTrace `json:"stages"`
+}
+
+// Stage returns the trace of the named stage, if it ran
+func (t *DocumentTrace) Stage(name PipelineStageName) (StageTrace, bool) {
+	for _, s := range t.Stages {
+		if s.Stage == name {
+			return s, true
+		}
+	}
+	return StageTrace{}, false
+}
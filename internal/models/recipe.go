@@ -0,0 +1,222 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Equipment is an appliance a recipe cooks with
+type Equipment string
+
+const (
+	EquipmentStove      Equipment = "stove"       // ガスコンロ・IH
+	EquipmentGrill      Equipment = "grill"       // 魚焼きグリル
+	EquipmentOven       Equipment = "oven"        // オーブン
+	EquipmentMicrowave  Equipment = "microwave"   // 電子レンジ
+	EquipmentRiceCooker Equipment = "rice_cooker" // 炊飯器
+	EquipmentToaster    Equipment = "toaster"     // トースター
+)
+
+// EquipmentList lists all equipment
+var EquipmentList = []Equipment{
+	EquipmentStove, EquipmentGrill, EquipmentOven, EquipmentMicrowave, EquipmentRiceCooker, EquipmentToaster,
+}
+
+// IsValid reports whether the equipment is one of the supported values
+func (e Equipment) IsValid() bool {
+	for _, known := range EquipmentList {
+		if e == known {
+			return true
+		}
+	}
+	return false
+}
+
+var equipmentLabels = map[Equipment]string{
+	EquipmentStove:      "コンロ",
+	EquipmentGrill:      "魚焼きグリル",
+	EquipmentOven:       "オーブン",
+	EquipmentMicrowave:  "電子レンジ",
+	EquipmentRiceCooker: "炊飯器",
+	EquipmentToaster:    "トースター",
+}
+
+// Label returns the Japanese name of the equipment
+func (e Equipment) Label() string {
+	return equipmentLabels[e]
+}
+
+// SkillLevel is how practiced a cook a recipe needs
+type SkillLevel string
+
+const (
+	SkillBasic        SkillLevel = "basic"        // Cutting, boiling and simple stir-frying
+	SkillIntermediate SkillLevel = "intermediate" // Rolled omelettes, simmering, cleaning whole fish
+	SkillAdvanced     SkillLevel = "advanced"     // Deep-frying in batter
+)
+
+// SkillLevels lists the skill levels from the easiest
+var SkillLevels = []SkillLevel{SkillBasic, SkillIntermediate, SkillAdvanced}
+
+// IsValid reports whether the skill level is one of the supported values
+func (l SkillLevel) IsValid() bool {
+	return slices.Contains(SkillLevels, l)
+}
+
+// Covers reports whether a cook of level l can cook a recipe of level r. An
+// empty l covers every level, and an empty r is SkillBasic.
+func (l SkillLevel) Covers(r SkillLevel) bool {
+	return l == "" || slices.Index(SkillLevels, r) <= slices.Index(SkillLevels, l)
+}
+
+// Units that are counted rather than weighed; scaled quantities are rounded
+// up to the nearest quarter below one and the nearest half above
+var countedUnits = map[string]bool{
+	"個": true, "本": true, "枚": true, "切れ": true, "丁": true, "束": true,
+	"袋": true, "パック": true, "片": true, "株": true, "合": true, "尾": true,
+}
+
+// IsCountedUnit reports whether a unit counts pieces or packs, such as "本"
+// or "パック", rather than measuring weight or volume
+func IsCountedUnit(unit string) bool {
+	return countedUnits[unit]
+}
+
+// Ingredient is one ingredient of a recipe. A zero Quantity means "to
+// taste" and is not scaled.
+type Ingredient struct {
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity,omitempty"`
+	Unit     string  `json:"unit,omitempty"` // Such as "g", "個", "大さじ" or "少々"
+}
+
+// Scale returns the ingredient for factor times as many servings
+func (i Ingredient) Scale(factor float64) Ingredient {
+	q := i.Quantity * factor
+	switch {
+	case countedUnits[i.Unit] && q < 1:
+		q = math.Ceil(q*4) / 4
+	case countedUnits[i.Unit]:
+		q = math.Ceil(q*2) / 2
+	case i.Unit == "大さじ" || i.Unit == "小さじ":
+		q = math.Round(q*2) / 2
+	case q >= 10:
+		q = math.Round(q)
+	default:
+		q = math.Round(q*10) / 10
+	}
+	i.Quantity = q
+	return i
+}
+
+// Recipe tells how to cook a dish of the dish dictionary. Quantities are for
+// one serving.
+type Recipe struct {
+	DishID      string       `json:"dish_id"`
+	Name        string       `json:"name"` // Name of the dish
+	Servings    int          `json:"servings"`
+	Ingredients []Ingredient `json:"ingredients"`
+	Steps       []string     `json:"steps"`
+	PrepMinutes int          `json:"prep_minutes"` // Washing, cutting and mixing
+	CookMinutes int          `json:"cook_minutes"` // On the heat or in an appliance
+	// ActiveMinutes is the hands-on part of the prep and cook time; the rest
+	// simmers or bakes without attention
+	ActiveMinutes int         `json:"active_minutes"`
+	Equipment     []Equipment `json:"equipment,omitempty"`
+	Skill         SkillLevel  `json:"skill,omitempty"` // SkillBasic when empty
+}
+
+// TotalMinutes returns the time from starting to serving
+func (r *Recipe) TotalMinutes() int {
+	return r.PrepMinutes + r.CookMinutes
+}
+
+// Scale returns the recipe for the given number of servings
+func (r Recipe) Scale(servings int) Recipe {
+	if servings < 1 {
+		servings = 1
+	}
+	factor := float64(servings) / float64(max(r.Servings, 1))
+	ingredients := make([]Ingredient, len(r.Ingredients))
+	for i, ingredient := range r.Ingredients {
+		ingredients[i] = ingredient.Scale(factor)
+	}
+	r.Ingredients = ingredients
+	r.Servings = servings
+	return r
+}
+
+// Summary returns what a suggestion shows of the recipe
+func (r *Recipe) Summary() RecipeSummary {
+	names := make([]string, len(r.Ingredients))
+	for i, ingredient := range r.Ingredients {
+		names[i] = ingredient.Name
+	}
+	return RecipeSummary{
+		DishID:        r.DishID,
+		Name:          r.Name,
+		Servings:      r.Servings,
+		Ingredients:   names,
+		ActiveMinutes: r.ActiveMinutes,
+		TotalMinutes:  r.TotalMinutes(),
+		Equipment:     r.Equipment,
+	}
+}
+
+// Validate checks that the recipe names a dish, has ingredients and steps,
+// spends no more time hands-on than in all, and uses known equipment
+func (r *Recipe) Validate() error {
+	var verrs ValidationErrors
+	if r.DishID == "" {
+		verrs.Add("dish_id", "required")
+	}
+	if r.Servings < 0 {
+		verrs.Add("servings", "must not be negative")
+	}
+	if len(r.Ingredients) == 0 {
+		verrs.Add("ingredients", "required")
+	}
+	for i, ingredient := range r.Ingredients {
+		if ingredient.Name == "" {
+			verrs.Add(fmt.Sprintf("ingredients[%d].name", i), "required")
+		}
+		if ingredient.Quantity < 0 {
+			verrs.Add(fmt.Sprintf("ingredients[%d].quantity", i), "must not be negative")
+		}
+	}
+	if len(r.Steps) == 0 {
+		verrs.Add("steps", "required")
+	}
+	if r.PrepMinutes < 0 {
+		verrs.Add("prep_minutes", "must not be negative")
+	}
+	if r.CookMinutes < 0 {
+		verrs.Add("cook_minutes", "must not be negative")
+	}
+	if r.ActiveMinutes < 0 {
+		verrs.Add("active_minutes", "must not be negative")
+	} else if r.ActiveMinutes > r.TotalMinutes() {
+		verrs.Add("active_minutes", "must not be more than prep_minutes and cook_minutes together")
+	}
+	for i, e := range r.Equipment {
+		if !e.IsValid() {
+			verrs.Add(fmt.Sprintf("equipment[%d]", i), fmt.Sprintf("unknown equipment %q", e))
+		}
+	}
+	if r.Skill != "" && !r.Skill.IsValid() {
+		verrs.Add("skill", fmt.Sprintf("unsupported skill %q (expected basic, intermediate or advanced)", r.Skill))
+	}
+	return verrs.Err()
+}
+
+// RecipeSummary is a short form of a recipe attached to a suggestion
+type RecipeSummary struct {
+	DishID        string      `json:"dish_id"`
+	Name          string      `json:"name"` // As written in the suggestion
+	Servings      int         `json:"servings"`
+	Ingredients   []string    `json:"ingredients"`
+	ActiveMinutes int         `json:"active_minutes"`
+	TotalMinutes  int         `json:"total_minutes"`
+	Equipment     []Equipment `json:"equipment,omitempty"`
+}
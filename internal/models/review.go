@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// ReviewStatus is the state of an extraction waiting for a person to check it
+type ReviewStatus string
+
+const (
+	ReviewStatusPending  ReviewStatus = "pending"
+	ReviewStatusApproved ReviewStatus = "approved"
+	ReviewStatusRejected ReviewStatus = "rejected"
+)
+
+// FieldExtraction describes where and how confidently a menu field was read
+type FieldExtraction struct {
+	Field      string       `json:"field"`
+	Text       string       `json:"text"`
+	Confidence float64      `json:"confidence"`
+	Box        *BoundingBox `json:"box,omitempty"`
+	Corrected  bool         `json:"corrected,omitempty"`
+}
+
+// PendingReview holds a parsed menu whose extraction confidence was too low
+// to store it without a person confirming it first
+type PendingReview struct {
+	ID         string                     `json:"id"`
+	DocumentID string                     `json:"document_id"`
+	Menu       SchoolLunchMenu            `json:"menu"`
+	Fields     map[string]FieldExtraction `json:"fields"`
+	Confidence float64                    `json:"confidence"`
+	Status     ReviewStatus               `json:"status"`
+	CreatedAt  time.Time                  `json:"created_at"`
+	ReviewedAt *time.Time                 `json:"reviewed_at,omitempty"`
+	ReviewedBy string                     `json:"reviewed_by,omitempty"`
+	// MergePolicy and IssuedAt are those of the upload, applied when the
+	// menu is merged on approval
+	MergePolicy MergePolicy `json:"merge_policy,omitempty"`
+	IssuedAt    *time.Time  `json:"issued_at,omitempty"`
+	// Merge is the outcome of merging the approved menu, such as a conflict
+	// with the stored day under keep_existing
+	Merge *DayMergeResult `json:"merge,omitempty"`
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StoreSection is the part of a supermarket an ingredient is found in
+type StoreSection string
+
+const (
+	SectionProduce   StoreSection = "produce"   // 野菜・果物
+	SectionMeat      StoreSection = "meat"      // 肉
+	SectionFish      StoreSection = "fish"      // 魚
+	SectionChilled   StoreSection = "chilled"   // 卵・豆腐・乳製品
+	SectionDryGoods  StoreSection = "dry_goods" // 乾物・缶詰
+	SectionGrains    StoreSection = "grains"    // 米・パン・麺
+	SectionSeasoning StoreSection = "seasoning" // 調味料
+	SectionOther     StoreSection = "other"
+)
+
+// StoreSections lists the sections in the order a shopping list walks them
+var StoreSections = []StoreSection{
+	SectionProduce, SectionMeat, SectionFish, SectionChilled,
+	SectionDryGoods, SectionGrains, SectionSeasoning, SectionOther,
+}
+
+var sectionLabels = map[StoreSection]string{
+	SectionProduce:   "野菜・果物",
+	SectionMeat:      "肉",
+	SectionFish:      "魚",
+	SectionChilled:   "卵・豆腐・乳製品",
+	SectionDryGoods:  "乾物・缶詰",
+	SectionGrains:    "米・パン・麺",
+	SectionSeasoning: "調味料",
+	SectionOther:     "その他",
+}
+
+// Label returns the section's name as shown in a Japanese store
+func (s StoreSection) Label() string {
+	return sectionLabels[s]
+}
+
+// ShoppingItem is one ingredient to buy
+type ShoppingItem struct {
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"` // Zero for seasonings used "to taste"
+	Unit     string  `json:"unit,omitempty"`
+	// Needed is the quantity the recipes call for, before pantry stock is taken off
+	Needed   float64  `json:"needed"`
+	InPantry float64  `json:"in_pantry,omitempty"`
+	Dishes   []string `json:"dishes"` // Dishes the ingredient is used in
+}
+
+// Amount formats the quantity as written on a Japanese shopping list, such
+// as "2本", "300g" or "大さじ1.5"
+func (i ShoppingItem) Amount() string {
+	q := strconv.FormatFloat(i.Quantity, 'f', -1, 64)
+	switch {
+	case i.Quantity == 0:
+		return i.Unit
+	case i.Unit == "大さじ" || i.Unit == "小さじ" || i.Unit == "カップ":
+		return i.Unit + q
+	default:
+		return q + i.Unit
+	}
+}
+
+// String returns the item as one line of a shopping list
+func (i ShoppingItem) String() string {
+	if amount := i.Amount(); amount != "" {
+		return fmt.Sprintf("%s %s", i.Name, amount)
+	}
+	return i.Name
+}
+
+// ShoppingSection groups the items of one store section
+type ShoppingSection struct {
+	Section StoreSection   `json:"section"`
+	Label   string         `json:"label"`
+	Items   []ShoppingItem `json:"items"`
+}
+
+// ShoppingList is what to buy for the home meals planned between two dates
+type ShoppingList struct {
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Servings int               `json:"servings"`
+	Meals    int               `json:"meals"` // Planned meals the list covers
+	Sections []ShoppingSection `json:"sections"`
+	// FromPantry lists ingredients the pantry has enough of
+	FromPantry []string `json:"from_pantry,omitempty"`
+	// NoRecipe lists planned dishes without a recipe, whose ingredients are missing
+	NoRecipe []string `json:"no_recipe,omitempty"`
+}
+
+// title returns the heading of the list
+func (l *ShoppingList) title() string {
+	return fmt.Sprintf("買い物リスト %s〜%s (%d人分)", l.From, l.To, l.Servings)
+}
+
+// Text formats the list as plain text to paste into a message
+func (l *ShoppingList) Text() string {
+	var b strings.Builder
+	b.WriteString(l.title() + "\n")
+	for _, section := range l.Sections {
+		fmt.Fprintf(&b, "\n【%s】\n", section.Label)
+		for _, item := range section.Items {
+			fmt.Fprintf(&b, "・%s\n", item)
+		}
+	}
+	if len(l.FromPantry) > 0 {
+		fmt.Fprintf(&b, "\n在庫で足りる: %s\n", strings.Join(l.FromPantry, "、"))
+	}
+	if len(l.NoRecipe) > 0 {
+		fmt.Fprintf(&b, "\nレシピなし: %s\n", strings.Join(l.NoRecipe, "、"))
+	}
+	return b.String()
+}
+
+// Markdown formats the list as a Markdown checklist
+func (l *ShoppingList) Markdown() string {
+	var b strings.Builder
+	b.WriteString("# " + l.title() + "\n")
+	for _, section := range l.Sections {
+		fmt.Fprintf(&b, "\n## %s\n\n", section.Label)
+		for _, item := range section.Items {
+			fmt.Fprintf(&b, "- [ ] %s\n", item)
+		}
+	}
+	if len(l.FromPantry) > 0 {
+		fmt.Fprintf(&b, "\n在庫で足りる: %s\n", strings.Join(l.FromPantry, "、"))
+	}
+	if len(l.NoRecipe) > 0 {
+		fmt.Fprintf(&b, "\nレシピなし: %s\n", strings.Join(l.NoRecipe, "、"))
+	}
+	return b.String()
+}
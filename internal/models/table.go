@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ColumnMapping maps spreadsheet column headers onto menu fields, for example
+// {"日付": "date", "おかず": "main_dish"}. A header matches when it starts with
+// the key, so "エネルギー(kcal)" matches "エネルギー".
+type ColumnMapping map[string]string
+
+// MenuColumnFields lists the menu fields a column can be mapped onto
+var MenuColumnFields = []string{
+	"date",
+	"main_dish",
+	"side_dishes",
+	"soup",
+	"dessert",
+	"nutrition.calories",
+	"nutrition.protein_g",
+	"nutrition.carbs_g",
+	"nutrition.fat_g",
+	"nutrition.fiber_g",
+	"nutrition.sodium_mg",
+	"nutrition.calcium_mg",
+	"nutrition.iron_mg",
+	"nutrition.vitamin_a_ug",
+	"nutrition.vitamin_b1_mg",
+	"nutrition.vitamin_b2_mg",
+	"nutrition.vitamin_c_mg",
+	"nutrition.magnesium_mg",
+	"nutrition.zinc_mg",
+	"nutrition.salt_g", // Also gives sodium when the menu prints none
+	"salt_g",           // Same as nutrition.salt_g, for existing mappings
+}
+
+// Validate checks that every column is mapped onto a known field
+func (m ColumnMapping) Validate() error {
+	var verrs ValidationErrors
+	headers := make([]string, 0, len(m))
+	for header := range m {
+		headers = append(headers, header)
+	}
+	sort.Strings(headers)
+	for _, header := range headers {
+		if header == "" {
+			verrs.Add("column_mapping", "column header must not be empty")
+			continue
+		}
+		if !IsMenuColumnField(m[header]) {
+			verrs.Add("column_mapping."+header, fmt.Sprintf("unknown field %q", m[header]))
+		}
+	}
+	return verrs.Err()
+}
+
+// IsMenuColumnField reports whether field is one of MenuColumnFields
+func IsMenuColumnField(field string) bool {
+	for _, f := range MenuColumnFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// TableColumn is a column of an imported spreadsheet and the field it was mapped onto
+type TableColumn struct {
+	Index  int    `json:"index"`
+	Header string `json:"header"`
+	Field  string `json:"field,omitempty"` // Empty when the column is ignored
+}
+
+// SkippedRow is a spreadsheet row that did not produce a menu
+type SkippedRow struct {
+	Row    int    `json:"row"` // 1-based, as shown by spreadsheet applications
+	Reason string `json:"reason"`
+}
+
+// TablePreview shows how a CSV or Excel document would be imported
+type TablePreview struct {
+	Type      DocumentType      `json:"type"`
+	HeaderRow int               `json:"header_row"`
+	Columns   []TableColumn     `json:"columns"`
+	Menus     []SchoolLunchMenu `json:"menus"`
+	Skipped   []SkippedRow      `json:"skipped,omitempty"`
+}
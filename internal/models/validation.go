@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// FieldError describes a single invalid field in a request or record
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects field errors found while validating input
+type ValidationErrors []FieldError
+
+// Error implements the error interface
+func (v ValidationErrors) Error() string {
+	parts := make([]string, 0, len(v))
+	for _, fe := range v {
+		parts = append(parts, fe.Field+": "+fe.Message)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Add appends a field error
+func (v *ValidationErrors) Add(field, message string) {
+	*v = append(*v, FieldError{Field: field, Message: message})
+}
+
+// Merge appends the field errors of err, or err itself under field when it
+// is not a ValidationErrors
+func (v *ValidationErrors) Merge(field string, err error) {
+	var verrs ValidationErrors
+	if errors.As(err, &verrs) {
+		*v = append(*v, verrs...)
+	} else if err != nil {
+		v.Add(field, err.Error())
+	}
+}
+
+// Err returns nil when no field errors were collected
+func (v ValidationErrors) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+const (
+	// maxArchiveEntries is the default bound on the files one zip archive may hold
+	maxArchiveEntries = 100
+	// maxArchiveEntrySize bounds the uncompressed size of one archived file
+	maxArchiveEntrySize = 32 << 20
+)
+
+// ProcessBatch processes several uploaded files, expanding zip archives into
+// their entries. Every file is processed even when others fail, and the
+// outcome of each is reported in upload order.
+func (dp *DocumentProcessor) ProcessBatch(reqs []*models.DocumentProcessingRequest) *models.BatchUploadResult {
+	result := &models.BatchUploadResult{Files: []models.BatchFileResult{}}
+	for _, req := range reqs {
+		archive, err := IsZipArchive(req.File)
+		if err != nil {
+			result.Add(models.BatchFileResult{Filename: sanitizeFilename(req.Header.Filename), Error: fmt.Sprintf("Failed to read document: %v", err)})
+			continue
+		}
+		if !archive {
+			result.Add(dp.processBatchFile(req, ""))
+			continue
+		}
+
+		archiveName := sanitizeFilename(req.Header.Filename)
+		entries, err := dp.archiveEntries(req)
+		if err != nil {
+			result.Add(models.BatchFileResult{Filename: archiveName, Error: err.Error()})
+			continue
+		}
+		for _, entry := range entries {
+			if entry.err != nil {
+				result.Add(models.BatchFileResult{Filename: sanitizeFilename(entry.req.Header.Filename), Archive: archiveName, Error: entry.err.Error()})
+				continue
+			}
+			result.Add(dp.processBatchFile(entry.req, archiveName))
+		}
+	}
+	return result
+}
+
+func (dp *DocumentProcessor) processBatchFile(req *models.DocumentProcessingRequest, archive string) models.BatchFileResult {
+	file := models.BatchFileResult{Filename: sanitizeFilename(req.Header.Filename), Archive: archive}
+	doc, err := dp.ProcessDocument(req)
+	file.Document = doc
+	file.Success = err == nil
+	if err != nil {
+		file.Error = err.Error()
+	}
+	return file
+}
+
+// IsZipArchive reports whether an upload is a zip archive of documents.
+// Excel workbooks are zip files too but are documents in their own right.
+func IsZipArchive(file multipart.File) (bool, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	defer file.Seek(0, io.SeekStart)
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(file, head); err != nil {
+		// Too short to be an archive
+		return false, nil
+	}
+	if !bytes.Equal(head, []byte("PK\x03\x04")) {
+		return false, nil
+	}
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+	zr, err := zip.NewReader(file, size)
+	if err != nil {
+		// A corrupt archive is reported when it is processed as a document
+		return false, nil
+	}
+	for _, f := range zr.File {
+		if f.Name == "xl/workbook.xml" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// DocumentCount returns how many documents an uploaded file holds: one per
+// document in a zip archive, and one for any other file
+func DocumentCount(file multipart.File) (int, error) {
+	archive, err := IsZipArchive(file)
+	if err != nil || !archive {
+		return 1, err
+	}
+	defer file.Seek(0, io.SeekStart)
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	zr, err := zip.NewReader(file, size)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for i, f := range zr.File {
+		if archiveEntryName(f, i) != "" {
+			count++
+		}
+	}
+	return max(count, 1), nil
+}
+
+// archiveEntry is a file extracted from an archive, or why it could not be
+type archiveEntry struct {
+	req *models.DocumentProcessingRequest
+	err error
+}
+
+// archiveEntries extracts the files of a zip archive into processing
+// requests that share the archive's upload options. Directories and the
+// metadata files added by macOS are left out. Archives with too many files
+// or that inflate beyond the upload limits are rejected as a whole.
+func (dp *DocumentProcessor) archiveEntries(req *models.DocumentProcessingRequest) ([]archiveEntry, error) {
+	limits := dp.UploadLimits()
+	size, err := req.File.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	zr, err := zip.NewReader(req.File, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	var entries []archiveEntry
+	var total int64
+	for i, f := range zr.File {
+		name := archiveEntryName(f, i)
+		if name == "" {
+			continue
+		}
+		if limits.MaxArchiveEntries > 0 && len(entries) == limits.MaxArchiveEntries {
+			return nil, fmt.Errorf("%w: archive holds more than %d files", ErrDocumentTooLarge, limits.MaxArchiveEntries)
+		}
+
+		data, err := readArchiveEntry(f, limits.MaxCompressionRatio)
+		total += int64(len(data))
+		if limits.MaxArchiveBytes > 0 && total > limits.MaxArchiveBytes {
+			return nil, fmt.Errorf("%w: archive expands to more than %d bytes", ErrDocumentTooLarge, limits.MaxArchiveBytes)
+		}
+		entryReq := *req
+		entryReq.File = &memoryFile{bytes.NewReader(data)}
+		entryReq.Header = &multipart.FileHeader{Filename: name, Size: int64(len(data))}
+		entries = append(entries, archiveEntry{req: &entryReq, err: err})
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("archive holds no documents")
+	}
+	return entries, nil
+}
+
+// archiveEntryName returns the base name of an archived file, or "" for
+// entries that are not documents. Names that are not UTF-8, such as the
+// Shift_JIS names written by older Windows tools, are replaced.
+func archiveEntryName(f *zip.File, index int) string {
+	if f.FileInfo().IsDir() {
+		return ""
+	}
+	name := strings.ReplaceAll(f.Name, "\\", "/")
+	if strings.HasPrefix(name, "__MACOSX/") {
+		return ""
+	}
+	base := path.Base(name)
+	if strings.HasPrefix(base, ".") {
+		return ""
+	}
+	if !utf8.ValidString(base) {
+		return fmt.Sprintf("entry-%d%s", index+1, path.Ext(base))
+	}
+	return base
+}
+
+// readArchiveEntry inflates one archived file. The sizes recorded in the
+// archive are not trusted: reading stops at maxArchiveEntrySize or at
+// maxRatio times the compressed size, whichever comes first.
+func readArchiveEntry(f *zip.File, maxRatio int64) ([]byte, error) {
+	limit := int64(maxArchiveEntrySize)
+	if maxRatio > 0 {
+		limit = min(limit, max(int64(f.CompressedSize64), 1)*maxRatio)
+	}
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, fmt.Errorf("%w: archived file would expand to %d bytes", ErrDocumentTooLarge, f.UncompressedSize64)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract archived file: %w", err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract archived file: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: archived file expands to more than %d bytes", ErrDocumentTooLarge, limit)
+	}
+	return data, nil
+}
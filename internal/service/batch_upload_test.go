@@ -0,0 +1,108 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// zipArchive builds a zip archive of the named files
+func zipArchive(t *testing.T, files map[string]string, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func batchRequest(filename string, data []byte) *models.DocumentProcessingRequest {
+	return &models.DocumentProcessingRequest{
+		File:        newMockFile(string(data)),
+		Header:      &multipart.FileHeader{Filename: filename, Size: int64(len(data))},
+		MergePolicy: models.MergePolicyReplace,
+		Actor:       "parent",
+	}
+}
+
+func TestProcessBatch(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+
+	files := map[string]string{
+		"2025-04/menu.json":            `[{"date":"2025-04-08T00:00:00Z","main_dish":"カレーライス"}]`,
+		"2025-05/menu.json":            `[{"date":"2025-05-08T00:00:00Z","main_dish":"ハヤシライス"}]`,
+		"2025-06/broken.json":          `[{"date":`,
+		"2025-06/":                     "",
+		"__MACOSX/2025-04/._menu.json": "resource fork",
+		".DS_Store":                    "finder",
+	}
+	archive := zipArchive(t, files, "2025-04/menu.json", "2025-05/menu.json", "2025-06/", "2025-06/broken.json", "__MACOSX/2025-04/._menu.json", ".DS_Store")
+	workbook, err := os.ReadFile(filepath.Join("testdata", "table", "menu.xlsx"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	result := processor.ProcessBatch([]*models.DocumentProcessingRequest{
+		batchRequest("semester.zip", archive),
+		batchRequest("notes.txt", []byte("not a menu")),
+		batchRequest("menu.xlsx", workbook),
+	})
+
+	if result.Success || result.Total != 5 || result.Succeeded != 3 || result.Failed != 2 {
+		t.Fatalf("Unexpected totals: %+v", result)
+	}
+	want := []struct {
+		filename, archive string
+		success           bool
+	}{
+		{"menu.json", "semester.zip", true},
+		{"menu.json", "semester.zip", true},
+		{"broken.json", "semester.zip", false},
+		{"notes.txt", "", false},
+		{"menu.xlsx", "", true}, // Workbooks are zip files but not archives
+	}
+	for i, w := range want {
+		f := result.Files[i]
+		if f.Filename != w.filename || f.Archive != w.archive || f.Success != w.success {
+			t.Errorf("File %d: expected %+v, got %+v", i, w, f)
+		}
+		if !f.Success && f.Error == "" {
+			t.Errorf("File %d: expected an error message", i)
+		}
+	}
+	if doc := result.Files[0].Document; doc == nil || doc.OriginalName != "menu.json" || doc.Status != "completed" {
+		t.Errorf("Expected the archived document to be recorded, got %+v", doc)
+	}
+
+	// The failed entry did not stop the ones after it
+	for _, date := range []time.Time{time.Date(2025, 4, 8, 0, 0, 0, 0, time.UTC), time.Date(2025, 5, 8, 0, 0, 0, 0, time.UTC)} {
+		if _, err := menuService.GetSchoolLunchForDate(date); err != nil {
+			t.Errorf("Expected menu for %s: %v", date.Format("2006-01-02"), err)
+		}
+	}
+}
+
+func TestProcessBatchRejectsEmptyArchive(t *testing.T) {
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+	archive := zipArchive(t, map[string]string{"__MACOSX/._x": ""}, "__MACOSX/._x")
+
+	result := processor.ProcessBatch([]*models.DocumentProcessingRequest{batchRequest("empty.zip", archive)})
+	if result.Success || result.Failed != 1 || result.Files[0].Filename != "empty.zip" {
+		t.Errorf("Expected the empty archive to fail, got %+v", result)
+	}
+}
@@ -0,0 +1,575 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// dishMatchThreshold is the lowest similarity fuzzy matching accepts
+const dishMatchThreshold = 0.75
+
+// readingMatchScore is the score of a match that differs only in okurigana
+const readingMatchScore = 0.95
+
+// DishDictionary maps the many ways schools and OCR write a dish onto
+// canonical dishes. Names are compared after folding character widths, kana
+// and common OCR confusions, so only genuinely different spellings need to be
+// listed as aliases.
+type DishDictionary struct {
+	mu     sync.RWMutex
+	dishes map[string]models.Dish // By ID
+	keys   []dishKey              // Sorted by key
+	exact  map[string]string      // Dish ID by folded name
+	// Dish ID by folded name without okurigana; empty when two dishes share it
+	reading map[string]string
+}
+
+// dishKey is one folded name of a dish
+type dishKey struct {
+	key      string
+	reading  []rune
+	proteins []int // Indexes into proteinWords of the proteins the name mentions
+	id       string
+}
+
+// NewDishDictionary creates an empty dictionary
+func NewDishDictionary() *DishDictionary {
+	return &DishDictionary{
+		dishes:  make(map[string]models.Dish),
+		exact:   make(map[string]string),
+		reading: make(map[string]string),
+	}
+}
+
+// DefaultDishDictionary creates a dictionary of dishes common in school lunches
+func DefaultDishDictionary() *DishDictionary {
+	d := NewDishDictionary()
+	for _, dish := range defaultDishes {
+		if n, ok := defaultDishNutrition[dish.ID]; ok {
+			dish.Nutrition = &n
+		}
+		if err := d.Add(dish); err != nil {
+			panic(fmt.Sprintf("invalid built-in dish %q: %v", dish.ID, err))
+		}
+	}
+	return d
+}
+
+// Add registers a dish, replacing any dish with the same ID. A name that
+// already belongs to another dish is refused.
+func (d *DishDictionary) Add(dish models.Dish) error {
+	if err := dish.Validate(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dishes := make(map[string]models.Dish, len(d.dishes)+1)
+	for id, existing := range d.dishes {
+		dishes[id] = existing
+	}
+	dishes[dish.ID] = dish
+	keys, exact, reading, err := indexDishes(dishes)
+	if err != nil {
+		return err
+	}
+	d.dishes, d.keys, d.exact, d.reading = dishes, keys, exact, reading
+	return nil
+}
+
+// indexDishes folds the names of every dish
+func indexDishes(dishes map[string]models.Dish) ([]dishKey, map[string]string, map[string]string, error) {
+	ids := make([]string, 0, len(dishes))
+	for id := range dishes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var keys []dishKey
+	exact := make(map[string]string)
+	reading := make(map[string]string)
+	for _, id := range ids {
+		dish := dishes[id]
+		for _, name := range append([]string{dish.Name}, dish.Aliases...) {
+			base, _ := splitDishVariant(cleanDishName(name))
+			key := foldDishKey(base)
+			if key == "" {
+				return nil, nil, nil, fmt.Errorf("dish %q: name %q has no letters", id, name)
+			}
+			if owner, ok := exact[key]; ok {
+				if owner != id {
+					return nil, nil, nil, fmt.Errorf("dish %q: name %q is already used by dish %q", id, name, owner)
+				}
+				continue
+			}
+			exact[key] = id
+			r := readingKey(base)
+			if owner, ok := reading[r]; ok && owner != id {
+				reading[r] = ""
+			} else {
+				reading[r] = id
+			}
+			keys = append(keys, dishKey{key: key, reading: []rune(r), proteins: mentionedProteins(key), id: id})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key < keys[j].key })
+	return keys, exact, reading, nil
+}
+
+// LoadDishes adds the dishes listed in a JSON file
+func (d *DishDictionary) LoadDishes(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read dishes: %w", err)
+	}
+	var dishes []models.Dish
+	if err := json.Unmarshal(data, &dishes); err != nil {
+		return fmt.Errorf("failed to parse dishes: %w", err)
+	}
+	for _, dish := range dishes {
+		if err := d.Add(dish); err != nil {
+			return fmt.Errorf("dish %q: %w", dish.ID, err)
+		}
+	}
+	return nil
+}
+
+// Get returns a dish by ID
+func (d *DishDictionary) Get(id string) (models.Dish, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	dish, ok := d.dishes[id]
+	return dish, ok
+}
+
+// Dishes returns every dish in ID order
+func (d *DishDictionary) Dishes() []models.Dish {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	dishes := make([]models.Dish, 0, len(d.dishes))
+	for _, dish := range d.dishes {
+		dishes = append(dishes, dish)
+	}
+	sort.Slice(dishes, func(i, j int) bool { return dishes[i].ID < dishes[j].ID })
+	return dishes
+}
+
+// Match finds the dish a name refers to. A parenthesized detail such as the
+// "わかめ" of "味噌汁（わかめ）" is left out of matching and returned as the
+// variant. Names that differ only in okurigana match next, and then the
+// closest name by edit distance, if it is similar enough and mentions the
+// same proteins.
+func (d *DishDictionary) Match(name string) models.DishMatch {
+	base, variant := splitDishVariant(cleanDishName(name))
+	match := models.DishMatch{Input: name, Variant: variant}
+	key := foldDishKey(base)
+	if key == "" {
+		return match
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	found := func(id string, method models.DishMatchMethod, score float64) models.DishMatch {
+		match.DishID, match.Name, match.Method = id, d.dishes[id].Name, method
+		match.Score = math.Round(score*100) / 100
+		return match
+	}
+	if id, ok := d.exact[key]; ok {
+		return found(id, models.DishMatchExact, 1)
+	}
+	r := readingKey(base)
+	if id := d.reading[r]; id != "" {
+		return found(id, models.DishMatchReading, readingMatchScore)
+	}
+
+	runes := []rune(r)
+	proteins := mentionedProteins(key)
+	best, bestID := 0.0, ""
+	for _, k := range d.keys {
+		if !slices.Equal(proteins, k.proteins) {
+			continue
+		}
+		if s := similarity(runes, k.reading); s > best {
+			best, bestID = s, k.id
+		}
+	}
+	if best >= dishMatchThreshold {
+		return found(bestID, models.DishMatchFuzzy, best)
+	}
+	return match
+}
+
+// similarity is one minus the edit distance between a and b relative to the
+// longer of the two
+func similarity(a, b []rune) float64 {
+	longer := max(len(a), len(b))
+	if longer == 0 {
+		return 0
+	}
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(b)])/float64(longer)
+}
+
+// halfWidthKana are the full-width forms of U+FF61 to U+FF9D
+var halfWidthKana = []rune("。「」、・ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン")
+
+// ocrLookalikes are characters OCR reads in place of a similar katakana when
+// they stand next to katakana
+var ocrLookalikes = map[rune]rune{
+	'力': 'カ', '口': 'ロ', '工': 'エ', '二': 'ニ', '八': 'ハ', '卜': 'ト', '夕': 'タ', 'へ': 'ヘ',
+}
+
+// ocrLongVowels are read in place of the long vowel mark "ー" after katakana
+var ocrLongVowels = map[rune]bool{
+	'一': true, '－': true, '-': true, '‐': true, '—': true, '―': true, '─': true,
+}
+
+// cleanDishName tidies a dish name as printed: full-width letters and digits
+// become half-width, half-width katakana become full-width, characters OCR
+// mistakes for katakana are repaired and runs of spaces are collapsed
+func cleanDishName(name string) string {
+	src := []rune(name)
+	out := make([]rune, 0, len(src))
+	for i := 0; i < len(src); i++ {
+		r := src[i]
+		switch {
+		case r >= 'Ａ' && r <= 'Ｚ', r >= 'ａ' && r <= 'ｚ', r >= '０' && r <= '９':
+			r -= 'Ａ' - 'A'
+		case r == '　':
+			r = ' '
+		case r >= 0xFF61 && r <= 0xFF9D:
+			r = halfWidthKana[r-0xFF61]
+			if i+1 < len(src) {
+				if voiced, ok := voiceKana(r, src[i+1]); ok {
+					r = voiced
+					i++
+				}
+			}
+		case r == 0xFF9E:
+			r = '゛'
+		case r == 0xFF9F:
+			r = '゜'
+		}
+		out = append(out, r)
+	}
+
+	for i, r := range out {
+		prevKatakana := i > 0 && isKatakana(out[i-1])
+		nextKatakana := i+1 < len(out) && isKatakana(out[i+1])
+		if ocrLongVowels[r] && prevKatakana {
+			out[i] = 'ー'
+		} else if k, ok := ocrLookalikes[r]; ok && (prevKatakana || nextKatakana) {
+			out[i] = k
+		}
+	}
+	return normalizeSpaces(string(out))
+}
+
+// voiceKana combines a katakana with a following half-width voiced or
+// semi-voiced sound mark
+func voiceKana(r, mark rune) (rune, bool) {
+	switch {
+	case mark == 0xFF9E && r == 'ウ':
+		return 'ヴ', true
+	case mark == 0xFF9E && strings.ContainsRune("カキクケコサシスセソタチツテトハヒフヘホ", r):
+		return r + 1, true
+	case mark == 0xFF9F && strings.ContainsRune("ハヒフヘホ", r):
+		return r + 2, true
+	}
+	return r, false
+}
+
+// isKatakana reports whether r is a katakana letter or the long vowel mark
+func isKatakana(r rune) bool {
+	return (r >= 'ァ' && r <= 'ヺ') || r == 'ー'
+}
+
+// dishVariantBrackets pairs the brackets a variant may be written in
+var dishVariantBrackets = map[rune]rune{'（': '）', '(': ')', '【': '】', '［': '］', '[': ']', '〈': '〉', '＜': '＞'}
+
+// splitDishVariant separates a bracketed detail at the start or end of a
+// name, such as "味噌汁（わかめ）" or "【新】カレーライス", from the dish itself
+func splitDishVariant(name string) (base, variant string) {
+	runes := []rune(name)
+	if len(runes) < 3 {
+		return name, ""
+	}
+	if end, ok := dishVariantBrackets[runes[0]]; ok {
+		if i := indexRune(runes[1:], end); i >= 0 && i+2 < len(runes) {
+			return strings.TrimSpace(string(runes[i+2:])), strings.TrimSpace(string(runes[1 : i+1]))
+		}
+	}
+	last := runes[len(runes)-1]
+	for open, end := range dishVariantBrackets {
+		if last != end {
+			continue
+		}
+		for i := len(runes) - 2; i > 0; i-- {
+			if runes[i] == open {
+				return strings.TrimSpace(string(runes[:i])), strings.TrimSpace(string(runes[i+1 : len(runes)-1]))
+			}
+		}
+	}
+	return name, ""
+}
+
+func indexRune(runes []rune, r rune) int {
+	for i, c := range runes {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// foldDishKey reduces a cleaned name to the form names are compared in:
+// without spaces or middle dots, with katakana as hiragana and letters in
+// lower case
+func foldDishKey(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == ' ' || r == '・':
+			continue
+		case r >= 'ァ' && r <= 'ヶ':
+			r -= 'ァ' - 'ぁ'
+		default:
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// proteinWords are the words that name the protein of a dish, one group per
+// protein. Names that differ in them are different dishes however alike they
+// look, such as 豚肉の照り焼き and 鶏肉の照り焼き.
+var proteinWords = foldWordGroups([][]string{
+	{"鶏", "とり", "チキン"},
+	{"豚", "ぶた", "ポーク"},
+	{"牛肉", "ぎゅう", "ビーフ"},
+	{"魚"},
+	{"鮭", "さけ", "しゃけ", "サーモン"},
+	{"鯖", "さば", "サバ"},
+	{"鰤", "ぶり", "ブリ"},
+	{"鯵", "あじ", "アジ"},
+	{"鰆", "さわら"},
+	{"鱈", "たら"},
+	{"鰯", "いわし"},
+	{"秋刀魚", "さんま"},
+})
+
+// foldWordGroups folds every word as names are folded for comparison
+func foldWordGroups(groups [][]string) [][]string {
+	for _, group := range groups {
+		for i, word := range group {
+			group[i] = foldDishKey(word)
+		}
+	}
+	return groups
+}
+
+// mentionedProteins returns the groups of proteinWords a folded name mentions
+func mentionedProteins(key string) []int {
+	var found []int
+	for i, group := range proteinWords {
+		for _, word := range group {
+			if strings.Contains(key, word) {
+				found = append(found, i)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// readingKey drops okurigana from a cleaned name and folds it: runs of at
+// most two hiragana between kanji or after the last kanji, so that "照り焼き",
+// "照焼き" and "照焼" compare equal. Katakana is never okurigana, so the "パン"
+// of "牛乳パン" stays.
+func readingKey(name string) string {
+	runes := []rune(strings.NewReplacer(" ", "", "・", "").Replace(name))
+	out := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); {
+		if !isHiragana(runes[i]) {
+			out = append(out, runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isHiragana(runes[j]) {
+			j++
+		}
+		afterKanji := i > 0 && isKanji(runes[i-1])
+		beforeKanji := j == len(runes) || isKanji(runes[j])
+		if !(afterKanji && beforeKanji && j-i <= 2) {
+			out = append(out, runes[i:j]...)
+		}
+		i = j
+	}
+	return foldDishKey(string(out))
+}
+
+func isHiragana(r rune) bool {
+	return r >= 'ぁ' && r <= 'ゖ'
+}
+
+func isKanji(r rune) bool {
+	return unicode.Is(unicode.Han, r) || r == '々'
+}
+
+// defaultDishes are the dishes school lunches serve most often
+var defaultDishes = []models.Dish{
+	// Main dishes
+	{ID: "chicken_teriyaki", Name: "鶏肉の照り焼き", Aliases: []string{"鶏の照り焼き", "とりの照り焼き", "とり肉の照り焼き", "チキン照り焼き", "照り焼きチキン"}, Category: models.DishCategoryMain, Protein: models.ProteinChicken},
+	{ID: "karaage", Name: "鶏の唐揚げ", Aliases: []string{"鶏のからあげ", "鶏肉の唐揚げ", "唐揚げ", "からあげ", "若鶏の唐揚げ"}, Category: models.DishCategoryMain, Protein: models.ProteinChicken, Tags: []string{"fried"}},
+	{ID: "oyakodon", Name: "親子丼", Aliases: []string{"親子どんぶり"}, Category: models.DishCategoryMain, Protein: models.ProteinChicken},
+	{ID: "ginger_pork", Name: "豚肉の生姜焼き", Aliases: []string{"豚の生姜焼き", "豚肉のしょうが焼き", "しょうが焼き", "生姜焼き", "ポークジンジャー"}, Category: models.DishCategoryMain, Protein: models.ProteinPork},
+	{ID: "sweet_sour_pork", Name: "酢豚", Category: models.DishCategoryMain, Protein: models.ProteinPork},
+	{ID: "yakisoba", Name: "焼きそば", Aliases: []string{"ソース焼きそば"}, Category: models.DishCategoryMain, Protein: models.ProteinPork, Tags: []string{"noodle"}},
+	{ID: "gyudon", Name: "牛丼", Category: models.DishCategoryMain, Protein: models.ProteinBeef},
+	{ID: "hayashi_rice", Name: "ハヤシライス", Category: models.DishCategoryMain, Protein: models.ProteinBeef},
+	{ID: "hamburg_steak", Name: "ハンバーグ", Aliases: []string{"ハンバーグステーキ"}, Category: models.DishCategoryMain, Protein: models.ProteinMeat},
+	{ID: "nikujaga", Name: "肉じゃが", Category: models.DishCategoryMain, Protein: models.ProteinMeat},
+	{ID: "curry_rice", Name: "カレーライス", Aliases: []string{"カレー", "ライスカレー", "ポークカレー", "チキンカレー", "ビーフカレー"}, Category: models.DishCategoryMain, Tags: []string{"curry"}},
+	{ID: "fried_fish", Name: "魚のフライ", Aliases: []string{"白身魚のフライ", "白身魚フライ", "フィッシュフライ"}, Category: models.DishCategoryMain, Protein: models.ProteinFish, Tags: []string{"fried"}},
+	{ID: "grilled_fish", Name: "焼き魚", Category: models.DishCategoryMain, Protein: models.ProteinFish},
+	{ID: "salted_salmon", Name: "鮭の塩焼き", Aliases: []string{"焼き鮭", "さけの塩焼き", "塩鮭"}, Category: models.DishCategoryMain, Protein: models.ProteinFish},
+	{ID: "salted_mackerel", Name: "鯖の塩焼き", Aliases: []string{"さばの塩焼き"}, Category: models.DishCategoryMain, Protein: models.ProteinFish},
+	{ID: "mackerel_miso", Name: "さばの味噌煮", Aliases: []string{"鯖の味噌煮", "さばのみそ煮", "鯖のみそ煮"}, Category: models.DishCategoryMain, Protein: models.ProteinFish},
+	{ID: "simmered_fish", Name: "魚の煮付け", Aliases: []string{"煮魚"}, Category: models.DishCategoryMain, Protein: models.ProteinFish},
+	{ID: "mapo_tofu", Name: "麻婆豆腐", Aliases: []string{"マーボー豆腐", "マーボードウフ"}, Category: models.DishCategoryMain, Protein: models.ProteinSoy},
+	{ID: "tamagoyaki", Name: "卵焼き", Aliases: []string{"玉子焼き", "たまご焼き", "だし巻き卵"}, Category: models.DishCategoryMain, Protein: models.ProteinEgg},
+	{ID: "natto", Name: "納豆", Category: models.DishCategorySide, Protein: models.ProteinSoy},
+
+	// Side dishes
+	{ID: "stir_fried_vegetables", Name: "野菜炒め", Category: models.DishCategorySide},
+	{ID: "hijiki", Name: "ひじきの煮物", Aliases: []string{"ひじき煮", "ひじきの炒め煮"}, Category: models.DishCategorySide},
+	{ID: "kiriboshi", Name: "切り干し大根", Aliases: []string{"切り干し大根の煮物", "切干大根"}, Category: models.DishCategorySide},
+	{ID: "komatsuna_goma", Name: "小松菜のごま和え", Aliases: []string{"小松菜の胡麻和え"}, Category: models.DishCategorySide},
+	{ID: "spinach_ohitashi", Name: "ほうれん草のおひたし", Aliases: []string{"おひたし", "ほうれん草のお浸し"}, Category: models.DishCategorySide},
+	{ID: "kinpira", Name: "きんぴらごぼう", Aliases: []string{"きんぴら"}, Category: models.DishCategorySide},
+	{ID: "chikuzenni", Name: "筑前煮", Aliases: []string{"がめ煮"}, Category: models.DishCategorySide, Protein: models.ProteinChicken},
+	{ID: "green_salad", Name: "野菜サラダ", Aliases: []string{"サラダ", "グリーンサラダ"}, Category: models.DishCategorySide},
+	{ID: "cabbage_salad", Name: "キャベツサラダ", Category: models.DishCategorySide},
+	{ID: "potato_salad", Name: "ポテトサラダ", Category: models.DishCategorySide},
+	{ID: "namul", Name: "ナムル", Aliases: []string{"もやしのナムル"}, Category: models.DishCategorySide},
+	{ID: "fukujinzuke", Name: "福神漬け", Aliases: []string{"福神漬"}, Category: models.DishCategorySide},
+
+	// Soups
+	{ID: "miso_soup", Name: "味噌汁", Aliases: []string{"みそ汁", "みそしる", "おみそ汁"}, Category: models.DishCategorySoup},
+	{ID: "tonjiru", Name: "豚汁", Aliases: []string{"とん汁", "ぶた汁"}, Category: models.DishCategorySoup, Protein: models.ProteinPork},
+	{ID: "kenchin", Name: "けんちん汁", Category: models.DishCategorySoup},
+	{ID: "clear_soup", Name: "すまし汁", Aliases: []string{"おすまし"}, Category: models.DishCategorySoup},
+	{ID: "vegetable_soup", Name: "野菜スープ", Category: models.DishCategorySoup},
+	{ID: "consomme", Name: "コンソメスープ", Category: models.DishCategorySoup},
+	{ID: "wakame_soup", Name: "わかめスープ", Category: models.DishCategorySoup},
+	{ID: "chinese_soup", Name: "中華スープ", Category: models.DishCategorySoup},
+	{ID: "corn_soup", Name: "コーンスープ", Aliases: []string{"コーンポタージュ"}, Category: models.DishCategorySoup},
+
+	// Staples
+	{ID: "rice", Name: "白米", Aliases: []string{"ごはん", "ご飯", "白飯", "ライス"}, Category: models.DishCategoryStaple},
+	{ID: "bread", Name: "パン", Aliases: []string{"コッペパン", "食パン"}, Category: models.DishCategoryStaple},
+
+	// Home dishes the recommender suggests
+	{ID: "pork_shabu", Name: "豚しゃぶしゃぶ", Aliases: []string{"豚しゃぶ", "冷しゃぶ"}, Category: models.DishCategoryMain, Protein: models.ProteinPork},
+	{ID: "beef_stir_fry", Name: "牛肉炒め", Aliases: []string{"牛肉の炒め物"}, Category: models.DishCategoryMain, Protein: models.ProteinBeef},
+	{ID: "hiyayakko", Name: "冷奴", Aliases: []string{"冷やっこ", "ひややっこ"}, Category: models.DishCategorySide, Protein: models.ProteinSoy},
+	{ID: "vegetable_tempura", Name: "野菜の天ぷら", Aliases: []string{"野菜天ぷら", "精進揚げ"}, Category: models.DishCategorySide, Tags: []string{"fried"}},
+	{ID: "steamed_vegetables", Name: "温野菜", Aliases: []string{"温野菜サラダ"}, Category: models.DishCategorySide},
+	{ID: "bean_sprout_stir_fry", Name: "もやし炒め", Category: models.DishCategorySide},
+	{ID: "spinach_goma", Name: "ほうれん草のごま和え", Aliases: []string{"ほうれん草の胡麻和え"}, Category: models.DishCategorySide},
+	{ID: "komatsuna_shirasu", Name: "小松菜としらすのおひたし", Aliases: []string{"小松菜のおひたし"}, Category: models.DishCategorySide, Protein: models.ProteinFish},
+	{ID: "nori", Name: "のり", Aliases: []string{"焼きのり", "味付けのり", "海苔"}, Category: models.DishCategorySide},
+
+	// Desserts and drinks
+	{ID: "fruit_salad", Name: "フルーツサラダ", Aliases: []string{"フルーツポンチ"}, Category: models.DishCategoryDessert},
+	{ID: "jelly", Name: "ゼリー", Category: models.DishCategoryDessert},
+	{ID: "milk", Name: "牛乳", Aliases: []string{"ミルク"}, Category: models.DishCategoryDrink},
+}
+
+// defaultDishNutrition is the nutrition of one school portion of the built-in
+// dishes for the middle grades of elementary school, from typical school
+// lunch recipes. Rice bowls and curries include their rice. Vitamins,
+// magnesium and zinc are left out; the salt equivalent follows from sodium.
+var defaultDishNutrition = map[string]models.Nutrition{
+	"chicken_teriyaki":      {Calories: 170, Protein: 14, Carbs: 5, Fat: 10, Fiber: 0.2, Sodium: 390, Calcium: 8, Iron: 0.5},
+	"karaage":               {Calories: 200, Protein: 13, Carbs: 7, Fat: 13, Fiber: 0.2, Sodium: 360, Calcium: 8, Iron: 0.5},
+	"oyakodon":              {Calories: 480, Protein: 19, Carbs: 75, Fat: 10, Fiber: 1.5, Sodium: 700, Vegetables: 1, Calcium: 40, Iron: 1.3},
+	"ginger_pork":           {Calories: 190, Protein: 12, Carbs: 5, Fat: 13, Fiber: 0.5, Sodium: 430, Calcium: 10, Iron: 0.5},
+	"sweet_sour_pork":       {Calories: 210, Protein: 10, Carbs: 15, Fat: 12, Fiber: 1.5, Sodium: 420, Vegetables: 1, Calcium: 15, Iron: 0.6},
+	"yakisoba":              {Calories: 380, Protein: 13, Carbs: 52, Fat: 12, Fiber: 3, Sodium: 900, Vegetables: 1, Calcium: 40, Iron: 1},
+	"gyudon":                {Calories: 520, Protein: 16, Carbs: 76, Fat: 15, Fiber: 1.5, Sodium: 750, Vegetables: 1, Calcium: 20, Iron: 1.5},
+	"hayashi_rice":          {Calories: 540, Protein: 13, Carbs: 82, Fat: 17, Fiber: 2.5, Sodium: 800, Vegetables: 1, Calcium: 25, Iron: 1.2},
+	"hamburg_steak":         {Calories: 210, Protein: 12, Carbs: 9, Fat: 13, Fiber: 0.8, Sodium: 400, Calcium: 25, Iron: 1.2},
+	"nikujaga":              {Calories: 170, Protein: 7, Carbs: 21, Fat: 6, Fiber: 2, Sodium: 450, Vegetables: 1, Calcium: 20, Iron: 0.8},
+	"curry_rice":            {Calories: 560, Protein: 14, Carbs: 85, Fat: 17, Fiber: 3, Sodium: 950, Vegetables: 1, Calcium: 30, Iron: 1.3},
+	"fried_fish":            {Calories: 180, Protein: 11, Carbs: 9, Fat: 11, Fiber: 0.4, Sodium: 200, Calcium: 15, Iron: 0.3},
+	"grilled_fish":          {Calories: 120, Protein: 15, Carbs: 0.5, Fat: 6, Sodium: 300, Calcium: 15, Iron: 0.5},
+	"salted_salmon":         {Calories: 110, Protein: 16, Carbs: 0.1, Fat: 4.5, Sodium: 470, Calcium: 10, Iron: 0.3},
+	"salted_mackerel":       {Calories: 170, Protein: 14, Carbs: 0.2, Fat: 12, Sodium: 390, Calcium: 5, Iron: 0.8},
+	"mackerel_miso":         {Calories: 190, Protein: 13, Carbs: 8, Fat: 11, Fiber: 0.3, Sodium: 470, Calcium: 20, Iron: 1},
+	"simmered_fish":         {Calories: 120, Protein: 14, Carbs: 6, Fat: 4, Fiber: 0.1, Sodium: 480, Calcium: 20, Iron: 0.4},
+	"mapo_tofu":             {Calories: 170, Protein: 10, Carbs: 7, Fat: 11, Fiber: 1, Sodium: 560, Calcium: 90, Iron: 1.3},
+	"tamagoyaki":            {Calories: 90, Protein: 6, Carbs: 3, Fat: 6, Sodium: 200, Calcium: 25, Iron: 0.8},
+	"natto":                 {Calories: 80, Protein: 6.6, Carbs: 5, Fat: 4, Fiber: 2.7, Sodium: 230, Calcium: 40, Iron: 1.5},
+	"stir_fried_vegetables": {Calories: 70, Protein: 2, Carbs: 6, Fat: 4, Fiber: 2, Sodium: 300, Vegetables: 1, Calcium: 30, Iron: 0.5},
+	"hijiki":                {Calories: 60, Protein: 2.5, Carbs: 7, Fat: 3, Fiber: 2.5, Sodium: 300, Calcium: 80, Iron: 0.8},
+	"kiriboshi":             {Calories: 55, Protein: 2, Carbs: 8, Fat: 2, Fiber: 2.5, Sodium: 290, Calcium: 60, Iron: 0.5},
+	"komatsuna_goma":        {Calories: 40, Protein: 2, Carbs: 3, Fat: 2, Fiber: 1.5, Sodium: 150, Vegetables: 1, Calcium: 110, Iron: 1.5},
+	"spinach_ohitashi":      {Calories: 20, Protein: 2, Carbs: 3, Fat: 0.3, Fiber: 1.7, Sodium: 160, Vegetables: 1, Calcium: 30, Iron: 1.2},
+	"kinpira":               {Calories: 65, Protein: 1, Carbs: 8, Fat: 3, Fiber: 2.3, Sodium: 260, Vegetables: 1, Calcium: 30, Iron: 0.4},
+	"chikuzenni":            {Calories: 110, Protein: 6, Carbs: 12, Fat: 4, Fiber: 2.5, Sodium: 450, Vegetables: 1, Calcium: 30, Iron: 0.8},
+	"green_salad":           {Calories: 40, Protein: 1, Carbs: 3, Fat: 3, Fiber: 1.2, Sodium: 120, Vegetables: 1, Calcium: 20, Iron: 0.3},
+	"cabbage_salad":         {Calories: 45, Protein: 1, Carbs: 4, Fat: 3, Fiber: 1.3, Sodium: 150, Vegetables: 1, Calcium: 25, Iron: 0.2},
+	"potato_salad":          {Calories: 110, Protein: 2, Carbs: 11, Fat: 7, Fiber: 1.2, Sodium: 200, Calcium: 10, Iron: 0.3},
+	"namul":                 {Calories: 45, Protein: 2, Carbs: 3, Fat: 3, Fiber: 1.5, Sodium: 200, Vegetables: 1, Calcium: 40, Iron: 0.8},
+	"fukujinzuke":           {Calories: 15, Protein: 0.3, Carbs: 3.3, Fiber: 0.4, Sodium: 200, Calcium: 6, Iron: 0.3},
+	"miso_soup":             {Calories: 40, Protein: 2.5, Carbs: 4, Fat: 1.3, Fiber: 1, Sodium: 600, Calcium: 40, Iron: 0.8},
+	"tonjiru":               {Calories: 90, Protein: 5, Carbs: 6, Fat: 5, Fiber: 1.5, Sodium: 650, Vegetables: 1, Calcium: 30, Iron: 0.7},
+	"kenchin":               {Calories: 60, Protein: 3, Carbs: 6, Fat: 3, Fiber: 1.5, Sodium: 600, Vegetables: 1, Calcium: 50, Iron: 0.8},
+	"clear_soup":            {Calories: 15, Protein: 1.5, Carbs: 1.5, Fat: 0.2, Fiber: 0.3, Sodium: 500, Calcium: 10, Iron: 0.2},
+	"vegetable_soup":        {Calories: 40, Protein: 1.5, Carbs: 6, Fat: 1, Fiber: 1.2, Sodium: 550, Vegetables: 1, Calcium: 20, Iron: 0.3},
+	"consomme":              {Calories: 30, Protein: 1.2, Carbs: 4, Fat: 0.8, Fiber: 0.8, Sodium: 550, Calcium: 10, Iron: 0.2},
+	"wakame_soup":           {Calories: 20, Protein: 1, Carbs: 2, Fat: 0.8, Fiber: 0.7, Sodium: 550, Calcium: 20, Iron: 0.3},
+	"chinese_soup":          {Calories: 35, Protein: 2, Carbs: 3, Fat: 1.5, Fiber: 0.8, Sodium: 600, Calcium: 15, Iron: 0.3},
+	"corn_soup":             {Calories: 110, Protein: 3, Carbs: 14, Fat: 5, Fiber: 1, Sodium: 500, Calcium: 60, Iron: 0.3},
+	"rice":                  {Calories: 234, Protein: 3.8, Carbs: 53, Fat: 0.5, Fiber: 2.3, Sodium: 2, Calcium: 5, Iron: 0.2},
+	"bread":                 {Calories: 160, Protein: 5.3, Carbs: 29, Fat: 2.5, Fiber: 1.4, Sodium: 300, Calcium: 15, Iron: 0.5},
+	"pork_shabu":            {Calories: 200, Protein: 15, Carbs: 4, Fat: 13, Fiber: 1, Sodium: 350, Vegetables: 1, Calcium: 25, Iron: 0.7},
+	"beef_stir_fry":         {Calories: 220, Protein: 12, Carbs: 8, Fat: 15, Fiber: 1.2, Sodium: 450, Vegetables: 1, Calcium: 20, Iron: 1.5},
+	"hiyayakko":             {Calories: 60, Protein: 5, Carbs: 2, Fat: 3.5, Fiber: 0.9, Sodium: 150, Calcium: 75, Iron: 1},
+	"vegetable_tempura":     {Calories: 180, Protein: 2.5, Carbs: 18, Fat: 11, Fiber: 2, Sodium: 150, Vegetables: 1, Calcium: 20, Iron: 0.4},
+	"steamed_vegetables":    {Calories: 35, Protein: 1.5, Carbs: 6, Fat: 0.3, Fiber: 2.2, Sodium: 100, Vegetables: 1, Calcium: 30, Iron: 0.4},
+	"bean_sprout_stir_fry":  {Calories: 50, Protein: 2, Carbs: 3, Fat: 3.5, Fiber: 1.3, Sodium: 250, Vegetables: 1, Calcium: 10, Iron: 0.3},
+	"spinach_goma":          {Calories: 45, Protein: 2, Carbs: 3.5, Fat: 2.5, Fiber: 2, Sodium: 170, Vegetables: 1, Calcium: 70, Iron: 1.5},
+	"komatsuna_shirasu":     {Calories: 25, Protein: 3, Carbs: 2, Fat: 0.3, Fiber: 1.3, Sodium: 250, Vegetables: 1, Calcium: 140, Iron: 1.9},
+	"nori":                  {Calories: 5, Protein: 0.8, Carbs: 0.8, Fiber: 0.7, Sodium: 10, Calcium: 6, Iron: 0.2},
+	"fruit_salad":           {Calories: 70, Protein: 0.5, Carbs: 17, Fat: 0.1, Fiber: 0.8, Sodium: 5, Calcium: 10, Iron: 0.2},
+	"jelly":                 {Calories: 70, Protein: 1, Carbs: 17, Sodium: 20, Calcium: 5, Iron: 0.1},
+	"milk":                  {Calories: 126, Protein: 6.8, Carbs: 9.9, Fat: 7.8, Sodium: 84, Calcium: 227},
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestDefaultDishDictionary(t *testing.T) {
+	dishes := DefaultDishDictionary().Dishes()
+	if len(dishes) != len(defaultDishes) {
+		t.Errorf("Expected %d dishes, got %d", len(defaultDishes), len(dishes))
+	}
+}
+
+func TestDishDictionaryMatch(t *testing.T) {
+	dict := DefaultDishDictionary()
+
+	tests := []struct {
+		name    string
+		dishID  string
+		method  models.DishMatchMethod
+		variant string
+	}{
+		{"鶏肉の照り焼き", "chicken_teriyaki", models.DishMatchExact, ""},
+		{"チキン照り焼き", "chicken_teriyaki", models.DishMatchExact, ""},
+		{"とりの照焼", "chicken_teriyaki", models.DishMatchReading, ""},
+		{"鶏肉の照焼", "chicken_teriyaki", models.DishMatchReading, ""},
+		{"鶏肉の照リ焼き", "chicken_teriyaki", models.DishMatchExact, ""}, // Katakana リ for hiragana り
+		{"味噌汁（わかめ）", "miso_soup", models.DishMatchExact, "わかめ"},
+		{"みそ汁(豆腐)", "miso_soup", models.DishMatchExact, "豆腐"},
+		{"焼き魚（さば）", "grilled_fish", models.DishMatchExact, "さば"},
+		{"【リクエスト】カレーライス", "curry_rice", models.DishMatchExact, "リクエスト"},
+		{"ｶﾚｰﾗｲｽ", "curry_rice", models.DishMatchExact, ""},
+		{"カレ一ライス", "curry_rice", models.DishMatchExact, ""},     // Kanji 一 for ー
+		{"力レーライス", "curry_rice", models.DishMatchExact, ""},     // Kanji 力 for カ
+		{"ﾊﾝﾊﾞｰｸﾞ", "hamburg_steak", models.DishMatchExact, ""}, // Half-width voiced marks
+		{"ハンバーグ ステーキ", "hamburg_steak", models.DishMatchExact, ""},
+		{"豚肉の生妻焼き", "ginger_pork", models.DishMatchFuzzy, ""}, // OCR misread of 姜
+		{"チーズケーキ", "", "", ""},
+		// Close in spelling but of another protein
+		{"豚肉の照り焼き", "", "", ""},
+		{"ぶりの照り焼き", "", "", ""},
+		{"牛肉の照り焼き", "", "", ""},
+		{"鶏肉の生姜焼き", "", "", ""},
+		{"豚肉の唐揚げ", "", "", ""},
+		{"牛乳パン", "", "", ""},
+		{"ポークカレー", "curry_rice", models.DishMatchExact, ""},
+		{"ビーフカレー", "curry_rice", models.DishMatchExact, ""},
+		{"チキンカレー", "curry_rice", models.DishMatchExact, ""},
+		{"", "", "", ""},
+	}
+	for _, test := range tests {
+		match := dict.Match(test.name)
+		if match.DishID != test.dishID || match.Method != test.method || match.Variant != test.variant {
+			t.Errorf("Match(%q) = %+v, expected %s by %s with variant %q", test.name, match, test.dishID, test.method, test.variant)
+		}
+		if match.Matched() && (match.Score <= dishMatchThreshold-0.01 || match.Score > 1) {
+			t.Errorf("Match(%q): unexpected score %v", test.name, match.Score)
+		}
+	}
+}
+
+func TestDishDictionaryAdd(t *testing.T) {
+	dict := DefaultDishDictionary()
+
+	// A name already used by another dish is refused
+	if err := dict.Add(models.Dish{ID: "curry_udon", Name: "カレー", Category: models.DishCategoryMain}); err == nil {
+		t.Error("Expected a name shared with curry_rice to be refused")
+	}
+	if err := dict.Add(models.Dish{ID: "bad", Name: "何か", Category: "snack"}); err == nil {
+		t.Error("Expected an unknown category to be refused")
+	}
+
+	// Replacing a dish drops its old names
+	if err := dict.Add(models.Dish{ID: "jelly", Name: "フルーツゼリー", Category: models.DishCategoryDessert}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if match := dict.Match("ゼリー"); match.Method == models.DishMatchExact {
+		t.Errorf("Expected the old name to be gone, got %+v", match)
+	}
+
+	path := filepath.Join(t.TempDir(), "dishes.json")
+	data, _ := json.Marshal([]models.Dish{{ID: "soft_noodles", Name: "ソフトめん", Aliases: []string{"ソフト麺"}, Category: models.DishCategoryStaple}})
+	os.WriteFile(path, data, 0o644)
+	if err := dict.LoadDishes(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if match := dict.Match("ｿﾌﾄ麺"); match.DishID != "soft_noodles" {
+		t.Errorf("Expected a loaded dish to match, got %+v", match)
+	}
+}
+
+func TestSuggestionUsesDishDictionary(t *testing.T) {
+	service := NewMenuAdvisorService()
+	date := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		mainDish  string
+		breakfast string
+		dinner    string
+	}{
+		// Neither name contains 鶏 or 肉, but both are chicken
+		{"とりの照焼", "焼き魚（アジ）", "魚の煮付け"},
+		{"チキン照り焼き", "焼き魚（アジ）", "魚の煮付け"},
+		{"さばの味噌煮", "卵焼き", "豚しゃぶしゃぶ"},
+		{"ｶﾚｰﾗｲｽ", "納豆", "鶏の唐揚げ"},
+		// Missing from the dictionary; guessed from the name
+		{"豚肉とキャベツのみそ炒め", "焼き魚（アジ）", "鯖の塩焼き"},
+		{"豚肉の照り焼き", "焼き魚（アジ）", "鯖の塩焼き"},
+		{"ぶりの照り焼き", "卵焼き", "豚しゃぶしゃぶ"},
+		{"鶏肉の生姜焼き", "焼き魚（アジ）", "魚の煮付け"},
+		// Curries named for their meat are still curries
+		{"ポークカレー", "納豆", "鶏の唐揚げ"},
+		{"ビーフカレー", "納豆", "鶏の唐揚げ"},
+		{"チキンカレー", "納豆", "鶏の唐揚げ"},
+	}
+	for _, test := range tests {
+		service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: test.mainDish})
+		breakfast, _ := service.GenerateHomeMenuSuggestion(date, models.MealTypeBreakfast)
+		dinner, _ := service.GenerateHomeMenuSuggestion(date, models.MealTypeDinner)
+		if breakfast.MainDish != test.breakfast || dinner.MainDish != test.dinner {
+			t.Errorf("%s: expected %s and %s, got %s and %s", test.mainDish, test.breakfast, test.dinner, breakfast.MainDish, dinner.MainDish)
+		}
+	}
+}
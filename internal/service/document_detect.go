@@ -0,0 +1,282 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// Image formats recognized by their magic bytes
+const (
+	ImageFormatJPEG = "jpeg"
+	ImageFormatPNG  = "png"
+	ImageFormatGIF  = "gif"
+	ImageFormatBMP  = "bmp"
+	ImageFormatWebP = "webp"
+	ImageFormatHEIC = "heic"
+	ImageFormatTIFF = "tiff"
+)
+
+// Limits on inflating PDF streams when looking for text operators. The scan
+// stops once a document has used up either of the totals, so many small
+// compressed streams cannot add up to more work than one large one.
+const (
+	maxPDFStreamSize    = 4 << 20  // Bytes inflated from one stream
+	maxPDFInflatedBytes = 16 << 20 // Bytes inflated from all streams
+	maxPDFFlateStreams  = 1024     // Compressed streams inflated
+)
+
+// contentDetection is the result of inspecting a document's bytes
+type contentDetection struct {
+	Type        models.DocumentType
+	ContentType string
+	Metadata    map[string]string
+	Size        int64 // Bytes
+	Pages       int   // Pages of a PDF or TIFF
+	// PagesUnknown is set for a PDF whose page count could not be read
+	PagesUnknown bool
+	Pixels       int64 // Largest image or page, width times height
+	SHA256       string
+}
+
+// heifBrands are the ISO-BMFF major brands used by HEIC/HEIF photos
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true,
+	"hevc": true, "hevx": true, "mif1": true, "msf1": true,
+}
+
+// detectDocumentContent identifies a document by its magic bytes. PDFs are
+// further inspected to tell text PDFs from scanned ones. ok is false when the
+// content is not recognized.
+func detectDocumentContent(data []byte) (contentDetection, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		info := inspectPDF(data)
+		docType := models.DocumentTypePDFText
+		if !info.HasText && info.Images > 0 {
+			docType = models.DocumentTypePDFImage
+		}
+		return contentDetection{
+			Type:        docType,
+			ContentType: "application/pdf",
+			Metadata: map[string]string{
+				"pages":      fmt.Sprint(info.Pages),
+				"text_layer": fmt.Sprint(info.HasText),
+				"images":     fmt.Sprint(info.Images),
+			},
+			Pages:        info.Pages,
+			PagesUnknown: !info.PagesKnown,
+			Pixels:       info.MaxImagePixels,
+		}, true
+	}
+
+	if isXLSX(data) {
+		return contentDetection{
+			Type:        models.DocumentTypeXLSX,
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		}, true
+	}
+
+	if format := detectImageFormat(data); format != "" {
+		detection := contentDetection{
+			Type:        models.DocumentTypeImage,
+			ContentType: "image/" + format,
+			Metadata:    map[string]string{"image_format": format},
+		}
+		if format == ImageFormatTIFF {
+			if offsets, _, err := tiffIFDOffsets(data); err == nil {
+				detection.Pages = len(offsets)
+				detection.Metadata["pages"] = fmt.Sprint(len(offsets))
+			}
+		}
+		if width, height, pixels, ok := imagePixels(data, format); ok {
+			detection.Pixels = pixels
+			detection.Metadata["width"] = fmt.Sprint(width)
+			detection.Metadata["height"] = fmt.Sprint(height)
+		}
+		return detection, true
+	}
+
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') && json.Valid(trimmed) {
+		return contentDetection{Type: models.DocumentTypeJSON, ContentType: "application/json"}, true
+	}
+
+	if lower := bytes.ToLower(trimmed[:min(len(trimmed), 64)]); bytes.HasPrefix(lower, []byte("<!doctype html")) || bytes.HasPrefix(lower, []byte("<html")) {
+		return contentDetection{Type: models.DocumentTypeHTML, ContentType: "text/html"}, true
+	}
+
+	return contentDetection{}, false
+}
+
+// detectImageFormat returns the image format identified by magic bytes, or ""
+func detectImageFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return ImageFormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return ImageFormatPNG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return ImageFormatGIF
+	case bytes.HasPrefix(data, []byte("BM")) && len(data) >= 26:
+		return ImageFormatBMP
+	case len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return ImageFormatWebP
+	case len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")) && heifBrands[string(data[8:12])]:
+		return ImageFormatHEIC
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return ImageFormatTIFF
+	}
+	return ""
+}
+
+// pdfInfo summarizes what a PDF's pages contain
+type pdfInfo struct {
+	// Pages is the larger of the page objects found and the /Count of the
+	// page tree. PagesKnown is false when no page was found or an object
+	// stream, which may hold pages, could not be inflated.
+	Pages      int
+	PagesKnown bool
+	Images     int
+	HasText    bool
+	// MaxImagePixels is the largest width times height declared by an image
+	MaxImagePixels int64
+	// Inflated counts the compressed streams and bytes inflated looking for text
+	InflatedStreams int
+	InflatedBytes   int64
+}
+
+var (
+	pdfPagePattern   = regexp.MustCompile(`/Type\s*/Page[^s]`)
+	pdfCountPattern  = regexp.MustCompile(`/Count\s+(\d+)`)
+	pdfObjStmPattern = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+	pdfEOLPattern    = regexp.MustCompile(`^\r?\n`)
+	pdfImagePattern  = regexp.MustCompile(`/Subtype\s*/Image\b`)
+	pdfWidthPattern  = regexp.MustCompile(`/Width\s+(\d+)`)
+	pdfHeightPattern = regexp.MustCompile(`/Height\s+(\d+)`)
+	pdfFlatePattern  = regexp.MustCompile(`/Filter\s*\[?\s*/FlateDecode\s*\]?`)
+	pdfFilterPattern = regexp.MustCompile(`/Filter\b`)
+	// A text object that shows at least one string: BT ... (..) Tj / [..] TJ ... ET
+	pdfTextPattern = regexp.MustCompile(`(?s)\bBT\b.*?(?:\)|>|\])\s*(?:Tj|TJ|'|")`)
+)
+
+// inspectPDF counts pages and image XObjects and checks whether any content
+// stream draws text. It is a heuristic scan, not a full PDF parser: only
+// uncompressed and FlateDecode streams are examined, and no more text is
+// looked for once the inflate limits are reached. Object streams (PDF 1.5)
+// are inflated within the same limits to count the pages they hold.
+func inspectPDF(data []byte) pdfInfo {
+	info := pdfInfo{PagesKnown: true}
+	countPages := func(objects []byte) {
+		info.Pages += len(pdfPagePattern.FindAllIndex(objects, -1))
+		for _, m := range pdfCountPattern.FindAllSubmatch(objects, -1) {
+			// The root of the page tree has the largest /Count
+			if n, err := strconv.Atoi(string(m[1])); err == nil && n > info.Pages {
+				info.Pages = n
+			}
+		}
+	}
+	countPages(data)
+
+	pos := 0
+	for {
+		i := bytes.Index(data[pos:], []byte("stream"))
+		if i < 0 {
+			break
+		}
+		kw := pos + i
+		start := kw + len("stream")
+		if kw >= 3 && string(data[kw-3:kw]) == "end" {
+			pos = start
+			continue
+		}
+		start += len(pdfEOLPattern.Find(data[start:]))
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		pos = start + end + len("endstream")
+
+		// The stream dictionary sits between "N 0 obj" and the stream keyword
+		dict := data[bytes.LastIndex(data[:kw], []byte("obj"))+1 : kw]
+		raw := data[start : start+end]
+
+		if pdfObjStmPattern.Match(dict) {
+			objects, ok := info.inflate(dict, raw)
+			if !ok {
+				info.PagesKnown = false
+			}
+			countPages(objects)
+			continue
+		}
+		if pdfImagePattern.Match(dict) {
+			info.Images++
+			if pixels := pdfDictInt(dict, pdfWidthPattern) * pdfDictInt(dict, pdfHeightPattern); pixels > info.MaxImagePixels {
+				info.MaxImagePixels = pixels
+			}
+			continue
+		}
+		if info.HasText || info.InflatedStreams >= maxPDFFlateStreams || info.InflatedBytes >= maxPDFInflatedBytes {
+			continue
+		}
+
+		content, _ := info.inflate(dict, raw)
+		if pdfTextPattern.Match(content) {
+			info.HasText = true
+		}
+	}
+	if info.Pages == 0 {
+		info.PagesKnown = false
+	}
+	return info
+}
+
+// inflate returns the content of an uncompressed or FlateDecode stream,
+// counting what it inflates against the limits. ok is false when the content
+// could not be read whole: another filter, a corrupt stream or a used-up limit.
+func (info *pdfInfo) inflate(dict, raw []byte) (content []byte, ok bool) {
+	switch {
+	case pdfFlatePattern.Match(dict):
+		if info.InflatedStreams >= maxPDFFlateStreams || info.InflatedBytes >= maxPDFInflatedBytes {
+			return nil, false
+		}
+		info.InflatedStreams++
+		r, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, false
+		}
+		defer r.Close()
+		limit := min(maxPDFStreamSize, maxPDFInflatedBytes-info.InflatedBytes)
+		content, err = io.ReadAll(io.LimitReader(r, limit+1))
+		if int64(len(content)) > limit {
+			content = content[:limit]
+			err = io.ErrShortBuffer
+		}
+		info.InflatedBytes += int64(len(content))
+		return content, err == nil
+	case !pdfFilterPattern.Match(dict):
+		return raw, true
+	default:
+		// Other filters (LZW, DCT, ...) are not used for text content in practice
+		return nil, false
+	}
+}
+
+// pdfDictInt reads the integer captured by pattern from a dictionary, or 0
+func pdfDictInt(dict []byte, pattern *regexp.Regexp) int64 {
+	m := pattern.FindSubmatch(dict)
+	if m == nil {
+		return 0
+	}
+	n, err := strconv.ParseInt(string(m[1]), 10, 64)
+	if err != nil || n > 1<<31 {
+		return 1 << 31
+	}
+	return n
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"bytes"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "detect", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture %s: %v", name, err)
+	}
+	return data
+}
+
+func TestDetectDocumentContent(t *testing.T) {
+	tests := []struct {
+		fixture      string
+		expectedType models.DocumentType
+		contentType  string
+	}{
+		{"menu.json", models.DocumentTypeJSON, "application/json"},
+		{"text.pdf", models.DocumentTypePDFText, "application/pdf"},
+		{"scanned.pdf", models.DocumentTypePDFImage, "application/pdf"},
+		{"photo.jpg", models.DocumentTypeImage, "image/jpeg"},
+		{"photo.png", models.DocumentTypeImage, "image/png"},
+		{"photo.gif", models.DocumentTypeImage, "image/gif"},
+		{"photo.bmp", models.DocumentTypeImage, "image/bmp"},
+		{"photo.webp", models.DocumentTypeImage, "image/webp"},
+		{"photo.heic", models.DocumentTypeImage, "image/heic"},
+		{"scan.tiff", models.DocumentTypeImage, "image/tiff"},
+	}
+
+	for _, test := range tests {
+		detection, ok := detectDocumentContent(readFixture(t, test.fixture))
+		if !ok {
+			t.Errorf("%s: expected content to be recognized", test.fixture)
+			continue
+		}
+		if detection.Type != test.expectedType {
+			t.Errorf("%s: expected type %s, got %s", test.fixture, test.expectedType, detection.Type)
+		}
+		if detection.ContentType != test.contentType {
+			t.Errorf("%s: expected content type %s, got %s", test.fixture, test.contentType, detection.ContentType)
+		}
+	}
+
+	if _, ok := detectDocumentContent(readFixture(t, "notes.txt")); ok {
+		t.Error("Expected plain text not to be recognized")
+	}
+}
+
+func TestInspectPDF(t *testing.T) {
+	text := inspectPDF(readFixture(t, "text.pdf"))
+	if text.Pages != 1 || !text.HasText || text.Images != 0 {
+		t.Errorf("Unexpected text PDF info: %+v", text)
+	}
+
+	scanned := inspectPDF(readFixture(t, "scanned.pdf"))
+	if scanned.Pages != 2 || scanned.HasText || scanned.Images != 1 {
+		t.Errorf("Unexpected scanned PDF info: %+v", scanned)
+	}
+}
+
+func TestProcessDocumentDetectsByContent(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+
+	// A JSON menu saved with the wrong extension is still processed as JSON
+	result, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+		File:   &mockFileBytes{bytes.NewReader(readFixture(t, "menu.json"))},
+		Header: &multipart.FileHeader{Filename: "menu.txt"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Type != models.DocumentTypeJSON || result.ContentType != "application/json" {
+		t.Errorf("Expected JSON detected from content, got %s (%s)", result.Type, result.ContentType)
+	}
+
+	// A scanned PDF goes to OCR rather than text extraction
+	ocr := &fakeOCR{lines: textLines("2025年1月21日(火)\n主菜: 焼きそば", 0.95)}
+	processor.SetOCREngine(ocr)
+	result, err = processor.ProcessDocument(&models.DocumentProcessingRequest{
+		File:   &mockFileBytes{bytes.NewReader(readFixture(t, "scanned.pdf"))},
+		Header: &multipart.FileHeader{Filename: "menu.pdf"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Type != models.DocumentTypePDFImage {
+		t.Errorf("Expected scanned PDF to be detected as %s, got %s", models.DocumentTypePDFImage, result.Type)
+	}
+
+	// Text layers are not read yet, so text PDFs are read with OCR too
+	ocr.lines = textLines("2025年1月22日(水)\n主菜: 鮭の塩焼き", 0.95)
+	result, err = processor.ProcessDocument(&models.DocumentProcessingRequest{
+		File:   &mockFileBytes{bytes.NewReader(readFixture(t, "text.pdf"))},
+		Header: &multipart.FileHeader{Filename: "menu.pdf"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Type != models.DocumentTypePDFText || result.Status != "completed" {
+		t.Errorf("Expected text PDF to be read with OCR, got %s (%s)", result.Type, result.Status)
+	}
+}
@@ -1,30 +1,236 @@
 package service
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/habuka036/menu-advisor/internal/models"
 )
 
+// ErrUnsupportedDocumentType is returned when a document's type cannot be handled
+var ErrUnsupportedDocumentType = errors.New("unsupported file type")
+
 // DocumentProcessor handles processing of various document types
 type DocumentProcessor struct {
-	menuService *MenuAdvisorService
+	menuService     *MenuAdvisorService
+	ocr             OCREngine
+	mu              sync.RWMutex
+	documents       map[string]*models.DocumentSource
+	reviews         map[string]*models.PendingReview
+	originals       map[string]originalDocument
+	reviewThreshold float64
+	htmlProfiles    map[string]*htmlProfile
+	limits          models.UploadLimits
+	contentIndex    map[string]string        // Document ID by content hash
+	inflight        map[string]chan struct{} // Content being processed, by hash
+	stages          []PipelineStage
+	traces          map[string]*models.DocumentTrace // By document ID
+	documentOrder   []string                         // Document IDs, oldest first
+	maxDocuments    int
+}
+
+// defaultMaxDocuments bounds how many processed documents are remembered; the
+// oldest are forgotten first
+const defaultMaxDocuments = 1000
+
+// OCREngine recognizes text in page images. Implementations wrap an OCR
+// library or service; format is the document type being recognized.
+type OCREngine interface {
+	Recognize(data []byte, format models.DocumentType) ([]models.TextLine, error)
 }
 
 // NewDocumentProcessor creates a new document processor
 func NewDocumentProcessor(menuService *MenuAdvisorService) *DocumentProcessor {
-	return &DocumentProcessor{
-		menuService: menuService,
+	dp := &DocumentProcessor{
+		menuService:     menuService,
+		documents:       make(map[string]*models.DocumentSource),
+		reviews:         make(map[string]*models.PendingReview),
+		originals:       make(map[string]originalDocument),
+		reviewThreshold: DefaultReviewThreshold,
+		htmlProfiles:    make(map[string]*htmlProfile),
+		limits:          DefaultUploadLimits(),
+		contentIndex:    make(map[string]string),
+		inflight:        make(map[string]chan struct{}),
+		traces:          make(map[string]*models.DocumentTrace),
+		maxDocuments:    defaultMaxDocuments,
+	}
+	dp.stages = dp.defaultPipelineStages()
+	return dp
+}
+
+// SetOCREngine sets the engine used to read image documents
+func (dp *DocumentProcessor) SetOCREngine(engine OCREngine) {
+	dp.ocr = engine
+}
+
+// GetDocument returns a previously processed document by ID
+func (dp *DocumentProcessor) GetDocument(id string) (*models.DocumentSource, bool) {
+	dp.mu.RLock()
+	defer dp.mu.RUnlock()
+	doc, ok := dp.documents[id]
+	return doc, ok
+}
+
+// documentDate returns the effective date of a previously processed document
+func (dp *DocumentProcessor) documentDate(id string) (time.Time, bool) {
+	doc, ok := dp.GetDocument(id)
+	if !ok {
+		return time.Time{}, false
+	}
+	return doc.EffectiveDate(), true
+}
+
+// registerDocument remembers a processed document and its trace so later
+// uploads can refer to it. Beyond maxDocuments the oldest are forgotten.
+func (dp *DocumentProcessor) registerDocument(doc *models.DocumentSource, trace *models.DocumentTrace) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	if dp.documents == nil {
+		dp.documents = make(map[string]*models.DocumentSource)
+	}
+	dp.documents[doc.ID] = doc
+	dp.traces[doc.ID] = trace
+	dp.documentOrder = append(dp.documentOrder, doc.ID)
+	for dp.maxDocuments > 0 && len(dp.documentOrder) > dp.maxDocuments {
+		dp.forgetDocumentLocked(dp.documentOrder[0])
+		dp.documentOrder = dp.documentOrder[1:]
+	}
+}
+
+// forgetDocumentLocked drops a document with its trace, reviews and original
+// bytes, so the same content uploaded again is processed anew. Caller must
+// hold dp.mu.
+func (dp *DocumentProcessor) forgetDocumentLocked(id string) {
+	delete(dp.documents, id)
+	delete(dp.traces, id)
+	delete(dp.originals, id)
+	for reviewID, r := range dp.reviews {
+		if r.DocumentID == id {
+			delete(dp.reviews, reviewID)
+		}
+	}
+	for key, indexed := range dp.contentIndex {
+		if indexed == id {
+			delete(dp.contentIndex, key)
+		}
+	}
+}
+
+// uploadKey identifies an upload for deduplication: the same bytes processed
+// with the same options. Options are normalized first, so that leaving out a
+// default and spelling it out give the same key.
+func uploadKey(doc *models.DocumentSource, req *models.DocumentProcessingRequest) string {
+	day := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return dateKey(*t)
+	}
+	policy := req.MergePolicy
+	if policy == "" {
+		policy = models.MergePolicyReplace
+	}
+	mapping := req.ColumnMapping
+	if len(mapping) == 0 {
+		mapping = nil
+	}
+	options, _ := json.Marshal(struct {
+		Type          models.DocumentType
+		DateFrom      string
+		DateTo        string
+		Year          int
+		Month         time.Month
+		IssuedAt      string
+		MergePolicy   models.MergePolicy
+		ColumnMapping models.ColumnMapping
+		HTMLProfile   string
+		SchoolID      string
+	}{doc.Type, day(req.DateFrom), day(req.DateTo), req.Year, req.Month, day(req.IssuedAt),
+		policy, mapping, strings.TrimSpace(req.HTMLProfile), req.SchoolID})
+	sum := sha256.Sum256(append([]byte(doc.ContentHash+"\n"), options...))
+	return hex.EncodeToString(sum[:])
+}
+
+// claimContent returns a copy of the document already made from the upload
+// with the given key, waiting while another such upload is being processed.
+// A document whose menus have since been edited, deleted or rolled back no
+// longer counts, so the upload can bring them back. When none exists, ok is
+// false and the caller, which now owns the key, must call releaseContent
+// once done.
+func (dp *DocumentProcessor) claimContent(key string) (*models.DocumentSource, bool) {
+	for {
+		dp.mu.Lock()
+		if id, ok := dp.contentIndex[key]; ok {
+			existing := *dp.documents[id]
+			dp.mu.Unlock()
+			// The menu service calls back into dp while holding its own
+			// lock, so it is asked without holding dp.mu
+			if dp.menuService.sourceInEffect(existing.ID, committedDays(existing.MergeReport)) {
+				existing.Duplicate = true
+				return &existing, true
+			}
+			dp.mu.Lock()
+			if dp.contentIndex[key] == id {
+				delete(dp.contentIndex, key)
+			}
+			dp.mu.Unlock()
+			continue
+		}
+		wait, busy := dp.inflight[key]
+		if !busy {
+			dp.inflight[key] = make(chan struct{})
+			dp.mu.Unlock()
+			return nil, false
+		}
+		dp.mu.Unlock()
+		<-wait
+	}
+}
+
+// committedDays lists the days a merge added or updated, fully or in part
+func committedDays(report *models.MergeReport) []string {
+	if report == nil || report.DryRun {
+		return nil
+	}
+	var days []string
+	for _, day := range report.Days {
+		if day.Status == models.DayMergeAdded || day.Status == models.DayMergeUpdated || day.Status == models.DayMergePartial {
+			days = append(days, day.Date)
+		}
 	}
+	return days
 }
 
-// ProcessDocument processes a document and extracts menu information
+// releaseContent indexes a processed document by its upload key and wakes
+// uploads waiting for it. Failed documents are not indexed, so that the same
+// file can be uploaded again.
+func (dp *DocumentProcessor) releaseContent(key string, doc *models.DocumentSource) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	if doc.Status == "completed" || doc.Status == "needs_review" {
+		dp.contentIndex[key] = doc.ID
+	}
+	if wait, ok := dp.inflight[key]; ok {
+		close(wait)
+		delete(dp.inflight, key)
+	}
+}
+
+// ProcessDocument processes a document and extracts menu information. The
+// document goes through each pipeline stage in turn and what every stage
+// produced is kept as the document's trace.
 func (dp *DocumentProcessor) ProcessDocument(req *models.DocumentProcessingRequest) (*models.DocumentSource, error) {
 	// Generate unique ID for this document
 	docID := generateDocumentID()
@@ -33,52 +239,122 @@ func (dp *DocumentProcessor) ProcessDocument(req *models.DocumentProcessingReque
 	doc := &models.DocumentSource{
 		ID:           docID,
 		Type:         req.Type,
-		OriginalName: req.Header.Filename,
+		OriginalName: sanitizeFilename(req.Header.Filename),
 		UploadedAt:   time.Now(),
+		IssuedAt:     req.IssuedAt,
+		SchoolID:     req.SchoolID,
 		Status:       "processing",
 	}
+	run := &PipelineRun{
+		Request:  req,
+		Document: doc,
+		File:     req.File,
+		trace:    &models.DocumentTrace{DocumentID: docID, StartedAt: doc.UploadedAt},
+	}
 
-	// Determine document type if not specified
-	if req.Type == "" {
-		detectedType, err := dp.detectDocumentType(req.Header.Filename)
-		if err != nil {
+	var err error
+	var key string
+	for _, stage := range dp.PipelineStages() {
+		if err = run.runStage(stage); err != nil {
 			doc.Status = "error"
-			doc.ErrorMessage = fmt.Sprintf("Failed to detect document type: %v", err)
-			return doc, err
+			if doc.ErrorMessage == "" {
+				doc.ErrorMessage = err.Error()
+			}
+			break
 		}
-		doc.Type = detectedType
+
+		// The same file uploaded again with the same options returns the
+		// document made the first time
+		if stage.Name() == models.StageDetect && !req.DryRun {
+			key = uploadKey(doc, req)
+			if existing, ok := dp.claimContent(key); ok {
+				return existing, nil
+			}
+		}
+	}
+
+	run.trace.Status = doc.Status
+	run.trace.DurationMS = durationMS(time.Since(run.trace.StartedAt))
+	// The document is registered before uploads waiting for the same
+	// content are released
+	dp.registerDocument(doc, run.trace)
+	if key != "" {
+		dp.releaseContent(key, doc)
 	}
+	return doc, err
+}
 
-	// Process based on document type
-	extractedData, err := dp.extractDataFromDocument(req, doc)
+// PreviewTable shows how the columns of a CSV or Excel document map onto menu
+// fields and which menus its rows produce, without importing anything
+func (dp *DocumentProcessor) PreviewTable(req *models.DocumentProcessingRequest) (*models.TablePreview, error) {
+	detection, detected, err := dp.detectContent(req.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	docType, err := dp.documentType(req, detection, detected)
 	if err != nil {
-		doc.Status = "error"
-		doc.ErrorMessage = fmt.Sprintf("Failed to extract data: %v", err)
-		return doc, err
+		return nil, err
+	}
+	if docType != models.DocumentTypeCSV && docType != models.DocumentTypeXLSX {
+		return nil, fmt.Errorf("%w: preview needs a CSV or Excel document, got %s", ErrUnsupportedDocumentType, docType)
+	}
+	if err := dp.checkLimits(docType, detection); err != nil {
+		return nil, err
 	}
 
-	// Parse extracted data into menu structure
-	menus, err := dp.parseExtractedMenuData(extractedData)
+	extracted, err := dp.extractFromTable(req.File, "", docType)
+	if err != nil {
+		return nil, err
+	}
+	table, err := parseTableMenuRows(extracted.Rows, req.ColumnMapping, parseContextFor(req))
 	if err != nil {
-		doc.Status = "error"
-		doc.ErrorMessage = fmt.Sprintf("Failed to parse menu data: %v", err)
-		return doc, err
+		return nil, err
 	}
 
-	// Add parsed menus to the service
-	for _, menu := range menus {
-		dp.menuService.AddSchoolLunchMenu(menu)
+	preview := &models.TablePreview{
+		Type:      docType,
+		HeaderRow: table.HeaderRow,
+		Columns:   table.Columns,
+		Menus:     make([]models.SchoolLunchMenu, len(table.Menus)),
+		Skipped:   table.Skipped,
 	}
+	for i, p := range table.Menus {
+		preview.Menus[i] = p.Menu
+	}
+	return preview, nil
+}
 
-	// Mark as completed
-	now := time.Now()
-	doc.ProcessedAt = &now
-	doc.Status = "completed"
+// documentType decides how to read a document: the requested type, else the
+// type recognized from its content, else the one implied by its file extension
+func (dp *DocumentProcessor) documentType(req *models.DocumentProcessingRequest, detection contentDetection, detected bool) (models.DocumentType, error) {
+	switch {
+	case req.Type != "":
+		return req.Type, nil
+	case detected:
+		return detection.Type, nil
+	default:
+		return dp.detectDocumentType(req.Header.Filename)
+	}
+}
 
-	return doc, nil
+// detectContent sniffs the document's bytes and rewinds the file for extraction
+func (dp *DocumentProcessor) detectContent(file multipart.File) (contentDetection, bool, error) {
+	data, err := readAllFrom(file)
+	if err != nil {
+		return contentDetection{}, false, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return contentDetection{}, false, err
+	}
+	detection, ok := detectDocumentContent(data)
+	detection.Size = int64(len(data))
+	sum := sha256.Sum256(data)
+	detection.SHA256 = hex.EncodeToString(sum[:])
+	return detection, ok, nil
 }
 
-// detectDocumentType determines the document type based on file extension
+// detectDocumentType determines the document type based on file extension,
+// for documents whose content detectDocumentContent does not recognize
 func (dp *DocumentProcessor) detectDocumentType(filename string) (models.DocumentType, error) {
 	ext := strings.ToLower(filepath.Ext(filename))
 	
@@ -86,28 +362,38 @@ func (dp *DocumentProcessor) detectDocumentType(filename string) (models.Documen
 	case ".json":
 		return models.DocumentTypeJSON, nil
 	case ".pdf":
-		// For now, default to PDF text - could enhance to detect if image-based
+		// Only the content tells a text PDF from a scanned one
 		return models.DocumentTypePDFText, nil
-	case ".jpg", ".jpeg", ".png", ".bmp", ".gif":
+	case ".jpg", ".jpeg", ".png", ".bmp", ".gif", ".webp", ".heic", ".heif", ".tif", ".tiff":
 		return models.DocumentTypeImage, nil
+	case ".csv":
+		return models.DocumentTypeCSV, nil
+	case ".xlsx":
+		return models.DocumentTypeXLSX, nil
+	case ".html", ".htm":
+		return models.DocumentTypeHTML, nil
 	default:
-		return "", fmt.Errorf("unsupported file type: %s", ext)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDocumentType, ext)
 	}
 }
 
 // extractDataFromDocument extracts raw data based on document type
-func (dp *DocumentProcessor) extractDataFromDocument(req *models.DocumentProcessingRequest, doc *models.DocumentSource) (*models.ExtractedMenuData, error) {
+func (dp *DocumentProcessor) extractDataFromDocument(file multipart.File, req *models.DocumentProcessingRequest, doc *models.DocumentSource) (*models.ExtractedMenuData, error) {
 	switch doc.Type {
 	case models.DocumentTypeJSON:
-		return dp.extractFromJSON(req.File, doc.ID)
+		return dp.extractFromJSON(file, doc.ID)
 	case models.DocumentTypePDFText:
-		return dp.extractFromPDFText(req.File, doc.ID)
+		return dp.extractFromPDFText(file, doc.ID)
 	case models.DocumentTypePDFImage:
-		return dp.extractFromPDFImage(req.File, doc.ID)
+		return dp.extractFromPDFImage(file, doc.ID)
 	case models.DocumentTypeImage:
-		return dp.extractFromImage(req.File, doc.ID)
+		return dp.extractFromImage(file, doc.ID)
+	case models.DocumentTypeCSV, models.DocumentTypeXLSX:
+		return dp.extractFromTable(file, doc.ID, doc.Type)
+	case models.DocumentTypeHTML:
+		return dp.extractFromHTML(file, doc.ID, req.Header.Header.Get("Content-Type"), req.HTMLProfile, req.ColumnMapping)
 	default:
-		return nil, fmt.Errorf("unsupported document type: %s", doc.Type)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDocumentType, doc.Type)
 	}
 }
 
@@ -127,10 +413,58 @@ func (dp *DocumentProcessor) extractFromJSON(file multipart.File, sourceID strin
 	}, nil
 }
 
-// extractFromPDFText extracts text from text-based PDFs
+// extractFromTable reads the cells of a CSV file or of the first sheet of an
+// Excel workbook
+func (dp *DocumentProcessor) extractFromTable(file multipart.File, sourceID string, format models.DocumentType) (*models.ExtractedMenuData, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file: %w", format, err)
+	}
+
+	var rows [][]string
+	if format == models.DocumentTypeXLSX {
+		rows, err = readXLSXRows(data)
+	} else {
+		rows, err = readCSVRows(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ExtractedMenuData{
+		SourceID:    sourceID,
+		Rows:        rows,
+		ExtractedAt: time.Now(),
+		Confidence:  1.0, // Cells are read exactly
+		Metadata:    map[string]string{"format": string(format), "rows": fmt.Sprint(len(rows))},
+	}, nil
+}
+
+// readCSVRows parses a UTF-8 CSV file, with or without a byte order mark.
+// Rows may have different numbers of cells.
+func readCSVRows(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("CSV file is not UTF-8; save it as \"CSV UTF-8\"")
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	}
+	return rows, nil
+}
+
+// extractFromPDFText extracts text from text-based PDFs. Reading the text
+// layer is not implemented yet, so the pages are read with the OCR engine
+// when one is configured.
 func (dp *DocumentProcessor) extractFromPDFText(file multipart.File, sourceID string) (*models.ExtractedMenuData, error) {
+	if dp.ocr != nil {
+		return dp.extractWithOCR(file, sourceID, models.DocumentTypePDFImage)
+	}
 	// Placeholder implementation - would use a PDF library like unidoc/unipdf
-	// For now, return a mock implementation
 	return &models.ExtractedMenuData{
 		SourceID:    sourceID,
 		RawText:     "PDF text extraction not yet implemented",
@@ -142,6 +476,9 @@ func (dp *DocumentProcessor) extractFromPDFText(file multipart.File, sourceID st
 
 // extractFromPDFImage extracts text from image-based PDFs using OCR
 func (dp *DocumentProcessor) extractFromPDFImage(file multipart.File, sourceID string) (*models.ExtractedMenuData, error) {
+	if dp.ocr != nil {
+		return dp.extractWithOCR(file, sourceID, models.DocumentTypePDFImage)
+	}
 	// Placeholder implementation - would use OCR library like tesseract
 	return &models.ExtractedMenuData{
 		SourceID:    sourceID,
@@ -154,6 +491,9 @@ func (dp *DocumentProcessor) extractFromPDFImage(file multipart.File, sourceID s
 
 // extractFromImage extracts text from image files using OCR
 func (dp *DocumentProcessor) extractFromImage(file multipart.File, sourceID string) (*models.ExtractedMenuData, error) {
+	if dp.ocr != nil {
+		return dp.extractWithOCR(file, sourceID, models.DocumentTypeImage)
+	}
 	// Placeholder implementation - would use OCR library like tesseract
 	return &models.ExtractedMenuData{
 		SourceID:    sourceID,
@@ -164,18 +504,145 @@ func (dp *DocumentProcessor) extractFromImage(file multipart.File, sourceID stri
 	}, fmt.Errorf("Image OCR not yet implemented")
 }
 
+// extractWithOCR runs the configured OCR engine over an image document.
+// Multi-page TIFFs are recognized page by page and their lines joined in page
+// order, so a day continued on the next page is parsed as one menu.
+func (dp *DocumentProcessor) extractWithOCR(file multipart.File, sourceID string, format models.DocumentType) (*models.ExtractedMenuData, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	pages := 1
+	var lines []models.TextLine
+	recognize := func(i int, page []byte) error {
+		pageLines, err := dp.ocr.Recognize(page, format)
+		if err != nil {
+			if pages > 1 {
+				return fmt.Errorf("OCR failed on page %d: %w", i+1, err)
+			}
+			return fmt.Errorf("OCR failed: %w", err)
+		}
+		for _, line := range pageLines {
+			if pages > 1 && line.Box != nil {
+				box := *line.Box
+				box.Page = i + 1
+				line.Box = &box
+			}
+			lines = append(lines, line)
+		}
+		return nil
+	}
+
+	if detectImageFormat(data) == ImageFormatTIFF {
+		offsets, _, err := tiffIFDOffsets(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TIFF pages: %w", err)
+		}
+		pages = len(offsets)
+		err = forEachTIFFPage(data, recognize)
+	} else {
+		err = recognize(0, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, len(lines))
+	confidence := 1.0
+	for i, line := range lines {
+		texts[i] = line.Text
+		if line.Confidence < confidence {
+			confidence = line.Confidence
+		}
+	}
+
+	return &models.ExtractedMenuData{
+		SourceID:    sourceID,
+		RawText:     strings.Join(texts, "\n"),
+		Lines:       lines,
+		ExtractedAt: time.Now(),
+		Confidence:  confidence,
+		Metadata:    map[string]string{"format": string(format), "engine": "ocr", "pages": fmt.Sprint(pages)},
+	}, nil
+}
+
 // parseExtractedMenuData converts extracted raw data into structured menu data
-func (dp *DocumentProcessor) parseExtractedMenuData(data *models.ExtractedMenuData) ([]models.SchoolLunchMenu, error) {
+func (dp *DocumentProcessor) parseExtractedMenuData(data *models.ExtractedMenuData, ctx textParseContext, mapping models.ColumnMapping) ([]parsedMenu, error) {
 	// For JSON format, use existing parsing logic
 	if data.Metadata["format"] == "json" {
-		return dp.parseJSONMenuData(data.RawText)
+		menus, err := dp.parseJSONMenuData(data.RawText)
+		if err != nil {
+			return nil, err
+		}
+		parsed := make([]parsedMenu, len(menus))
+		for i, menu := range menus {
+			parsed[i] = parsedMenu{Menu: menu}
+		}
+		return parsed, nil
 	}
-	
-	// For other formats, would implement text parsing logic here
-	// This would involve NLP/pattern matching to extract menu information
-	// from OCR text or PDF text
-	
-	return nil, fmt.Errorf("parsing for format %s not yet implemented", data.Metadata["format"])
+
+	// Spreadsheets and web pages are parsed by column
+	if data.Rows != nil {
+		table, err := parseTableMenuRows(data.Rows, mapping, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return table.Menus, nil
+	}
+
+	// Text from OCR or PDF extraction is parsed line by line
+	lines := data.Lines
+	if len(lines) == 0 {
+		for _, text := range strings.Split(data.RawText, "\n") {
+			lines = append(lines, models.TextLine{Text: text, Confidence: data.Confidence})
+		}
+	}
+	return parseTextMenuLines(lines, ctx)
+}
+
+// parseContextFor picks the year and month used for dates printed without
+// them: the request's target month, else the start of its date range, else today
+func parseContextFor(req *models.DocumentProcessingRequest) textParseContext {
+	ref := time.Now()
+	if req.DateFrom != nil {
+		ref = *req.DateFrom
+	}
+	ctx := textParseContext{Year: ref.Year(), Month: ref.Month()}
+	if req.Year != 0 {
+		ctx.Year = req.Year
+	}
+	if req.Month != 0 {
+		ctx.Month = req.Month
+	}
+	return ctx
+}
+
+// filterDateRange drops menus dated outside [from, to] (either bound may be
+// nil) and reports each dropped day
+func filterDateRange(parsed []parsedMenu, from, to *time.Time) ([]parsedMenu, []models.DayMergeResult) {
+	if from == nil && to == nil {
+		return parsed, nil
+	}
+	var kept []parsedMenu
+	var dropped []models.DayMergeResult
+	for _, p := range parsed {
+		day := dateKey(p.Menu.Date)
+		if (from != nil && day < dateKey(*from)) || (to != nil && day > dateKey(*to)) {
+			dropped = append(dropped, models.DayMergeResult{Date: day, Status: models.DayMergeOutOfRange})
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, dropped
+}
+
+// readAllFrom reads a document from the beginning, regardless of how much was already consumed
+func readAllFrom(file multipart.File) ([]byte, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(file)
 }
 
 // parseJSONMenuData parses JSON menu data (reuses existing logic)
@@ -185,9 +652,4 @@ func (dp *DocumentProcessor) parseJSONMenuData(jsonText string) ([]models.School
 		return nil, fmt.Errorf("failed to parse JSON menu data: %w", err)
 	}
 	return menus, nil
-}
-
-// generateDocumentID generates a unique ID for a document
-func generateDocumentID() string {
-	return fmt.Sprintf("doc_%d", time.Now().UnixNano())
 }
\ No newline at end of file
@@ -1,9 +1,12 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"mime/multipart"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/habuka036/menu-advisor/internal/models"
 )
@@ -210,4 +213,255 @@ func TestProcessDocumentJSON(t *testing.T) {
 	if !found {
 		t.Error("Expected new menu to be added to service")
 	}
-}
\ No newline at end of file
+}
+
+func TestProcessDocumentDateRange(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	processor.SetOCREngine(&fakeOCR{lines: textLines("○○小学校 給食献立表\n13日(月)\n主菜: 鶏肉の照り焼き\n14日(火)\n主菜: 魚のフライ\n15日(水)\n主菜: カレーライス", 0.95)})
+
+	from := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	req := &models.DocumentProcessingRequest{
+		File:     newMockFile("image"),
+		Header:   &multipart.FileHeader{Filename: "menu.jpg"},
+		DateFrom: &from,
+		DateTo:   &to,
+		Year:     2025,
+		Month:    time.January,
+	}
+
+	result, err := processor.ProcessDocument(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.MergeReport.Summary[models.DayMergeAdded] != 2 || result.MergeReport.Summary[models.DayMergeOutOfRange] != 1 {
+		t.Errorf("Expected 2 added and 1 out of range, got %+v", result.MergeReport.Summary)
+	}
+	if _, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("Expected day before date_from to be dropped")
+	}
+	if _, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected 13日-style date to resolve to the target month: %v", err)
+	}
+}
+
+func TestParseContextFor(t *testing.T) {
+	from := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	ctx := parseContextFor(&models.DocumentProcessingRequest{DateFrom: &from})
+	if ctx.Year != 2024 || ctx.Month != time.December {
+		t.Errorf("Expected date_from to supply year and month, got %+v", ctx)
+	}
+	ctx = parseContextFor(&models.DocumentProcessingRequest{DateFrom: &from, Year: 2025, Month: time.March})
+	if ctx.Year != 2025 || ctx.Month != time.March {
+		t.Errorf("Expected explicit year and month to win, got %+v", ctx)
+	}
+}
+
+func TestProcessDocumentDeduplicatesContent(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	content := `[{"date":"2025-04-08T00:00:00Z","main_dish":"カレーライス"}]`
+	upload := func(name string, dryRun bool) (*models.DocumentSource, error) {
+		return processor.ProcessDocument(&models.DocumentProcessingRequest{
+			File:   newMockFile(content),
+			Header: &multipart.FileHeader{Filename: name},
+			DryRun: dryRun,
+		})
+	}
+
+	// A dry run does not count as the first upload
+	preview, err := upload("menu.json", true)
+	if err != nil || preview.Duplicate {
+		t.Fatalf("Unexpected dry run result: %+v (%v)", preview, err)
+	}
+
+	first, err := upload("menu.json", false)
+	if err != nil || first.Duplicate || first.ContentHash == "" {
+		t.Fatalf("Unexpected first result: %+v (%v)", first, err)
+	}
+	again, err := upload("renamed.json", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again.ID != first.ID || !again.Duplicate || again.OriginalName != "menu.json" {
+		t.Errorf("Expected the first document to be returned, got %+v", again)
+	}
+	if n := len(menuService.GetAuditLog(nil)); n != 1 {
+		t.Errorf("Expected the duplicate not to be processed, got %d audit entries", n)
+	}
+	if stored, _ := processor.GetDocument(first.ID); stored.Duplicate {
+		t.Error("Expected the stored document not to be marked as a duplicate")
+	}
+
+	// Failed documents can be uploaded again
+	broken := `[{"date":`
+	for i := 0; i < 2; i++ {
+		doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+			File:   newMockFile(broken),
+			Header: &multipart.FileHeader{Filename: "broken.json"},
+		})
+		if err == nil || doc.Duplicate {
+			t.Errorf("Attempt %d: expected the broken document to be processed again, got %+v", i+1, doc)
+		}
+	}
+}
+
+func TestProcessDocumentConcurrentDuplicates(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	content := `[{"date":"2025-04-09T00:00:00Z","main_dish":"ハヤシライス"}]`
+
+	const n = 10
+	ids := make(chan string, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+				File:   newMockFile(content),
+				Header: &multipart.FileHeader{Filename: "menu.json"},
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			ids <- doc.ID
+		}()
+	}
+	first := <-ids
+	for i := 1; i < n; i++ {
+		if id := <-ids; id != first {
+			t.Errorf("Expected every upload to return %s, got %s", first, id)
+		}
+	}
+	if n := len(menuService.GetAuditLog(nil)); n != 1 {
+		t.Errorf("Expected the content to be processed once, got %d audit entries", n)
+	}
+}
+
+func TestProcessDocumentForgetsOldDocuments(t *testing.T) {
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+	processor.maxDocuments = 2
+	upload := func(day int) *models.DocumentSource {
+		doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+			File:   newMockFile(fmt.Sprintf(`[{"date":"2025-04-%02dT00:00:00Z","main_dish":"カレーライス"}]`, day)),
+			Header: &multipart.FileHeader{Filename: "menu.json"},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return doc
+	}
+
+	first := upload(7)
+	upload(8)
+	upload(9)
+	if _, ok := processor.GetDocument(first.ID); ok {
+		t.Error("Expected the oldest document to be forgotten")
+	}
+	if _, err := processor.GetTrace(first.ID); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected the oldest trace to be forgotten, got %v", err)
+	}
+	if len(processor.documents) != 2 || len(processor.traces) != 2 || len(processor.contentIndex) != 2 {
+		t.Errorf("Expected 2 documents, got %d with %d traces indexed by %d hashes", len(processor.documents), len(processor.traces), len(processor.contentIndex))
+	}
+	// Its content is no longer a duplicate
+	if again := upload(7); again.Duplicate || again.ID == first.ID {
+		t.Errorf("Expected the forgotten content to be processed again, got %+v", again)
+	}
+}
+
+func TestProcessDocumentDeduplicatesByOptions(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	content := `[{"date":"2025-04-10T00:00:00Z","main_dish":"カレーライス"}]`
+	upload := func(policy models.MergePolicy, schoolID string) *models.DocumentSource {
+		doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+			File:        newMockFile(content),
+			Header:      &multipart.FileHeader{Filename: "menu.json"},
+			MergePolicy: policy,
+			SchoolID:    schoolID,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return doc
+	}
+
+	first := upload("", "")
+	// The default policy spelled out is the same upload
+	if again := upload(models.MergePolicyReplace, ""); !again.Duplicate || again.ID != first.ID {
+		t.Errorf("Expected the default policy to be a duplicate, got %+v", again)
+	}
+	// Other options are processed again
+	for _, doc := range []*models.DocumentSource{upload(models.MergePolicyKeepExisting, ""), upload("", "minato")} {
+		if doc.Duplicate || doc.ID == first.ID {
+			t.Errorf("Expected different options to be processed again, got %+v", doc)
+		}
+	}
+}
+
+func TestProcessDocumentReimportsAfterDelete(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	date := time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC)
+	upload := func() *models.DocumentSource {
+		doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+			File:   newMockFile(`[{"date":"2025-04-11T00:00:00Z","main_dish":"カレーライス"}]`),
+			Header: &multipart.FileHeader{Filename: "menu.json"},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return doc
+	}
+
+	first := upload()
+	if err := menuService.DeleteSchoolLunchMenu(date, "*", models.ChangeContext{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	again := upload()
+	if again.Duplicate || again.ID == first.ID {
+		t.Errorf("Expected the upload to be processed again after the delete, got %+v", again)
+	}
+	if lunch, err := menuService.GetSchoolLunchForDate(date); err != nil || lunch.MainDish != "カレーライス" {
+		t.Errorf("Expected the menu to be imported again, got %v, %v", lunch, err)
+	}
+	// Now in effect again, the next upload is a duplicate of the new document
+	if third := upload(); !third.Duplicate || third.ID != again.ID {
+		t.Errorf("Expected a duplicate of %s, got %+v", again.ID, third)
+	}
+}
+
+func TestProcessDocumentReimportsWhenNothingWasApplied(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	date := time.Date(2025, 4, 14, 0, 0, 0, 0, time.UTC)
+	menuService.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: "うどん"})
+	upload := func() *models.DocumentSource {
+		doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+			File:        newMockFile(`[{"date":"2025-04-14T00:00:00Z","main_dish":"カレーライス"}]`),
+			Header:      &multipart.FileHeader{Filename: "menu.json"},
+			MergePolicy: models.MergePolicyKeepExisting,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return doc
+	}
+
+	// Every day conflicts, so the document changes nothing
+	first := upload()
+	if first.MergeReport.Summary[models.DayMergeConflict] != 1 {
+		t.Fatalf("Expected a conflict, got %+v", first.MergeReport.Days)
+	}
+	if err := menuService.DeleteSchoolLunchMenu(date, "*", models.ChangeContext{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	again := upload()
+	if again.Duplicate || again.ID == first.ID {
+		t.Errorf("Expected a document that applied nothing to be processed again, got %+v", again)
+	}
+	if lunch, err := menuService.GetSchoolLunchForDate(date); err != nil || lunch.MainDish != "カレーライス" {
+		t.Errorf("Expected the menu to be imported, got %v, %v", lunch, err)
+	}
+}
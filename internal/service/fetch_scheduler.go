@@ -0,0 +1,312 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// ErrFetchSourceNotFound is returned when a fetch source is not configured
+var ErrFetchSourceNotFound = errors.New("fetch source not found")
+
+// maxFetchSize bounds how much of a downloaded document is read
+const maxFetchSize = 32 << 20
+
+// fetchActor is recorded as the actor of changes made by downloaded documents
+const fetchActor = "scheduler"
+
+// HTTPDoer sends HTTP requests; *http.Client satisfies it
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// FetchScheduler periodically downloads menu documents from configured URLs
+// and processes each new version. Changes are detected with conditional
+// requests (ETag, Last-Modified) and, for servers that ignore them, by
+// comparing a hash of the content.
+type FetchScheduler struct {
+	processor *DocumentProcessor
+	client    HTTPDoer
+	mu        sync.Mutex
+	sources   map[string]*models.FetchStatus
+	inflight  map[string]chan struct{} // Sources being fetched, by name
+	now       func() time.Time
+}
+
+// NewFetchScheduler creates a scheduler that feeds downloaded documents into processor
+func NewFetchScheduler(processor *DocumentProcessor, client HTTPDoer) *FetchScheduler {
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	return &FetchScheduler{
+		processor: processor,
+		client:    client,
+		sources:   make(map[string]*models.FetchStatus),
+		inflight:  make(map[string]chan struct{}),
+		now:       time.Now,
+	}
+}
+
+// AddSource configures a URL to fetch, replacing any source of the same name.
+// What is known about an existing source's last version is kept when its URL
+// is unchanged.
+func (fs *FetchScheduler) AddSource(source models.FetchSource) error {
+	if err := source.Validate(); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if existing, ok := fs.sources[source.Name]; ok && existing.Source.URL == source.URL {
+		existing.Source = source
+		return nil
+	}
+	fs.sources[source.Name] = &models.FetchStatus{Source: source}
+	return nil
+}
+
+// LoadSources configures the sources listed in a JSON file
+func (fs *FetchScheduler) LoadSources(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read fetch sources: %w", err)
+	}
+	var sources []models.FetchSource
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return fmt.Errorf("failed to parse fetch sources: %w", err)
+	}
+	for _, s := range sources {
+		if err := fs.AddSource(s); err != nil {
+			return fmt.Errorf("fetch source %q: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// Status returns the state of every source in name order
+func (fs *FetchScheduler) Status() []models.FetchStatus {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	statuses := make([]models.FetchStatus, 0, len(fs.sources))
+	for _, s := range fs.sources {
+		statuses = append(statuses, *s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Source.Name < statuses[j].Source.Name })
+	return statuses
+}
+
+// Run checks every source now and then once per interval until ctx is done
+func (fs *FetchScheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, status := range fs.FetchAll(ctx) {
+			if status.Outcome == models.FetchOutcomeError {
+				log.Printf("Fetching %s failed: %s", status.Source.Name, status.Error)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FetchAll checks every source once, in name order
+func (fs *FetchScheduler) FetchAll(ctx context.Context) []models.FetchStatus {
+	var statuses []models.FetchStatus
+	for _, s := range fs.Status() {
+		status, err := fs.Fetch(ctx, s.Source.Name)
+		if err != nil && ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			// The source was removed while fetching the others
+			continue
+		}
+		statuses = append(statuses, *status)
+	}
+	return statuses
+}
+
+// Fetch checks one source and processes its document when it changed. A
+// failed download or processing error is recorded in the returned status;
+// the error result is only for an unknown source or a ctx that is done while
+// waiting. A source is fetched by one caller at a time: a manual fetch during
+// a scheduled one waits for it and then checks against the version it stored.
+func (fs *FetchScheduler) Fetch(ctx context.Context, name string) (*models.FetchStatus, error) {
+	prev, err := fs.claimSource(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Downloading and processing happen without holding fs.mu
+	next := fs.fetch(ctx, prev)
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	close(fs.inflight[name])
+	delete(fs.inflight, name)
+	if current, ok := fs.sources[name]; ok && current.Source.URL == prev.Source.URL {
+		next.Source = current.Source
+		*current = next
+	}
+	return &next, nil
+}
+
+// claimSource marks a source as being fetched and returns its status,
+// waiting while another caller fetches it until ctx is done
+func (fs *FetchScheduler) claimSource(ctx context.Context, name string) (models.FetchStatus, error) {
+	for {
+		fs.mu.Lock()
+		s, ok := fs.sources[name]
+		if !ok {
+			fs.mu.Unlock()
+			return models.FetchStatus{}, fmt.Errorf("%w: %s", ErrFetchSourceNotFound, name)
+		}
+		wait, busy := fs.inflight[name]
+		if !busy {
+			fs.inflight[name] = make(chan struct{})
+			status := *s
+			fs.mu.Unlock()
+			return status, nil
+		}
+		fs.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return models.FetchStatus{}, ctx.Err()
+		}
+	}
+}
+
+// fetch downloads a source and returns its new status
+func (fs *FetchScheduler) fetch(ctx context.Context, status models.FetchStatus) models.FetchStatus {
+	now := fs.now()
+	status.LastCheckedAt = &now
+	status.Error = ""
+
+	fail := func(err error) models.FetchStatus {
+		status.Outcome = models.FetchOutcomeError
+		status.Error = err.Error()
+		return status
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, status.Source.URL, nil)
+	if err != nil {
+		return fail(err)
+	}
+	if status.ETag != "" {
+		req.Header.Set("If-None-Match", status.ETag)
+	}
+	if status.LastModified != "" {
+		req.Header.Set("If-Modified-Since", status.LastModified)
+	}
+
+	resp, err := fs.client.Do(req)
+	if err != nil {
+		return fail(fmt.Errorf("download failed: %w", err))
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		status.Outcome = models.FetchOutcomeUnchanged
+		return status
+	case resp.StatusCode != http.StatusOK:
+		return fail(fmt.Errorf("download failed: %s", resp.Status))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return fail(fmt.Errorf("download failed: %w", err))
+	}
+	if len(data) > maxFetchSize {
+		return fail(fmt.Errorf("document is larger than %d bytes", maxFetchSize))
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if hash == status.ContentHash {
+		status.Outcome = models.FetchOutcomeUnchanged
+		status.ETag, status.LastModified = etag, lastModified
+		return status
+	}
+
+	doc, err := fs.processor.ProcessDocument(fs.processingRequest(status.Source, data, resp.Header.Get("Content-Type"), lastModified))
+	if doc != nil {
+		status.DocumentID = doc.ID
+	}
+	if err != nil {
+		// Validators are not kept, so the next check downloads and retries it
+		return fail(fmt.Errorf("processing failed: %w", err))
+	}
+
+	status.Outcome = models.FetchOutcomeNew
+	status.LastChangedAt = &now
+	status.ContentHash = hash
+	status.ETag, status.LastModified = etag, lastModified
+	return status
+}
+
+// processingRequest builds the request for a downloaded document. The
+// server's Content-Type is kept so that the charset of a page is known, and
+// its Last-Modified date is used as the document's issue date so that
+// newer_document_wins compares publication dates.
+func (fs *FetchScheduler) processingRequest(source models.FetchSource, data []byte, contentType, lastModified string) *models.DocumentProcessingRequest {
+	filename := source.Name
+	if u, err := url.Parse(source.URL); err == nil {
+		if base := path.Base(u.Path); base != "/" && base != "." {
+			filename = base
+		}
+	}
+
+	req := &models.DocumentProcessingRequest{
+		File: &memoryFile{bytes.NewReader(data)},
+		Header: &multipart.FileHeader{
+			Filename: filename,
+			Header:   textproto.MIMEHeader{"Content-Type": {contentType}},
+			Size:     int64(len(data)),
+		},
+		Type:        source.Type,
+		HTMLProfile: source.HTMLProfile,
+		MergePolicy: source.MergePolicy,
+		Actor:       fetchActor,
+	}
+	if req.MergePolicy == "" {
+		req.MergePolicy = models.MergePolicyReplace
+	}
+	if t, err := http.ParseTime(lastModified); err == nil {
+		req.IssuedAt = &t
+	}
+	return req
+}
+
+// memoryFile serves in-memory document bytes as a multipart.File
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (f *memoryFile) Close() error {
+	return nil
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// menuServer serves a menu document that tests can change, counting requests
+type menuServer struct {
+	mu              sync.Mutex
+	body            string
+	etag            string
+	status          int
+	ignoreCondition bool
+	requests        int
+}
+
+func (m *menuServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests++
+	if m.status != 0 {
+		w.WriteHeader(m.status)
+		return
+	}
+	if m.etag != "" {
+		if !m.ignoreCondition && r.Header.Get("If-None-Match") == m.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", m.etag)
+	}
+	w.Header().Set("Last-Modified", "Fri, 10 Jan 2025 09:00:00 GMT")
+	fmt.Fprint(w, m.body)
+}
+
+func (m *menuServer) set(body, etag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.body, m.etag = body, etag
+}
+
+func menuJSON(mainDish string) string {
+	return fmt.Sprintf(`[{"date":"2025-01-14T00:00:00Z","main_dish":%q}]`, mainDish)
+}
+
+func newFetchTest(t *testing.T) (*MenuAdvisorService, *FetchScheduler, *menuServer) {
+	t.Helper()
+	server := &menuServer{}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	menuService := NewMenuAdvisorService()
+	scheduler := NewFetchScheduler(NewDocumentProcessor(menuService), ts.Client())
+	if err := scheduler.AddSource(models.FetchSource{Name: "center", URL: ts.URL + "/kondate/2025-01.json"}); err != nil {
+		t.Fatalf("Failed to add source: %v", err)
+	}
+	return menuService, scheduler, server
+}
+
+func TestFetchDetectsChanges(t *testing.T) {
+	menuService, scheduler, server := newFetchTest(t)
+	ctx := context.Background()
+	date := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+
+	server.set(menuJSON("カレーライス"), `"v1"`)
+	status, err := scheduler.Fetch(ctx, "center")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status.Outcome != models.FetchOutcomeNew || status.ETag != `"v1"` || status.DocumentID == "" {
+		t.Fatalf("Unexpected status: %+v", status)
+	}
+	menu, err := menuService.GetSchoolLunchForDate(date)
+	if err != nil || menu.MainDish != "カレーライス" {
+		t.Fatalf("Expected downloaded menu to be stored, got %+v (%v)", menu, err)
+	}
+	versions, _ := menuService.GetSchoolLunchVersions(date)
+	if versions[0].Actor != fetchActor || versions[0].SourceID != status.DocumentID {
+		t.Errorf("Expected change to be attributed to the scheduler, got %+v", versions[0])
+	}
+
+	// The server answers the conditional request with 304
+	status, _ = scheduler.Fetch(ctx, "center")
+	if status.Outcome != models.FetchOutcomeUnchanged {
+		t.Errorf("Expected unchanged after 304, got %+v", status)
+	}
+
+	// A server that ignores conditional requests is caught by the content hash
+	server.ignoreCondition = true
+	status, _ = scheduler.Fetch(ctx, "center")
+	if status.Outcome != models.FetchOutcomeUnchanged {
+		t.Errorf("Expected unchanged for identical content, got %+v", status)
+	}
+	if n := len(menuService.GetAuditLog(nil)); n != 1 {
+		t.Errorf("Expected unchanged versions not to be processed, got %d audit entries", n)
+	}
+
+	server.set(menuJSON("ハヤシライス"), `"v2"`)
+	status, _ = scheduler.Fetch(ctx, "center")
+	if status.Outcome != models.FetchOutcomeNew || status.ETag != `"v2"` {
+		t.Errorf("Expected new version, got %+v", status)
+	}
+	menu, _ = menuService.GetSchoolLunchForDate(date)
+	if menu.MainDish != "ハヤシライス" {
+		t.Errorf("Expected updated menu, got %+v", menu)
+	}
+}
+
+func TestFetchRecordsErrors(t *testing.T) {
+	_, scheduler, server := newFetchTest(t)
+	ctx := context.Background()
+
+	server.status = http.StatusInternalServerError
+	status, err := scheduler.Fetch(ctx, "center")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status.Outcome != models.FetchOutcomeError || status.Error == "" {
+		t.Errorf("Expected download error to be recorded, got %+v", status)
+	}
+
+	// A document that cannot be processed is retried on the next check
+	server.status = 0
+	server.set("not a menu", `"bad"`)
+	status, _ = scheduler.Fetch(ctx, "center")
+	if status.Outcome != models.FetchOutcomeError || status.ETag != "" {
+		t.Errorf("Expected processing error without keeping the ETag, got %+v", status)
+	}
+	requests := server.requests
+	scheduler.Fetch(ctx, "center")
+	if server.requests != requests+1 {
+		t.Error("Expected the failed version to be downloaded again")
+	}
+
+	if _, err := scheduler.Fetch(ctx, "missing"); !errors.Is(err, ErrFetchSourceNotFound) {
+		t.Errorf("Expected ErrFetchSourceNotFound, got %v", err)
+	}
+}
+
+// gatedDoer holds the first request until released
+type gatedDoer struct {
+	next    HTTPDoer
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g *gatedDoer) Do(req *http.Request) (*http.Response, error) {
+	g.once.Do(func() {
+		close(g.started)
+		<-g.release
+	})
+	return g.next.Do(req)
+}
+
+func TestFetchOneSourceAtATime(t *testing.T) {
+	menuService, scheduler, server := newFetchTest(t)
+	server.set(menuJSON("カレーライス"), `"v1"`)
+	gate := &gatedDoer{next: scheduler.client, started: make(chan struct{}), release: make(chan struct{})}
+	scheduler.client = gate
+
+	// A manual fetch arrives while the scheduled one is downloading
+	results := make(chan *models.FetchStatus, 2)
+	fetch := func() {
+		status, err := scheduler.Fetch(context.Background(), "center")
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		results <- status
+	}
+	go fetch()
+	<-gate.started
+	go fetch()
+
+	time.Sleep(20 * time.Millisecond)
+	server.mu.Lock()
+	requests := server.requests
+	server.mu.Unlock()
+	if requests != 0 {
+		t.Errorf("Expected the second fetch to wait for the first, got %d requests", requests)
+	}
+	close(gate.release)
+
+	outcomes := map[models.FetchOutcome]int{}
+	for i := 0; i < 2; i++ {
+		outcomes[(<-results).Outcome]++
+	}
+	if outcomes[models.FetchOutcomeNew] != 1 || outcomes[models.FetchOutcomeUnchanged] != 1 {
+		t.Errorf("Expected one new and one unchanged outcome, got %v", outcomes)
+	}
+	if n := len(menuService.GetAuditLog(nil)); n != 1 {
+		t.Errorf("Expected the document to be processed once, got %d audit entries", n)
+	}
+}
+
+func TestFetchUsesLastModifiedAsIssueDate(t *testing.T) {
+	_, scheduler, server := newFetchTest(t)
+	server.set(menuJSON("カレーライス"), "")
+
+	status, _ := scheduler.Fetch(context.Background(), "center")
+	doc, ok := scheduler.processor.GetDocument(status.DocumentID)
+	if !ok {
+		t.Fatalf("Expected document %s to be registered", status.DocumentID)
+	}
+	if doc.OriginalName != "2025-01.json" {
+		t.Errorf("Expected file name from the URL, got %s", doc.OriginalName)
+	}
+	if doc.IssuedAt == nil || !doc.IssuedAt.Equal(time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected Last-Modified as issue date, got %v", doc.IssuedAt)
+	}
+}
+
+func TestRunFetchesUntilCancelled(t *testing.T) {
+	_, scheduler, server := newFetchTest(t)
+	server.set(menuJSON("カレーライス"), `"v1"`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		server.mu.Lock()
+		n := server.requests
+		server.mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the scheduler to check the source repeatedly")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestAddSourceValidation(t *testing.T) {
+	scheduler := NewFetchScheduler(NewDocumentProcessor(NewMenuAdvisorService()), nil)
+	err := scheduler.AddSource(models.FetchSource{URL: "file:///etc/passwd", MergePolicy: "overwrite"})
+	var verrs models.ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 3 {
+		t.Errorf("Expected name, url and merge policy errors, got %v", err)
+	}
+
+	err = scheduler.AddSource(models.FetchSource{Name: "center", URL: "https://example.jp/kondate.pdf", Type: "pdf"})
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Field != "type" {
+		t.Errorf("Expected a type error, got %v", err)
+	}
+	err = scheduler.AddSource(models.FetchSource{Name: "center", URL: "https://example.jp/kondate.pdf", Type: models.DocumentTypePDFText, HTMLProfile: "minato"})
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Field != "html_profile" {
+		t.Errorf("Expected an html_profile error, got %v", err)
+	}
+}
+
+func TestFetchWaitingForAnotherStopsWithContext(t *testing.T) {
+	_, scheduler, server := newFetchTest(t)
+	server.set(menuJSON("カレーライス"), `"v1"`)
+	gate := &gatedDoer{next: scheduler.client, started: make(chan struct{}), release: make(chan struct{})}
+	scheduler.client = gate
+	defer close(gate.release)
+
+	go scheduler.Fetch(context.Background(), "center")
+	<-gate.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	status, err := scheduler.Fetch(ctx, "center")
+	if !errors.Is(err, context.DeadlineExceeded) || status != nil {
+		t.Errorf("Expected the waiting fetch to stop with its context, got %+v (%v)", status, err)
+	}
+}
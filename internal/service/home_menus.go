@@ -0,0 +1,186 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// homeMenu is one home meal the recommender can suggest
+type homeMenu struct {
+	main  string
+	sides []string
+	soup  string
+}
+
+// dishes returns the names of the dishes of the menu
+func (m homeMenu) dishes() []string {
+	dishes := append([]string{m.main}, m.sides...)
+	if m.soup != "" {
+		dishes = append(dishes, m.soup)
+	}
+	return dishes
+}
+
+// quickMenus are suggested when none of the menus of a recommender rule fits
+// the household's time budget, kitchen or skill. They take little time and
+// need at most a stove, or only a microwave and a rice cooker.
+var quickMenus = map[models.MealType][]homeMenu{
+	models.MealTypeBreakfast: {
+		{main: "納豆", sides: []string{"野菜サラダ"}},
+		{main: "冷奴", sides: []string{"温野菜"}},
+	},
+	models.MealTypeDinner: {
+		{main: "豚しゃぶしゃぶ", sides: []string{"野菜サラダ", "白米"}},
+		{main: "冷奴", sides: []string{"温野菜", "小松菜としらすのおひたし", "白米"}},
+	},
+}
+
+// kitchen is what limits the home menus the household can cook for a meal
+type kitchen struct {
+	household models.Household
+	mealType  models.MealType
+	budget    *models.TimeBudget
+}
+
+// limited tells whether the household restricts the time, equipment or
+// skill of cooking the meal
+func (k kitchen) limited() bool {
+	return k.budget != nil || len(k.household.Equipment) > 0 || k.household.Skill != ""
+}
+
+// kitchenFor returns the limits on cooking the meal on date
+func (s *MenuAdvisorService) kitchenFor(date time.Time, mealType models.MealType) kitchen {
+	household := s.Household()
+	k := kitchen{household: household, mealType: mealType}
+	if budget, ok := household.TimeBudget(date, mealType); ok {
+		k.budget = &budget
+	}
+	return k
+}
+
+// cookingMinutes returns the hands-on and start-to-serve minutes of cooking
+// dishes together. One cook does the hands-on parts one after another while
+// other dishes simmer, so the meal takes the longer of the hands-on time and
+// the longest dish. Rice counts only its hands-on time, as the rice cooker's
+// timer has it ready by the meal. Dishes without a recipe are not counted.
+func (s *MenuAdvisorService) cookingMinutes(dishes []string) (active, total int) {
+	for _, dish := range dishes {
+		recipe, ok := s.recipes.Find(dish)
+		if !ok {
+			continue
+		}
+		active += recipe.ActiveMinutes
+		if slices.Equal(recipe.Equipment, []models.Equipment{models.EquipmentRiceCooker}) {
+			total = max(total, recipe.ActiveMinutes)
+		} else {
+			total = max(total, recipe.TotalMinutes())
+		}
+	}
+	return active, max(active, total)
+}
+
+// misfits returns the conditions of the kitchen that cooking the dishes
+// breaks, worded for a suggestion's reason; none when the household can
+// cook them. A dish without a recipe breaks any limit of the kitchen, as its
+// time and equipment are unknown.
+func (s *MenuAdvisorService) misfits(dishes []string, k kitchen) []string {
+	var notes []string
+	if k.budget != nil {
+		active, total := s.cookingMinutes(dishes)
+		meal := k.budget.DaysLabel() + "の" + k.mealType.Label()
+		if k.budget.MaxMinutes > 0 && total > k.budget.MaxMinutes {
+			notes = append(notes, fmt.Sprintf("%sは%d分以内で作る", meal, k.budget.MaxMinutes))
+		}
+		if k.budget.MaxActiveMinutes > 0 && active > k.budget.MaxActiveMinutes {
+			notes = append(notes, fmt.Sprintf("%sは手を動かす時間を%d分以内にする", meal, k.budget.MaxActiveMinutes))
+		}
+	}
+
+	var missing []string
+	hard, unknown := false, false
+	for _, dish := range dishes {
+		recipe, ok := s.recipes.Find(dish)
+		if !ok {
+			unknown = true
+			continue
+		}
+		for _, e := range recipe.Equipment {
+			if !k.household.HasEquipment(e) && !slices.Contains(missing, e.Label()) {
+				missing = append(missing, e.Label())
+			}
+		}
+		hard = hard || !k.household.Skill.Covers(recipe.Skill)
+	}
+	if len(missing) > 0 {
+		notes = append(notes, strings.Join(missing, "・")+"がない")
+	}
+	if hard {
+		notes = append(notes, "手順の難しい料理は作らない")
+	}
+	if unknown && k.limited() {
+		notes = append(notes, "作り方の分からない料理は作らない")
+	}
+	return notes
+}
+
+// fitting returns the menus the household can cook
+func (s *MenuAdvisorService) fitting(menus []homeMenu, k kitchen) []homeMenu {
+	var fit []homeMenu
+	for _, menu := range menus {
+		if len(s.misfits(menu.dishes(), k)) == 0 {
+			fit = append(fit, menu)
+		}
+	}
+	return fit
+}
+
+// chooseHomeMenu fills in the suggestion with the first of the menus a
+// recommender rule offers that the household can cook in its time budget,
+// kitchen and skill, falling back to quick menus when none fits. Another
+// menu is chosen when it makes better use of the pantry: ingredients about
+// to expire count for a menu, and ingredients the pantry lacks against it.
+func (s *MenuAdvisorService) chooseHomeMenu(suggestion *models.HomeMenuSuggestion, reason string, menus []homeMenu) {
+	k := s.kitchenFor(suggestion.Date, suggestion.MealType)
+	candidates := s.fitting(menus, k)
+	if notes := s.misfits(menus[0].dishes(), k); len(notes) > 0 {
+		conditions := strings.Join(notes, "、")
+		if len(candidates) > 0 {
+			reason += "。" + conditions + "ため、条件に合う献立を選択"
+		} else if candidates = s.fitting(quickMenus[suggestion.MealType], k); len(candidates) > 0 {
+			reason = conditions + "ため、手軽に作れる献立を提案"
+		} else {
+			candidates = menus
+			reason += "。" + conditions + "という条件に合う献立はない"
+		}
+	}
+
+	best, bestUse := candidates[0], pantryUse{}
+	if pantry := s.Pantry(); len(pantry) > 0 {
+		for i, menu := range candidates {
+			use := s.pantryUse(menu, pantry, suggestion.Date)
+			if i == 0 || use.score() > bestUse.score() {
+				best, bestUse = menu, use
+			}
+		}
+	}
+
+	suggestion.MainDish = best.main
+	suggestion.SideDishes = slices.Clone(best.sides)
+	suggestion.Soup = best.soup
+	suggestion.Reason = reason
+	if len(bestUse.expiring) > 0 {
+		suggestion.Reason += "。" + strings.Join(bestUse.expiring, "。")
+	}
+}
+
+// attachCookingTime adds how long the suggested dishes take to cook and the
+// household's time budget for the meal
+func (s *MenuAdvisorService) attachCookingTime(suggestion *models.HomeMenuSuggestion) {
+	menu := homeMenu{main: suggestion.MainDish, sides: suggestion.SideDishes, soup: suggestion.Soup}
+	suggestion.ActiveMinutes, suggestion.TotalMinutes = s.cookingMinutes(menu.dishes())
+	suggestion.TimeBudget = s.kitchenFor(suggestion.Date, suggestion.MealType).budget
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestCookingMinutes(t *testing.T) {
+	service := NewMenuAdvisorService()
+
+	// One cook: 8 minutes on the omelette and 3 on the soup, which simmers
+	// for 7 minutes in all
+	if active, total := service.cookingMinutes([]string{"卵焼き", "のり", "みそ汁"}); active != 11 || total != 11 {
+		t.Errorf("Expected 11/11 minutes, got %d/%d", active, total)
+	}
+	// Simmered fish takes 20 minutes with 8 of them hands-on; rice is on a timer
+	if active, total := service.cookingMinutes([]string{"魚の煮付け", "白米"}); active != 13 || total != 20 {
+		t.Errorf("Expected 13/20 minutes, got %d/%d", active, total)
+	}
+
+	recipe, _ := service.Recipe("chikuzenni", 1)
+	if recipe.ActiveMinutes != 22 || recipe.TotalMinutes() != 35 || recipe.Skill != models.SkillIntermediate {
+		t.Errorf("Unexpected recipe times: %+v", recipe)
+	}
+
+	// Recipes without active minutes are all hands-on
+	book := NewRecipeBook(service.Dishes())
+	quick := models.Recipe{DishID: "natto", Ingredients: []models.Ingredient{{Name: "納豆", Quantity: 1, Unit: "パック"}}, Steps: []string{"混ぜる"}, PrepMinutes: 2}
+	if err := book.Add(quick); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if added, _ := book.Get("natto"); added.ActiveMinutes != 2 {
+		t.Errorf("Expected 2 active minutes, got %d", added.ActiveMinutes)
+	}
+	quick.ActiveMinutes = 5
+	var verrs models.ValidationErrors
+	if err := book.Add(quick); !errors.As(err, &verrs) {
+		t.Errorf("Expected a validation error for more active than total minutes, got %v", err)
+	}
+}
+
+func TestSuggestionTimeBudget(t *testing.T) {
+	service := NewMenuAdvisorService()
+	household := models.Household{
+		Size: 4,
+		TimeBudgets: []models.TimeBudget{
+			{Days: []models.Weekday{"mon", "tue", "wed", "thu", "fri"}, MealType: models.MealTypeBreakfast, MaxMinutes: 10},
+			{MealType: models.MealTypeDinner, MaxMinutes: 30},
+		},
+	}
+	if err := service.SetHousehold(household); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	monday := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	saturday := time.Date(2025, 1, 18, 0, 0, 0, 0, time.UTC)
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: monday, MainDish: "鶏肉の照り焼き"})
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: saturday, MainDish: "鶏肉の照り焼き"})
+
+	// The grilled horse mackerel takes 22 minutes, too long for a weekday
+	breakfast, err := service.GenerateHomeMenuSuggestion(monday, models.MealTypeBreakfast)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if breakfast.MainDish == "焼き魚（アジ）" || breakfast.TotalMinutes > 10 || breakfast.TimeBudget == nil {
+		t.Errorf("Expected a breakfast within 10 minutes, got %+v", breakfast)
+	}
+	if !strings.Contains(breakfast.Reason, "平日の朝食は10分以内で作るため") {
+		t.Errorf("Unexpected reason: %s", breakfast.Reason)
+	}
+	// The side dish for the lunch's calcium would take too long
+	if slices.Contains(breakfast.SideDishes, "小松菜としらすのおひたし") || !strings.Contains(breakfast.Reason, "小松菜としらすのおひたしは加えない") {
+		t.Errorf("Expected the calcium side dish to be left out, got %+v", breakfast)
+	}
+
+	breakfast, _ = service.GenerateHomeMenuSuggestion(saturday, models.MealTypeBreakfast)
+	if breakfast.MainDish != "焼き魚（アジ）" || breakfast.TimeBudget != nil {
+		t.Errorf("Expected no limit on Saturday, got %+v", breakfast)
+	}
+
+	// Vegetable tempura makes the simmered fish dinner 41 minutes
+	dinner, _ := service.GenerateHomeMenuSuggestion(monday, models.MealTypeDinner)
+	if slices.Contains(dinner.SideDishes, "野菜の天ぷら") || dinner.TotalMinutes > 30 || dinner.ActiveMinutes == 0 {
+		t.Errorf("Expected a dinner within 30 minutes, got %+v", dinner)
+	}
+	for _, recipe := range dinner.Recipes {
+		if recipe.ActiveMinutes == 0 || recipe.ActiveMinutes > recipe.TotalMinutes {
+			t.Errorf("Unexpected recipe times: %+v", recipe)
+		}
+	}
+}
+
+func TestSuggestionKitchen(t *testing.T) {
+	service := NewMenuAdvisorService()
+	household := models.Household{
+		Size:      2,
+		Equipment: []models.Equipment{models.EquipmentMicrowave, models.EquipmentRiceCooker},
+		Skill:     models.SkillBasic,
+	}
+	if err := service.SetHousehold(household); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	mains := []string{"鶏肉の照り焼き", "魚のフライ", "豚肉の生姜焼き", "カレーライス", "麻婆豆腐"}
+	for i, main := range mains {
+		date := time.Date(2025, 2, 3+i, 0, 0, 0, 0, time.UTC)
+		service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: main})
+		for _, mealType := range models.MealTypes {
+			suggestion, err := service.GenerateHomeMenuSuggestion(date, mealType)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !strings.Contains(suggestion.Reason, "手軽に作れる献立を提案") {
+				t.Errorf("%s %s: unexpected reason %s", main, mealType, suggestion.Reason)
+			}
+			for _, recipe := range suggestion.Recipes {
+				full, _ := service.Recipes().Get(recipe.DishID)
+				for _, e := range recipe.Equipment {
+					if !slices.Contains(household.Equipment, e) {
+						t.Errorf("%s %s: %s needs %s", main, mealType, recipe.Name, e)
+					}
+				}
+				if !household.Skill.Covers(full.Skill) {
+					t.Errorf("%s %s: %s is %s", main, mealType, recipe.Name, full.Skill)
+				}
+			}
+		}
+	}
+
+	var verrs models.ValidationErrors
+	invalid := []models.Household{
+		{Equipment: []models.Equipment{"pressure_cooker"}},
+		{Skill: "expert"},
+		{TimeBudgets: []models.TimeBudget{{Days: []models.Weekday{"monday"}, MealType: models.MealTypeBreakfast, MaxMinutes: 10}}},
+		{TimeBudgets: []models.TimeBudget{{MealType: models.MealTypeDinner}}},
+		{TimeBudgets: []models.TimeBudget{{MealType: "lunch", MaxMinutes: 10}}},
+	}
+	for _, h := range invalid {
+		if err := service.SetHousehold(h); !errors.As(err, &verrs) {
+			t.Errorf("Expected a validation error for %+v, got %v", h, err)
+		}
+	}
+}
+
+func TestSuggestionDishWithoutRecipe(t *testing.T) {
+	service := NewMenuAdvisorService()
+	date := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: "魚のフライ"})
+
+	// The nori has no recipe, which matters only when the kitchen has limits
+	breakfast, err := service.GenerateHomeMenuSuggestion(date, models.MealTypeBreakfast)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Contains(breakfast.SideDishes, "のり") {
+		t.Errorf("Expected the first menu without limits, got %+v", breakfast)
+	}
+
+	household := models.Household{
+		Size:        2,
+		TimeBudgets: []models.TimeBudget{{MealType: models.MealTypeBreakfast, MaxMinutes: 60}},
+	}
+	if err := service.SetHousehold(household); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	breakfast, _ = service.GenerateHomeMenuSuggestion(date, models.MealTypeBreakfast)
+	if slices.Contains(breakfast.SideDishes, "のり") || breakfast.MainDish != "卵焼き" {
+		t.Errorf("Expected another egg menu, got %+v", breakfast)
+	}
+	if !strings.Contains(breakfast.Reason, "作り方の分からない料理は作らないため、条件に合う献立を選択") {
+		t.Errorf("Unexpected reason: %s", breakfast.Reason)
+	}
+}
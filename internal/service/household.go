@@ -0,0 +1,169 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+var (
+	// ErrChildNotFound is returned for a child_id the household does not have
+	ErrChildNotFound = errors.New("child not found")
+	// ErrHomeMealNotFound is returned when an accepted home meal does not exist
+	ErrHomeMealNotFound = errors.New("home meal not found")
+)
+
+// Household returns the family the home meals are cooked for
+func (s *MenuAdvisorService) Household() models.Household {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	household := s.household
+	household.Children = append([]models.Child(nil), s.household.Children...)
+	return household
+}
+
+// SetHousehold replaces the household
+func (s *MenuAdvisorService) SetHousehold(household models.Household) error {
+	if err := household.Validate(); err != nil {
+		return err
+	}
+	if household.Children == nil {
+		household.Children = []models.Child{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.household = household
+	return nil
+}
+
+// LoadHousehold reads the household from a JSON file
+func (s *MenuAdvisorService) LoadHousehold(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read household: %w", err)
+	}
+	var household models.Household
+	if err := json.Unmarshal(data, &household); err != nil {
+		return fmt.Errorf("failed to parse household: %w", err)
+	}
+	return s.SetHousehold(household)
+}
+
+// householdServings returns the number of servings home meals are cooked
+// in: the household size, or one per child when the size is not set
+func (s *MenuAdvisorService) householdServings() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.household.Size > 0 {
+		return s.household.Size
+	}
+	return max(len(s.household.Children), 1)
+}
+
+// childGrade returns the grade band of the child with childID, or grade
+// when no child is given
+func (s *MenuAdvisorService) childGrade(childID string, grade models.GradeBand) (models.GradeBand, error) {
+	if childID == "" {
+		return models.ParseGradeBand(string(grade))
+	}
+	s.mu.RLock()
+	child, ok := s.household.Child(childID)
+	s.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrChildNotFound, childID)
+	}
+	return child.Grade, nil
+}
+
+// AcceptHomeMeal records a home meal the household will cook. A meal already
+// accepted for the same date and meal type is replaced, keeping its ID, and
+// replaced reports whether that happened.
+func (s *MenuAdvisorService) AcceptHomeMeal(meal models.HomeMeal) (accepted *models.HomeMeal, replaced bool, err error) {
+	if err := meal.Validate(); err != nil {
+		return nil, false, err
+	}
+	meal.AcceptedAt = s.now()
+	if meal.SideDishes == nil {
+		meal.SideDishes = []string{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.homeMeals {
+		if dateKey(existing.Date) == dateKey(meal.Date) && existing.MealType == meal.MealType {
+			meal.ID = existing.ID
+			s.homeMeals[i] = meal
+			return &meal, true, nil
+		}
+	}
+	meal.ID = generateHomeMealID()
+	s.homeMeals = append(s.homeMeals, meal)
+	return &meal, false, nil
+}
+
+// AcceptHomeMenuSuggestion accepts the suggestion for date and mealType as
+// the household's home meal, replacing one already accepted as AcceptHomeMeal
+// does
+func (s *MenuAdvisorService) AcceptHomeMenuSuggestion(date time.Time, mealType models.MealType, opts SuggestionOptions) (accepted *models.HomeMeal, replaced bool, err error) {
+	suggestion, err := s.GenerateHomeMenuSuggestionWithOptions(date, mealType, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return s.AcceptHomeMeal(models.HomeMeal{
+		Date:       suggestion.Date,
+		MealType:   suggestion.MealType,
+		MainDish:   suggestion.MainDish,
+		SideDishes: suggestion.SideDishes,
+		Soup:       suggestion.Soup,
+	})
+}
+
+// HomeMeals returns the accepted home meals from from to to inclusive, by
+// date and then breakfast before dinner
+func (s *MenuAdvisorService) HomeMeals(from, to time.Time) []models.HomeMeal {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	meals := []models.HomeMeal{}
+	for _, meal := range s.homeMeals {
+		if day := dateKey(meal.Date); dateKey(from) <= day && day <= dateKey(to) {
+			meals = append(meals, meal)
+		}
+	}
+	sort.SliceStable(meals, func(i, j int) bool {
+		if a, b := dateKey(meals[i].Date), dateKey(meals[j].Date); a != b {
+			return a < b
+		}
+		return meals[i].MealType == models.MealTypeBreakfast && meals[j].MealType != models.MealTypeBreakfast
+	})
+	return meals
+}
+
+// HomeMeal returns the accepted home meal with id
+func (s *MenuAdvisorService) HomeMeal(id string) (*models.HomeMeal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, meal := range s.homeMeals {
+		if meal.ID == id {
+			return &meal, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrHomeMealNotFound, id)
+}
+
+// DeleteHomeMeal removes an accepted home meal
+func (s *MenuAdvisorService) DeleteHomeMeal(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, meal := range s.homeMeals {
+		if meal.ID == id {
+			s.homeMeals = append(s.homeMeals[:i], s.homeMeals[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrHomeMealNotFound, id)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestSetHousehold(t *testing.T) {
+	service := NewMenuAdvisorService()
+	invalid := []models.Household{
+		{Children: []models.Child{{ID: "taro"}}},
+		{Children: []models.Child{{ID: "taro", Grade: "kindergarten"}}},
+		{Children: []models.Child{{ID: "taro", Grade: models.GradeElementaryLow}, {ID: "taro", Grade: models.GradeJuniorHigh}}},
+		{Size: 1, Children: []models.Child{{ID: "a", Grade: models.GradeElementaryLow}, {ID: "b", Grade: models.GradeElementaryLow}}},
+	}
+	for _, household := range invalid {
+		var verrs models.ValidationErrors
+		if err := service.SetHousehold(household); !errors.As(err, &verrs) {
+			t.Errorf("Expected a validation error for %+v, got %v", household, err)
+		}
+	}
+
+	if err := service.SetHousehold(models.Household{Size: 3, Children: []models.Child{{ID: "taro", Grade: models.GradeJuniorHigh}}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if grade, err := service.childGrade("taro", models.GradeElementaryLow); err != nil || grade != models.GradeJuniorHigh {
+		t.Errorf("Expected the child's grade, got %q, %v", grade, err)
+	}
+	if _, err := service.childGrade("jiro", ""); !errors.Is(err, ErrChildNotFound) {
+		t.Errorf("Expected ErrChildNotFound, got %v", err)
+	}
+}
+
+func TestAcceptHomeMeal(t *testing.T) {
+	service := NewMenuAdvisorService()
+	date := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照り焼き", SideDishes: []string{"白米"}})
+
+	if _, _, err := service.AcceptHomeMeal(models.HomeMeal{Date: date, MealType: "lunch"}); err == nil {
+		t.Error("Expected an invalid meal to be refused")
+	}
+
+	dinner, replaced, err := service.AcceptHomeMenuSuggestion(date, models.MealTypeDinner, SuggestionOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if dinner.MainDish != "魚の煮付け" || dinner.ID == "" || replaced {
+		t.Errorf("Expected the dinner suggestion to be accepted, got %+v", dinner)
+	}
+	breakfast, _, _ := service.AcceptHomeMeal(models.HomeMeal{Date: date, MealType: models.MealTypeBreakfast, MainDish: "納豆"})
+
+	// Accepting another dinner for the same day replaces the first
+	again, replaced, _ := service.AcceptHomeMeal(models.HomeMeal{Date: date, MealType: models.MealTypeDinner, MainDish: "ハンバーグ"})
+	meals := service.HomeMeals(date, date)
+	if again.ID != dinner.ID || !replaced || len(meals) != 2 || meals[0].ID != breakfast.ID || meals[1].MainDish != "ハンバーグ" {
+		t.Errorf("Unexpected meals: %+v", meals)
+	}
+	if meal, err := service.HomeMeal(dinner.ID); err != nil || meal.MainDish != "ハンバーグ" {
+		t.Errorf("Unexpected meal: %+v, %v", meal, err)
+	}
+
+	if err := service.DeleteHomeMeal(breakfast.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := service.DeleteHomeMeal(breakfast.ID); !errors.Is(err, ErrHomeMealNotFound) {
+		t.Errorf("Expected ErrHomeMealNotFound, got %v", err)
+	}
+	if _, err := service.HomeMeal(breakfast.ID); !errors.Is(err, ErrHomeMealNotFound) {
+		t.Errorf("Expected ErrHomeMealNotFound, got %v", err)
+	}
+}
+
+func TestDailyNutrition(t *testing.T) {
+	service := NewMenuAdvisorService()
+	date := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{
+		Date: date, MainDish: "鶏肉の照り焼き", SideDishes: []string{"白米", "牛乳"},
+		Nutrition: models.Nutrition{Calories: 600},
+	})
+	service.AcceptHomeMeal(models.HomeMeal{Date: date, MealType: models.MealTypeDinner, MainDish: "鯖の塩焼き", SideDishes: []string{"白米", "謎の料理"}})
+	service.AcceptHomeMeal(models.HomeMeal{Date: date, MealType: models.MealTypeBreakfast, MainDish: "パン", Nutrition: &models.Nutrition{Calories: 400}})
+
+	day, err := service.DailyNutrition(date, "", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(day.Meals) != 3 || day.Meals[0].Meal != "breakfast" || day.Meals[1].Meal != "lunch" || day.Meals[2].Meal != "dinner" {
+		t.Fatalf("Expected breakfast, lunch and dinner in order, got %+v", day.Meals)
+	}
+	// 400 given + 600 printed + 170 + 234 estimated
+	if day.Total.Calories != 1404 || day.Meals[0].Source != models.NutritionPublished {
+		t.Errorf("Unexpected total: %+v", day.Total)
+	}
+	if len(day.Meals[2].Unmatched) != 1 {
+		t.Errorf("Expected the unknown dish to be reported, got %+v", day.Meals[2])
+	}
+	statuses := make(map[string]models.NutrientTotal)
+	for _, n := range day.Nutrients {
+		statuses[n.Key] = n
+	}
+	if c := statuses["calories"]; c.Target != 1750 || c.Percent != 80 || c.Status != models.NutrientOK || c.Source != models.NutritionMixed {
+		t.Errorf("Unexpected calories: %+v", c)
+	}
+	// No meal gives vitamin C, and the breakfast gives nothing but calories
+	for _, key := range []string{"vitamin_c_mg", "protein_g"} {
+		if n := statuses[key]; n.Status != models.NutrientUnknown || day.Sources[key] != "" {
+			t.Errorf("Expected %s to be unknown, got %+v", key, n)
+		}
+	}
+	if day.Meals[2].Sources["protein_g"] != models.NutritionEstimated || day.Meals[0].Sources["calories"] != models.NutritionPublished {
+		t.Errorf("Unexpected meal sources: %v and %v", day.Meals[0].Sources, day.Meals[2].Sources)
+	}
+
+	// Larger portions for junior high, apart from the given breakfast
+	junior, _ := service.DailyNutrition(date, "", models.GradeJuniorHigh)
+	if junior.Total.Calories <= day.Total.Calories || junior.Target.Calories != 2500 {
+		t.Errorf("Unexpected junior high total: %+v", junior.Total)
+	}
+	if _, err := service.DailyNutrition(date, "nobody", ""); !errors.Is(err, ErrChildNotFound) {
+		t.Errorf("Expected ErrChildNotFound, got %v", err)
+	}
+}
+
+func TestWeeklyNutrition(t *testing.T) {
+	service := NewMenuAdvisorService()
+	// Monday 13 and Wednesday 15 January 2025
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC), MainDish: "カレーライス", Nutrition: models.Nutrition{Sodium: 3000}})
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), MainDish: "焼き魚", Nutrition: models.Nutrition{Sodium: 2000}})
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), MainDish: "焼き魚", Nutrition: models.Nutrition{Sodium: 5000}})
+
+	week, err := service.WeeklyNutrition(time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC), "", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if week.From != "2025-01-13" || week.To != "2025-01-19" || len(week.Days) != 7 {
+		t.Fatalf("Expected the week from Monday to Sunday, got %s to %s with %d days", week.From, week.To, len(week.Days))
+	}
+	if week.Total.Sodium != 5000 || week.Target.Sodium != 1970*7 {
+		t.Errorf("Unexpected sodium: %v of %v", week.Total.Sodium, week.Target.Sodium)
+	}
+	for _, n := range week.Nutrients {
+		// Only the two days with lunch count, so 5000mg is above two days' limit
+		if n.Key == "sodium_mg" && (n.Status != models.NutrientOver || n.Target != 1970*2) {
+			t.Errorf("Unexpected sodium status: %+v", n)
+		}
+		// Nothing gives vitamin C, and days without meals do not count as eating none
+		if n.Key == "vitamin_c_mg" && n.Status != models.NutrientUnknown {
+			t.Errorf("Expected vitamin C to be unknown, got %+v", n)
+		}
+	}
+	if sunday := week.Days[6]; sunday.Sources["sodium_mg"] != "" || sunday.Nutrients[0].Status != models.NutrientUnknown {
+		t.Errorf("Expected nothing to be known of a day without meals, got %+v", sunday)
+	}
+
+	day, _ := service.DailyNutrition(time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC), "", "")
+	for _, n := range day.Nutrients {
+		if n.Key == "sodium_mg" && n.Status != models.NutrientOver {
+			t.Errorf("Expected sodium above the limit to be over, got %+v", n)
+		}
+	}
+}
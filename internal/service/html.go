@@ -0,0 +1,353 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// htmlNode is an element or text node of a parsed HTML page
+type htmlNode struct {
+	Tag      string // Lower-case element name; empty for text
+	Attrs    map[string]string
+	Text     string
+	Parent   *htmlNode
+	Children []*htmlNode
+}
+
+var (
+	// htmlVoidElements never have content or an end tag
+	htmlVoidElements = map[string]bool{
+		"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+		"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+	}
+	// htmlRawTextElements hold text that is not markup and not shown
+	htmlRawTextElements = map[string]bool{"script": true, "style": true}
+	// htmlImpliedEnd lists elements closed by the start of a sibling, and the
+	// elements that bound the search for the open one
+	htmlImpliedEnd = map[string]struct {
+		closes []string
+		scope  []string
+	}{
+		"li": {[]string{"li"}, []string{"ul", "ol"}},
+		"dt": {[]string{"dt", "dd"}, []string{"dl"}},
+		"dd": {[]string{"dt", "dd"}, []string{"dl"}},
+		"tr": {[]string{"tr"}, []string{"table", "thead", "tbody", "tfoot"}},
+		"td": {[]string{"td", "th"}, []string{"tr", "table"}},
+		"th": {[]string{"td", "th"}, []string{"tr", "table"}},
+		"p":  {[]string{"p"}, []string{"div", "td", "th", "li", "body"}},
+	}
+	// htmlBlockElements start a new line in extracted text
+	htmlBlockElements = map[string]bool{
+		"address": true, "article": true, "aside": true, "blockquote": true, "dd": true, "div": true,
+		"dl": true, "dt": true, "footer": true, "h1": true, "h2": true, "h3": true, "h4": true,
+		"h5": true, "h6": true, "header": true, "li": true, "ol": true, "p": true, "section": true,
+		"table": true, "td": true, "th": true, "tr": true, "ul": true,
+	}
+)
+
+// parseHTML builds a tree from an HTML page. It is forgiving in the way
+// browsers are: tag names are case-insensitive, end tags may be omitted where
+// HTML allows it, and stray end tags are ignored. It never fails on markup.
+func parseHTML(src string) *htmlNode {
+	root := &htmlNode{Tag: "#document"}
+	cur := root
+	appendText := func(text string) {
+		if text == "" {
+			return
+		}
+		cur.Children = append(cur.Children, &htmlNode{Text: html.UnescapeString(text), Parent: cur})
+	}
+
+	for len(src) > 0 {
+		lt := strings.IndexByte(src, '<')
+		if lt < 0 {
+			appendText(src)
+			break
+		}
+		appendText(src[:lt])
+		src = src[lt:]
+
+		switch {
+		case strings.HasPrefix(src, "<!--"):
+			end := strings.Index(src, "-->")
+			if end < 0 {
+				return root
+			}
+			src = src[end+3:]
+		case strings.HasPrefix(src, "<!"), strings.HasPrefix(src, "<?"):
+			end := strings.IndexByte(src, '>')
+			if end < 0 {
+				return root
+			}
+			src = src[end+1:]
+		case strings.HasPrefix(src, "</"):
+			end := strings.IndexByte(src, '>')
+			if end < 0 {
+				return root
+			}
+			name := strings.ToLower(strings.TrimSpace(src[2:end]))
+			src = src[end+1:]
+			// Close the nearest open element of that name, ignoring stray end tags
+			for n := cur; n != root; n = n.Parent {
+				if n.Tag == name {
+					cur = n.Parent
+					break
+				}
+			}
+		default:
+			name, attrs, rest, ok := parseHTMLStartTag(src)
+			if !ok {
+				appendText("<")
+				src = src[1:]
+				continue
+			}
+			src = rest
+			cur = closeImpliedElements(cur, name)
+			n := &htmlNode{Tag: name, Attrs: attrs, Parent: cur}
+			cur.Children = append(cur.Children, n)
+			if htmlVoidElements[name] {
+				continue
+			}
+			if htmlRawTextElements[name] {
+				end := strings.Index(strings.ToLower(src), "</"+name)
+				if end < 0 {
+					end = len(src)
+				}
+				src = src[end:]
+				continue
+			}
+			cur = n
+		}
+	}
+	return root
+}
+
+var (
+	htmlStartTagPattern = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9:-]*)((?:\s+[^\s/>=]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s>]+))?)*)\s*/?>`)
+	htmlAttrPattern     = regexp.MustCompile(`([^\s/>=]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+)))?`)
+)
+
+// parseHTMLStartTag reads "<name attr=value ...>" at the start of src
+func parseHTMLStartTag(src string) (name string, attrs map[string]string, rest string, ok bool) {
+	m := htmlStartTagPattern.FindStringSubmatch(src)
+	if m == nil {
+		return "", nil, src, false
+	}
+	attrs = map[string]string{}
+	for _, a := range htmlAttrPattern.FindAllStringSubmatch(m[2], -1) {
+		attrs[strings.ToLower(a[1])] = html.UnescapeString(a[2] + a[3] + a[4])
+	}
+	return strings.ToLower(m[1]), attrs, src[len(m[0]):], true
+}
+
+// closeImpliedElements closes the elements that the start of name ends, such
+// as an open <li> when the next <li> begins
+func closeImpliedElements(cur *htmlNode, name string) *htmlNode {
+	rule, ok := htmlImpliedEnd[name]
+	if !ok {
+		return cur
+	}
+	for n := cur; n != nil && n.Tag != "#document"; n = n.Parent {
+		if slices.Contains(rule.scope, n.Tag) {
+			return cur
+		}
+		if slices.Contains(rule.closes, n.Tag) {
+			return n.Parent
+		}
+	}
+	return cur
+}
+
+func isHTMLNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == ':'
+}
+
+// text returns the visible text of a node: whitespace is collapsed within
+// lines, and <br> and block elements separate lines
+func (n *htmlNode) text() string {
+	var b strings.Builder
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		switch {
+		case n.Tag == "":
+			b.WriteString(n.Text)
+			return
+		case n.Tag == "br":
+			b.WriteByte('\n')
+			return
+		}
+		if htmlBlockElements[n.Tag] {
+			b.WriteByte('\n')
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+		if htmlBlockElements[n.Tag] {
+			b.WriteByte('\n')
+		}
+	}
+	walk(n)
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// htmlSelector is a compiled CSS selector: a chain of compound selectors
+// joined by combinators
+type htmlSelector []htmlCompound
+
+// htmlCompound is one step of a selector chain, such as "li.dish:not(.main)"
+type htmlCompound struct {
+	Combinator byte // ' ' (descendant) or '>' (child) to the previous step
+	Tag        string
+	ID         string
+	Classes    []string
+	Not        []htmlCompound
+}
+
+// compileSelector parses the subset of CSS selectors used by extraction
+// profiles: type, #id, .class and :not(...), combined with descendant and
+// child (>) combinators
+func compileSelector(s string) (htmlSelector, error) {
+	var sel htmlSelector
+	combinator := byte(' ')
+	for _, token := range strings.Fields(strings.ReplaceAll(s, ">", " > ")) {
+		if token == ">" {
+			if len(sel) == 0 || combinator == '>' {
+				return nil, fmt.Errorf("misplaced > in %q", s)
+			}
+			combinator = '>'
+			continue
+		}
+		c, err := parseCompound(token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		c.Combinator = combinator
+		sel = append(sel, c)
+		combinator = ' '
+	}
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("empty selector")
+	}
+	if combinator == '>' {
+		return nil, fmt.Errorf("selector %q ends with >", s)
+	}
+	return sel, nil
+}
+
+func parseCompound(token string) (htmlCompound, error) {
+	var c htmlCompound
+	i := 0
+	readName := func() string {
+		start := i
+		for i < len(token) && (isHTMLNameByte(token[i]) && token[i] != ':' || token[i] == '_' || token[i] >= 0x80) {
+			i++
+		}
+		return token[start:i]
+	}
+
+	c.Tag = strings.ToLower(readName())
+	for i < len(token) {
+		switch token[i] {
+		case '#':
+			i++
+			c.ID = readName()
+		case '.':
+			i++
+			c.Classes = append(c.Classes, readName())
+		case ':':
+			end := strings.IndexByte(token[i:], ')')
+			if !strings.HasPrefix(token[i:], ":not(") || end < 6 {
+				return c, fmt.Errorf("unsupported pseudo-class in %q", token)
+			}
+			inner, err := parseCompound(token[i+5 : i+end])
+			if err != nil {
+				return c, err
+			}
+			c.Not = append(c.Not, inner)
+			i += end + 1
+		default:
+			return c, fmt.Errorf("unexpected %q", token[i:])
+		}
+	}
+	if c.Tag == "" && c.ID == "" && len(c.Classes) == 0 && len(c.Not) == 0 {
+		return c, fmt.Errorf("empty compound selector")
+	}
+	return c, nil
+}
+
+// queryAll returns the descendants of n matched by sel, in document order.
+// Ancestors are only considered up to n itself, so a selector is evaluated
+// within the scope it is applied to.
+func (n *htmlNode) queryAll(sel htmlSelector) []*htmlNode {
+	var matches []*htmlNode
+	var walk func(*htmlNode)
+	walk = func(node *htmlNode) {
+		for _, c := range node.Children {
+			if c.Tag == "" {
+				continue
+			}
+			if matchChain(c, sel, len(sel)-1, n) {
+				matches = append(matches, c)
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+	return matches
+}
+
+// matchChain reports whether node matches sel[i] and the steps before it
+// match its ancestors within scope
+func matchChain(node *htmlNode, sel htmlSelector, i int, scope *htmlNode) bool {
+	if !sel[i].matches(node) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	for p := node.Parent; p != nil; p = p.Parent {
+		if matchChain(p, sel, i-1, scope) {
+			return true
+		}
+		if sel[i].Combinator == '>' || p == scope {
+			return false
+		}
+	}
+	return false
+}
+
+func (c *htmlCompound) matches(n *htmlNode) bool {
+	if n.Tag == "" || n.Tag == "#document" {
+		return false
+	}
+	if c.Tag != "" && c.Tag != n.Tag {
+		return false
+	}
+	if c.ID != "" && n.Attrs["id"] != c.ID {
+		return false
+	}
+	if len(c.Classes) > 0 {
+		classes := strings.Fields(n.Attrs["class"])
+		for _, want := range c.Classes {
+			if !slices.Contains(classes, want) {
+				return false
+			}
+		}
+	}
+	for _, not := range c.Not {
+		if not.matches(n) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// htmlProfile is an extraction profile with its selectors compiled
+type htmlProfile struct {
+	models.HTMLProfile
+	title  htmlSelector
+	day    htmlSelector
+	date   htmlSelector
+	fields map[string]htmlSelector
+}
+
+// AddHTMLProfile registers an extraction profile for a municipality's menu
+// page, replacing any profile of the same name
+func (dp *DocumentProcessor) AddHTMLProfile(profile models.HTMLProfile) error {
+	compiled, err := compileHTMLProfile(profile)
+	if err != nil {
+		return err
+	}
+
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	if dp.htmlProfiles == nil {
+		dp.htmlProfiles = make(map[string]*htmlProfile)
+	}
+	dp.htmlProfiles[profile.Name] = compiled
+	return nil
+}
+
+// LoadHTMLProfiles registers the extraction profiles listed in a JSON file
+func (dp *DocumentProcessor) LoadHTMLProfiles(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read HTML profiles: %w", err)
+	}
+	var profiles []models.HTMLProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("failed to parse HTML profiles: %w", err)
+	}
+	for _, p := range profiles {
+		if err := dp.AddHTMLProfile(p); err != nil {
+			return fmt.Errorf("HTML profile %q: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// HTMLProfiles returns the registered extraction profiles in name order
+func (dp *DocumentProcessor) HTMLProfiles() []models.HTMLProfile {
+	dp.mu.RLock()
+	defer dp.mu.RUnlock()
+
+	profiles := make([]models.HTMLProfile, 0, len(dp.htmlProfiles))
+	for _, p := range dp.htmlProfiles {
+		profiles = append(profiles, p.HTMLProfile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles
+}
+
+func compileHTMLProfile(profile models.HTMLProfile) (*htmlProfile, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	var verrs models.ValidationErrors
+	compile := func(field, selector string) htmlSelector {
+		if selector == "" {
+			return nil
+		}
+		sel, err := compileSelector(selector)
+		if err != nil {
+			verrs.Add(field, err.Error())
+		}
+		return sel
+	}
+
+	compiled := &htmlProfile{
+		HTMLProfile: profile,
+		title:       compile("title", profile.Title),
+		day:         compile("day", profile.Day),
+		date:        compile("date", profile.Date),
+		fields:      make(map[string]htmlSelector, len(profile.Fields)),
+	}
+	for field, selector := range profile.Fields {
+		compiled.fields[field] = compile("fields."+field, selector)
+	}
+	if err := verrs.Err(); err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+// extractFromHTML applies an extraction profile to a menu web page. The page
+// becomes a table with one row per day, parsed like a spreadsheet. Only UTF-8
+// pages are read; pages in other encodings, such as Shift_JIS or EUC-JP, are
+// rejected with the charset that they declare.
+func (dp *DocumentProcessor) extractFromHTML(file multipart.File, sourceID, contentType, profileName string, mapping models.ColumnMapping) (*models.ExtractedMenuData, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTML page: %w", err)
+	}
+	// A page saved as UTF-8 may keep the <meta> charset of the original
+	if !utf8.Valid(data) {
+		if charset := htmlCharset(contentType, data); charset != "" && !strings.EqualFold(charset, "utf-8") {
+			return nil, fmt.Errorf("HTML page is encoded as %s, which is not supported; save it as UTF-8", charset)
+		}
+		return nil, fmt.Errorf("HTML page is not UTF-8 encoded")
+	}
+
+	profiles, err := dp.htmlProfilesFor(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	root := parseHTML(string(data))
+	for _, p := range profiles {
+		rows, days := p.extractRows(root)
+		if days == 0 {
+			continue
+		}
+		if err := p.checkColumnMapping(mapping); err != nil {
+			return nil, err
+		}
+		return &models.ExtractedMenuData{
+			SourceID:    sourceID,
+			Rows:        rows,
+			ExtractedAt: time.Now(),
+			Confidence:  1.0,
+			Metadata:    map[string]string{"format": string(models.DocumentTypeHTML), "profile": p.Name, "days": fmt.Sprint(days)},
+		}, nil
+	}
+	return nil, fmt.Errorf("no HTML profile found menu days on the page")
+}
+
+// htmlMetaCharset finds the charset of <meta charset="..."> and of
+// <meta http-equiv="Content-Type" content="text/html; charset=...">
+var htmlMetaCharset = regexp.MustCompile(`(?i)<meta\s[^>]*charset\s*=\s*["']?([a-z0-9_.:-]+)`)
+
+// htmlCharset returns the charset declared by a page's Content-Type or else
+// by a <meta> tag in its first 1024 bytes, or "" when none is declared
+func htmlCharset(contentType string, data []byte) string {
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		return params["charset"]
+	}
+	if m := htmlMetaCharset.FindSubmatch(data[:min(len(data), 1024)]); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// htmlProfilesFor returns the named profile, or every profile when name is empty
+func (dp *DocumentProcessor) htmlProfilesFor(name string) ([]*htmlProfile, error) {
+	dp.mu.RLock()
+	defer dp.mu.RUnlock()
+
+	if name != "" {
+		p, ok := dp.htmlProfiles[name]
+		if !ok {
+			return nil, models.ValidationErrors{{Field: "html_profile", Message: fmt.Sprintf("unknown HTML profile %q", name)}}
+		}
+		return []*htmlProfile{p}, nil
+	}
+	if len(dp.htmlProfiles) == 0 {
+		return nil, fmt.Errorf("no HTML profiles are configured")
+	}
+	profiles := make([]*htmlProfile, 0, len(dp.htmlProfiles))
+	for _, p := range dp.htmlProfiles {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+// fieldNames returns the fields the profile extracts, sorted
+func (p *htmlProfile) fieldNames() []string {
+	fields := make([]string, 0, len(p.fields))
+	for field := range p.fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// checkColumnMapping rejects a column mapping with headers that match none of
+// the columns the profile creates: "date" and the names of its fields
+func (p *htmlProfile) checkColumnMapping(mapping models.ColumnMapping) error {
+	columns := append([]string{"date"}, p.fieldNames()...)
+	headers := make([]string, 0, len(mapping))
+	for header := range mapping {
+		headers = append(headers, header)
+	}
+	sort.Strings(headers)
+
+	var verrs models.ValidationErrors
+	for _, header := range headers {
+		found := false
+		for _, column := range columns {
+			if strings.HasPrefix(column, header) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			verrs.Add("column_mapping."+header, fmt.Sprintf("HTML profile %q has no column %q; its columns are %s",
+				p.Name, header, strings.Join(columns, ", ")))
+		}
+	}
+	return verrs.Err()
+}
+
+// extractRows turns the page into rows: the title (for the year and month),
+// a header of field names, then one row per day element
+func (p *htmlProfile) extractRows(root *htmlNode) ([][]string, int) {
+	fields := p.fieldNames()
+
+	var rows [][]string
+	if p.title != nil {
+		if titles := root.queryAll(p.title); len(titles) > 0 {
+			rows = append(rows, []string{firstLine(titles[0].text())})
+		}
+	}
+	rows = append(rows, append([]string{"date"}, fields...))
+
+	days := root.queryAll(p.day)
+	for _, day := range days {
+		date := day.text()
+		if p.date != nil {
+			date = ""
+			if matches := day.queryAll(p.date); len(matches) > 0 {
+				date = matches[0].text()
+			}
+		}
+		row := []string{firstLine(date)}
+		for _, field := range fields {
+			var values []string
+			for _, m := range day.queryAll(p.fields[field]) {
+				if text := m.text(); text != "" {
+					values = append(values, text)
+				}
+			}
+			row = append(row, strings.Join(values, "\n"))
+		}
+		rows = append(rows, row)
+	}
+	return rows, len(days)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
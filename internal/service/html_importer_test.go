@@ -0,0 +1,208 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func newHTMLTestProcessor(t *testing.T) (*MenuAdvisorService, *DocumentProcessor) {
+	t.Helper()
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	if err := processor.LoadHTMLProfiles(filepath.Join("testdata", "html", "profiles.json")); err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+	return menuService, processor
+}
+
+// processHTMLFixture runs a saved page through ProcessDocument as a local file
+func processHTMLFixture(t *testing.T, processor *DocumentProcessor, name, profile string) (*models.DocumentSource, error) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "html", name))
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer f.Close()
+	return processor.ProcessDocument(&models.DocumentProcessingRequest{
+		File:        f,
+		Header:      &multipart.FileHeader{Filename: name},
+		HTMLProfile: profile,
+		Year:        2024, // Overridden by the page title
+		Month:       time.December,
+	})
+}
+
+func TestProcessHTMLTablePage(t *testing.T) {
+	menuService, processor := newHTMLTestProcessor(t)
+
+	doc, err := processHTMLFixture(t, processor, "minato.html", "minato")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if doc.Type != models.DocumentTypeHTML || doc.ContentType != "text/html" {
+		t.Errorf("Unexpected document type %s (%s)", doc.Type, doc.ContentType)
+	}
+	if doc.MergeReport.Summary[models.DayMergeAdded] != 2 {
+		t.Fatalf("Expected 2 days added, got %+v", doc.MergeReport.Days)
+	}
+
+	menu, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if menu.MainDish != "鶏肉の照り焼き" || menu.Soup != "みそ汁" || menu.Nutrition.Calories != 650 {
+		t.Errorf("Unexpected menu: %+v", menu)
+	}
+	if strings.Join(menu.SideDishes, ",") != "ごまあえ,ごはん,牛乳" {
+		t.Errorf("Unexpected side dishes: %v", menu.SideDishes)
+	}
+
+	menu, _ = menuService.GetSchoolLunchForDate(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC))
+	if menu.MainDish != "ハンバーグ&ポテト" || strings.Join(menu.SideDishes, ",") != "パン,牛乳" {
+		t.Errorf("Unexpected menu: %+v", menu)
+	}
+}
+
+func TestProcessHTMLListPageWithAnyProfile(t *testing.T) {
+	menuService, processor := newHTMLTestProcessor(t)
+
+	// Without a profile name, the profile that finds days on the page is used
+	if _, err := processHTMLFixture(t, processor, "kawabe.html", ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	menu, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if menu.MainDish != "さばの味噌煮" || menu.Soup != "すまし汁" || menu.Nutrition.Calories != 620 || menu.Nutrition.Protein != 24.5 {
+		t.Errorf("Unexpected menu: %+v", menu)
+	}
+	if strings.Join(menu.SideDishes, ",") != "きんぴらごぼう,ごはん" {
+		t.Errorf("Unexpected side dishes: %v", menu.SideDishes)
+	}
+	if _, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 21, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected second day to be imported: %v", err)
+	}
+}
+
+func TestProcessHTMLProfileErrors(t *testing.T) {
+	_, processor := newHTMLTestProcessor(t)
+
+	_, err := processHTMLFixture(t, processor, "minato.html", "unknown")
+	var verrs models.ValidationErrors
+	if !errors.As(err, &verrs) || verrs[0].Field != "html_profile" {
+		t.Errorf("Expected a validation error for an unknown profile, got %v", err)
+	}
+
+	// The list profile finds no days on the table page
+	if _, err := processHTMLFixture(t, processor, "minato.html", "kawabe"); err == nil {
+		t.Error("Expected an error when the profile does not fit the page")
+	}
+}
+
+func TestProcessHTMLNamesUnsupportedCharset(t *testing.T) {
+	_, processor := newHTMLTestProcessor(t)
+	// 給食 in Shift_JIS and EUC-JP
+	sjis := "<html><head><meta charset=\"Shift_JIS\"></head><body>\x8b\x8b\x90\x48</body></html>"
+	eucJP := "<html><body>\xb5\xeb\xbf\xa9</body></html>"
+
+	tests := []struct {
+		name        string
+		page        string
+		contentType string
+		want        string
+	}{
+		{"meta charset", sjis, "text/html", "encoded as Shift_JIS"},
+		{"content type", eucJP, "text/html; charset=EUC-JP", "encoded as EUC-JP"},
+		{"undeclared", eucJP, "", "not UTF-8"},
+	}
+	for _, tt := range tests {
+		header := &multipart.FileHeader{Filename: "kondate.html", Header: textproto.MIMEHeader{"Content-Type": {tt.contentType}}}
+		_, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+			File:   &memoryFile{bytes.NewReader([]byte(tt.page))},
+			Header: header,
+		})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestProcessHTMLColumnMapping(t *testing.T) {
+	process := func(processor *DocumentProcessor, mapping models.ColumnMapping) error {
+		f, err := os.Open(filepath.Join("testdata", "html", "minato.html"))
+		if err != nil {
+			t.Fatalf("Failed to open fixture: %v", err)
+		}
+		defer f.Close()
+		_, err = processor.ProcessDocument(&models.DocumentProcessingRequest{
+			File:          f,
+			Header:        &multipart.FileHeader{Filename: "minato.html"},
+			HTMLProfile:   "minato",
+			ColumnMapping: mapping,
+		})
+		return err
+	}
+
+	// Spreadsheet headers do not exist on the page, only the profile's columns
+	_, processor := newHTMLTestProcessor(t)
+	err := process(processor, models.ColumnMapping{"こんだて": "main_dish", "soup": "dessert"})
+	var verrs models.ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Field != "column_mapping.こんだて" {
+		t.Fatalf("Expected the unknown column to be reported, got %v", err)
+	}
+	if !strings.Contains(verrs[0].Message, "date, main_dish, nutrition.calories, side_dishes, soup") {
+		t.Errorf("Expected the profile's columns to be listed, got %q", verrs[0].Message)
+	}
+
+	menuService, processor := newHTMLTestProcessor(t)
+	if err := process(processor, models.ColumnMapping{"soup": "dessert"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	menu, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if menu.Dessert != "みそ汁" || menu.Soup != "" {
+		t.Errorf("Expected the soup column to be mapped onto dessert, got %+v", menu)
+	}
+}
+
+func TestAddHTMLProfileValidation(t *testing.T) {
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+
+	err := processor.AddHTMLProfile(models.HTMLProfile{
+		Name:   "broken",
+		Day:    "tr.day >",
+		Fields: map[string]string{"main_dish": "td:hover", "dish": "td"},
+	})
+	var verrs models.ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("Expected validation errors, got %v", err)
+	}
+	if len(verrs) != 1 || verrs[0].Field != "fields.dish" {
+		t.Errorf("Expected the unknown field to be reported first, got %v", verrs)
+	}
+
+	err = processor.AddHTMLProfile(models.HTMLProfile{
+		Name:   "broken",
+		Day:    "tr.day >",
+		Fields: map[string]string{"main_dish": "td:hover"},
+	})
+	if !errors.As(err, &verrs) || len(verrs) != 2 {
+		t.Errorf("Expected both selectors to be reported, got %v", err)
+	}
+	if len(processor.HTMLProfiles()) != 0 {
+		t.Error("Expected invalid profiles not to be registered")
+	}
+}
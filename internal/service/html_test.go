@@ -0,0 +1,80 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustSelect(t *testing.T, root *htmlNode, selector string) []string {
+	t.Helper()
+	sel, err := compileSelector(selector)
+	if err != nil {
+		t.Fatalf("Failed to compile %q: %v", selector, err)
+	}
+	var texts []string
+	for _, n := range root.queryAll(sel) {
+		texts = append(texts, n.text())
+	}
+	return texts
+}
+
+func TestParseHTMLIsForgiving(t *testing.T) {
+	root := parseHTML(`<TABLE><TR><TD>a<TD>b<br>c<TR><TD>d</td></table></div>
+<ul><li>x &amp; y<li>z</ul><script>if (a < b) { x = "<li>no</li>"; }</script><p class='last' title="a > b">end`)
+
+	rows := mustSelect(t, root, "table tr")
+	if len(rows) != 2 {
+		t.Fatalf("Expected implied end tags to give 2 rows, got %q", rows)
+	}
+	if cells := mustSelect(t, root, "tr > td"); strings.Join(cells, "|") != "a|b\nc|d" {
+		t.Errorf("Unexpected cells of first row: %q", cells)
+	}
+	if items := mustSelect(t, root, "li"); strings.Join(items, "|") != "x & y|z" {
+		t.Errorf("Expected script content to be skipped and entities decoded, got %q", items)
+	}
+	if p := mustSelect(t, root, "p.last"); len(p) != 1 || p[0] != "end" {
+		t.Errorf("Expected parsing to continue after a stray end tag, got %q", p)
+	}
+}
+
+func TestCompileSelector(t *testing.T) {
+	root := parseHTML(`<div id="menu" class="week">
+  <section class="day today" data-date="2025-01-14"><h3>14日</h3><span class="dish main">カレー</span><span class="dish">サラダ</span></section>
+  <section class="day"><h3>15日</h3><span class="dish main">うどん</span></section>
+</div>
+<section class="day"><h3>outside</h3></section>`)
+
+	tests := map[string]string{
+		"#menu section.day h3":         "14日|15日",
+		"div > section h3":             "14日|15日",
+		"section.day.today .main":      "カレー",
+		"span.dish:not(.main)":         "サラダ",
+		"section.day:not(.today) span": "うどん",
+		"body > section":               "",
+	}
+	for selector, expected := range tests {
+		if got := strings.Join(mustSelect(t, root, selector), "|"); got != expected {
+			t.Errorf("%s: expected %q, got %q", selector, expected, got)
+		}
+	}
+
+	for _, invalid := range []string{"", "div >", "> div", "a:hover", "li:nth-child(1)", "td[class]", "h3, p"} {
+		if _, err := compileSelector(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+func TestQueryAllIsScoped(t *testing.T) {
+	root := parseHTML(`<table><tr class="day"><td class="date">14日</td><td>カレー</td></tr></table>`)
+	sel, _ := compileSelector("tr.day")
+	day := root.queryAll(sel)[0]
+
+	// Within a day, "table td" cannot reach the table outside the day
+	if cells := mustSelect(t, day, "table td"); len(cells) != 0 {
+		t.Errorf("Expected no matches outside the scope, got %q", cells)
+	}
+	if cells := mustSelect(t, day, "tr > td.date"); len(cells) != 1 {
+		t.Errorf("Expected the scope element itself to match, got %q", cells)
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"log"
+	"sync"
+	"time"
+)
+
+// crockford is the Base32 alphabet used by ULIDs; it leaves out I, L, O and
+// U so that IDs are easy to read aloud and cannot spell words
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidSource generates ULIDs that sort in creation order, even for IDs made
+// within the same millisecond
+type ulidSource struct {
+	mu      sync.Mutex
+	lastMS  uint64
+	lastHi  uint16 // Top 16 of the 80 random bits
+	lastLo  uint64 // Bottom 64 of the 80 random bits
+	now     func() time.Time
+	entropy func([]byte) (int, error)
+}
+
+var (
+	documentIDs = &ulidSource{now: time.Now, entropy: rand.Read}
+	homeMealIDs = &ulidSource{now: time.Now, entropy: rand.Read}
+	pantryIDs   = &ulidSource{now: time.Now, entropy: rand.Read}
+)
+
+// next returns a 26-character ULID: a 48-bit millisecond timestamp followed
+// by 80 random bits. Within one millisecond the random part of the previous
+// ID is incremented instead, so that IDs stay strictly increasing.
+func (s *ulidSource) next() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms := uint64(s.now().UnixMilli())
+	if ms <= s.lastMS {
+		ms = s.lastMS
+		s.lastLo++
+		if s.lastLo == 0 {
+			s.lastHi++
+		}
+	} else {
+		var random [10]byte
+		if _, err := s.entropy(random[:]); err != nil {
+			// IDs stay unique and ordered within this process, since the
+			// timestamp has moved on; only their unpredictability is lost
+			log.Printf("Generating an ID without random bits: %v", err)
+		}
+		s.lastMS = ms
+		s.lastHi = binary.BigEndian.Uint16(random[0:2])
+		s.lastLo = binary.BigEndian.Uint64(random[2:10])
+	}
+
+	var id [16]byte
+	id[0], id[1], id[2], id[3], id[4], id[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	binary.BigEndian.PutUint16(id[6:8], s.lastHi)
+	binary.BigEndian.PutUint64(id[8:16], s.lastLo)
+	return encodeULID(id)
+}
+
+// encodeULID writes 128 bits as 26 Crockford Base32 characters, most
+// significant first; the first character only carries 3 bits
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// generateDocumentID returns a new document ID such as
+// "doc_01JBQ0Z8RM8W7X9GQ5V3T2K4NP"; IDs sort by upload time
+func generateDocumentID() string {
+	return "doc_" + documentIDs.next()
+}
+
+// generateHomeMealID returns a new home meal ID such as
+// "meal_01JBQ0Z8RM8W7X9GQ5V3T2K4NP"
+func generateHomeMealID() string {
+	return "meal_" + homeMealIDs.next()
+}
+
+// generatePantryItemID returns a new pantry item ID such as
+// "pantry_01JBQ0Z8RM8W7X9GQ5V3T2K4NP"
+func generatePantryItemID() string {
+	return "pantry_" + pantryIDs.next()
+}
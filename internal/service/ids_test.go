@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestULIDSource(t *testing.T) {
+	now := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	source := &ulidSource{
+		now:     func() time.Time { return now },
+		entropy: func(b []byte) (int, error) { return len(b), nil }, // All zero
+	}
+
+	var ids []string
+	for i := 0; i < 100; i++ {
+		ids = append(ids, source.next())
+	}
+	if len(ids[0]) != 26 || strings.Trim(ids[0], crockford) != "" {
+		t.Fatalf("Expected a 26-character Crockford Base32 ID, got %q", ids[0])
+	}
+	// 2025-04-01T09:00:00Z is 1743498000000 ms, 0x0195F0943680
+	if !strings.HasPrefix(ids[0], "01JQR98DM0") {
+		t.Errorf("Expected the ID to start with the timestamp, got %q", ids[0])
+	}
+	if ids[1] != "01JQR98DM00000000000000001" {
+		t.Errorf("Expected IDs in the same millisecond to increment, got %q", ids[1])
+	}
+
+	// A clock that goes backwards does not break the order
+	now = now.Add(-time.Second)
+	ids = append(ids, source.next())
+	now = now.Add(time.Hour)
+	ids = append(ids, source.next())
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("Expected IDs to sort in creation order: %v", ids)
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			t.Fatalf("Duplicate ID %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestGenerateDocumentIDUnique(t *testing.T) {
+	const n = 1000
+	ids := make(chan string, n)
+	for i := 0; i < n; i++ {
+		go func() { ids <- generateDocumentID() }()
+	}
+	seen := map[string]bool{}
+	for i := 0; i < n; i++ {
+		id := <-ids
+		if !strings.HasPrefix(id, "doc_") || seen[id] {
+			t.Fatalf("Unexpected or duplicate ID %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestULIDSourceEntropyFailure(t *testing.T) {
+	now := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	source := &ulidSource{
+		now:     func() time.Time { return now },
+		entropy: func(b []byte) (int, error) { return 0, errors.New("no entropy") },
+	}
+	first := source.next()
+	now = now.Add(time.Millisecond)
+	if second := source.next(); len(second) != 26 || second <= first {
+		t.Errorf("Expected ordered IDs without entropy, got %q then %q", first, second)
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// imapTimeout bounds each exchange with the IMAP server
+const imapTimeout = time.Minute
+
+// maxIMAPMessageSize bounds the size of a message fetched from the IMAP server
+const maxIMAPMessageSize = maxMailAttachmentSize * 2
+
+// IngestIMAP ingests the unseen messages of an IMAP mailbox. Messages are
+// fetched without being marked and flagged \Seen once all of their
+// attachments have been processed, so a failure leaves them to be retried on
+// the next poll. Mail from senders that are not mapped to a school is left
+// unread for the people sharing the mailbox, as are messages larger than
+// maxIMAPMessageSize, which are skipped on later polls.
+func (m *MailIngester) IngestIMAP(ctx context.Context, config models.IMAPConfig) ([]models.MailIngestResult, error) {
+	c, err := dialIMAP(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	if _, err := c.command("LOGIN %s %s", imapQuote(config.Username), imapQuote(imapPassword(config))); err != nil {
+		return nil, fmt.Errorf("IMAP login failed: %w", err)
+	}
+	mailbox := config.Mailbox
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+	if _, err := c.command("SELECT %s", imapQuote(mailbox)); err != nil {
+		return nil, fmt.Errorf("IMAP select failed: %w", err)
+	}
+
+	untagged, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, fmt.Errorf("IMAP search failed: %w", err)
+	}
+	var uids []string
+	for _, line := range untagged {
+		if rest, ok := strings.CutPrefix(line.text, "SEARCH"); ok {
+			uids = append(uids, strings.Fields(rest)...)
+		}
+	}
+
+	var results []models.MailIngestResult
+	for _, uid := range uids {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		key := fmt.Sprintf("imap:%s/%s/%s/%s", config.Addr, config.Username, mailbox, uid)
+		if m.isSeen(key) {
+			continue
+		}
+		untagged, err := c.command("UID FETCH %s BODY.PEEK[]", uid)
+		if err != nil {
+			return results, fmt.Errorf("IMAP fetch of message %s failed: %w", uid, err)
+		}
+		var raw []byte
+		var oversized int64
+		for _, line := range untagged {
+			if line.literal != nil || line.oversized > 0 {
+				raw, oversized = line.literal, line.oversized
+				break
+			}
+		}
+		if oversized > 0 {
+			m.markSeen(key)
+			results = append(results, models.MailIngestResult{
+				MessageID: key,
+				Skipped:   fmt.Sprintf("message is %d bytes, larger than %d", oversized, maxIMAPMessageSize),
+			})
+			continue
+		}
+		if raw == nil {
+			continue
+		}
+
+		result, err := m.IngestMessage(raw)
+		if err != nil {
+			// Unreadable messages are marked too, rather than retried forever
+			log.Printf("Skipping unreadable message %s: %v", uid, err)
+		} else if result != nil {
+			results = append(results, *result)
+		}
+		if result != nil && (result.SchoolID == "" || result.Retry) {
+			continue
+		}
+		if _, err := c.command("UID STORE %s +FLAGS (\\Seen)", uid); err != nil {
+			return results, fmt.Errorf("IMAP store of message %s failed: %w", uid, err)
+		}
+	}
+
+	c.command("LOGOUT")
+	return results, nil
+}
+
+// imapClient is just enough of an IMAP4rev1 client to fetch new messages
+type imapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// imapLine is an untagged response; literal holds the data of a {n} literal
+// and oversized the size of one that was discarded for being too large
+type imapLine struct {
+	text      string
+	literal   []byte
+	oversized int64
+}
+
+func dialIMAP(ctx context.Context, config models.IMAPConfig) (*imapClient, error) {
+	dialer := &net.Dialer{Timeout: imapTimeout}
+	var conn net.Conn
+	var err error
+	if config.TLS {
+		host, _, _ := net.SplitHostPort(config.Addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", config.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", config.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to IMAP server: %w", err)
+	}
+
+	c := &imapClient{conn: conn, r: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(imapTimeout))
+	greeting, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read IMAP greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected IMAP greeting: %s", greeting)
+	}
+	return c, nil
+}
+
+func (c *imapClient) close() error {
+	return c.conn.Close()
+}
+
+// command sends a tagged command and returns its untagged responses, or an
+// error when the server does not answer OK
+func (c *imapClient) command(format string, args ...any) ([]imapLine, error) {
+	c.tag++
+	tag := fmt.Sprintf("A%03d", c.tag)
+	c.conn.SetDeadline(time.Now().Add(imapTimeout))
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+
+	var untagged []imapLine
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return untagged, err
+		}
+		if status, ok := strings.CutPrefix(line, tag+" "); ok {
+			if !strings.HasPrefix(status, "OK") {
+				return untagged, fmt.Errorf("%s", status)
+			}
+			return untagged, nil
+		}
+
+		resp := imapLine{text: strings.TrimPrefix(line, "* ")}
+		if n, ok := imapLiteralSize(line); ok {
+			if n > maxIMAPMessageSize {
+				// Read past the literal so that its data is not taken for responses
+				if _, err := io.CopyN(io.Discard, c.r, n); err != nil {
+					return untagged, err
+				}
+				resp.oversized = n
+			} else {
+				resp.literal = make([]byte, n)
+				if _, err := io.ReadFull(c.r, resp.literal); err != nil {
+					return untagged, err
+				}
+			}
+			// The rest of the response, such as the closing parenthesis
+			if _, err := c.readLine(); err != nil {
+				return untagged, err
+			}
+		}
+		untagged = append(untagged, resp)
+	}
+}
+
+func (c *imapClient) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// imapLiteralSize reports the size of a literal announced as {n} at the end of a line
+func imapLiteralSize(line string) (int64, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	open := strings.LastIndexByte(line, '{')
+	if open < 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(line[open+1:len(line)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// imapQuote quotes a string argument
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// imapStandIn serves one mailbox to one IMAP connection at a time, recording
+// the commands it receives
+type imapStandIn struct {
+	messages map[string][]byte // by UID
+	// filler holds the sizes of messages served as repeated response-like lines
+	filler   map[string]int64
+	mu       sync.Mutex
+	commands []string
+	seen     map[string]bool
+}
+
+func (s *imapStandIn) serve(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s.seen = make(map[string]bool)
+
+	session := func(conn net.Conn) {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "* OK IMAP4rev1 stand-in ready\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+			s.mu.Lock()
+			s.commands = append(s.commands, cmd)
+			s.mu.Unlock()
+
+			fields := strings.Fields(cmd)
+			switch {
+			case strings.HasPrefix(cmd, "LOGIN"):
+				if cmd != `LOGIN "family@example.com" "se\"cret"` {
+					fmt.Fprintf(conn, "%s NO LOGIN failed\r\n", tag)
+					continue
+				}
+			case strings.HasPrefix(cmd, "UID SEARCH"):
+				var uids []string
+				for uid := range s.messages {
+					if !s.seen[uid] {
+						uids = append(uids, uid)
+					}
+				}
+				for uid := range s.filler {
+					if !s.seen[uid] {
+						uids = append(uids, uid)
+					}
+				}
+				fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+			case strings.HasPrefix(cmd, "UID FETCH") && s.filler[fields[2]] > 0:
+				n := s.filler[fields[2]]
+				fmt.Fprintf(conn, "* 1 FETCH (UID %s BODY[] {%d}\r\n", fields[2], n)
+				line := strings.Repeat(fmt.Sprintf("%s OK done\r\n", tag), 1024)
+				io.CopyN(conn, strings.NewReader(strings.Repeat(line, int(n)/len(line)+1)), n)
+				fmt.Fprint(conn, ")\r\n")
+			case strings.HasPrefix(cmd, "UID FETCH"):
+				msg := s.messages[fields[2]]
+				fmt.Fprintf(conn, "* 1 FETCH (UID %s BODY[] {%d}\r\n%s)\r\n", fields[2], len(msg), msg)
+			case strings.HasPrefix(cmd, "UID STORE"):
+				s.mu.Lock()
+				s.seen[fields[2]] = true
+				s.mu.Unlock()
+			case cmd == "LOGOUT":
+				fmt.Fprint(conn, "* BYE\r\n")
+				fmt.Fprintf(conn, "%s OK LOGOUT completed\r\n", tag)
+				return
+			}
+			fmt.Fprintf(conn, "%s OK done\r\n", tag)
+		}
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			session(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestIngestIMAP(t *testing.T) {
+	menuService, _, ingester := newMailTest(t)
+	msg, err := os.ReadFile(filepath.Join("testdata", "mail", "maildir", "new", "1738000000.M1P1.host"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	// The mailbox is shared with mail that is not from a school
+	other := strings.NewReplacer("center@kyushoku.example.jp", "friend@example.com", "center-feb@", "friend-feb@").Replace(string(msg))
+	server := &imapStandIn{messages: map[string][]byte{"7": msg, "8": []byte(other)}}
+	addr := server.serve(t)
+
+	t.Setenv("MENU_IMAP_PASSWORD", `se"cret`)
+	results, err := ingester.IngestIMAP(context.Background(), models.IMAPConfig{
+		Addr:        addr,
+		Username:    "family@example.com",
+		PasswordEnv: "MENU_IMAP_PASSWORD",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var ingested int
+	for _, result := range results {
+		if result.SchoolID == "minato" {
+			ingested++
+		}
+	}
+	if len(results) != 2 || ingested != 1 {
+		t.Fatalf("Unexpected results: %+v", results)
+	}
+	if _, err := menuService.GetSchoolLunchForDate(time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected attached menu to be stored: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !server.seen["7"] {
+		t.Errorf("Expected the message to be flagged as seen, commands: %q", server.commands)
+	}
+	if server.seen["8"] {
+		t.Errorf("Expected mail from other senders to stay unread, commands: %q", server.commands)
+	}
+	for _, cmd := range server.commands {
+		if strings.HasPrefix(cmd, "UID FETCH") && !strings.Contains(cmd, "BODY.PEEK[]") {
+			t.Errorf("Expected messages to be fetched without marking them, got %q", cmd)
+		}
+	}
+	if server.commands[1] != `SELECT "INBOX"` {
+		t.Errorf("Expected INBOX to be selected, got %q", server.commands)
+	}
+}
+
+func TestIngestIMAPLeavesFailedMessagesUnseen(t *testing.T) {
+	_, processor, ingester := newMailTest(t)
+	processor.SetPipelineStage(stageFunc{name: models.StageParse, run: func(*PipelineRun) (any, error) {
+		return nil, errors.New("temporarily unavailable")
+	}})
+	msg, err := os.ReadFile(filepath.Join("testdata", "mail", "maildir", "new", "1738000000.M1P1.host"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	server := &imapStandIn{messages: map[string][]byte{"7": msg}}
+	addr := server.serve(t)
+
+	results, err := ingester.IngestIMAP(context.Background(), models.IMAPConfig{Addr: addr, Username: "family@example.com", Password: `se"cret`})
+	if err != nil || len(results) != 1 || !results[0].Retry {
+		t.Fatalf("Expected the message to be retried, got %+v (%v)", results, err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.seen["7"] {
+		t.Errorf("Expected the message not to be flagged as seen, commands: %q", server.commands)
+	}
+}
+
+func TestIngestIMAPSkipsOversizedMessages(t *testing.T) {
+	menuService, _, ingester := newMailTest(t)
+	msg, err := os.ReadFile(filepath.Join("testdata", "mail", "maildir", "new", "1738000000.M1P1.host"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	// The body of the large message looks like tagged replies
+	server := &imapStandIn{messages: map[string][]byte{"7": msg}, filler: map[string]int64{"9": maxIMAPMessageSize + 1}}
+	config := models.IMAPConfig{Addr: server.serve(t), Username: "family@example.com", Password: `se"cret`}
+	results, err := ingester.IngestIMAP(context.Background(), config)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %+v (%v)", results, err)
+	}
+	var skipped int
+	for _, result := range results {
+		if result.Skipped != "" && strings.Contains(result.Skipped, "larger than") {
+			skipped++
+		}
+	}
+	if skipped != 1 {
+		t.Errorf("Expected the large message to be skipped, got %+v", results)
+	}
+	if _, err := menuService.GetSchoolLunchForDate(time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected attached menu to be stored: %v", err)
+	}
+
+	// The next poll does not fetch the large message again
+	if results, err := ingester.IngestIMAP(context.Background(), config); err != nil || len(results) != 0 {
+		t.Fatalf("Expected nothing new on the second poll, got %+v (%v)", results, err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.seen["9"] {
+		t.Errorf("Expected the large message to stay unread, commands: %q", server.commands)
+	}
+	var fetches int
+	for _, cmd := range server.commands {
+		if strings.HasPrefix(cmd, "UID FETCH 9 ") {
+			fetches++
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("Expected the large message to be fetched once, commands: %q", server.commands)
+	}
+}
+
+func TestIngestIMAPLoginFailure(t *testing.T) {
+	_, _, ingester := newMailTest(t)
+	server := &imapStandIn{}
+	addr := server.serve(t)
+
+	_, err := ingester.IngestIMAP(context.Background(), models.IMAPConfig{Addr: addr, Username: "family@example.com", Password: "wrong"})
+	if err == nil || !strings.Contains(err.Error(), "login failed") {
+		t.Errorf("Expected login failure, got %v", err)
+	}
+}
@@ -0,0 +1,567 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// maxMailAttachmentSize bounds the decoded size of a single attachment
+const maxMailAttachmentSize = 32 << 20
+
+// mailAttachmentExtensions are the attachment types submitted for processing
+var mailAttachmentExtensions = map[string]bool{
+	".pdf": true, ".json": true,
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true,
+	".webp": true, ".heic": true, ".heif": true, ".tif": true, ".tiff": true,
+}
+
+// mailAttachmentMediaTypes maps attachment content types onto an extension,
+// for attachments whose file name does not tell their type
+var mailAttachmentMediaTypes = map[string]string{
+	"application/pdf":  ".pdf",
+	"application/json": ".json",
+	"image/jpeg":       ".jpg",
+	"image/png":        ".png",
+	"image/gif":        ".gif",
+	"image/bmp":        ".bmp",
+	"image/webp":       ".webp",
+	"image/heic":       ".heic",
+	"image/heif":       ".heif",
+	"image/tiff":       ".tiff",
+}
+
+// maxMailAttempts bounds how often a message whose attachments failed to
+// process is ingested before it is given up on
+const maxMailAttempts = 3
+
+// MailIngester submits menu attachments of emails from known senders to a
+// DocumentProcessor. Messages are read from an IMAP mailbox, a Maildir or an
+// mbox file; each message is ingested only once, by Message-ID.
+type MailIngester struct {
+	processor *DocumentProcessor
+	mu        sync.Mutex
+	config    models.MailConfig
+	seen      map[string]bool
+	ingesting map[string]bool
+	// attempts counts the ingestions of messages with failed attachments
+	attempts map[string]int
+	// mboxOffsets is where the next poll of each mbox file starts reading
+	mboxOffsets map[string]int64
+}
+
+// NewMailIngester creates an ingester that feeds attachments into processor
+func NewMailIngester(processor *DocumentProcessor, config models.MailConfig) *MailIngester {
+	return &MailIngester{
+		processor:   processor,
+		config:      config,
+		seen:        make(map[string]bool),
+		ingesting:   make(map[string]bool),
+		attempts:    make(map[string]int),
+		mboxOffsets: make(map[string]int64),
+	}
+}
+
+// LoadMailConfig reads and validates a mail ingestion configuration from a
+// JSON file
+func LoadMailConfig(path string) (models.MailConfig, error) {
+	var config models.MailConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read mail configuration: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse mail configuration: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("invalid mail configuration: %w", err)
+	}
+	if config.IMAP != nil {
+		config.IMAP.Password = imapPassword(*config.IMAP)
+	}
+	return config, nil
+}
+
+// imapPassword returns the configured IMAP password, read from the
+// environment variable named by PasswordEnv when it is not given directly
+func imapPassword(config models.IMAPConfig) string {
+	if config.Password == "" && config.PasswordEnv != "" {
+		return os.Getenv(config.PasswordEnv)
+	}
+	return config.Password
+}
+
+// Run polls the configured IMAP mailbox, Maildir and mbox file now and then once per
+// interval until ctx is done
+func (m *MailIngester) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := m.Poll(ctx); err != nil {
+			log.Printf("Mail ingestion failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll ingests new messages from the configured IMAP mailbox, Maildir and
+// mbox file
+func (m *MailIngester) Poll(ctx context.Context) ([]models.MailIngestResult, error) {
+	var results []models.MailIngestResult
+	var errs []error
+	if m.config.IMAP != nil {
+		r, err := m.IngestIMAP(ctx, *m.config.IMAP)
+		results = append(results, r...)
+		errs = append(errs, err)
+	}
+	if m.config.Maildir != "" {
+		r, err := m.IngestMaildir(m.config.Maildir)
+		results = append(results, r...)
+		errs = append(errs, err)
+	}
+	if m.config.Mbox != "" {
+		r, err := m.IngestMboxFile(m.config.Mbox)
+		results = append(results, r...)
+		errs = append(errs, err)
+	}
+	return results, errors.Join(errs...)
+}
+
+// IngestMaildir ingests the messages in a Maildir's new directory and moves
+// each one to cur, marked as seen, once it has been ingested. Messages to be
+// retried stay in new.
+func (m *MailIngester) IngestMaildir(dir string) ([]models.MailIngestResult, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Maildir: %w", err)
+	}
+
+	var results []models.MailIngestResult
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, "new", e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return results, fmt.Errorf("failed to read message %s: %w", e.Name(), err)
+		}
+		result, err := m.IngestMessage(data)
+		if err != nil {
+			log.Printf("Skipping unreadable message %s: %v", e.Name(), err)
+		} else if result != nil {
+			results = append(results, *result)
+		}
+		if result != nil && result.Retry {
+			continue
+		}
+		if err := os.Rename(path, filepath.Join(dir, "cur", e.Name()+":2,S")); err != nil {
+			return results, fmt.Errorf("failed to move message %s to cur: %w", e.Name(), err)
+		}
+	}
+	return results, nil
+}
+
+// IngestMbox ingests every message of an mbox file
+func (m *MailIngester) IngestMbox(r io.Reader) ([]models.MailIngestResult, error) {
+	results, _, _, err := m.ingestMbox(r)
+	return results, err
+}
+
+// IngestMboxFile ingests the messages appended to an mbox file since the
+// last poll. Reading resumes at the first message to be retried or else at
+// the end of what was read before; a file that shrank is read from the start.
+func (m *MailIngester) IngestMboxFile(path string) ([]models.MailIngestResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mbox: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mbox: %w", err)
+	}
+
+	m.mu.Lock()
+	offset := m.mboxOffsets[path]
+	m.mu.Unlock()
+	if offset > info.Size() {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read mbox: %w", err)
+	}
+
+	results, read, retryAt, err := m.ingestMbox(f)
+	next := offset + read
+	if retryAt >= 0 {
+		next = offset + retryAt
+	}
+	m.mu.Lock()
+	m.mboxOffsets[path] = next
+	m.mu.Unlock()
+	return results, err
+}
+
+// ingestMbox ingests the messages of an mbox, returning how many bytes it
+// read and the offset of the first message to be retried, or -1
+func (m *MailIngester) ingestMbox(r io.Reader) (results []models.MailIngestResult, read, retryAt int64, err error) {
+	retryAt = -1
+	read, err = splitMbox(r, func(message []byte, start int64) {
+		result, err := m.IngestMessage(message)
+		if err != nil {
+			log.Printf("Skipping unreadable message in mbox: %v", err)
+			return
+		}
+		if result == nil {
+			return
+		}
+		results = append(results, *result)
+		if result.Retry && retryAt < 0 {
+			retryAt = start
+		}
+	})
+	return results, read, retryAt, err
+}
+
+// splitMbox calls fn with each message of an mbox file and the offset of the
+// "From " line that starts it, and returns the number of bytes read. Lines
+// quoted as ">From " (mboxrd) are unquoted.
+func splitMbox(r io.Reader, fn func(message []byte, start int64)) (int64, error) {
+	reader := bufio.NewReader(r)
+	var message bytes.Buffer
+	var read, start int64
+	started := false
+	flush := func() {
+		if started {
+			fn(append([]byte(nil), message.Bytes()...), start)
+		}
+		message.Reset()
+	}
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Keep reading the rest of an overlong line
+			more, rerr := reader.ReadBytes('\n')
+			line, err = append(append([]byte(nil), line...), more...), rerr
+		}
+		if len(line) > 0 {
+			if message.Len()+len(line) > maxMailAttachmentSize*2 {
+				return read, fmt.Errorf("failed to read mbox: message at offset %d is larger than %d bytes", start, maxMailAttachmentSize*2)
+			}
+			lineStart := read
+			read += int64(len(line))
+			line = bytes.TrimRight(line, "\r\n")
+			if bytes.HasPrefix(line, []byte("From ")) {
+				flush()
+				started = true
+				start = lineStart
+			} else {
+				if trimmed := bytes.TrimLeft(line, ">"); len(trimmed) < len(line) && bytes.HasPrefix(trimmed, []byte("From ")) {
+					line = line[1:]
+				}
+				message.Write(line)
+				message.WriteString("\r\n")
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return read, fmt.Errorf("failed to read mbox: %w", err)
+		}
+	}
+	flush()
+	return read, nil
+}
+
+// IngestMessage submits the menu attachments of one raw message. It returns
+// nil without error for a message that was already ingested or is being
+// ingested. A message counts as ingested once all of its attachments have
+// been processed; one with failed attachments is marked for retry, up to
+// maxMailAttempts times.
+func (m *MailIngester) IngestMessage(raw []byte) (*models.MailIngestResult, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	result := &models.MailIngestResult{
+		MessageID: strings.Trim(msg.Header.Get("Message-Id"), "<> "),
+		Subject:   decodeMailHeader(msg.Header.Get("Subject")),
+	}
+	if result.MessageID == "" {
+		sum := sha256.Sum256(raw)
+		result.MessageID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	if !m.claimMessage(result.MessageID) {
+		return nil, nil
+	}
+	defer func() { m.finishMessage(result.MessageID, result.Retry) }()
+	if date, err := msg.Header.Date(); err == nil {
+		result.Date = &date
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		result.Skipped = "sender address is missing or invalid"
+		return result, nil
+	}
+	result.From = strings.ToLower(from.Address)
+	schoolID, ok := m.schoolFor(result.From)
+	if !ok {
+		result.Skipped = "sender is not mapped to a school"
+		return result, nil
+	}
+	result.SchoolID = schoolID
+
+	attachments, err := mailAttachments(textprotoHeader(msg.Header), msg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachments: %w", err)
+	}
+	if len(attachments) == 0 {
+		result.Skipped = "message has no attachments"
+		return result, nil
+	}
+
+	for _, a := range attachments {
+		res := models.MailAttachmentResult{Filename: a.Filename, ContentType: a.ContentType}
+		if !a.supported() {
+			res.Status = models.MailAttachmentSkipped
+			res.Error = "not a PDF, image or JSON attachment"
+			result.Attachments = append(result.Attachments, res)
+			continue
+		}
+
+		doc, err := m.processor.ProcessDocument(&models.DocumentProcessingRequest{
+			File:     &memoryFile{bytes.NewReader(a.Data)},
+			Header:   &multipart.FileHeader{Filename: a.filename(), Size: int64(len(a.Data))},
+			Actor:    "mail:" + result.From,
+			IssuedAt: result.Date,
+			SchoolID: schoolID,
+		})
+		if doc != nil {
+			res.DocumentID = doc.ID
+		}
+		res.Status = models.MailAttachmentProcessed
+		if err != nil {
+			res.Status = models.MailAttachmentFailed
+			res.Error = err.Error()
+		}
+		result.Attachments = append(result.Attachments, res)
+	}
+	failed := slices.ContainsFunc(result.Attachments, func(a models.MailAttachmentResult) bool {
+		return a.Status == models.MailAttachmentFailed
+	})
+	result.Retry = failed && m.canRetry(result.MessageID)
+	return result, nil
+}
+
+// claimMessage reserves a message ID for ingestion, reporting false when the
+// message was already ingested or is being ingested
+func (m *MailIngester) claimMessage(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.seen[id] || m.ingesting[id] {
+		return false
+	}
+	m.ingesting[id] = true
+	return true
+}
+
+// isSeen reports whether a message was ingested or given up on
+func (m *MailIngester) isSeen(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.seen[id]
+}
+
+// markSeen records a message as given up on, so that it is not fetched again
+func (m *MailIngester) markSeen(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seen[id] = true
+}
+
+// finishMessage releases a message ID claimed by claimMessage, recording
+// the message as seen unless it is to be retried
+func (m *MailIngester) finishMessage(id string, retry bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.ingesting, id)
+	if retry {
+		m.attempts[id]++
+		return
+	}
+	m.seen[id] = true
+	delete(m.attempts, id)
+}
+
+// canRetry reports whether a message with failed attachments has attempts
+// left after the current one
+func (m *MailIngester) canRetry(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.attempts[id]+1 < maxMailAttempts
+}
+
+// schoolFor maps a sender onto a school, by full address first and then by domain
+func (m *MailIngester) schoolFor(address string) (string, bool) {
+	for key, school := range m.config.Senders {
+		if strings.EqualFold(key, address) {
+			return school, true
+		}
+	}
+	if at := strings.LastIndexByte(address, '@'); at >= 0 {
+		for key, school := range m.config.Senders {
+			if strings.EqualFold(key, address[at:]) {
+				return school, true
+			}
+		}
+	}
+	return "", false
+}
+
+// mailAttachment is a decoded attachment of a message
+type mailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// supported reports whether the attachment is a PDF, image or JSON document
+func (a *mailAttachment) supported() bool {
+	return mailAttachmentExtensions[strings.ToLower(filepath.Ext(a.Filename))] || mailAttachmentMediaTypes[a.ContentType] != ""
+}
+
+// filename returns a name whose extension tells the attachment's type
+func (a *mailAttachment) filename() string {
+	if mailAttachmentExtensions[strings.ToLower(filepath.Ext(a.Filename))] {
+		return a.Filename
+	}
+	return a.Filename + mailAttachmentMediaTypes[a.ContentType]
+}
+
+// textprotoHeader adapts a mail header to the map type used by multipart parts
+func textprotoHeader(h mail.Header) map[string][]string {
+	return map[string][]string(h)
+}
+
+// mailAttachments walks a MIME body, descending into multipart parts and
+// forwarded messages, and returns the parts that carry a file name
+func mailAttachments(header map[string][]string, body io.Reader) ([]mailAttachment, error) {
+	get := func(key string) string {
+		if v := header[key]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	mediaType, params, err := mime.ParseMediaType(get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		var attachments []mailAttachment
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return attachments, nil
+			}
+			if err != nil {
+				return attachments, err
+			}
+			found, err := mailAttachments(part.Header, part)
+			if err != nil {
+				return attachments, err
+			}
+			attachments = append(attachments, found...)
+		}
+	}
+
+	decoded := decodeTransferEncoding(get("Content-Transfer-Encoding"), body)
+	if mediaType == "message/rfc822" {
+		msg, err := mail.ReadMessage(decoded)
+		if err != nil {
+			return nil, nil
+		}
+		return mailAttachments(textprotoHeader(msg.Header), msg.Body)
+	}
+
+	filename := ""
+	disposition, dparams, err := mime.ParseMediaType(get("Content-Disposition"))
+	if err == nil {
+		filename = dparams["filename"]
+	}
+	if filename == "" {
+		filename = params["name"]
+	}
+	if filename == "" && disposition != "attachment" {
+		// Message text
+		return nil, nil
+	}
+	if filename == "" {
+		filename = "attachment"
+	}
+
+	data, err := io.ReadAll(io.LimitReader(decoded, maxMailAttachmentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", filename, err)
+	}
+	if len(data) > maxMailAttachmentSize {
+		return nil, fmt.Errorf("attachment %s is larger than %d bytes", filename, maxMailAttachmentSize)
+	}
+	return []mailAttachment{{
+		Filename:    sanitizeFilename(decodeMailHeader(filename)),
+		ContentType: mediaType,
+		Data:        data,
+	}}, nil
+}
+
+func decodeTransferEncoding(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// The decoder skips the line breaks that wrap base64 bodies
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// decodeMailHeader decodes RFC 2047 encoded words such as
+// "=?UTF-8?B?57Wm6aOf?=", as used for Japanese subjects and file names
+func decodeMailHeader(s string) string {
+	dec := mime.WordDecoder{}
+	if decoded, err := dec.DecodeHeader(s); err == nil {
+		return decoded
+	}
+	return s
+}
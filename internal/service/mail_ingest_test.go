@@ -0,0 +1,253 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func newMailTest(t *testing.T) (*MenuAdvisorService, *DocumentProcessor, *MailIngester) {
+	t.Helper()
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	ingester := NewMailIngester(processor, models.MailConfig{Senders: map[string]string{
+		"@minato-school.example.jp":  "minato",
+		"center@kyushoku.example.jp": "minato",
+	}})
+	return menuService, processor, ingester
+}
+
+func TestIngestMbox(t *testing.T) {
+	menuService, processor, ingester := newMailTest(t)
+	f, err := os.Open(filepath.Join("testdata", "mail", "menu.mbox"))
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer f.Close()
+
+	results, err := ingester.IngestMbox(f)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The resent copy of the first message is ignored
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %+v", results)
+	}
+
+	school := results[0]
+	if school.MessageID != "feb-menu@minato-school.example.jp" || school.SchoolID != "minato" || school.Subject != "2月の給食おたより" {
+		t.Errorf("Unexpected message result: %+v", school)
+	}
+	if len(school.Attachments) != 2 {
+		t.Fatalf("Expected 2 attachments, got %+v", school.Attachments)
+	}
+	menu := school.Attachments[0]
+	if menu.Filename != "2月献立.json" || menu.Status != models.MailAttachmentProcessed || menu.DocumentID == "" {
+		t.Errorf("Unexpected menu attachment: %+v", menu)
+	}
+	if letter := school.Attachments[1]; letter.Status != models.MailAttachmentSkipped {
+		t.Errorf("Expected the letter to be skipped, got %+v", letter)
+	}
+
+	doc, ok := processor.GetDocument(menu.DocumentID)
+	if !ok || doc.SchoolID != "minato" {
+		t.Errorf("Expected document attributed to the school, got %+v", doc)
+	}
+	lunch, err := menuService.GetSchoolLunchForDate(time.Date(2025, 2, 4, 0, 0, 0, 0, time.UTC))
+	if err != nil || lunch.MainDish != "カレーライス" {
+		t.Errorf("Expected attached menu to be stored, got %+v (%v)", lunch, err)
+	}
+	versions, _ := menuService.GetSchoolLunchVersions(time.Date(2025, 2, 4, 0, 0, 0, 0, time.UTC))
+	if len(versions) != 1 || versions[0].Actor != "mail:kondate@minato-school.example.jp" {
+		t.Errorf("Expected change attributed to the sender, got %+v", versions)
+	}
+
+	if unknown := results[1]; unknown.Skipped == "" || len(unknown.Attachments) != 0 {
+		t.Errorf("Expected mail from an unknown sender to be skipped, got %+v", unknown)
+	}
+}
+
+func TestIngestMboxFileReadsAppendedMessages(t *testing.T) {
+	menuService, _, ingester := newMailTest(t)
+	data, err := os.ReadFile(filepath.Join("testdata", "mail", "menu.mbox"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "kondate.mbox")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ingester.IngestMboxFile(path)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %+v (%v)", results, err)
+	}
+	if offset := ingester.mboxOffsets[path]; offset != int64(len(data)) {
+		t.Errorf("Expected the next poll to start at %d, got %d", len(data), offset)
+	}
+	results, err = ingester.IngestMboxFile(path)
+	if err != nil || len(results) != 0 {
+		t.Errorf("Expected nothing new on the second poll, got %+v (%v)", results, err)
+	}
+
+	msg, err := os.ReadFile(filepath.Join("testdata", "mail", "maildir", "new", "1738000000.M1P1.host"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("From center@kyushoku.example.jp Tue Jan 28 09:00:00 2025\n")
+	f.Write(msg)
+	f.Close()
+
+	results, err = ingester.IngestMboxFile(path)
+	if err != nil || len(results) != 1 || results[0].SchoolID != "minato" {
+		t.Fatalf("Expected the appended message to be ingested, got %+v (%v)", results, err)
+	}
+	if _, err := menuService.GetSchoolLunchForDate(time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected attached menu to be stored: %v", err)
+	}
+}
+
+func TestIngestMaildir(t *testing.T) {
+	menuService, _, ingester := newMailTest(t)
+	dir := t.TempDir()
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	name := "1738000000.M1P1.host"
+	data, err := os.ReadFile(filepath.Join("testdata", "mail", "maildir", "new", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new", name), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ingester.IngestMaildir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].SchoolID != "minato" || len(results[0].Attachments) != 1 {
+		t.Fatalf("Unexpected results: %+v", results)
+	}
+	// An attachment without a file name is typed by its content type
+	if a := results[0].Attachments[0]; a.Status != models.MailAttachmentProcessed {
+		t.Errorf("Expected attachment to be processed, got %+v", a)
+	}
+	lunch, err := menuService.GetSchoolLunchForDate(time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC))
+	if err != nil || lunch.MainDish != "さばのみそに" {
+		t.Errorf("Expected attached menu to be stored, got %+v (%v)", lunch, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "cur", name+":2,S")); err != nil {
+		t.Errorf("Expected message to be moved to cur: %v", err)
+	}
+	results, err = ingester.IngestMaildir(dir)
+	if err != nil || len(results) != 0 {
+		t.Errorf("Expected nothing new on the second poll, got %+v (%v)", results, err)
+	}
+}
+
+func TestIngestMaildirRetriesFailedAttachments(t *testing.T) {
+	menuService, processor, ingester := newMailTest(t)
+	dir := t.TempDir()
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	name := "1738000000.M1P1.host"
+	data, err := os.ReadFile(filepath.Join("testdata", "mail", "maildir", "new", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new", name), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Parsing fails for the first two polls
+	var parse PipelineStage
+	for _, stage := range processor.PipelineStages() {
+		if stage.Name() == models.StageParse {
+			parse = stage
+		}
+	}
+	failures := 2
+	processor.SetPipelineStage(stageFunc{name: models.StageParse, run: func(run *PipelineRun) (any, error) {
+		if failures > 0 {
+			failures--
+			return nil, errors.New("temporarily unavailable")
+		}
+		return parse.Run(run)
+	}})
+
+	for poll := 1; poll <= 2; poll++ {
+		results, err := ingester.IngestMaildir(dir)
+		if err != nil || len(results) != 1 || !results[0].Retry || results[0].Attachments[0].Status != models.MailAttachmentFailed {
+			t.Fatalf("Poll %d: expected the message to be retried, got %+v (%v)", poll, results, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "new", name)); err != nil {
+			t.Fatalf("Poll %d: expected the message to stay in new: %v", poll, err)
+		}
+	}
+	results, err := ingester.IngestMaildir(dir)
+	if err != nil || len(results) != 1 || results[0].Retry || results[0].Attachments[0].Status != models.MailAttachmentProcessed {
+		t.Fatalf("Expected the message to be ingested on the third poll, got %+v (%v)", results, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cur", name+":2,S")); err != nil {
+		t.Errorf("Expected message to be moved to cur: %v", err)
+	}
+	if _, err := menuService.GetSchoolLunchForDate(time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected attached menu to be stored: %v", err)
+	}
+}
+
+func TestIngestMessageGivesUpAfterAttempts(t *testing.T) {
+	_, processor, ingester := newMailTest(t)
+	processor.SetPipelineStage(stageFunc{name: models.StageParse, run: func(*PipelineRun) (any, error) {
+		return nil, errors.New("broken")
+	}})
+	data, err := os.ReadFile(filepath.Join("testdata", "mail", "maildir", "new", "1738000000.M1P1.host"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	for attempt := 1; attempt <= maxMailAttempts; attempt++ {
+		result, err := ingester.IngestMessage(data)
+		if err != nil || result == nil || result.Retry != (attempt < maxMailAttempts) {
+			t.Fatalf("Attempt %d: unexpected result %+v (%v)", attempt, result, err)
+		}
+	}
+	if result, err := ingester.IngestMessage(data); result != nil || err != nil {
+		t.Errorf("Expected the message to be given up on, got %+v (%v)", result, err)
+	}
+}
+
+func TestLoadMailConfigRejectsSeveralSchools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.json")
+	config := `{"senders": {"@minato-school.example.jp": "minato", "center@kyushoku.example.jp": "kyushoku-center"}}`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var verrs models.ValidationErrors
+	if _, err := LoadMailConfig(path); !errors.As(err, &verrs) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+
+	config = `{"senders": {"@minato-school.example.jp": "minato", "center@kyushoku.example.jp": "minato"}}`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := LoadMailConfig(path); err != nil || len(loaded.Senders) != 2 {
+		t.Errorf("Expected senders of one school to load, got %+v (%v)", loaded, err)
+	}
+}
@@ -2,25 +2,48 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/habuka036/menu-advisor/internal/models"
 )
 
+// ErrSchoolLunchNotFound is returned when no school lunch exists for a requested date
+var ErrSchoolLunchNotFound = errors.New("no school lunch found")
+
 // MenuAdvisorService provides menu recommendation functionality
 type MenuAdvisorService struct {
+	mu            sync.RWMutex
 	schoolLunches []models.SchoolLunchMenu
+	auditLog      []models.AuditEntry
+	versions      map[string][]models.SchoolLunchVersion
 	homeMenuDB    map[string][]models.FoodItem
+	dishes        *DishDictionary
+	recipes       *RecipeBook
+	calendar      *SchoolCalendar
+	household     models.Household
+	homeMeals     []models.HomeMeal
+	pantry        []models.PantryItem
+	now           func() time.Time
 }
 
 // NewMenuAdvisorService creates a new instance of the service
 func NewMenuAdvisorService() *MenuAdvisorService {
+	dishes := DefaultDishDictionary()
 	service := &MenuAdvisorService{
 		homeMenuDB: make(map[string][]models.FoodItem),
+		versions:   make(map[string][]models.SchoolLunchVersion),
+		dishes:     dishes,
+		recipes:    DefaultRecipeBook(dishes),
+		calendar:   NewSchoolCalendar(),
+		household:  models.Household{Children: []models.Child{}},
+		now:        time.Now,
 	}
 	service.initializeHomeMenuDatabase()
 	return service
@@ -39,26 +62,61 @@ func (s *MenuAdvisorService) LoadSchoolLunchData(filepath string) error {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
-	if err := json.Unmarshal(data, &s.schoolLunches); err != nil {
+	var lunches []models.SchoolLunchMenu
+	if err := json.Unmarshal(data, &lunches); err != nil {
 		return fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
+	s.mu.Lock()
+	s.schoolLunches = lunches
+	// The history of the menus being replaced does not apply to these
+	s.versions = make(map[string][]models.SchoolLunchVersion)
+	for i := range lunches {
+		s.recordVersionLocked(models.ChangeContext{}, models.AuditActionCreate, &lunches[i])
+	}
+	s.mu.Unlock()
+
 	return nil
 }
 
 // GetSchoolLunchForDate returns the school lunch menu for a specific date
 func (s *MenuAdvisorService) GetSchoolLunchForDate(date time.Time) (*models.SchoolLunchMenu, error) {
-	dateStr := date.Format("2006-01-02")
-	for _, lunch := range s.schoolLunches {
-		if lunch.Date.Format("2006-01-02") == dateStr {
-			return &lunch, nil
-		}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.indexOfLocked(date)
+	if i < 0 {
+		return nil, fmt.Errorf("%w for date: %s", ErrSchoolLunchNotFound, dateKey(date))
 	}
-	return nil, fmt.Errorf("no school lunch found for date: %s", dateStr)
+	lunch := s.schoolLunches[i]
+	return &lunch, nil
+}
+
+// SuggestionOptions adjusts a home menu suggestion to the child it is for
+type SuggestionOptions struct {
+	// Grade decides the school lunch portions; empty means GradeElementaryMid
+	Grade models.GradeBand
+	// ChildID selects a child of the household, whose grade replaces Grade
+	ChildID string
 }
 
 // GenerateHomeMenuSuggestion generates home menu suggestions based on school lunch
-func (s *MenuAdvisorService) GenerateHomeMenuSuggestion(date time.Time, mealType string) (*models.HomeMenuSuggestion, error) {
+func (s *MenuAdvisorService) GenerateHomeMenuSuggestion(date time.Time, mealType models.MealType) (*models.HomeMenuSuggestion, error) {
+	return s.GenerateHomeMenuSuggestionWithOptions(date, mealType, SuggestionOptions{})
+}
+
+// GenerateHomeMenuSuggestionWithOptions generates a home menu suggestion
+// based on school lunch, for the child described by opts
+func (s *MenuAdvisorService) GenerateHomeMenuSuggestionWithOptions(date time.Time, mealType models.MealType, opts SuggestionOptions) (*models.HomeMenuSuggestion, error) {
+	if !mealType.IsValid() {
+		_, err := models.ParseMealType(string(mealType))
+		return nil, err
+	}
+	grade, err := s.childGrade(opts.ChildID, opts.Grade)
+	if err != nil {
+		return nil, err
+	}
+
 	schoolLunch, err := s.GetSchoolLunchForDate(date)
 	if err != nil {
 		return nil, err
@@ -68,66 +126,256 @@ func (s *MenuAdvisorService) GenerateHomeMenuSuggestion(date time.Time, mealType
 		Date:           date,
 		MealType:       mealType,
 		SchoolLunchRef: schoolLunch.MainDish,
+		LunchNutrition: s.EstimateLunchNutrition(schoolLunch, grade),
 	}
 
 	// Generate complementary menu based on school lunch
-	if mealType == "breakfast" {
+	switch mealType {
+	case models.MealTypeBreakfast:
 		s.generateBreakfastSuggestion(suggestion, schoolLunch)
-	} else if mealType == "dinner" {
+	case models.MealTypeDinner:
 		s.generateDinnerSuggestion(suggestion, schoolLunch)
 	}
+	s.complementLunchGap(suggestion, suggestion.LunchNutrition)
+	s.attachRecipes(suggestion)
+	s.attachMissingIngredients(suggestion)
+	s.attachCookingTime(suggestion)
 
 	return suggestion, nil
 }
 
+// attachRecipes adds a summary of the recipe of every suggested dish that
+// has one, for the household's number of servings
+func (s *MenuAdvisorService) attachRecipes(suggestion *models.HomeMenuSuggestion) {
+	servings := s.householdServings()
+	names := append([]string{suggestion.MainDish}, suggestion.SideDishes...)
+	if suggestion.Soup != "" {
+		names = append(names, suggestion.Soup)
+	}
+	for _, name := range names {
+		if recipe, ok := s.recipes.Find(name); ok {
+			recipe = recipe.Scale(servings)
+			summary := recipe.Summary()
+			summary.Name = name
+			suggestion.Recipes = append(suggestion.Recipes, summary)
+		}
+	}
+}
+
+// gapShare is the share of a nutrient's lunch target below which a home meal
+// makes up for it
+const gapShare = 0.8
+
+// gapSides are the side dishes added to make up for a nutrient the school
+// lunch falls short of. Calcium and iron are the nutrients children most
+// often lack.
+var gapSides = []struct {
+	nutrient string
+	dish     string
+}{
+	{"calcium_mg", "小松菜としらすのおひたし"},
+	{"iron_mg", "ほうれん草のごま和え"},
+	{"protein_g", "冷奴"},
+	{"fiber_g", "きんぴらごぼう"},
+}
+
+// complementLunchGap adds a side dish for the nutrient the school lunch falls
+// furthest short of its target, using published or estimated values. The
+// side dish is left out when it would break the household's time budget,
+// kitchen or skill.
+func (s *MenuAdvisorService) complementLunchGap(suggestion *models.HomeMenuSuggestion, lunch *models.LunchNutrition) {
+	target := lunch.Grade.LunchTarget()
+	var nutrient models.Nutrient
+	dish, share := "", gapShare
+	for _, side := range gapSides {
+		n, _ := models.NutrientByKey(side.nutrient)
+		want := n.Value(target)
+		if !lunch.Known(n.Key) || want == 0 || suggestion.MainDish == side.dish || slices.Contains(suggestion.SideDishes, side.dish) {
+			continue
+		}
+		if got := n.Value(lunch.Nutrition) / want; got < share {
+			nutrient, dish, share = n, side.dish, got
+		}
+	}
+	if dish == "" {
+		return
+	}
+
+	estimated := ""
+	if lunch.Sources[nutrient.Key] == models.NutritionEstimated {
+		estimated = "（推定）"
+	}
+	// A menu that already breaks the conditions is not held to them
+	k := s.kitchenFor(suggestion.Date, suggestion.MealType)
+	dishes := homeMenu{main: suggestion.MainDish, sides: suggestion.SideDishes, soup: suggestion.Soup}.dishes()
+	if len(s.misfits(dishes, k)) == 0 {
+		if notes := s.misfits(append(dishes, dish), k); len(notes) > 0 {
+			suggestion.Reason += fmt.Sprintf("。給食の%sが目安の%d%%%sだが、%sため%sは加えない", nutrient.Label, int(share*100), estimated, strings.Join(notes, "、"), dish)
+			return
+		}
+	}
+	suggestion.SideDishes = append(suggestion.SideDishes, dish)
+	suggestion.Reason += fmt.Sprintf("。給食の%sが目安の%d%%%sのため、%sで補う", nutrient.Label, int(share*100), estimated, dish)
+}
+
+// Dishes returns the dictionary used to recognize dish names
+func (s *MenuAdvisorService) Dishes() *DishDictionary {
+	return s.dishes
+}
+
+// Recipes returns the recipes of the dishes in the dish dictionary
+func (s *MenuAdvisorService) Recipes() *RecipeBook {
+	return s.recipes
+}
+
+// Calendar returns the calendar of days the school serves lunch
+func (s *MenuAdvisorService) Calendar() *SchoolCalendar {
+	return s.calendar
+}
+
+// lunchProfile is what the recommender needs to know about a school lunch
+type lunchProfile struct {
+	Protein models.ProteinSource
+	Curry   bool
+}
+
+// proteinKeywords guess the protein of a dish missing from the dictionary,
+// checked in order
+var proteinKeywords = []struct {
+	protein  models.ProteinSource
+	keywords []string
+}{
+	{models.ProteinChicken, []string{"鶏", "とり", "チキン"}},
+	{models.ProteinFish, []string{"魚", "鮭", "さけ", "サーモン", "鯖", "さば", "サバ", "鰆", "さわら", "鰤", "ぶり", "鱈", "たら", "あじ", "アジ", "いわし"}},
+	{models.ProteinPork, []string{"豚", "ポーク"}},
+	{models.ProteinBeef, []string{"牛", "ビーフ"}},
+	{models.ProteinMeat, []string{"肉", "ハンバーグ", "ミート"}},
+	{models.ProteinEgg, []string{"卵", "玉子", "たまご", "オムレツ"}},
+	{models.ProteinSoy, []string{"豆腐", "納豆"}},
+}
+
+// analyzeLunch looks up the main dish of a lunch in the dish dictionary,
+// falling back to the words in its name for dishes the dictionary lacks. A
+// dish matched by edit distance alone may be another dish of the same kind,
+// so the words in its name come first.
+func (s *MenuAdvisorService) analyzeLunch(lunch *models.SchoolLunchMenu) lunchProfile {
+	var profile lunchProfile
+	name := cleanDishName(lunch.MainDish)
+	dish, sure, ok := s.lunchDish(lunch, lunch.MainDish)
+	if ok && sure {
+		profile.Protein = dish.Protein
+		profile.Curry = dish.HasTag("curry")
+	} else {
+		profile.Protein = guessProtein(name)
+		if profile.Protein == "" && ok {
+			profile.Protein = dish.Protein
+		}
+	}
+	profile.Curry = profile.Curry || strings.Contains(name, "カレー")
+	return profile
+}
+
+// lunchDish looks up a dish of a school lunch by the ID recorded when the
+// menu was imported, else by its name. sure is false for a dish matched by
+// edit distance alone.
+func (s *MenuAdvisorService) lunchDish(lunch *models.SchoolLunchMenu, name string) (dish models.Dish, sure, ok bool) {
+	if id, recorded := lunch.DishIDs[name]; recorded {
+		if dish, ok := s.dishes.Get(id); ok {
+			return dish, true, true
+		}
+	}
+	match := s.dishes.Match(name)
+	dish, ok = s.dishes.Get(match.DishID)
+	return dish, match.Method != models.DishMatchFuzzy, ok
+}
+
+// guessProtein returns the protein named by the first matching keyword
+func guessProtein(name string) models.ProteinSource {
+	for _, p := range proteinKeywords {
+		for _, keyword := range p.keywords {
+			if strings.Contains(name, keyword) {
+				return p.protein
+			}
+		}
+	}
+	return ""
+}
+
 func (s *MenuAdvisorService) generateBreakfastSuggestion(suggestion *models.HomeMenuSuggestion, schoolLunch *models.SchoolLunchMenu) {
 	// For breakfast, focus on lighter options that complement lunch
-	if strings.Contains(schoolLunch.MainDish, "魚") {
-		suggestion.MainDish = "卵焼き"
-		suggestion.SideDishes = []string{"のり", "みそ汁"}
-		suggestion.Reason = "昼食で魚を摂取するため、朝食ではタンパク質として卵を提案"
-	} else if strings.Contains(schoolLunch.MainDish, "肉") {
-		suggestion.MainDish = "焼き魚（アジ）"
-		suggestion.SideDishes = []string{"野菜サラダ", "みそ汁"}
-		suggestion.Reason = "昼食で肉類を摂取するため、朝食では魚でバランスを取る"
-	} else if strings.Contains(schoolLunch.MainDish, "カレー") {
-		suggestion.MainDish = "納豆"
-		suggestion.SideDishes = []string{"野菜炒め", "みそ汁"}
-		suggestion.Reason = "昼食が重めのカレーのため、朝食は軽めの和食で消化を助ける"
-	} else {
-		suggestion.MainDish = "焼き鮭"
-		suggestion.SideDishes = []string{"おひたし", "みそ汁"}
-		suggestion.Reason = "栄養バランスを考慮した和食中心の朝食"
+	reason, menus := breakfastMenus(s.analyzeLunch(schoolLunch))
+	s.chooseHomeMenu(suggestion, reason, menus)
+}
+
+// breakfastMenus returns the reason for the breakfasts that complement a
+// lunch and the breakfasts, the one the rule prefers first
+func breakfastMenus(profile lunchProfile) (string, []homeMenu) {
+	switch {
+	case profile.Protein == models.ProteinFish:
+		return "昼食で魚を摂取するため、朝食ではタンパク質として卵を提案", []homeMenu{
+			{main: "卵焼き", sides: []string{"のり", "みそ汁"}},
+			{main: "卵焼き", sides: []string{"小松菜のごま和え", "みそ汁"}},
+			{main: "卵焼き", sides: []string{"野菜サラダ", "みそ汁"}},
+		}
+	case profile.Protein.IsMeat():
+		return "昼食で肉類を摂取するため、朝食では魚でバランスを取る", []homeMenu{
+			{main: "焼き魚（アジ）", sides: []string{"野菜サラダ", "みそ汁"}},
+			{main: "焼き鮭", sides: []string{"小松菜のごま和え", "みそ汁"}},
+			{main: "鯖の塩焼き", sides: []string{"ほうれん草のおひたし", "みそ汁"}},
+		}
+	case profile.Curry:
+		return "昼食が重めのカレーのため、朝食は軽めの和食で消化を助ける", []homeMenu{
+			{main: "納豆", sides: []string{"野菜炒め", "みそ汁"}},
+			{main: "冷奴", sides: []string{"小松菜のごま和え", "みそ汁"}},
+			{main: "納豆", sides: []string{"ほうれん草のおひたし", "みそ汁"}},
+		}
+	}
+	return "栄養バランスを考慮した和食中心の朝食", []homeMenu{
+		{main: "焼き鮭", sides: []string{"おひたし", "みそ汁"}},
+		{main: "卵焼き", sides: []string{"小松菜としらすのおひたし", "みそ汁"}},
+		{main: "納豆", sides: []string{"ほうれん草のごま和え", "みそ汁"}},
 	}
 }
 
 func (s *MenuAdvisorService) generateDinnerSuggestion(suggestion *models.HomeMenuSuggestion, schoolLunch *models.SchoolLunchMenu) {
 	// For dinner, complement what was missing in lunch or provide variety
-	if strings.Contains(schoolLunch.MainDish, "鶏") {
-		suggestion.MainDish = "魚の煮付け"
-		suggestion.SideDishes = []string{"野菜の天ぷら", "白米"}
-		suggestion.Soup = "すまし汁"
-		suggestion.Reason = "昼食で鶏肉を摂取したため、夕食では魚でタンパク質の種類を変える"
-	} else if strings.Contains(schoolLunch.MainDish, "魚") {
-		suggestion.MainDish = "豚しゃぶしゃぶ"
-		suggestion.SideDishes = []string{"温野菜", "白米"}
-		suggestion.Soup = "みそ汁"
-		suggestion.Reason = "昼食で魚を摂取したため、夕食では豚肉でタンパク質の種類を変える"
-	} else if strings.Contains(schoolLunch.MainDish, "豚") {
-		suggestion.MainDish = "鯖の塩焼き"
-		suggestion.SideDishes = []string{"筑前煮", "白米"}
-		suggestion.Soup = "わかめスープ"
-		suggestion.Reason = "昼食で豚肉を摂取したため、夕食では魚でバランスを取る"
-	} else if strings.Contains(schoolLunch.MainDish, "カレー") {
-		suggestion.MainDish = "鶏の唐揚げ"
-		suggestion.SideDishes = []string{"キャベツサラダ", "白米"}
-		suggestion.Soup = "みそ汁"
-		suggestion.Reason = "昼食がスパイシーなカレーのため、夕食は優しい味付けの料理で胃を休める"
-	} else {
-		suggestion.MainDish = "牛肉炒め"
-		suggestion.SideDishes = []string{"もやし炒め", "白米"}
-		suggestion.Soup = "中華スープ"
-		suggestion.Reason = "栄養バランスを考慮したボリュームのある夕食"
+	reason, menus := dinnerMenus(s.analyzeLunch(schoolLunch))
+	s.chooseHomeMenu(suggestion, reason, menus)
+}
+
+// dinnerMenus returns the reason for the dinners that complement a lunch
+// and the dinners, the one the rule prefers first
+func dinnerMenus(profile lunchProfile) (string, []homeMenu) {
+	switch {
+	case profile.Protein == models.ProteinChicken:
+		return "昼食で鶏肉を摂取したため、夕食では魚でタンパク質の種類を変える", []homeMenu{
+			{main: "魚の煮付け", sides: []string{"野菜の天ぷら", "白米"}, soup: "すまし汁"},
+			{main: "鯖の塩焼き", sides: []string{"小松菜のごま和え", "白米"}, soup: "みそ汁"},
+			{main: "焼き鮭", sides: []string{"きんぴらごぼう", "白米"}, soup: "みそ汁"},
+		}
+	case profile.Protein == models.ProteinFish:
+		return "昼食で魚を摂取したため、夕食では豚肉でタンパク質の種類を変える", []homeMenu{
+			{main: "豚しゃぶしゃぶ", sides: []string{"温野菜", "白米"}, soup: "みそ汁"},
+			{main: "豚しゃぶしゃぶ", sides: []string{"小松菜のごま和え", "白米"}, soup: "わかめスープ"},
+			{main: "豚しゃぶしゃぶ", sides: []string{"もやし炒め", "白米"}, soup: "中華スープ"},
+		}
+	case profile.Protein == models.ProteinPork:
+		return "昼食で豚肉を摂取したため、夕食では魚でバランスを取る", []homeMenu{
+			{main: "鯖の塩焼き", sides: []string{"筑前煮", "白米"}, soup: "わかめスープ"},
+			{main: "魚の煮付け", sides: []string{"小松菜のごま和え", "白米"}, soup: "みそ汁"},
+			{main: "焼き鮭", sides: []string{"温野菜", "白米"}, soup: "すまし汁"},
+		}
+	case profile.Curry:
+		return "昼食がスパイシーなカレーのため、夕食は優しい味付けの料理で胃を休める", []homeMenu{
+			{main: "鶏の唐揚げ", sides: []string{"キャベツサラダ", "白米"}, soup: "みそ汁"},
+			{main: "豚しゃぶしゃぶ", sides: []string{"温野菜", "白米"}, soup: "すまし汁"},
+			{main: "魚の煮付け", sides: []string{"ほうれん草のおひたし", "白米"}, soup: "みそ汁"},
+		}
+	}
+	return "栄養バランスを考慮したボリュームのある夕食", []homeMenu{
+		{main: "牛肉炒め", sides: []string{"もやし炒め", "白米"}, soup: "中華スープ"},
+		{main: "鶏の唐揚げ", sides: []string{"小松菜のごま和え", "白米"}, soup: "みそ汁"},
+		{main: "豚しゃぶしゃぶ", sides: []string{"野菜炒め", "白米"}, soup: "わかめスープ"},
 	}
 }
 
@@ -168,22 +416,56 @@ func (s *MenuAdvisorService) initializeHomeMenuDatabase() {
 
 // GetAllSchoolLunches returns all loaded school lunch menus
 func (s *MenuAdvisorService) GetAllSchoolLunches() []models.SchoolLunchMenu {
-	return s.schoolLunches
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	lunches := make([]models.SchoolLunchMenu, len(s.schoolLunches))
+	copy(lunches, s.schoolLunches)
+	return lunches
 }
 
 // AddSchoolLunchMenu adds a new school lunch menu to the service
 func (s *MenuAdvisorService) AddSchoolLunchMenu(menu models.SchoolLunchMenu) {
+	s.AddSchoolLunchMenuWithContext(menu, models.ChangeContext{})
+}
+
+// AddSchoolLunchMenuWithContext adds or replaces a school lunch menu and
+// records the change in the audit log under the given actor
+func (s *MenuAdvisorService) AddSchoolLunchMenuWithContext(menu models.SchoolLunchMenu, ctx models.ChangeContext) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putLocked(menu, ctx)
+}
+
+// putLocked stores the menu, replacing any menu for the same date. Caller must hold s.mu.
+func (s *MenuAdvisorService) putLocked(menu models.SchoolLunchMenu, ctx models.ChangeContext) {
 	// Check if menu for this date already exists and update it
-	dateStr := menu.Date.Format("2006-01-02")
-	for i, existingMenu := range s.schoolLunches {
-		if existingMenu.Date.Format("2006-01-02") == dateStr {
-			s.schoolLunches[i] = menu
-			return
-		}
+	if i := s.indexOfLocked(menu.Date); i >= 0 {
+		old := s.schoolLunches[i]
+		s.schoolLunches[i] = menu
+		s.recordChangeLocked(ctx, models.AuditActionUpdate, menu.Date, &old, &menu)
+		return
 	}
-	
+
 	// Add new menu if not found
 	s.schoolLunches = append(s.schoolLunches, menu)
+	s.recordChangeLocked(ctx, models.AuditActionCreate, menu.Date, nil, &menu)
+}
+
+// indexOfLocked returns the index of the menu for the given date, or -1. Caller must hold s.mu.
+func (s *MenuAdvisorService) indexOfLocked(date time.Time) int {
+	key := dateKey(date)
+	for i, lunch := range s.schoolLunches {
+		if dateKey(lunch.Date) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// dateKey formats a date as the YYYY-MM-DD key used to identify a school day
+func dateKey(t time.Time) string {
+	return t.Format("2006-01-02")
 }
 
 // AddSchoolLunchMenus adds multiple school lunch menus to the service
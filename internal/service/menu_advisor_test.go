@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -89,4 +90,24 @@ func TestGetSchoolLunchForDate(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for non-existing date")
 	}
-}
\ No newline at end of file
+}
+
+func TestGenerateHomeMenuSuggestionErrors(t *testing.T) {
+	service := NewMenuAdvisorService()
+	testDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	service.schoolLunches = []models.SchoolLunchMenu{{Date: testDate, MainDish: "カレーライス"}}
+
+	_, err := service.GenerateHomeMenuSuggestion(testDate, "lunch")
+	var verrs models.ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("Expected validation error for unknown meal type, got: %v", err)
+	}
+	if verrs[0].Field != "meal_type" {
+		t.Errorf("Expected field 'meal_type', got: %s", verrs[0].Field)
+	}
+
+	_, err = service.GenerateHomeMenuSuggestion(testDate.AddDate(0, 0, 1), models.MealTypeDinner)
+	if !errors.Is(err, ErrSchoolLunchNotFound) {
+		t.Errorf("Expected ErrSchoolLunchNotFound, got: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// maxDocumentDistance is how far a menu's date may be from the date of its
+// document before it is reported, catching misread years and months
+const maxDocumentDistance = 120 * 24 * time.Hour
+
+// nutritionRange is the plausible range of one nutrient of a school lunch.
+// Values outside [low, high] are reported as warnings and values above max as
+// errors. A zero value was not printed and is not checked.
+type nutritionRange struct {
+	nutrient       string
+	low, high, max float64
+}
+
+// nutritionRanges are based on the school lunch intake standards, which ask
+// for about 530 kcal for the youngest pupils and 830 kcal in junior high school
+var nutritionRanges = []nutritionRange{
+	{"calories", 300, 1200, 3000},
+	{"protein_g", 10, 60, 200},
+	{"carbs_g", 40, 200, 500},
+	{"fat_g", 5, 50, 200},
+	{"fiber_g", 1, 15, 100},
+	{"sodium_mg", 200, 2000, 10000},
+	{"vegetables_servings", 0, 6, 20},
+	{"calcium_mg", 50, 700, 3000},
+	{"iron_mg", 0.5, 10, 50},
+	{"vitamin_a_ug", 0, 1000, 5000},
+	{"vitamin_b1_mg", 0, 2, 20},
+	{"vitamin_b2_mg", 0, 2, 20},
+	{"vitamin_c_mg", 0, 150, 2000},
+	{"magnesium_mg", 0, 300, 2000},
+	{"zinc_mg", 0, 10, 50},
+	{"salt_g", 0.5, 5, 25},
+}
+
+// ValidateMenus checks the menus parsed from one document. Errors are found
+// in days that cannot be stored as they are: a missing date or main dish,
+// impossible nutrition values, or one day printed twice with different
+// menus. Warnings are found in days that are merely unusual: a day the
+// calendar has no lunch on, a date far from documentDate (skipped when it is
+// zero), nutrition outside the usual range, or the same menu printed twice,
+// in which case the later copies are ignored.
+func ValidateMenus(menus []models.SchoolLunchMenu, calendar *SchoolCalendar, documentDate time.Time) *models.MenuValidation {
+	validation := &models.MenuValidation{Warnings: []models.MenuIssue{}, Errors: []models.MenuIssue{}}
+	first := make(map[string]int)
+	for i, menu := range menus {
+		day := menuDay(menu)
+		add := func(severity models.IssueSeverity, code, field, format string, args ...any) {
+			validation.Add(models.MenuIssue{
+				Index: i, Date: day, Field: field, Severity: severity, Code: code,
+				Message: fmt.Sprintf(format, args...),
+			})
+		}
+
+		if day == "" {
+			add(models.IssueError, models.IssueMissingDate, "date", "menu %d has no date", i+1)
+		} else {
+			if j, ok := first[day]; !ok {
+				first[day] = i
+			} else if reflect.DeepEqual(menus[j], menu) {
+				add(models.IssueWarning, models.IssueDuplicateDay, "date", "%s is listed again with the same menu; the copy is ignored", day)
+			} else {
+				add(models.IssueError, models.IssueDuplicateDay, "date", "%s is listed more than once with different menus", day)
+			}
+			if reason, off := calendar.DayOff(menu.Date); off {
+				add(models.IssueWarning, models.IssueNoSchool, "date", "%s has no school lunch (%s)", day, reason)
+			}
+			if !documentDate.IsZero() {
+				if d := menu.Date.Sub(documentDate); d > maxDocumentDistance || d < -maxDocumentDistance {
+					add(models.IssueWarning, models.IssueDateOutOfPeriod, "date", "%s is more than %d days from the document date %s",
+						day, int(maxDocumentDistance.Hours()/24), dateKey(documentDate))
+				}
+			}
+		}
+
+		if strings.TrimSpace(menu.MainDish) == "" {
+			add(models.IssueError, models.IssueMissingMainDish, "main_dish", "%s has no main dish", dayOrIndex(day, i))
+		}
+
+		for _, r := range nutritionRanges {
+			nutrient, _ := models.NutrientByKey(r.nutrient)
+			field := "nutrition." + r.nutrient
+			switch v := nutrient.Value(menu.Nutrition); {
+			case v == 0:
+			case v < 0 || v > r.max:
+				add(models.IssueError, models.IssueNutritionImplausible, field, "%s of %g is not possible for a school lunch", field, v)
+			case v < r.low || v > r.high:
+				add(models.IssueWarning, models.IssueNutritionRange, field, "%s of %g is outside the usual range %g-%g", field, v, r.low, r.high)
+			}
+		}
+	}
+
+	// Every copy of a day printed with different menus is in error, not only the later ones
+	for _, issue := range validation.Errors {
+		if issue.Code == models.IssueDuplicateDay {
+			if j := first[issue.Date]; !hasIssue(validation.Errors, j, models.IssueDuplicateDay) {
+				validation.Add(models.MenuIssue{
+					Index: j, Date: issue.Date, Field: "date", Severity: models.IssueError, Code: models.IssueDuplicateDay,
+					Message: fmt.Sprintf("%s is listed more than once with different menus", issue.Date),
+				})
+			}
+		}
+	}
+	sort.SliceStable(validation.Errors, func(i, j int) bool { return validation.Errors[i].Index < validation.Errors[j].Index })
+	return validation
+}
+
+// dayOrIndex names a menu by its date, or by its position when it has none
+func dayOrIndex(day string, i int) string {
+	if day != "" {
+		return day
+	}
+	return fmt.Sprintf("menu %d", i+1)
+}
+
+// hasIssue reports whether issues include one with code for the menu at index
+func hasIssue(issues []models.MenuIssue, index int, code string) bool {
+	for _, issue := range issues {
+		if issue.Index == index && issue.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectedMenus returns the menus that are left out of the merge, those with
+// errors and repeated copies of a day, by index with the message of their
+// first issue
+func rejectedMenus(validation *models.MenuValidation) map[int]string {
+	rejected := make(map[int]string)
+	reject := func(issue models.MenuIssue) {
+		if _, ok := rejected[issue.Index]; !ok {
+			rejected[issue.Index] = issue.Message
+		}
+	}
+	for _, issue := range validation.Errors {
+		reject(issue)
+	}
+	for _, issue := range validation.Warnings {
+		if issue.Code == models.IssueDuplicateDay {
+			reject(issue)
+		}
+	}
+	return rejected
+}
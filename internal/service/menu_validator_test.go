@@ -0,0 +1,104 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestValidateMenus(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 0, 0, 0, 0, time.UTC) }
+	menus := []models.SchoolLunchMenu{
+		{Date: day(9), MainDish: "カレーライス", Nutrition: models.Nutrition{Calories: 650, Protein: 22}},
+		{Date: day(10), MainDish: "親子丼", Nutrition: models.Nutrition{Calories: 6500}},
+		{Date: day(11), MainDish: "鯖の味噌煮", Nutrition: models.Nutrition{Calories: 1500, Sodium: -1}},
+		{Date: day(12), MainDish: " "},
+		{Date: day(9), MainDish: "カレーライス", Nutrition: models.Nutrition{Calories: 650, Protein: 22}},
+		{Date: day(13), MainDish: "ハンバーグ"},
+		{Date: day(13), MainDish: "焼きそば"},
+		{Date: day(15), MainDish: "焼き魚"},
+		{MainDish: "ミートソース"},
+		{Date: time.Date(2052, 6, 17, 0, 0, 0, 0, time.UTC), MainDish: "肉じゃが"},
+	}
+	validation := ValidateMenus(menus, NewSchoolCalendar(), day(1))
+
+	type issue struct {
+		index int
+		code  string
+		field string
+	}
+	expectedErrors := []issue{
+		{1, models.IssueNutritionImplausible, "nutrition.calories"},
+		{2, models.IssueNutritionImplausible, "nutrition.sodium_mg"},
+		{3, models.IssueMissingMainDish, "main_dish"},
+		{5, models.IssueDuplicateDay, "date"},
+		{6, models.IssueDuplicateDay, "date"},
+		{8, models.IssueMissingDate, "date"},
+	}
+	expectedWarnings := []issue{
+		{2, models.IssueNutritionRange, "nutrition.calories"},
+		{4, models.IssueDuplicateDay, "date"},
+		{7, models.IssueNoSchool, "date"},
+		{9, models.IssueDateOutOfPeriod, "date"},
+	}
+	check := func(kind string, got []models.MenuIssue, expected []issue) {
+		if len(got) != len(expected) {
+			t.Fatalf("Expected %d %s, got %+v", len(expected), kind, got)
+		}
+		for i, e := range expected {
+			if got[i].Index != e.index || got[i].Code != e.code || got[i].Field != e.field {
+				t.Errorf("%s[%d]: expected %+v, got %+v", kind, i, e, got[i])
+			}
+		}
+	}
+	check("errors", validation.Errors, expectedErrors)
+	check("warnings", validation.Warnings, expectedWarnings)
+
+	rejected := rejectedMenus(validation)
+	for _, i := range []int{1, 2, 3, 4, 5, 6, 8} {
+		if reason, ok := rejected[i]; !ok || reason == "" {
+			t.Errorf("Expected menu %d to be rejected with a reason", i)
+		}
+	}
+	if _, ok := rejected[0]; ok || rejected[7] != "" || rejected[9] != "" {
+		t.Errorf("Expected menus with only warnings to be kept, got %v", rejected)
+	}
+}
+
+func TestProcessDocumentValidation(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+
+	doc, err := processor.ProcessDocument(batchRequest("menu.json", []byte(`[
+		{"date":"2025-06-09T00:00:00Z","main_dish":"カレーライス","nutrition":{"calories":6500}},
+		{"date":"2025-06-10T00:00:00Z","main_dish":""},
+		{"date":"2025-06-11T00:00:00Z","main_dish":"親子丼"},
+		{"date":"2025-06-15T00:00:00Z","main_dish":"焼き魚"}
+	]`)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if doc.Status != "completed" || doc.Validation == nil || len(doc.Validation.Errors) != 2 || len(doc.Validation.Warnings) != 1 {
+		t.Fatalf("Unexpected result: %+v %+v", doc, doc.Validation)
+	}
+	if warning := doc.Validation.Warnings[0]; warning.Code != models.IssueNoSchool || warning.Date != "2025-06-15" {
+		t.Errorf("Expected Sunday to be reported, got %+v", warning)
+	}
+
+	report := doc.MergeReport
+	if report.Summary[models.DayMergeAdded] != 2 || report.Summary[models.DayMergeInvalid] != 2 {
+		t.Errorf("Unexpected summary: %v", report.Summary)
+	}
+	if _, err := menuService.GetSchoolLunchForDate(time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("Expected the day with impossible calories to be left out")
+	}
+	if _, err := menuService.GetSchoolLunchForDate(time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected a day with only warnings to be stored: %v", err)
+	}
+
+	trace, _ := processor.GetTrace(doc.ID)
+	if stage, _ := trace.Stage(models.StageValidate); stage.Status != models.StageStatusOK {
+		t.Errorf("Expected the validate stage to run, got %+v", stage)
+	}
+}
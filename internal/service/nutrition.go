@@ -0,0 +1,245 @@
+package service
+
+import (
+	"slices"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// estimatedNutrients are the nutrients the dish dictionary gives; the others
+// are only known when printed on the menu
+var estimatedNutrients = map[string]bool{
+	"calories": true, "protein_g": true, "carbs_g": true, "fat_g": true, "fiber_g": true,
+	"sodium_mg": true, "vegetables_servings": true, "calcium_mg": true, "iron_mg": true, "salt_g": true,
+}
+
+// minEstimatedShare is the share of a lunch's dishes that must be found in
+// the dish dictionary for its nutrition to be estimated
+const minEstimatedShare = 0.75
+
+// LunchNutrition returns the nutrition of the school lunch on date for the grade band
+func (s *MenuAdvisorService) LunchNutrition(date time.Time, grade models.GradeBand) (*models.LunchNutrition, error) {
+	lunch, err := s.GetSchoolLunchForDate(date)
+	if err != nil {
+		return nil, err
+	}
+	return s.EstimateLunchNutrition(lunch, grade), nil
+}
+
+// EstimateLunchNutrition returns the nutrition of a school lunch for the
+// grade band. Values printed on the menu are kept as they are. The others are
+// estimated from the dishes found in the dish dictionary, with portions scaled
+// to the grade band; drinks such as milk are the same for every grade. The
+// dishes missing from the dictionary are left out, so a lunch is only
+// estimated when its main dish and most of its dishes were found; otherwise
+// the estimate would fall well short and its dishes are only listed.
+func (s *MenuAdvisorService) EstimateLunchNutrition(lunch *models.SchoolLunchMenu, grade models.GradeBand) *models.LunchNutrition {
+	result := &models.LunchNutrition{
+		Date:    dateKey(lunch.Date),
+		Grade:   grade,
+		Sources: map[string]models.NutritionSource{},
+	}
+
+	var estimate models.Nutrition
+	result.Dishes, estimate, result.Unmatched = s.estimateDishes(lunchDishes(lunch), grade)
+	if result.Dishes == nil {
+		result.Dishes = []models.DishNutrition{}
+	}
+
+	matched := float64(len(result.Dishes)) / float64(len(result.Dishes)+len(result.Unmatched))
+	estimable := len(result.Dishes) > 0 && !slices.Contains(result.Unmatched, lunch.MainDish) && matched >= minEstimatedShare
+
+	published, estimated := 0, 0
+	for _, nutrient := range models.Nutrients {
+		value := nutrient.Value(lunch.Nutrition)
+		switch {
+		case value != 0:
+			result.Sources[nutrient.Key] = models.NutritionPublished
+			published++
+		case estimable && estimatedNutrients[nutrient.Key]:
+			value = nutrient.Value(estimate)
+			result.Sources[nutrient.Key] = models.NutritionEstimated
+			estimated++
+		}
+		nutrient.SetValue(&result.Nutrition, value)
+	}
+	result.Nutrition = result.Nutrition.Round()
+
+	switch {
+	case published > 0 && estimated > 0:
+		result.Source = models.NutritionMixed
+	case published > 0:
+		result.Source = models.NutritionPublished
+	case estimated > 0:
+		result.Source = models.NutritionEstimated
+	}
+	return result
+}
+
+// lunchDishes returns the names of a school lunch's dishes in serving order
+func lunchDishes(lunch *models.SchoolLunchMenu) []string {
+	names := append([]string{lunch.MainDish}, lunch.SideDishes...)
+	names = append(names, lunch.Soup, lunch.Dessert)
+	return slices.DeleteFunc(names, func(name string) bool { return name == "" })
+}
+
+// estimateDishes estimates the nutrition of each named dish found in the dish
+// dictionary, with portions scaled to the grade band, and returns the names
+// it could not find
+func (s *MenuAdvisorService) estimateDishes(names []string, grade models.GradeBand) ([]models.DishNutrition, models.Nutrition, []string) {
+	var dishes []models.DishNutrition
+	var total models.Nutrition
+	var unmatched []string
+	for _, name := range names {
+		dish, ok := s.dishes.Get(s.dishes.Match(name).DishID)
+		if !ok || dish.Nutrition == nil {
+			unmatched = append(unmatched, name)
+			continue
+		}
+		n := *dish.Nutrition
+		if n.Salt == 0 {
+			n.Salt = models.SodiumToSalt(n.Sodium)
+		}
+		if dish.Category != models.DishCategoryDrink {
+			n = n.Scale(grade.PortionFactor())
+		}
+		n = n.Round()
+		dishes = append(dishes, models.DishNutrition{Name: name, DishID: dish.ID, Nutrition: n})
+		total = total.Plus(n)
+	}
+	return dishes, total, unmatched
+}
+
+// EstimateHomeMealNutrition returns the nutrition of a child's portion of a
+// home meal. Nutrition given with the meal is for one portion of the
+// standard grade band, like the dish dictionary's, and is scaled to the
+// child's grade band; a school lunch's printed values are already the
+// child's portion and are not. Meals without nutrition are estimated from
+// the dish names.
+func (s *MenuAdvisorService) EstimateHomeMealNutrition(meal *models.HomeMeal, grade models.GradeBand) models.MealNutrition {
+	result := models.MealNutrition{Meal: string(meal.MealType), MealID: meal.ID, Dishes: meal.Dishes(), Sources: map[string]models.NutritionSource{}}
+	if meal.Nutrition != nil {
+		result.Nutrition = meal.Nutrition.Scale(grade.PortionFactor()).Round()
+		result.Source = models.NutritionPublished
+		for _, nutrient := range models.Nutrients {
+			if nutrient.Value(*meal.Nutrition) != 0 {
+				result.Sources[nutrient.Key] = models.NutritionPublished
+			}
+		}
+		return result
+	}
+	dishes, total, unmatched := s.estimateDishes(result.Dishes, grade)
+	result.Nutrition, result.Unmatched = total.Round(), unmatched
+	if len(dishes) > 0 {
+		result.Source = models.NutritionEstimated
+		for key := range estimatedNutrients {
+			result.Sources[key] = models.NutritionEstimated
+		}
+	}
+	return result
+}
+
+// DailyNutrition adds up what a child eats on date: the school lunch, if
+// there is one, and the accepted home meals. The child is chosen by childID,
+// or only their grade band is given when childID is empty.
+func (s *MenuAdvisorService) DailyNutrition(date time.Time, childID string, grade models.GradeBand) (*models.DailyNutrition, error) {
+	grade, err := s.childGrade(childID, grade)
+	if err != nil {
+		return nil, err
+	}
+	return s.dailyNutrition(date, childID, grade), nil
+}
+
+func (s *MenuAdvisorService) dailyNutrition(date time.Time, childID string, grade models.GradeBand) *models.DailyNutrition {
+	day := &models.DailyNutrition{
+		Date:    dateKey(date),
+		ChildID: childID,
+		Grade:   grade,
+		Meals:   []models.MealNutrition{},
+		Target:  grade.DailyTarget(),
+	}
+	lunchAdded := false
+	addLunch := func() {
+		lunchAdded = true
+		lunch, err := s.GetSchoolLunchForDate(date)
+		if err != nil {
+			return
+		}
+		nutrition := s.EstimateLunchNutrition(lunch, grade)
+		day.Meals = append(day.Meals, models.MealNutrition{
+			Meal:      "lunch",
+			Dishes:    lunchDishes(lunch),
+			Nutrition: nutrition.Nutrition,
+			Source:    nutrition.Source,
+			Sources:   nutrition.Sources,
+			Unmatched: nutrition.Unmatched,
+		})
+	}
+	for _, meal := range s.HomeMeals(date, date) {
+		if meal.MealType != models.MealTypeBreakfast && !lunchAdded {
+			addLunch()
+		}
+		day.Meals = append(day.Meals, s.EstimateHomeMealNutrition(&meal, grade))
+	}
+	if !lunchAdded {
+		addLunch()
+	}
+
+	day.Sources = map[string]models.NutritionSource{}
+	for _, nutrient := range models.Nutrients {
+		var source models.NutritionSource
+		for _, meal := range day.Meals {
+			if meal.Sources[nutrient.Key] == "" {
+				source = ""
+				break
+			}
+			source = models.CombineSources(source, meal.Sources[nutrient.Key])
+		}
+		if source != "" {
+			day.Sources[nutrient.Key] = source
+		}
+	}
+	for _, meal := range day.Meals {
+		day.Total = day.Total.Plus(meal.Nutrition)
+	}
+	day.Total = day.Total.Round()
+	day.Nutrients = models.CompareNutrition(day.Total, day.Target, day.Sources)
+	return day
+}
+
+// WeeklyNutrition adds up what a child eats in the week from Monday to
+// Sunday that includes date, against seven days of targets. Each nutrient is
+// compared over the days it is known for only, so days without meals do not
+// count as eating nothing.
+func (s *MenuAdvisorService) WeeklyNutrition(date time.Time, childID string, grade models.GradeBand) (*models.WeeklyNutrition, error) {
+	grade, err := s.childGrade(childID, grade)
+	if err != nil {
+		return nil, err
+	}
+	monday := date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+	week := &models.WeeklyNutrition{
+		From:    dateKey(monday),
+		To:      dateKey(monday.AddDate(0, 0, 6)),
+		ChildID: childID,
+		Grade:   grade,
+		Target:  grade.DailyTarget().Scale(7).Round(),
+	}
+	var known, target models.Nutrition
+	week.Sources = map[string]models.NutritionSource{}
+	for i := 0; i < 7; i++ {
+		day := s.dailyNutrition(monday.AddDate(0, 0, i), childID, grade)
+		week.Days = append(week.Days, *day)
+		week.Total = week.Total.Plus(day.Total)
+		for _, nutrient := range models.Nutrients {
+			if source := day.Sources[nutrient.Key]; source != "" {
+				nutrient.SetValue(&known, nutrient.Value(known)+nutrient.Value(day.Total))
+				nutrient.SetValue(&target, nutrient.Value(target)+nutrient.Value(day.Target))
+				week.Sources[nutrient.Key] = models.CombineSources(week.Sources[nutrient.Key], source)
+			}
+		}
+	}
+	week.Total = week.Total.Round()
+	week.Nutrients = models.CompareNutrition(known.Round(), target.Round(), week.Sources)
+	return week, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestEstimateLunchNutrition(t *testing.T) {
+	service := NewMenuAdvisorService()
+	lunch := &models.SchoolLunchMenu{
+		Date:       time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC),
+		MainDish:   "鶏肉の照り焼き",
+		SideDishes: []string{"ごはん", "牛乳", "季節のフルーツ"},
+		Soup:       "みそ汁（豆腐）",
+	}
+
+	mid := service.EstimateLunchNutrition(lunch, models.GradeElementaryMid)
+	// 170 + 234 + 126 + 40
+	if mid.Source != models.NutritionEstimated || mid.Nutrition.Calories != 570 || len(mid.Dishes) != 4 {
+		t.Fatalf("Unexpected estimate: %+v", mid)
+	}
+	if len(mid.Unmatched) != 1 || mid.Unmatched[0] != "季節のフルーツ" {
+		t.Errorf("Expected the unknown dish to be reported, got %v", mid.Unmatched)
+	}
+	if mid.Sources["calories"] != models.NutritionEstimated || mid.Sources["calcium_mg"] != models.NutritionEstimated || mid.Sources["vitamin_c_mg"] != "" {
+		t.Errorf("Unexpected sources: %v", mid.Sources)
+	}
+	// 8 + 5 + 227 + 40 mg of calcium; salt follows from 390 + 2 + 84 + 600 mg of sodium
+	if mid.Nutrition.Calcium != 280 || mid.Nutrition.Salt != 2.7 {
+		t.Errorf("Unexpected minerals: %+v", mid.Nutrition)
+	}
+
+	// Junior high portions are larger, but milk is one bottle for everyone
+	junior := service.EstimateLunchNutrition(lunch, models.GradeJuniorHigh)
+	if junior.Nutrition.Calories <= mid.Nutrition.Calories {
+		t.Errorf("Expected larger portions for junior high, got %d", junior.Nutrition.Calories)
+	}
+	for _, dish := range junior.Dishes {
+		if dish.DishID == "milk" && dish.Nutrition.Calories != 126 {
+			t.Errorf("Expected milk not to be scaled, got %+v", dish)
+		}
+	}
+
+	// Printed values are kept and only the missing ones are estimated
+	lunch.Nutrition = models.Nutrition{Calories: 640, Protein: 25}
+	mixed := service.EstimateLunchNutrition(lunch, models.GradeElementaryMid)
+	if mixed.Source != models.NutritionMixed || mixed.Nutrition.Calories != 640 || mixed.Sources["calories"] != models.NutritionPublished {
+		t.Errorf("Expected published values to be kept, got %+v", mixed)
+	}
+	if mixed.Nutrition.Fat != mid.Nutrition.Fat || mixed.Sources["fat_g"] != models.NutritionEstimated {
+		t.Errorf("Expected fat to be estimated, got %v", mixed.Nutrition.Fat)
+	}
+
+	unknown := service.EstimateLunchNutrition(&models.SchoolLunchMenu{MainDish: "謎の料理"}, models.GradeElementaryMid)
+	if unknown.Source != "" || len(unknown.Sources) != 0 {
+		t.Errorf("Expected nothing to be known, got %+v", unknown)
+	}
+}
+
+func TestEstimateLunchNutritionPartlyMatched(t *testing.T) {
+	service := NewMenuAdvisorService()
+	date := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		lunch models.SchoolLunchMenu
+	}{
+		{"Most dishes unknown", models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照り焼き", SideDishes: []string{"ごはん", "謎の和え物", "謎の煮物"}, Soup: "謎のスープ"}},
+		{"Main dish unknown", models.SchoolLunchMenu{Date: date, MainDish: "謎の料理", SideDishes: []string{"ごはん", "牛乳", "ひじきの煮物"}, Soup: "みそ汁（豆腐）"}},
+	}
+	for _, test := range tests {
+		lunch := service.EstimateLunchNutrition(&test.lunch, models.GradeJuniorHigh)
+		if lunch.Source != "" || len(lunch.Sources) != 0 || lunch.Nutrition.Calories != 0 {
+			t.Errorf("%s: expected nothing to be estimated, got %+v", test.name, lunch)
+		}
+		if len(lunch.Dishes) == 0 || len(lunch.Unmatched) == 0 {
+			t.Errorf("%s: expected the dishes to be listed, got %+v", test.name, lunch)
+		}
+
+		// A share of the lunch is no reason to make up for a nutrient
+		service.AddSchoolLunchMenu(test.lunch)
+		dinner, err := service.GenerateHomeMenuSuggestionWithOptions(date, models.MealTypeDinner, SuggestionOptions{Grade: models.GradeJuniorHigh})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Contains(dinner.Reason, "目安の") {
+			t.Errorf("%s: expected no side dish for a nutrient gap, got %q", test.name, dinner.Reason)
+		}
+	}
+}
+
+func TestSuggestionComplementsLunchGap(t *testing.T) {
+	service := NewMenuAdvisorService()
+	date := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	// About 8g of fiber against a target of 7g, but only 12g of protein and no milk
+	lunch := models.SchoolLunchMenu{Date: date, MainDish: "焼きそば", SideDishes: []string{"ひじきの煮物"}, Soup: "野菜スープ"}
+	service.AddSchoolLunchMenu(lunch)
+
+	dinner, err := service.GenerateHomeMenuSuggestionWithOptions(date, models.MealTypeDinner, SuggestionOptions{Grade: models.GradeJuniorHigh})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if dinner.LunchNutrition == nil || dinner.LunchNutrition.Grade != models.GradeJuniorHigh {
+		t.Fatalf("Expected the lunch nutrition to be included, got %+v", dinner.LunchNutrition)
+	}
+	if last := dinner.SideDishes[len(dinner.SideDishes)-1]; last != "小松菜としらすのおひたし" {
+		t.Errorf("Expected a calcium-rich side dish, got %v", dinner.SideDishes)
+	}
+	if !strings.Contains(dinner.Reason, "給食のカルシウムが目安の") || !strings.Contains(dinner.Reason, "（推定）") {
+		t.Errorf("Unexpected reason: %s", dinner.Reason)
+	}
+
+	// With enough calcium and iron printed on the menu, protein is what is missing
+	lunch.Nutrition = models.Nutrition{Calcium: 420, Iron: 4.2}
+	service.AddSchoolLunchMenu(lunch)
+	dinner, _ = service.GenerateHomeMenuSuggestionWithOptions(date, models.MealTypeDinner, SuggestionOptions{Grade: models.GradeJuniorHigh})
+	if last := dinner.SideDishes[len(dinner.SideDishes)-1]; last != "冷奴" || !strings.Contains(dinner.Reason, "給食のたんぱく質が目安の63%（推定）") {
+		t.Errorf("Expected tofu to make up for protein, got %v: %s", dinner.SideDishes, dinner.Reason)
+	}
+
+	if _, err := service.GenerateHomeMenuSuggestionWithOptions(date, models.MealTypeDinner, SuggestionOptions{Grade: "college"}); err == nil {
+		t.Error("Expected an unknown grade to be refused")
+	}
+}
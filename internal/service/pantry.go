@@ -0,0 +1,211 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// ErrPantryItemNotFound is returned for a pantry item ID that does not exist
+var ErrPantryItemNotFound = errors.New("pantry item not found")
+
+// Pantry returns the ingredients the household already has, those expiring
+// soonest first and those without an expiry date last
+func (s *MenuAdvisorService) Pantry() []models.PantryItem {
+	s.mu.RLock()
+	items := append([]models.PantryItem{}, s.pantry...)
+	s.mu.RUnlock()
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].ExpiresOn, items[j].ExpiresOn
+		return a != "" && (b == "" || a < b)
+	})
+	return items
+}
+
+// SetPantry replaces the ingredients the household has. Items without an ID
+// are given one.
+func (s *MenuAdvisorService) SetPantry(items []models.PantryItem) error {
+	if err := models.ValidatePantry(items); err != nil {
+		return err
+	}
+	items = append([]models.PantryItem{}, items...)
+	for i := range items {
+		if items[i].ID == "" {
+			items[i].ID = generatePantryItemID()
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pantry = items
+	return nil
+}
+
+// LoadPantry reads the pantry from a JSON file
+func (s *MenuAdvisorService) LoadPantry(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read pantry: %w", err)
+	}
+	var items []models.PantryItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("failed to parse pantry: %w", err)
+	}
+	return s.SetPantry(items)
+}
+
+// PantryItem returns the pantry item with the given ID
+func (s *MenuAdvisorService) PantryItem(id string) (*models.PantryItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, item := range s.pantry {
+		if item.ID == id {
+			return &item, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrPantryItemNotFound, id)
+}
+
+// AddPantryItem adds an ingredient to the pantry
+func (s *MenuAdvisorService) AddPantryItem(item models.PantryItem) (*models.PantryItem, error) {
+	item.ID = ""
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := models.ValidatePantry(append(slices.Clone(s.pantry), item)); err != nil {
+		return nil, err
+	}
+	item.ID = generatePantryItemID()
+	s.pantry = append(s.pantry, item)
+	return &item, nil
+}
+
+// UpdatePantryItem replaces the pantry item with the given ID, such as when
+// some of it has been used
+func (s *MenuAdvisorService) UpdatePantryItem(id string, item models.PantryItem) (*models.PantryItem, error) {
+	item.ID = id
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.pantry, func(existing models.PantryItem) bool { return existing.ID == id })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPantryItemNotFound, id)
+	}
+	items := slices.Clone(s.pantry)
+	items[i] = item
+	if err := models.ValidatePantry(items); err != nil {
+		return nil, err
+	}
+	s.pantry = items
+	return &item, nil
+}
+
+// DeletePantryItem removes an ingredient from the pantry
+func (s *MenuAdvisorService) DeletePantryItem(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.pantry, func(item models.PantryItem) bool { return item.ID == id })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrPantryItemNotFound, id)
+	}
+	s.pantry = slices.Delete(slices.Clone(s.pantry), i, i+1)
+	return nil
+}
+
+// expiringDays is how many days before its expiry date a pantry item is
+// counted as one to use up
+const expiringDays = 3
+
+// Weights of the pantry in choosing a home menu: using up an ingredient
+// about to expire is worth buying a few more
+const (
+	expiringWeight = 3
+	missingWeight  = 1
+)
+
+// pantryUse is what cooking a home menu does with the pantry
+type pantryUse struct {
+	// expiring describes the pantry items about to expire the menu uses
+	expiring []string
+	// missing lists the ingredients the pantry does not have
+	missing []string
+}
+
+// score rates the menu's use of the pantry; higher is better
+func (u pantryUse) score() int {
+	return expiringWeight*len(u.expiring) - missingWeight*len(u.missing)
+}
+
+// pantryUse checks the ingredients of a menu cooked on date against the
+// pantry. Seasonings and amounts "to taste" are assumed to be at home, and
+// expired items are not counted.
+func (s *MenuAdvisorService) pantryUse(menu homeMenu, pantry []models.PantryItem, date time.Time) pantryUse {
+	type need struct {
+		quantity float64
+		unit     string
+	}
+	var names []string
+	needs := make(map[string][]need)
+	servings := s.householdServings()
+	for _, dish := range menu.dishes() {
+		recipe, ok := s.recipes.Find(dish)
+		if !ok {
+			continue
+		}
+		factor := float64(servings) / float64(max(recipe.Servings, 1))
+		for _, i := range recipe.Ingredients {
+			if i.Quantity == 0 || ingredient(i.Name).section == models.SectionSeasoning {
+				continue
+			}
+			if _, ok := needs[i.Name]; !ok {
+				names = append(names, i.Name)
+			}
+			needs[i.Name] = append(needs[i.Name], need{i.Quantity * factor, i.Unit})
+		}
+	}
+
+	var use pantryUse
+	for _, name := range names {
+		var stocked []models.PantryItem
+		for _, item := range pantry {
+			if days, ok := item.DaysLeft(date); item.Name == name && item.Quantity > 0 && (!ok || days >= 0) {
+				stocked = append(stocked, item)
+			}
+		}
+		if len(stocked) == 0 {
+			use.missing = append(use.missing, name)
+			continue
+		}
+		// Pantry items are sorted by expiry, so the first is used first
+		item := stocked[0]
+		if days, ok := item.DaysLeft(date); !ok || days > expiringDays {
+			continue
+		}
+		needed, convertible := 0.0, true
+		for _, n := range needs[name] {
+			q, ok := ingredient(name).convert(n.quantity, n.unit, item.Unit)
+			needed += q
+			convertible = convertible && ok
+		}
+		verb := "使えます"
+		if convertible && needed >= item.Quantity-1e-9 {
+			verb = "使い切れます"
+		}
+		use.expiring = append(use.expiring, fmt.Sprintf("%sの%sを%s", item.Storage.Label(), name, verb))
+	}
+	return use
+}
+
+// attachMissingIngredients lists the ingredients of the suggested dishes the
+// pantry lacks, when the household keeps a pantry
+func (s *MenuAdvisorService) attachMissingIngredients(suggestion *models.HomeMenuSuggestion) {
+	pantry := s.Pantry()
+	if len(pantry) == 0 {
+		return
+	}
+	menu := homeMenu{main: suggestion.MainDish, sides: suggestion.SideDishes, soup: suggestion.Soup}
+	suggestion.MissingIngredients = s.pantryUse(menu, pantry, suggestion.Date).missing
+}
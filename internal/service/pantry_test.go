@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestPantryItems(t *testing.T) {
+	service := NewMenuAdvisorService()
+	items := []models.PantryItem{
+		{Name: "米", Quantity: 5, Unit: "合", Storage: models.StorageShelf},
+		{Name: "豆腐", Quantity: 1, Unit: "丁", Storage: models.StorageFridge, ExpiresOn: "2025-01-16"},
+	}
+	if err := service.SetPantry(items); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	spinach, err := service.AddPantryItem(models.PantryItem{Name: "ほうれん草", Quantity: 1, Unit: "束", ExpiresOn: "2025-01-14"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(spinach.ID, "pantry_") {
+		t.Errorf("Unexpected ID %q", spinach.ID)
+	}
+
+	// Soonest to expire first, no expiry date last
+	var names []string
+	for _, item := range service.Pantry() {
+		if item.ID == "" {
+			t.Errorf("No ID for %s", item.Name)
+		}
+		names = append(names, item.Name)
+	}
+	if !slices.Equal(names, []string{"ほうれん草", "豆腐", "米"}) {
+		t.Errorf("Unexpected order: %v", names)
+	}
+
+	spinach.Quantity = 0.5
+	if updated, err := service.UpdatePantryItem(spinach.ID, *spinach); err != nil || updated.Quantity != 0.5 {
+		t.Errorf("Unexpected update: %+v, %v", updated, err)
+	}
+	if item, _ := service.PantryItem(spinach.ID); item.Quantity != 0.5 {
+		t.Errorf("Update not stored: %+v", item)
+	}
+
+	var verrs models.ValidationErrors
+	invalid := []models.PantryItem{
+		{Name: "卵", Quantity: 6, Unit: "個", Storage: "cellar"},
+		{Name: "卵", Quantity: 6, Unit: "個", ExpiresOn: "1/20"},
+		{Name: "豆腐", Quantity: 1, Unit: "丁", ExpiresOn: "2025-01-16"},
+	}
+	for _, item := range invalid {
+		if _, err := service.AddPantryItem(item); !errors.As(err, &verrs) {
+			t.Errorf("Expected a validation error for %+v, got %v", item, err)
+		}
+	}
+
+	if err := service.DeletePantryItem(spinach.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := service.DeletePantryItem(spinach.ID); !errors.Is(err, ErrPantryItemNotFound) {
+		t.Errorf("Expected ErrPantryItemNotFound, got %v", err)
+	}
+	if _, err := service.UpdatePantryItem(spinach.ID, *spinach); !errors.Is(err, ErrPantryItemNotFound) {
+		t.Errorf("Expected ErrPantryItemNotFound, got %v", err)
+	}
+}
+
+func TestHomeMenusHaveRecipes(t *testing.T) {
+	service := NewMenuAdvisorService()
+	profiles := []lunchProfile{
+		{Protein: models.ProteinFish}, {Protein: models.ProteinChicken}, {Protein: models.ProteinPork},
+		{Protein: models.ProteinBeef}, {Curry: true}, {},
+	}
+	for _, profile := range profiles {
+		_, breakfasts := breakfastMenus(profile)
+		_, dinners := dinnerMenus(profile)
+		for _, menu := range append(breakfasts, dinners...) {
+			for _, dish := range menu.dishes() {
+				if _, ok := service.Recipes().Find(dish); !ok && dish != "のり" {
+					t.Errorf("%+v: no recipe for %s", profile, dish)
+				}
+			}
+		}
+	}
+}
+
+func TestSuggestionUsesPantry(t *testing.T) {
+	monday := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	newService := func(pantry ...models.PantryItem) *MenuAdvisorService {
+		service := NewMenuAdvisorService()
+		service.SetHousehold(models.Household{Size: 4})
+		service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: monday, MainDish: "鶏肉の照り焼き"})
+		if err := service.SetPantry(pantry); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return service
+	}
+
+	// Without a pantry the rule's first dinner is suggested
+	suggestion, err := newService().GenerateHomeMenuSuggestion(monday, models.MealTypeDinner)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if suggestion.MainDish != "魚の煮付け" || suggestion.MissingIngredients != nil {
+		t.Errorf("Unexpected suggestion: %+v", suggestion)
+	}
+
+	// Komatsuna expiring tomorrow is used up: a quarter bunch each for four
+	komatsuna := models.PantryItem{Name: "小松菜", Quantity: 1, Unit: "束", Storage: models.StorageFridge, ExpiresOn: "2025-01-14"}
+	suggestion, _ = newService(komatsuna).GenerateHomeMenuSuggestion(monday, models.MealTypeDinner)
+	if !slices.Contains(suggestion.SideDishes, "小松菜のごま和え") || !strings.Contains(suggestion.Reason, "冷蔵庫の小松菜を使い切れます") {
+		t.Errorf("Expected the komatsuna to be used up, got %+v", suggestion)
+	}
+	if slices.Contains(suggestion.MissingIngredients, "小松菜") || !slices.Contains(suggestion.MissingIngredients, "さば") {
+		t.Errorf("Unexpected missing ingredients: %v", suggestion.MissingIngredients)
+	}
+
+	// Two bunches are not used up
+	komatsuna.Quantity = 2
+	suggestion, _ = newService(komatsuna).GenerateHomeMenuSuggestion(monday, models.MealTypeDinner)
+	if !strings.Contains(suggestion.Reason, "冷蔵庫の小松菜を使えます") {
+		t.Errorf("Unexpected reason: %s", suggestion.Reason)
+	}
+
+	// Expired or far from expiry, it is not one to use up
+	for _, expires := range []string{"2025-01-10", "2025-01-31"} {
+		komatsuna.ExpiresOn = expires
+		suggestion, _ = newService(komatsuna).GenerateHomeMenuSuggestion(monday, models.MealTypeDinner)
+		if strings.Contains(suggestion.Reason, "冷蔵庫の小松菜") {
+			t.Errorf("Expires %s: unexpected suggestion %+v", expires, suggestion)
+		}
+	}
+
+	// The dinner needing the fewest ingredients the pantry lacks is preferred
+	suggestion, _ = newService(
+		models.PantryItem{Name: "甘塩鮭", Quantity: 4, Unit: "切れ"},
+		models.PantryItem{Name: "ごぼう", Quantity: 1, Unit: "本"},
+		models.PantryItem{Name: "にんじん", Quantity: 1, Unit: "本"},
+	).GenerateHomeMenuSuggestion(monday, models.MealTypeDinner)
+	if suggestion.MainDish != "焼き鮭" || slices.Contains(suggestion.MissingIngredients, "甘塩鮭") {
+		t.Errorf("Expected the salmon dinner, got %+v", suggestion)
+	}
+}
@@ -0,0 +1,537 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// ErrDocumentNotFound is returned when no document has the requested ID
+var ErrDocumentNotFound = errors.New("document not found")
+
+// Limits on how much of a stage's output is kept in a trace
+const (
+	maxTraceText  = 8 << 10 // Bytes of raw text
+	maxTraceItems = 200     // Lines or rows
+)
+
+// PipelineStage is one step of document processing. Run reads the document
+// from the run and leaves its result there for the stages after it; the
+// output it returns is recorded in the document's trace. A stage that has
+// nothing to do for a document returns SkipStage.
+type PipelineStage interface {
+	Name() models.PipelineStageName
+	Run(run *PipelineRun) (output any, err error)
+}
+
+// PipelineRun carries a document through the pipeline
+type PipelineRun struct {
+	Request  *models.DocumentProcessingRequest
+	Document *models.DocumentSource
+	// File is what the extract stage reads; pre-processing may replace it.
+	// The request's file is kept as uploaded.
+	File      multipart.File
+	Extracted *models.ExtractedMenuData
+
+	detection contentDetection
+	detected  bool
+	parsed    []parsedMenu
+	skipped   []models.DayMergeResult // Days left out before the merge
+	trace     *models.DocumentTrace
+}
+
+// Fail records message and err as the document's error and returns err
+func (run *PipelineRun) Fail(message string, err error) error {
+	run.Document.ErrorMessage = fmt.Sprintf("%s: %v", message, err)
+	return err
+}
+
+// stageSkipped reports that a stage had nothing to do
+type stageSkipped struct {
+	reason string
+}
+
+func (s *stageSkipped) Error() string {
+	return "stage skipped: " + s.reason
+}
+
+// SkipStage is returned by a stage that has nothing to do for a document;
+// the reason is shown in the trace
+func SkipStage(reason string) error {
+	return &stageSkipped{reason: reason}
+}
+
+// stageFunc adapts a function to PipelineStage
+type stageFunc struct {
+	name models.PipelineStageName
+	run  func(*PipelineRun) (any, error)
+}
+
+func (s stageFunc) Name() models.PipelineStageName { return s.name }
+
+func (s stageFunc) Run(run *PipelineRun) (any, error) { return s.run(run) }
+
+// defaultPipelineStages returns the built-in stages, in the order of
+// models.PipelineStageNames
+func (dp *DocumentProcessor) defaultPipelineStages() []PipelineStage {
+	return []PipelineStage{
+		stageFunc{models.StageDetect, dp.detectStage},
+		stageFunc{models.StagePreprocess, dp.preprocessStage},
+		stageFunc{models.StageExtract, dp.extractStage},
+		stageFunc{models.StageLayout, dp.layoutStage},
+		stageFunc{models.StageParse, dp.parseStage},
+		stageFunc{models.StageValidate, dp.validateStage},
+		stageFunc{models.StageNormalize, dp.normalizeStage},
+		stageFunc{models.StageCommit, dp.commitStage},
+	}
+}
+
+// SetPipelineStage replaces the stage with the same name
+func (dp *DocumentProcessor) SetPipelineStage(stage PipelineStage) error {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	for i, s := range dp.stages {
+		if s.Name() == stage.Name() {
+			dp.stages[i] = stage
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown pipeline stage %q", stage.Name())
+}
+
+// PipelineStages returns the stages documents go through, in order
+func (dp *DocumentProcessor) PipelineStages() []PipelineStage {
+	dp.mu.RLock()
+	defer dp.mu.RUnlock()
+	return slices.Clone(dp.stages)
+}
+
+// GetTrace returns how a document went through the pipeline
+func (dp *DocumentProcessor) GetTrace(id string) (*models.DocumentTrace, error) {
+	dp.mu.RLock()
+	defer dp.mu.RUnlock()
+	trace, ok := dp.traces[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, id)
+	}
+	copied := *trace
+	copied.Stages = slices.Clone(trace.Stages)
+	return &copied, nil
+}
+
+// runStage runs one stage and appends what it did to the trace. A skipped
+// stage is not an error.
+func (run *PipelineRun) runStage(stage PipelineStage) error {
+	trace := models.StageTrace{Stage: stage.Name(), StartedAt: time.Now()}
+	output, err := stage.Run(run)
+	trace.DurationMS = durationMS(time.Since(trace.StartedAt))
+
+	var skipped *stageSkipped
+	switch {
+	case errors.As(err, &skipped):
+		trace.Status, trace.Note, err = models.StageStatusSkipped, skipped.reason, nil
+	case err != nil:
+		trace.Status, trace.Error = models.StageStatusFailed, err.Error()
+	default:
+		trace.Status = models.StageStatusOK
+	}
+	// Outputs are captured now, as later stages change the run in place
+	if output != nil {
+		if data, merr := json.Marshal(output); merr == nil {
+			trace.Output = data
+		}
+	}
+	run.trace.Stages = append(run.trace.Stages, trace)
+	return err
+}
+
+// durationMS converts a duration to fractional milliseconds
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// detectOutput is the trace output of the detect stage
+type detectOutput struct {
+	Type        models.DocumentType `json:"type"`
+	ContentType string              `json:"content_type,omitempty"`
+	Detected    bool                `json:"detected"` // Recognized from content rather than the file name
+	Size        int64               `json:"size"`
+	Pages       int                 `json:"pages,omitempty"`
+	Pixels      int64               `json:"pixels,omitempty"`
+	SHA256      string              `json:"sha256"`
+	Metadata    map[string]string   `json:"metadata,omitempty"`
+}
+
+// detectStage inspects the content to tell what the document really is; the
+// file extension is only used when the content is not recognized. Documents
+// that would take too much memory or work to read are refused.
+func (dp *DocumentProcessor) detectStage(run *PipelineRun) (any, error) {
+	req, doc := run.Request, run.Document
+	detection, detected, err := dp.detectContent(req.File)
+	if err != nil {
+		return nil, run.Fail("Failed to read document", err)
+	}
+	run.detection, run.detected = detection, detected
+	doc.ContentType = detection.ContentType
+	doc.ContentHash = detection.SHA256
+
+	if doc.Type, err = dp.documentType(req, detection, detected); err != nil {
+		return nil, run.Fail("Failed to detect document type", err)
+	}
+	output := detectOutput{
+		Type:        doc.Type,
+		ContentType: detection.ContentType,
+		Detected:    detected,
+		Size:        detection.Size,
+		Pages:       detection.Pages,
+		Pixels:      detection.Pixels,
+		SHA256:      detection.SHA256,
+		Metadata:    detection.Metadata,
+	}
+	return output, dp.checkLimits(doc.Type, detection)
+}
+
+// utf8BOM is written at the start of text files by some Windows programs
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// preprocessStage removes a UTF-8 byte order mark from text documents, which
+// JSON decoding would otherwise refuse
+func (dp *DocumentProcessor) preprocessStage(run *PipelineRun) (any, error) {
+	switch run.Document.Type {
+	case models.DocumentTypeJSON, models.DocumentTypeCSV, models.DocumentTypeHTML:
+	default:
+		return nil, SkipStage(fmt.Sprintf("nothing to clean up in %s documents", run.Document.Type))
+	}
+	data, err := readAllFrom(run.File)
+	if err != nil {
+		return nil, run.Fail("Failed to read document", err)
+	}
+	if _, err := run.File.Seek(0, io.SeekStart); err != nil {
+		return nil, run.Fail("Failed to read document", err)
+	}
+	if !bytes.HasPrefix(data, utf8BOM) {
+		return nil, SkipStage("no byte order mark")
+	}
+	run.File = &memoryFile{bytes.NewReader(data[len(utf8BOM):])}
+	return map[string]any{"removed": "utf-8 byte order mark", "bytes": len(data) - len(utf8BOM)}, nil
+}
+
+// extractOutput is the trace output of the extract stage. Long text and
+// tables are cut short.
+type extractOutput struct {
+	Confidence float64           `json:"confidence"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	RawText    string            `json:"raw_text,omitempty"`
+	Lines      []models.TextLine `json:"lines,omitempty"`
+	Rows       [][]string        `json:"rows,omitempty"`
+	Truncated  bool              `json:"truncated,omitempty"`
+}
+
+// extractStage reads the document's text, recognized lines or table cells
+func (dp *DocumentProcessor) extractStage(run *PipelineRun) (any, error) {
+	extracted, err := dp.extractDataFromDocument(run.File, run.Request, run.Document)
+	if err != nil {
+		return nil, run.Fail("Failed to extract data", err)
+	}
+	for k, v := range run.detection.Metadata {
+		if _, ok := extracted.Metadata[k]; !ok {
+			extracted.Metadata[k] = v
+		}
+	}
+	run.Extracted = extracted
+
+	output := extractOutput{
+		Confidence: extracted.Confidence,
+		Metadata:   extracted.Metadata,
+		RawText:    extracted.RawText,
+		Lines:      extracted.Lines,
+		Rows:       extracted.Rows,
+	}
+	if len(output.RawText) > maxTraceText {
+		text := output.RawText[:maxTraceText]
+		for !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+		output.RawText, output.Truncated = text, true
+	}
+	if len(output.Lines) > maxTraceItems {
+		output.Lines, output.Truncated = output.Lines[:maxTraceItems], true
+	}
+	if len(output.Rows) > maxTraceItems {
+		output.Rows, output.Truncated = output.Rows[:maxTraceItems], true
+	}
+	return output, nil
+}
+
+// layoutStage puts recognized lines in reading order, since OCR engines do
+// not always return them that way
+func (dp *DocumentProcessor) layoutStage(run *PipelineRun) (any, error) {
+	lines := run.Extracted.Lines
+	if len(lines) == 0 {
+		return nil, SkipStage("no recognized lines")
+	}
+	for _, line := range lines {
+		if line.Box == nil {
+			return nil, SkipStage("lines have no positions")
+		}
+	}
+
+	ordered, rows := readingOrder(lines)
+	moved := 0
+	texts := make([]string, len(ordered))
+	for i, line := range ordered {
+		if line.Box != lines[i].Box {
+			moved++
+		}
+		texts[i] = line.Text
+	}
+	run.Extracted.Lines = ordered
+	run.Extracted.RawText = strings.Join(texts, "\n")
+	return map[string]int{"lines": len(ordered), "rows": rows, "moved": moved}, nil
+}
+
+// readingOrder sorts positioned lines by page and then from top to bottom.
+// Lines starting within half a line height of each other form a row and are
+// read left to right. It returns the sorted lines and the number of rows.
+func readingOrder(lines []models.TextLine) ([]models.TextLine, int) {
+	sorted := slices.Clone(lines)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Box, sorted[j].Box
+		if a.Page != b.Page {
+			return a.Page < b.Page
+		}
+		return a.Y < b.Y
+	})
+
+	rows := 0
+	for start := 0; start < len(sorted); rows++ {
+		top := sorted[start].Box
+		end := start + 1
+		for end < len(sorted) && sorted[end].Box.Page == top.Page && sorted[end].Box.Y-top.Y < (top.Height+1)/2 {
+			end++
+		}
+		row := sorted[start:end]
+		sort.SliceStable(row, func(i, j int) bool { return row[i].Box.X < row[j].Box.X })
+		start = end
+	}
+	return sorted, rows
+}
+
+// tracedMenu is a parsed menu as shown in a trace
+type tracedMenu struct {
+	Menu       models.SchoolLunchMenu            `json:"menu"`
+	Confidence float64                           `json:"confidence"`
+	Fields     map[string]models.FieldExtraction `json:"fields,omitempty"`
+}
+
+// traceMenus lists parsed menus for a trace
+func traceMenus(parsed []parsedMenu) []tracedMenu {
+	menus := make([]tracedMenu, len(parsed))
+	for i, p := range parsed {
+		menus[i] = tracedMenu{Menu: p.Menu, Confidence: p.confidence(), Fields: p.Fields}
+	}
+	return menus
+}
+
+// parseStage turns the extracted text or cells into menus
+func (dp *DocumentProcessor) parseStage(run *PipelineRun) (any, error) {
+	parsed, err := dp.parseExtractedMenuData(run.Extracted, parseContextFor(run.Request), run.Request.ColumnMapping)
+	if err != nil {
+		return nil, run.Fail("Failed to parse menu data", err)
+	}
+	run.parsed = parsed
+	return map[string]any{"menus": traceMenus(parsed)}, nil
+}
+
+// validateStage drops days outside the requested range, then checks the rest
+// with ValidateMenus. Days with errors are left out of the merge and reported
+// as invalid; the issues found are recorded on the document.
+func (dp *DocumentProcessor) validateStage(run *PipelineRun) (any, error) {
+	kept, dropped := filterDateRange(run.parsed, run.Request.DateFrom, run.Request.DateTo)
+	run.skipped = append(run.skipped, dropped...)
+
+	menus := make([]models.SchoolLunchMenu, len(kept))
+	for i, p := range kept {
+		menus[i] = p.Menu
+	}
+	var issuedAt time.Time
+	if run.Document.IssuedAt != nil {
+		issuedAt = *run.Document.IssuedAt
+	}
+	validation := ValidateMenus(menus, dp.menuService.Calendar(), issuedAt)
+	if !validation.Empty() {
+		run.Document.Validation = validation
+	}
+
+	rejected := rejectedMenus(validation)
+	var valid []parsedMenu
+	merged := make(map[string]bool)
+	for i, p := range kept {
+		if _, ok := rejected[i]; !ok {
+			valid = append(valid, p)
+			merged[menuDay(p.Menu)] = true
+		}
+	}
+	// A day is invalid when none of its copies could be kept; repeated
+	// copies of a valid day are ignored without a report of their own
+	invalid := []models.DayMergeResult{}
+	for i, p := range kept {
+		reason, ok := rejected[i]
+		if day := menuDay(p.Menu); ok && !merged[day] {
+			invalid = append(invalid, models.DayMergeResult{Date: day, Status: models.DayMergeInvalid, Message: reason})
+			merged[day] = true
+		}
+	}
+	run.parsed = valid
+	run.skipped = append(run.skipped, invalid...)
+
+	return map[string]any{
+		"kept":     len(valid),
+		"dropped":  dropped,
+		"invalid":  invalid,
+		"warnings": len(validation.Warnings),
+		"errors":   len(validation.Errors),
+	}, nil
+}
+
+// menuDay returns the date of a menu, or "" when it has none
+func menuDay(menu models.SchoolLunchMenu) string {
+	if menu.Date.IsZero() {
+		return ""
+	}
+	return dateKey(menu.Date)
+}
+
+// normalizeChange records one value changed by the normalize stage. An empty
+// To means the value was removed.
+type normalizeChange struct {
+	Date  string `json:"date"`
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// tracedDish is the dictionary match of one dish of a parsed menu
+type tracedDish struct {
+	Date  string `json:"date"`
+	Field string `json:"field"`
+	models.DishMatch
+}
+
+// normalizeStage tidies dish names, folding character widths, repairing
+// characters OCR mistakes for katakana and collapsing spaces, and removes
+// empty and repeated side dishes. Each dish is then looked up in the dish
+// dictionary; names are kept as printed, and the canonical IDs of the dishes
+// they surely are, matched other than by edit distance, are recorded in the
+// menu's DishIDs. Every match is shown in the trace.
+func (dp *DocumentProcessor) normalizeStage(run *PipelineRun) (any, error) {
+	dishes := dp.menuService.Dishes()
+	changes := []normalizeChange{}
+	matches := []tracedDish{}
+	for i := range run.parsed {
+		menu := &run.parsed[i].Menu
+		day := dateKey(menu.Date)
+		identify := func(field, name string) {
+			match := dishes.Match(name)
+			matches = append(matches, tracedDish{Date: day, Field: field, DishMatch: match})
+			if match.Matched() && match.Method != models.DishMatchFuzzy {
+				if menu.DishIDs == nil {
+					menu.DishIDs = make(map[string]string)
+				}
+				menu.DishIDs[name] = match.DishID
+			}
+		}
+		clean := func(field string, value *string) {
+			if v := cleanDishName(*value); v != *value {
+				changes = append(changes, normalizeChange{Date: day, Field: field, From: *value, To: v})
+				*value = v
+			}
+			if *value != "" {
+				identify(field, *value)
+			}
+		}
+		clean("main_dish", &menu.MainDish)
+
+		var sides []string
+		for j, side := range menu.SideDishes {
+			field := fmt.Sprintf("side_dishes[%d]", j)
+			v := cleanDishName(side)
+			if v == "" || slices.Contains(sides, v) {
+				changes = append(changes, normalizeChange{Date: day, Field: field, From: side})
+				continue
+			}
+			if v != side {
+				changes = append(changes, normalizeChange{Date: day, Field: field, From: side, To: v})
+			}
+			identify(field, v)
+			sides = append(sides, v)
+		}
+		if len(sides) != len(menu.SideDishes) {
+			menu.SideDishes = sides
+		} else {
+			copy(menu.SideDishes, sides)
+		}
+
+		clean("soup", &menu.Soup)
+		clean("dessert", &menu.Dessert)
+	}
+	return map[string]any{"changes": changes, "dishes": matches}, nil
+}
+
+// normalizeSpaces trims s and collapses runs of spaces, including full-width
+// ones, to a single space
+func normalizeSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// commitOutput is the trace output of the commit stage
+type commitOutput struct {
+	Merged    int                           `json:"merged"`
+	ReviewIDs []string                      `json:"review_ids,omitempty"`
+	Summary   map[models.DayMergeStatus]int `json:"summary"`
+	DryRun    bool                          `json:"dry_run,omitempty"`
+}
+
+// commitStage holds back low-confidence menus until someone confirms them and
+// merges the rest into the service
+func (dp *DocumentProcessor) commitStage(run *PipelineRun) (any, error) {
+	req, doc := run.Request, run.Document
+	menus, held := dp.holdForReview(run.parsed, run.Extracted, doc, req)
+
+	doc.MergeReport = dp.menuService.MergeSchoolLunchMenus(menus, MergeOptions{
+		Policy:       req.MergePolicy,
+		DryRun:       req.DryRun,
+		Context:      models.ChangeContext{Actor: req.Actor, ActorLabel: req.ActorLabel, SourceID: doc.ID},
+		DocumentDate: doc.EffectiveDate(),
+		SourceDate:   dp.documentDate,
+	})
+	for _, day := range append(held, run.skipped...) {
+		doc.MergeReport.Add(day)
+	}
+
+	now := time.Now()
+	doc.ProcessedAt = &now
+	doc.Status = "completed"
+	if req.DryRun {
+		doc.Status = "preview"
+	} else if len(doc.ReviewIDs) > 0 {
+		doc.Status = "needs_review"
+	}
+
+	return commitOutput{
+		Merged:    len(menus),
+		ReviewIDs: doc.ReviewIDs,
+		Summary:   doc.MergeReport.Summary,
+		DryRun:    req.DryRun,
+	}, nil
+}
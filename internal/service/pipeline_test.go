@@ -0,0 +1,243 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestProcessDocumentTrace(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+
+	from := time.Date(2025, 4, 8, 0, 0, 0, 0, time.UTC)
+	req := batchRequest("menu.json", []byte("\xef\xbb\xbf"+`[
+		{"date":"2025-04-07T00:00:00Z","main_dish":"親子丼"},
+		{"date":"2025-04-08T00:00:00Z","main_dish":"　カレー  ライス ","side_dishes":["サラダ","","サラダ"]}
+	]`))
+	req.DateFrom = &from
+	doc, err := processor.ProcessDocument(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	trace, err := processor.GetTrace(doc.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if trace.Status != "completed" || len(trace.Stages) != len(models.PipelineStageNames) {
+		t.Fatalf("Unexpected trace: %+v", trace)
+	}
+	expected := map[models.PipelineStageName]models.StageStatus{
+		models.StageDetect:     models.StageStatusOK,
+		models.StagePreprocess: models.StageStatusOK, // Removes the byte order mark
+		models.StageExtract:    models.StageStatusOK,
+		models.StageLayout:     models.StageStatusSkipped,
+		models.StageParse:      models.StageStatusOK,
+		models.StageValidate:   models.StageStatusOK,
+		models.StageNormalize:  models.StageStatusOK,
+		models.StageCommit:     models.StageStatusOK,
+	}
+	for i, stage := range trace.Stages {
+		if stage.Stage != models.PipelineStageNames[i] || stage.Status != expected[stage.Stage] {
+			t.Errorf("Stage %d: expected %s %s, got %s %s (%s)", i, models.PipelineStageNames[i], expected[stage.Stage], stage.Stage, stage.Status, stage.Note)
+		}
+	}
+
+	// Each stage's output is captured when it finishes
+	parse, _ := trace.Stage(models.StageParse)
+	var parsed struct {
+		Menus []tracedMenu `json:"menus"`
+	}
+	json.Unmarshal(parse.Output, &parsed)
+	if len(parsed.Menus) != 2 || parsed.Menus[1].Menu.MainDish != "　カレー  ライス " {
+		t.Errorf("Expected the parse output before normalization, got %s", parse.Output)
+	}
+	normalize, _ := trace.Stage(models.StageNormalize)
+	var normalized struct {
+		Changes []normalizeChange `json:"changes"`
+		Dishes  []tracedDish      `json:"dishes"`
+	}
+	json.Unmarshal(normalize.Output, &normalized)
+	if len(normalized.Changes) != 3 || normalized.Changes[0].To != "カレー ライス" {
+		t.Errorf("Unexpected normalize output: %s", normalize.Output)
+	}
+	if len(normalized.Dishes) != 2 || normalized.Dishes[0].DishID != "curry_rice" || normalized.Dishes[1].DishID != "green_salad" {
+		t.Errorf("Expected the dishes to be matched to the dictionary, got %+v", normalized.Dishes)
+	}
+
+	menu, err := menuService.GetSchoolLunchForDate(from)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if menu.MainDish != "カレー ライス" || len(menu.SideDishes) != 1 {
+		t.Errorf("Expected the normalized menu to be stored, got %+v", menu)
+	}
+
+	if _, err := processor.GetTrace("doc_missing"); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+func TestProcessDocumentTraceStopsAtFailure(t *testing.T) {
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+
+	doc, err := processor.ProcessDocument(batchRequest("broken.json", []byte(`[{"date":`)))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !strings.HasPrefix(doc.ErrorMessage, "Failed to parse menu data") {
+		t.Errorf("Unexpected error message: %q", doc.ErrorMessage)
+	}
+
+	trace, _ := processor.GetTrace(doc.ID)
+	last := trace.Stages[len(trace.Stages)-1]
+	if trace.Status != "error" || last.Stage != models.StageParse || last.Status != models.StageStatusFailed || last.Error == "" {
+		t.Errorf("Expected the trace to end at the failed parse, got %+v", trace)
+	}
+	extract, _ := trace.Stage(models.StageExtract)
+	if !strings.Contains(string(extract.Output), `"raw_text":"[{\"date\":"`) {
+		t.Errorf("Expected the extracted text in the trace, got %s", extract.Output)
+	}
+}
+
+func TestLayoutStageReadingOrder(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	// The engine returns the right column before the left one
+	processor.SetOCREngine(&fakeOCR{lines: []models.TextLine{
+		{Text: "主菜: 鶏肉の照り焼き", Confidence: 0.95, Box: &models.BoundingBox{X: 0, Y: 52, Width: 150, Height: 20}},
+		{Text: "13日(月)", Confidence: 0.97, Box: &models.BoundingBox{X: 80, Y: 31, Width: 60, Height: 20}},
+		{Text: "2025年1月", Confidence: 0.98, Box: &models.BoundingBox{X: 0, Y: 28, Width: 70, Height: 20}},
+	}})
+
+	doc, err := processor.ProcessDocument(batchRequest("menu.png", testPNG(t, 200, 100)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected the lines to be read in order: %v", err)
+	}
+
+	trace, _ := processor.GetTrace(doc.ID)
+	layout, _ := trace.Stage(models.StageLayout)
+	if layout.Status != models.StageStatusOK || string(layout.Output) != `{"lines":3,"moved":2,"rows":2}` {
+		t.Errorf("Unexpected layout trace: %+v %s", layout, layout.Output)
+	}
+}
+
+func TestLayoutStageRewritesText(t *testing.T) {
+	run := &PipelineRun{Extracted: &models.ExtractedMenuData{
+		RawText: "2ページ目\n右\n左",
+		Lines: []models.TextLine{
+			{Text: "2ページ目", Box: &models.BoundingBox{Page: 2, X: 0, Y: 0, Width: 50, Height: 20}},
+			{Text: "右", Box: &models.BoundingBox{Page: 1, X: 100, Y: 10, Width: 50, Height: 20}},
+			{Text: "左", Box: &models.BoundingBox{Page: 1, X: 0, Y: 15, Width: 50, Height: 20}},
+		},
+	}}
+	if _, err := NewDocumentProcessor(NewMenuAdvisorService()).layoutStage(run); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Pages come first, then rows from the top, each read left to right; the
+	// raw text the parser reads is rebuilt in that order
+	if run.Extracted.RawText != "左\n右\n2ページ目" {
+		t.Errorf("Expected the text in reading order, got %q", run.Extracted.RawText)
+	}
+	if run.Extracted.Lines[0].Text != "左" {
+		t.Errorf("Expected the lines in reading order, got %+v", run.Extracted.Lines)
+	}
+}
+
+func TestNormalizeStageChangesMenus(t *testing.T) {
+	day := time.Date(2025, 4, 8, 0, 0, 0, 0, time.UTC)
+	run := &PipelineRun{parsed: []parsedMenu{{Menu: models.SchoolLunchMenu{
+		Date:       day,
+		MainDish:   " ＡＢＣ　　ｶﾚｰ ",
+		SideDishes: []string{"サラダ", " ", "サラダ ", "ﾌﾙｰﾂ"},
+		Soup:       "みそ汁",
+	}}}}
+	if _, err := NewDocumentProcessor(NewMenuAdvisorService()).normalizeStage(run); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	menu := run.parsed[0].Menu
+	// Widths are folded and spaces trimmed and collapsed
+	if menu.MainDish != "ABC カレー" {
+		t.Errorf("Expected the main dish to be cleaned up, got %q", menu.MainDish)
+	}
+	// Empty and repeated side dishes are dropped
+	if strings.Join(menu.SideDishes, ",") != "サラダ,フルーツ" {
+		t.Errorf("Expected empty and repeated side dishes to be dropped, got %q", menu.SideDishes)
+	}
+	if menu.Soup != "みそ汁" {
+		t.Errorf("Expected a clean name to be kept, got %q", menu.Soup)
+	}
+}
+
+func TestNormalizeStageRecordsDishIDs(t *testing.T) {
+	service := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(service)
+	_, err := processor.ProcessDocument(batchRequest("menu.json", []byte(`[{"date":"2025-04-08T00:00:00Z","main_dish":"とりの照焼","side_dishes":["豚肉の生妻焼き","牛乳パン"],"soup":"みそ汁（わかめ）"}]`)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	day := time.Date(2025, 4, 8, 0, 0, 0, 0, time.UTC)
+	lunch, err := service.GetSchoolLunchForDate(day)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Only dishes matched other than by edit distance are recorded
+	expected := map[string]string{"とりの照焼": "chicken_teriyaki", "みそ汁（わかめ）": "miso_soup"}
+	if len(lunch.DishIDs) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, lunch.DishIDs)
+	}
+	for name, id := range expected {
+		if lunch.DishIDs[name] != id {
+			t.Errorf("Expected %s to be recorded as %s, got %v", name, id, lunch.DishIDs)
+		}
+	}
+
+	// A dish edited away takes its ID with it
+	lunch, err = service.PatchSchoolLunchMenu(day, func(menu *models.SchoolLunchMenu) error {
+		menu.MainDish = "ハンバーグ"
+		return nil
+	}, "*", models.ChangeContext{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := lunch.DishIDs["とりの照焼"]; ok || lunch.DishIDs["みそ汁（わかめ）"] != "miso_soup" {
+		t.Errorf("Expected only the edited dish to be dropped, got %v", lunch.DishIDs)
+	}
+}
+
+// skipNormalizeStage stands in for the normalize stage
+type skipNormalizeStage struct{}
+
+func (skipNormalizeStage) Name() models.PipelineStageName { return models.StageNormalize }
+
+func (skipNormalizeStage) Run(run *PipelineRun) (any, error) {
+	return nil, SkipStage("replaced in test")
+}
+
+func TestSetPipelineStage(t *testing.T) {
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+	if err := processor.SetPipelineStage(skipNormalizeStage{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := processor.SetPipelineStage(stageFunc{name: "ocr"}); err == nil {
+		t.Error("Expected an unknown stage to be refused")
+	}
+
+	doc, err := processor.ProcessDocument(batchRequest("menu.json", []byte(`[{"date":"2025-04-08T00:00:00Z","main_dish":" カレーライス"}]`)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	trace, _ := processor.GetTrace(doc.ID)
+	if stage, _ := trace.Stage(models.StageNormalize); stage.Status != models.StageStatusSkipped || stage.Note != "replaced in test" {
+		t.Errorf("Expected the replacement stage to run, got %+v", stage)
+	}
+}
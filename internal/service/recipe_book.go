@@ -0,0 +1,465 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// ErrRecipeNotFound is returned when a dish has no recipe
+var ErrRecipeNotFound = errors.New("recipe not found")
+
+// RecipeBook holds the recipes of the dishes in a dish dictionary
+type RecipeBook struct {
+	mu      sync.RWMutex
+	dishes  *DishDictionary
+	recipes map[string]models.Recipe // By dish ID
+}
+
+// NewRecipeBook creates an empty recipe book for the dishes of a dictionary
+func NewRecipeBook(dishes *DishDictionary) *RecipeBook {
+	return &RecipeBook{dishes: dishes, recipes: make(map[string]models.Recipe)}
+}
+
+// DefaultRecipeBook creates a recipe book with the home dishes the
+// recommender suggests
+func DefaultRecipeBook(dishes *DishDictionary) *RecipeBook {
+	b := NewRecipeBook(dishes)
+	for _, recipe := range defaultRecipes {
+		if err := b.Add(recipe); err != nil {
+			panic(fmt.Sprintf("invalid built-in recipe %q: %v", recipe.DishID, err))
+		}
+	}
+	return b
+}
+
+// Add registers a recipe for a dish of the dictionary, replacing any recipe
+// the dish had. Quantities are for one serving unless Servings says otherwise,
+// and all of the time is hands-on unless ActiveMinutes says otherwise.
+func (b *RecipeBook) Add(recipe models.Recipe) error {
+	if err := recipe.Validate(); err != nil {
+		return err
+	}
+	dish, ok := b.dishes.Get(recipe.DishID)
+	if !ok {
+		return models.ValidationErrors{{Field: "dish_id", Message: fmt.Sprintf("unknown dish %q", recipe.DishID)}}
+	}
+	recipe.Name = dish.Name
+	if recipe.Servings == 0 {
+		recipe.Servings = 1
+	}
+	if recipe.ActiveMinutes == 0 {
+		recipe.ActiveMinutes = recipe.TotalMinutes()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.recipes[recipe.DishID] = recipe
+	return nil
+}
+
+// LoadRecipes adds the recipes listed in a JSON file
+func (b *RecipeBook) LoadRecipes(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read recipes: %w", err)
+	}
+	var recipes []models.Recipe
+	if err := json.Unmarshal(data, &recipes); err != nil {
+		return fmt.Errorf("failed to parse recipes: %w", err)
+	}
+	for _, recipe := range recipes {
+		if err := b.Add(recipe); err != nil {
+			return fmt.Errorf("recipe %q: %w", recipe.DishID, err)
+		}
+	}
+	return nil
+}
+
+// Get returns the recipe of a dish by its ID
+func (b *RecipeBook) Get(dishID string) (models.Recipe, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	recipe, ok := b.recipes[dishID]
+	return recipe, ok
+}
+
+// Find returns the recipe of the dish a name refers to, as matched by the
+// dish dictionary
+func (b *RecipeBook) Find(name string) (models.Recipe, bool) {
+	if recipe, ok := b.Get(name); ok {
+		return recipe, true
+	}
+	return b.Get(b.dishes.Match(name).DishID)
+}
+
+// Recipes returns every recipe in dish ID order
+func (b *RecipeBook) Recipes() []models.Recipe {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	recipes := make([]models.Recipe, 0, len(b.recipes))
+	for _, recipe := range b.recipes {
+		recipes = append(recipes, recipe)
+	}
+	sort.Slice(recipes, func(i, j int) bool { return recipes[i].DishID < recipes[j].DishID })
+	return recipes
+}
+
+// Recipe returns the recipe of a dish, given by its ID or a name the dish
+// dictionary recognizes, for the given number of servings. Zero servings
+// means the household's.
+func (s *MenuAdvisorService) Recipe(dish string, servings int) (*models.Recipe, error) {
+	if servings < 0 {
+		return nil, models.ValidationErrors{{Field: "servings", Message: "must not be negative"}}
+	}
+	recipe, ok := s.recipes.Find(dish)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRecipeNotFound, dish)
+	}
+	if servings == 0 {
+		servings = s.householdServings()
+	}
+	recipe = recipe.Scale(servings)
+	return &recipe, nil
+}
+
+// defaultRecipes are simple home recipes for the dishes the recommender
+// suggests, with quantities for one adult serving
+var defaultRecipes = []models.Recipe{
+	// Main dishes
+	{
+		DishID: "tamagoyaki",
+		Ingredients: []models.Ingredient{
+			{Name: "卵", Quantity: 1.5, Unit: "個"}, {Name: "砂糖", Quantity: 1, Unit: "小さじ"},
+			{Name: "しょうゆ", Quantity: 0.5, Unit: "小さじ"}, {Name: "サラダ油", Unit: "少々"},
+		},
+		Steps: []string{
+			"卵を溶きほぐし、砂糖としょうゆを混ぜる",
+			"卵焼き器に油をなじませ、卵液を3回に分けて流し入れ、巻きながら焼く",
+			"食べやすい大きさに切る",
+		},
+		PrepMinutes: 3, CookMinutes: 5, ActiveMinutes: 8, Equipment: []models.Equipment{models.EquipmentStove}, Skill: models.SkillIntermediate,
+	},
+	{
+		DishID: "natto",
+		Ingredients: []models.Ingredient{
+			{Name: "納豆", Quantity: 1, Unit: "パック"}, {Name: "長ねぎ", Quantity: 5, Unit: "g"},
+		},
+		Steps:       []string{"ねぎを小口切りにする", "納豆を添付のたれとよく混ぜ、ねぎをのせる"},
+		PrepMinutes: 2, ActiveMinutes: 2,
+	},
+	{
+		DishID: "grilled_fish",
+		Ingredients: []models.Ingredient{
+			{Name: "あじ", Quantity: 1, Unit: "尾"}, {Name: "塩", Unit: "少々"}, {Name: "大根", Quantity: 30, Unit: "g"},
+		},
+		Steps: []string{
+			"あじのぜいごとわたを取り、塩をふって10分おく",
+			"魚焼きグリルで両面を焼く",
+			"大根をおろして添える",
+		},
+		PrepMinutes: 12, CookMinutes: 10, ActiveMinutes: 14, Equipment: []models.Equipment{models.EquipmentGrill}, Skill: models.SkillIntermediate,
+	},
+	{
+		DishID: "salted_salmon",
+		Ingredients: []models.Ingredient{
+			{Name: "甘塩鮭", Quantity: 1, Unit: "切れ"},
+		},
+		Steps:       []string{"魚焼きグリルで皮目から焼き、裏返して火を通す"},
+		PrepMinutes: 1, CookMinutes: 8, ActiveMinutes: 2, Equipment: []models.Equipment{models.EquipmentGrill},
+	},
+	{
+		DishID: "salted_mackerel",
+		Ingredients: []models.Ingredient{
+			{Name: "さば", Quantity: 1, Unit: "切れ"}, {Name: "塩", Unit: "少々"}, {Name: "レモン", Quantity: 0.1, Unit: "個"},
+		},
+		Steps: []string{
+			"さばに塩をふって10分おき、出てきた水気をふく",
+			"魚焼きグリルで皮目から焼く",
+			"くし形に切ったレモンを添える",
+		},
+		PrepMinutes: 12, CookMinutes: 10, ActiveMinutes: 13, Equipment: []models.Equipment{models.EquipmentGrill},
+	},
+	{
+		DishID: "simmered_fish",
+		Ingredients: []models.Ingredient{
+			{Name: "かれい", Quantity: 1, Unit: "切れ"}, {Name: "しょうが", Quantity: 0.25, Unit: "片"},
+			{Name: "しょうゆ", Quantity: 1, Unit: "大さじ"}, {Name: "みりん", Quantity: 1, Unit: "大さじ"},
+			{Name: "酒", Quantity: 1, Unit: "大さじ"}, {Name: "砂糖", Quantity: 1, Unit: "小さじ"},
+		},
+		Steps: []string{
+			"しょうがを薄切りにする",
+			"鍋に調味料と水100mlを煮立て、魚としょうがを入れる",
+			"落としぶたをして中火で10分煮る",
+		},
+		PrepMinutes: 5, CookMinutes: 15, ActiveMinutes: 8, Equipment: []models.Equipment{models.EquipmentStove}, Skill: models.SkillIntermediate,
+	},
+	{
+		DishID: "pork_shabu",
+		Ingredients: []models.Ingredient{
+			{Name: "豚ロース薄切り肉", Quantity: 100, Unit: "g"}, {Name: "レタス", Quantity: 50, Unit: "g"},
+			{Name: "トマト", Quantity: 0.25, Unit: "個"}, {Name: "ポン酢", Quantity: 1.5, Unit: "大さじ"},
+		},
+		Steps: []string{
+			"レタスをちぎり、トマトをくし形に切る",
+			"沸騰直前の湯で豚肉を1枚ずつゆで、色が変わったら取り出す",
+			"野菜と盛り合わせ、ポン酢をかける",
+		},
+		PrepMinutes: 5, CookMinutes: 10, ActiveMinutes: 12, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+	{
+		DishID: "karaage",
+		Ingredients: []models.Ingredient{
+			{Name: "鶏もも肉", Quantity: 120, Unit: "g"}, {Name: "しょうゆ", Quantity: 1, Unit: "大さじ"},
+			{Name: "酒", Quantity: 0.5, Unit: "大さじ"}, {Name: "しょうが", Quantity: 0.25, Unit: "片"},
+			{Name: "片栗粉", Quantity: 2, Unit: "大さじ"}, {Name: "揚げ油", Unit: "適量"},
+		},
+		Steps: []string{
+			"鶏肉をひと口大に切り、しょうゆ・酒・すりおろしたしょうがをもみ込んで15分おく",
+			"片栗粉をまぶし、170℃の油で4分揚げる",
+			"一度取り出して2分休ませ、180℃で1分揚げる",
+		},
+		PrepMinutes: 20, CookMinutes: 15, ActiveMinutes: 25, Equipment: []models.Equipment{models.EquipmentStove}, Skill: models.SkillIntermediate,
+	},
+	{
+		DishID: "beef_stir_fry",
+		Ingredients: []models.Ingredient{
+			{Name: "牛こま切れ肉", Quantity: 100, Unit: "g"}, {Name: "ピーマン", Quantity: 1, Unit: "個"},
+			{Name: "玉ねぎ", Quantity: 0.25, Unit: "個"}, {Name: "焼肉のたれ", Quantity: 1.5, Unit: "大さじ"},
+			{Name: "サラダ油", Quantity: 1, Unit: "小さじ"},
+		},
+		Steps: []string{
+			"ピーマンと玉ねぎを細切りにする",
+			"フライパンで牛肉を炒め、色が変わったら野菜を加える",
+			"焼肉のたれを回し入れて炒め合わせる",
+		},
+		PrepMinutes: 5, CookMinutes: 7, ActiveMinutes: 12, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+
+	// Side dishes
+	{
+		DishID: "spinach_ohitashi",
+		Ingredients: []models.Ingredient{
+			{Name: "ほうれん草", Quantity: 0.25, Unit: "束"}, {Name: "かつお節", Quantity: 1, Unit: "g"},
+			{Name: "しょうゆ", Quantity: 1, Unit: "小さじ"},
+		},
+		Steps: []string{
+			"ほうれん草をゆでて水にとり、水気をしぼって4cmに切る",
+			"しょうゆであえ、かつお節をのせる",
+		},
+		PrepMinutes: 3, CookMinutes: 3, ActiveMinutes: 5, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+	{
+		DishID: "spinach_goma",
+		Ingredients: []models.Ingredient{
+			{Name: "ほうれん草", Quantity: 0.25, Unit: "束"}, {Name: "すりごま", Quantity: 1, Unit: "大さじ"},
+			{Name: "砂糖", Quantity: 0.5, Unit: "小さじ"}, {Name: "しょうゆ", Quantity: 1, Unit: "小さじ"},
+		},
+		Steps: []string{
+			"ほうれん草をゆでて水にとり、水気をしぼって4cmに切る",
+			"すりごま・砂糖・しょうゆを混ぜ、ほうれん草をあえる",
+		},
+		PrepMinutes: 3, CookMinutes: 3, ActiveMinutes: 5, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+	{
+		DishID: "komatsuna_goma",
+		Ingredients: []models.Ingredient{
+			{Name: "小松菜", Quantity: 0.25, Unit: "束"}, {Name: "すりごま", Quantity: 1, Unit: "大さじ"},
+			{Name: "砂糖", Quantity: 0.5, Unit: "小さじ"}, {Name: "しょうゆ", Quantity: 1, Unit: "小さじ"},
+		},
+		Steps: []string{
+			"小松菜をゆでて水にとり、水気をしぼって4cmに切る",
+			"すりごま・砂糖・しょうゆを混ぜ、小松菜をあえる",
+		},
+		PrepMinutes: 3, CookMinutes: 3, ActiveMinutes: 5, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+	{
+		DishID: "komatsuna_shirasu",
+		Ingredients: []models.Ingredient{
+			{Name: "小松菜", Quantity: 0.25, Unit: "束"}, {Name: "しらす干し", Quantity: 10, Unit: "g"},
+			{Name: "しょうゆ", Quantity: 1, Unit: "小さじ"},
+		},
+		Steps: []string{
+			"小松菜を4cmに切り、耐熱容器に入れてラップをし、電子レンジで1分半加熱する",
+			"水にとって水気をしぼり、しらすとしょうゆであえる",
+		},
+		PrepMinutes: 3, CookMinutes: 2, ActiveMinutes: 4, Equipment: []models.Equipment{models.EquipmentMicrowave},
+	},
+	{
+		DishID: "hiyayakko",
+		Ingredients: []models.Ingredient{
+			{Name: "絹ごし豆腐", Quantity: 0.25, Unit: "丁"}, {Name: "長ねぎ", Quantity: 5, Unit: "g"},
+			{Name: "かつお節", Quantity: 1, Unit: "g"}, {Name: "しょうゆ", Quantity: 1, Unit: "小さじ"},
+		},
+		Steps:       []string{"豆腐を器に盛り、小口切りにしたねぎとかつお節をのせ、しょうゆをかける"},
+		PrepMinutes: 3, ActiveMinutes: 3,
+	},
+	{
+		DishID: "kinpira",
+		Ingredients: []models.Ingredient{
+			{Name: "ごぼう", Quantity: 0.25, Unit: "本"}, {Name: "にんじん", Quantity: 20, Unit: "g"},
+			{Name: "しょうゆ", Quantity: 1, Unit: "小さじ"}, {Name: "みりん", Quantity: 1, Unit: "小さじ"},
+			{Name: "ごま油", Quantity: 0.5, Unit: "小さじ"}, {Name: "白ごま", Unit: "少々"},
+		},
+		Steps: []string{
+			"ごぼうとにんじんを細切りにし、ごぼうは水にさらす",
+			"ごま油で炒め、しんなりしたらしょうゆとみりんを加えて汁気がなくなるまで炒める",
+			"白ごまをふる",
+		},
+		PrepMinutes: 8, CookMinutes: 7, ActiveMinutes: 15, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+	{
+		DishID: "chikuzenni",
+		Ingredients: []models.Ingredient{
+			{Name: "鶏もも肉", Quantity: 50, Unit: "g"}, {Name: "れんこん", Quantity: 30, Unit: "g"},
+			{Name: "にんじん", Quantity: 20, Unit: "g"}, {Name: "ごぼう", Quantity: 0.1, Unit: "本"},
+			{Name: "こんにゃく", Quantity: 30, Unit: "g"}, {Name: "干ししいたけ", Quantity: 1, Unit: "枚"},
+			{Name: "しょうゆ", Quantity: 1, Unit: "大さじ"}, {Name: "みりん", Quantity: 1, Unit: "大さじ"},
+			{Name: "砂糖", Quantity: 1, Unit: "小さじ"},
+		},
+		Steps: []string{
+			"干ししいたけを水で戻し、野菜とこんにゃくを乱切り、鶏肉をひと口大に切る",
+			"鍋で鶏肉を炒め、野菜とこんにゃくを加えて炒め合わせる",
+			"しいたけの戻し汁100mlと調味料を加え、落としぶたをして15分煮る",
+		},
+		PrepMinutes: 15, CookMinutes: 20, ActiveMinutes: 22, Equipment: []models.Equipment{models.EquipmentStove}, Skill: models.SkillIntermediate,
+	},
+	{
+		DishID: "stir_fried_vegetables",
+		Ingredients: []models.Ingredient{
+			{Name: "キャベツ", Quantity: 60, Unit: "g"}, {Name: "にんじん", Quantity: 15, Unit: "g"},
+			{Name: "もやし", Quantity: 0.25, Unit: "袋"}, {Name: "豚こま切れ肉", Quantity: 30, Unit: "g"},
+			{Name: "塩こしょう", Unit: "少々"}, {Name: "サラダ油", Quantity: 1, Unit: "小さじ"},
+		},
+		Steps: []string{
+			"キャベツをざく切り、にんじんを短冊切りにする",
+			"フライパンで豚肉を炒め、にんじん・キャベツ・もやしの順に加えて強火で炒める",
+			"塩こしょうで味を調える",
+		},
+		PrepMinutes: 5, CookMinutes: 5, ActiveMinutes: 10, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+	{
+		DishID: "bean_sprout_stir_fry",
+		Ingredients: []models.Ingredient{
+			{Name: "もやし", Quantity: 0.5, Unit: "袋"}, {Name: "にら", Quantity: 0.1, Unit: "束"},
+			{Name: "鶏がらスープの素", Quantity: 0.5, Unit: "小さじ"}, {Name: "ごま油", Quantity: 1, Unit: "小さじ"},
+		},
+		Steps: []string{
+			"にらを4cmに切る",
+			"ごま油でもやしを強火で炒め、にらと鶏がらスープの素を加えてさっと炒める",
+		},
+		PrepMinutes: 2, CookMinutes: 3, ActiveMinutes: 5, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+	{
+		DishID: "vegetable_tempura",
+		Ingredients: []models.Ingredient{
+			{Name: "さつまいも", Quantity: 40, Unit: "g"}, {Name: "なす", Quantity: 0.5, Unit: "本"},
+			{Name: "かぼちゃ", Quantity: 40, Unit: "g"}, {Name: "天ぷら粉", Quantity: 3, Unit: "大さじ"},
+			{Name: "揚げ油", Unit: "適量"}, {Name: "天つゆ", Quantity: 2, Unit: "大さじ"},
+		},
+		Steps: []string{
+			"野菜を7mm幅に切る",
+			"天ぷら粉を冷水で溶いて衣を作る",
+			"野菜に衣をつけ、170℃の油で3分ずつ揚げる",
+		},
+		PrepMinutes: 10, CookMinutes: 15, ActiveMinutes: 25, Equipment: []models.Equipment{models.EquipmentStove}, Skill: models.SkillAdvanced,
+	},
+	{
+		DishID: "steamed_vegetables",
+		Ingredients: []models.Ingredient{
+			{Name: "ブロッコリー", Quantity: 0.25, Unit: "株"}, {Name: "にんじん", Quantity: 20, Unit: "g"},
+			{Name: "じゃがいも", Quantity: 0.5, Unit: "個"}, {Name: "ごまドレッシング", Quantity: 1, Unit: "大さじ"},
+		},
+		Steps: []string{
+			"野菜をひと口大に切る",
+			"耐熱容器に入れて水大さじ1をふり、ラップをして電子レンジで4分加熱する",
+			"ドレッシングを添える",
+		},
+		PrepMinutes: 5, CookMinutes: 4, ActiveMinutes: 5, Equipment: []models.Equipment{models.EquipmentMicrowave},
+	},
+	{
+		DishID: "green_salad",
+		Ingredients: []models.Ingredient{
+			{Name: "レタス", Quantity: 40, Unit: "g"}, {Name: "きゅうり", Quantity: 0.25, Unit: "本"},
+			{Name: "ミニトマト", Quantity: 2, Unit: "個"}, {Name: "ドレッシング", Quantity: 1, Unit: "大さじ"},
+		},
+		Steps:       []string{"レタスをちぎり、きゅうりを薄切り、ミニトマトを半分に切る", "盛り合わせてドレッシングをかける"},
+		PrepMinutes: 5, ActiveMinutes: 5,
+	},
+	{
+		DishID: "cabbage_salad",
+		Ingredients: []models.Ingredient{
+			{Name: "キャベツ", Quantity: 60, Unit: "g"}, {Name: "ホールコーン", Quantity: 15, Unit: "g"},
+			{Name: "マヨネーズ", Quantity: 1, Unit: "大さじ"}, {Name: "塩こしょう", Unit: "少々"},
+		},
+		Steps:       []string{"キャベツを千切りにする", "コーンを加え、マヨネーズと塩こしょうであえる"},
+		PrepMinutes: 5, ActiveMinutes: 5,
+	},
+
+	// Soups
+	{
+		DishID: "miso_soup",
+		Ingredients: []models.Ingredient{
+			{Name: "豆腐", Quantity: 0.1, Unit: "丁"}, {Name: "乾燥わかめ", Quantity: 1, Unit: "g"},
+			{Name: "だし汁", Quantity: 150, Unit: "ml"}, {Name: "みそ", Quantity: 0.5, Unit: "大さじ"},
+		},
+		Steps: []string{
+			"だし汁を温め、さいの目に切った豆腐とわかめを入れる",
+			"煮立つ直前に火を弱め、みそを溶き入れる",
+		},
+		PrepMinutes: 2, CookMinutes: 5, ActiveMinutes: 3, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+	{
+		DishID: "clear_soup",
+		Ingredients: []models.Ingredient{
+			{Name: "だし汁", Quantity: 150, Unit: "ml"}, {Name: "絹ごし豆腐", Quantity: 0.1, Unit: "丁"},
+			{Name: "三つ葉", Quantity: 2, Unit: "g"}, {Name: "薄口しょうゆ", Quantity: 0.5, Unit: "小さじ"},
+			{Name: "塩", Unit: "少々"},
+		},
+		Steps: []string{
+			"だし汁を温め、薄口しょうゆと塩で味を調える",
+			"豆腐を入れて温め、三つ葉を散らす",
+		},
+		PrepMinutes: 2, CookMinutes: 5, ActiveMinutes: 3, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+	{
+		DishID: "wakame_soup",
+		Ingredients: []models.Ingredient{
+			{Name: "乾燥わかめ", Quantity: 1, Unit: "g"}, {Name: "長ねぎ", Quantity: 10, Unit: "g"},
+			{Name: "鶏がらスープの素", Quantity: 0.5, Unit: "小さじ"}, {Name: "白ごま", Unit: "少々"},
+			{Name: "ごま油", Unit: "少々"},
+		},
+		Steps: []string{
+			"水150mlに鶏がらスープの素を入れて煮立てる",
+			"わかめと斜め切りにしたねぎを入れ、ごま油と白ごまを加える",
+		},
+		PrepMinutes: 2, CookMinutes: 4, ActiveMinutes: 3, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+	{
+		DishID: "chinese_soup",
+		Ingredients: []models.Ingredient{
+			{Name: "卵", Quantity: 0.5, Unit: "個"}, {Name: "長ねぎ", Quantity: 10, Unit: "g"},
+			{Name: "鶏がらスープの素", Quantity: 0.5, Unit: "小さじ"}, {Name: "片栗粉", Quantity: 0.5, Unit: "小さじ"},
+		},
+		Steps: []string{
+			"水150mlに鶏がらスープの素を入れて煮立て、水溶き片栗粉でとろみをつける",
+			"溶き卵を回し入れ、ねぎを散らす",
+		},
+		PrepMinutes: 2, CookMinutes: 5, ActiveMinutes: 4, Equipment: []models.Equipment{models.EquipmentStove},
+	},
+
+	// Staples
+	{
+		DishID: "rice",
+		Ingredients: []models.Ingredient{
+			{Name: "米", Quantity: 0.5, Unit: "合"},
+		},
+		Steps:       []string{"米を研いで30分浸水させる", "炊飯器で炊く"},
+		PrepMinutes: 5, CookMinutes: 50, ActiveMinutes: 5, Equipment: []models.Equipment{models.EquipmentRiceCooker},
+	},
+}
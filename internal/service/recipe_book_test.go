@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestDefaultRecipeBookCoversSuggestions(t *testing.T) {
+	service := NewMenuAdvisorService()
+	mains := []string{"鶏肉の照り焼き", "魚のフライ", "豚肉の生姜焼き", "カレーライス", "麻婆豆腐"}
+	for i, main := range mains {
+		date := time.Date(2025, 2, 3+i, 0, 0, 0, 0, time.UTC)
+		service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: main})
+		for _, mealType := range models.MealTypes {
+			suggestion, err := service.GenerateHomeMenuSuggestion(date, mealType)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			dishes := append([]string{suggestion.MainDish}, suggestion.SideDishes...)
+			if suggestion.Soup != "" {
+				dishes = append(dishes, suggestion.Soup)
+			}
+			for _, dish := range dishes {
+				if _, ok := service.Recipes().Find(dish); !ok && dish != "のり" {
+					t.Errorf("%s %s: no recipe for %s", main, mealType, dish)
+				}
+			}
+		}
+	}
+	for _, side := range gapSides {
+		if _, ok := service.Recipes().Find(side.dish); !ok {
+			t.Errorf("No recipe for %s", side.dish)
+		}
+	}
+}
+
+func TestRecipeScaling(t *testing.T) {
+	service := NewMenuAdvisorService()
+
+	// Names are matched through the dish dictionary
+	recipe, err := service.Recipe("焼き魚（アジ）", 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if recipe.DishID != "grilled_fish" || recipe.Servings != 3 || recipe.Ingredients[0].Quantity != 3 {
+		t.Errorf("Unexpected recipe: %+v", recipe)
+	}
+
+	recipe, _ = service.Recipe("miso_soup", 3)
+	quantities := make(map[string]models.Ingredient)
+	for _, ingredient := range recipe.Ingredients {
+		quantities[ingredient.Name] = ingredient
+	}
+	// 150ml of stock and half a spoon of miso each; 0.1 block of tofu is rounded up to a quarter
+	if quantities["だし汁"].Quantity != 450 || quantities["みそ"].Quantity != 1.5 || quantities["豆腐"].Quantity != 0.5 {
+		t.Errorf("Unexpected quantities: %+v", recipe.Ingredients)
+	}
+
+	// Without servings the recipe is for the household
+	service.SetHousehold(models.Household{Size: 4})
+	recipe, _ = service.Recipe("卵焼き", 0)
+	if recipe.Servings != 4 || recipe.Ingredients[0].Quantity != 6 || recipe.Ingredients[3].Quantity != 0 {
+		t.Errorf("Unexpected household recipe: %+v", recipe)
+	}
+
+	if _, err := service.Recipe("のり", 1); !errors.Is(err, ErrRecipeNotFound) {
+		t.Errorf("Expected ErrRecipeNotFound, got %v", err)
+	}
+	if _, err := service.Recipe("卵焼き", -1); err == nil {
+		t.Error("Expected negative servings to be refused")
+	}
+}
+
+func TestRecipeBookAdd(t *testing.T) {
+	book := NewRecipeBook(DefaultDishDictionary())
+	recipe := models.Recipe{
+		DishID:      "nikujaga",
+		Ingredients: []models.Ingredient{{Name: "じゃがいも", Quantity: 1, Unit: "個"}},
+		Steps:       []string{"煮る"},
+		Equipment:   []models.Equipment{models.EquipmentStove},
+	}
+	if err := book.Add(recipe); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, ok := book.Find("肉じゃが"); !ok || got.Name != "肉じゃが" || got.Servings != 1 {
+		t.Errorf("Unexpected recipe: %+v", got)
+	}
+
+	invalid := recipe
+	invalid.DishID = "unknown"
+	if err := book.Add(invalid); err == nil {
+		t.Error("Expected a recipe for an unknown dish to be refused")
+	}
+	invalid = recipe
+	invalid.Equipment = []models.Equipment{"pressure_cooker"}
+	if err := book.Add(invalid); err == nil {
+		t.Error("Expected unknown equipment to be refused")
+	}
+}
+
+func TestSuggestionRecipes(t *testing.T) {
+	service := NewMenuAdvisorService()
+	service.SetHousehold(models.Household{Size: 3})
+	date := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照り焼き"})
+
+	suggestion, err := service.GenerateHomeMenuSuggestion(date, models.MealTypeDinner)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(suggestion.Recipes) == 0 {
+		t.Fatal("Expected recipe summaries")
+	}
+	first := suggestion.Recipes[0]
+	if first.Name != suggestion.MainDish || first.DishID != "simmered_fish" || first.Servings != 3 || first.TotalMinutes != 20 {
+		t.Errorf("Unexpected summary: %+v", first)
+	}
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Register decoders for uploaded images
+	_ "image/jpeg"
+	"image/png"
+	"sort"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+var (
+	// ErrReviewNotFound is returned when a pending review does not exist
+	ErrReviewNotFound = errors.New("review not found")
+	// ErrReviewClosed is returned when changing a review that was already approved or rejected
+	ErrReviewClosed = errors.New("review is already closed")
+	// ErrImageUnavailable is returned when the original image of a review cannot be shown
+	ErrImageUnavailable = errors.New("original image is not available")
+)
+
+// DefaultReviewThreshold is the extraction confidence below which parsed menus
+// are held for review instead of being stored
+const DefaultReviewThreshold = 0.8
+
+// reviewImagePadding is the margin in pixels kept around a cropped field region
+const reviewImagePadding = 4
+
+// originalDocument keeps the uploaded bytes of a document that has pending reviews
+type originalDocument struct {
+	Data []byte
+	Type models.DocumentType
+}
+
+// SetReviewThreshold changes the confidence below which extractions need review
+func (dp *DocumentProcessor) SetReviewThreshold(threshold float64) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	dp.reviewThreshold = threshold
+}
+
+// holdForReview splits parsed menus into those confident enough to store and
+// those that need a person to confirm them, creating a pending review for each
+// of the latter. Reviews are not created in a dry run.
+func (dp *DocumentProcessor) holdForReview(parsed []parsedMenu, extracted *models.ExtractedMenuData, doc *models.DocumentSource, req *models.DocumentProcessingRequest) ([]models.SchoolLunchMenu, []models.DayMergeResult) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+
+	var accepted []models.SchoolLunchMenu
+	var held []models.DayMergeResult
+	for i, p := range parsed {
+		confidence := p.confidence()
+		if len(p.Fields) == 0 {
+			// Structured data carries no per-field confidence
+			confidence = extracted.Confidence
+		}
+		if confidence >= dp.reviewThreshold {
+			accepted = append(accepted, p.Menu)
+			continue
+		}
+
+		held = append(held, models.DayMergeResult{Date: dateKey(p.Menu.Date), Status: models.DayMergeNeedsReview})
+		if req.DryRun {
+			continue
+		}
+
+		review := &models.PendingReview{
+			ID:          fmt.Sprintf("%s_r%d", doc.ID, i+1),
+			DocumentID:  doc.ID,
+			Menu:        p.Menu,
+			Fields:      p.Fields,
+			Confidence:  confidence,
+			Status:      models.ReviewStatusPending,
+			CreatedAt:   time.Now(),
+			MergePolicy: req.MergePolicy,
+			IssuedAt:    req.IssuedAt,
+		}
+		dp.reviews[review.ID] = review
+		doc.ReviewIDs = append(doc.ReviewIDs, review.ID)
+	}
+
+	if len(doc.ReviewIDs) > 0 {
+		if data, err := readAllFrom(req.File); err == nil {
+			dp.originals[doc.ID] = originalDocument{Data: data, Type: doc.Type}
+		}
+	}
+	return accepted, held
+}
+
+// ListReviews returns reviews with the given status (all when empty), oldest first
+func (dp *DocumentProcessor) ListReviews(status models.ReviewStatus) []models.PendingReview {
+	dp.mu.RLock()
+	defer dp.mu.RUnlock()
+
+	reviews := []models.PendingReview{}
+	for _, r := range dp.reviews {
+		if status == "" || r.Status == status {
+			reviews = append(reviews, *copyReview(r))
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool {
+		if !reviews[i].CreatedAt.Equal(reviews[j].CreatedAt) {
+			return reviews[i].CreatedAt.Before(reviews[j].CreatedAt)
+		}
+		return reviews[i].ID < reviews[j].ID
+	})
+	return reviews
+}
+
+// GetReview returns a single review
+func (dp *DocumentProcessor) GetReview(id string) (*models.PendingReview, error) {
+	dp.mu.RLock()
+	defer dp.mu.RUnlock()
+
+	r, ok := dp.reviews[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReviewNotFound, id)
+	}
+	return copyReview(r), nil
+}
+
+// CorrectReview applies a person's corrections to a pending review. Fields
+// whose value changed are marked as corrected with full confidence.
+func (dp *DocumentProcessor) CorrectReview(id string, correct func(*models.SchoolLunchMenu) error) (*models.PendingReview, error) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+
+	r, err := dp.openReviewLocked(id)
+	if err != nil {
+		return nil, err
+	}
+
+	menu := r.Menu
+	menu.SideDishes = append([]string(nil), r.Menu.SideDishes...)
+	if err := correct(&menu); err != nil {
+		return nil, err
+	}
+
+	for _, change := range DiffSchoolLunchMenus(&r.Menu, &menu) {
+		f := r.Fields[change.Field]
+		f.Field = change.Field
+		f.Text = fmt.Sprint(change.New)
+		f.Confidence = 1.0
+		f.Corrected = true
+		r.Fields[change.Field] = f
+	}
+	r.Menu = menu
+	r.Confidence = (&parsedMenu{Fields: r.Fields}).confidence()
+
+	return copyReview(r), nil
+}
+
+// ApproveReview closes the review and merges its menu into the service under
+// the merge policy of the upload it came from. The outcome, such as a conflict
+// with the stored day, is recorded as the review's Merge.
+func (dp *DocumentProcessor) ApproveReview(id string, actor string) (*models.PendingReview, error) {
+	dp.mu.Lock()
+	r, err := dp.openReviewLocked(id)
+	if err == nil {
+		err = r.Menu.Validate()
+	}
+	if err != nil {
+		dp.mu.Unlock()
+		return nil, err
+	}
+	dp.closeReviewLocked(r, models.ReviewStatusApproved, actor)
+	review := copyReview(r)
+	dp.mu.Unlock()
+
+	// The menu service is called without holding dp.mu, since merges call
+	// back into the processor for document dates
+	documentDate := review.CreatedAt
+	if review.IssuedAt != nil {
+		documentDate = *review.IssuedAt
+	}
+	report := dp.menuService.MergeSchoolLunchMenus([]models.SchoolLunchMenu{review.Menu}, MergeOptions{
+		Policy:       review.MergePolicy,
+		Context:      models.ChangeContext{Actor: actor, SourceID: review.DocumentID},
+		DocumentDate: documentDate,
+		SourceDate:   dp.documentDate,
+	})
+	if len(report.Days) > 0 {
+		day := report.Days[0]
+		dp.mu.Lock()
+		if r, ok := dp.reviews[id]; ok {
+			stored := day
+			r.Merge = &stored
+		}
+		dp.mu.Unlock()
+		review.Merge = &day
+	}
+	return review, nil
+}
+
+// RejectReview closes a review without storing its menu
+func (dp *DocumentProcessor) RejectReview(id string, actor string) (*models.PendingReview, error) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+
+	r, err := dp.openReviewLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	dp.closeReviewLocked(r, models.ReviewStatusRejected, actor)
+
+	return copyReview(r), nil
+}
+
+// ReviewFieldImage returns a PNG of the region of the original image that a
+// field was read from, on the page of a multi-page TIFF it was read from, or
+// of the whole first page when the field has no position. Images that cannot
+// be decoded, such as PDFs, HEIC photos and fax-compressed TIFFs, give
+// ErrImageUnavailable.
+func (dp *DocumentProcessor) ReviewFieldImage(id, field string) ([]byte, error) {
+	dp.mu.RLock()
+	r, ok := dp.reviews[id]
+	var original originalDocument
+	var box *models.BoundingBox
+	if ok {
+		original = dp.originals[r.DocumentID]
+		box = r.Fields[field].Box
+	}
+	dp.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReviewNotFound, id)
+	}
+	if original.Data == nil {
+		return nil, fmt.Errorf("%w for document %s", ErrImageUnavailable, r.DocumentID)
+	}
+
+	page := 1
+	if box != nil && box.Page > 0 {
+		page = box.Page
+	}
+	img, err := decodePageImage(original.Data, page)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageUnavailable, err)
+	}
+	if box != nil {
+		rect := image.Rect(box.X, box.Y, box.X+box.Width, box.Y+box.Height).
+			Inset(-reviewImagePadding).
+			Intersect(img.Bounds())
+		if sub, ok := img.(interface {
+			SubImage(image.Rectangle) image.Image
+		}); ok && !rect.Empty() {
+			img = sub.SubImage(rect)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodePageImage decodes one page, counted from 1, of an uploaded image.
+// Only TIFFs have more than one page.
+func decodePageImage(data []byte, page int) (image.Image, error) {
+	switch format := detectImageFormat(data); format {
+	case ImageFormatPNG, ImageFormatJPEG, ImageFormatGIF:
+		if page != 1 {
+			return nil, fmt.Errorf("image has no page %d", page)
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		return img, err
+	case ImageFormatTIFF:
+		offsets, order, err := tiffIFDOffsets(data)
+		if err != nil {
+			return nil, err
+		}
+		if page > len(offsets) {
+			return nil, fmt.Errorf("TIFF has no page %d", page)
+		}
+		return decodeTIFF(data, offsets[page-1], order)
+	case "":
+		return nil, fmt.Errorf("document is not an image")
+	default:
+		return nil, fmt.Errorf("%s images cannot be shown", format)
+	}
+}
+
+// openReviewLocked returns a review that can still be changed. Caller must hold dp.mu.
+func (dp *DocumentProcessor) openReviewLocked(id string) (*models.PendingReview, error) {
+	r, ok := dp.reviews[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReviewNotFound, id)
+	}
+	if r.Status != models.ReviewStatusPending {
+		return nil, fmt.Errorf("%w: %s is %s", ErrReviewClosed, id, r.Status)
+	}
+	return r, nil
+}
+
+// closeReviewLocked marks a review as decided. The original document is
+// dropped once none of its reviews is pending. Caller must hold dp.mu.
+func (dp *DocumentProcessor) closeReviewLocked(r *models.PendingReview, status models.ReviewStatus, actor string) {
+	now := time.Now()
+	r.Status = status
+	r.ReviewedAt = &now
+	r.ReviewedBy = actor
+
+	for _, other := range dp.reviews {
+		if other.DocumentID == r.DocumentID && other.Status == models.ReviewStatusPending {
+			return
+		}
+	}
+	delete(dp.originals, r.DocumentID)
+}
+
+// copyReview returns a copy of a review that callers may use without holding dp.mu
+func copyReview(r *models.PendingReview) *models.PendingReview {
+	review := *r
+	review.Menu.SideDishes = append([]string(nil), r.Menu.SideDishes...)
+	review.Fields = make(map[string]models.FieldExtraction, len(r.Fields))
+	for k, v := range r.Fields {
+		review.Fields[k] = v
+	}
+	if r.Merge != nil {
+		merge := *r.Merge
+		review.Merge = &merge
+	}
+	return &review
+}
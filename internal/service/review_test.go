@@ -0,0 +1,297 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// fakeOCR returns fixed lines regardless of the image
+type fakeOCR struct {
+	lines []models.TextLine
+}
+
+func (f *fakeOCR) Recognize(data []byte, format models.DocumentType) ([]models.TextLine, error) {
+	return f.lines, nil
+}
+
+// mockFileBytes implements multipart.File over binary data
+type mockFileBytes struct {
+	*bytes.Reader
+}
+
+func (m *mockFileBytes) Close() error {
+	return nil
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newReviewTestProcessor(t *testing.T) (*MenuAdvisorService, *DocumentProcessor, *models.DocumentSource) {
+	t.Helper()
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	processor.SetOCREngine(&fakeOCR{lines: []models.TextLine{
+		{Text: "2025年1月", Confidence: 0.98},
+		{Text: "13日(月)", Confidence: 0.97, Box: &models.BoundingBox{X: 0, Y: 0, Width: 60, Height: 20}},
+		{Text: "主菜: 鶏肉の照り焼き", Confidence: 0.93, Box: &models.BoundingBox{X: 0, Y: 20, Width: 150, Height: 20}},
+		{Text: "14日(火)", Confidence: 0.96, Box: &models.BoundingBox{X: 0, Y: 50, Width: 60, Height: 20}},
+		{Text: "主菜: 魚のフヲイ", Confidence: 0.41, Box: &models.BoundingBox{X: 10, Y: 80, Width: 100, Height: 20}},
+	}})
+
+	doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+		File:   &mockFileBytes{bytes.NewReader(testPNG(t, 200, 100))},
+		Header: &multipart.FileHeader{Filename: "menu.png"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return menuService, processor, doc
+}
+
+func TestProcessDocumentHoldsLowConfidenceForReview(t *testing.T) {
+	menuService, processor, doc := newReviewTestProcessor(t)
+
+	if doc.Status != "needs_review" || len(doc.ReviewIDs) != 1 {
+		t.Fatalf("Expected one day to need review, got status %s and reviews %v", doc.Status, doc.ReviewIDs)
+	}
+	if doc.MergeReport.Summary[models.DayMergeAdded] != 1 || doc.MergeReport.Summary[models.DayMergeNeedsReview] != 1 {
+		t.Errorf("Unexpected merge report: %+v", doc.MergeReport.Summary)
+	}
+	if _, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("Expected low-confidence day not to be stored before approval")
+	}
+
+	review, err := processor.GetReview(doc.ReviewIDs[0])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if review.Fields["main_dish"].Confidence != 0.41 {
+		t.Errorf("Expected per-field confidence, got %+v", review.Fields["main_dish"])
+	}
+}
+
+func TestCorrectAndApproveReview(t *testing.T) {
+	menuService, processor, doc := newReviewTestProcessor(t)
+	id := doc.ReviewIDs[0]
+
+	review, err := processor.CorrectReview(id, func(menu *models.SchoolLunchMenu) error {
+		menu.MainDish = "魚のフライ"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f := review.Fields["main_dish"]; !f.Corrected || f.Confidence != 1.0 {
+		t.Errorf("Expected corrected field to be marked, got %+v", f)
+	}
+
+	if _, err := processor.ApproveReview(id, "mom"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lunch, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC))
+	if err != nil || lunch.MainDish != "魚のフライ" {
+		t.Errorf("Expected corrected menu to be stored after approval, got %v, %v", lunch, err)
+	}
+	versions, _ := menuService.GetSchoolLunchVersions(lunch.Date)
+	if versions[0].SourceID != doc.ID || versions[0].Actor != "mom" {
+		t.Errorf("Expected version to reference document and reviewer, got %+v", versions[0])
+	}
+
+	if _, err := processor.ApproveReview(id, "mom"); !errors.Is(err, ErrReviewClosed) {
+		t.Errorf("Expected ErrReviewClosed on second approval, got %v", err)
+	}
+	if pending := processor.ListReviews(models.ReviewStatusPending); len(pending) != 0 {
+		t.Errorf("Expected no pending reviews, got %d", len(pending))
+	}
+}
+
+func TestApproveReviewKeepsExistingDay(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	date := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	menuService.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: "うどん"})
+	processor := NewDocumentProcessor(menuService)
+	processor.SetOCREngine(&fakeOCR{lines: []models.TextLine{
+		{Text: "2025年1月", Confidence: 0.98},
+		{Text: "14日(火)", Confidence: 0.96},
+		{Text: "主菜: 魚のフヲイ", Confidence: 0.41},
+	}})
+
+	doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+		File:        &mockFileBytes{bytes.NewReader(testPNG(t, 200, 100))},
+		Header:      &multipart.FileHeader{Filename: "menu.png"},
+		MergePolicy: models.MergePolicyKeepExisting,
+	})
+	if err != nil || len(doc.ReviewIDs) != 1 {
+		t.Fatalf("Expected one review, got %+v (%v)", doc, err)
+	}
+
+	review, err := processor.ApproveReview(doc.ReviewIDs[0], "mom")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if review.Merge == nil || review.Merge.Status != models.DayMergeConflict || len(review.Merge.Conflicts) == 0 {
+		t.Errorf("Expected the approval to report a conflict, got %+v", review.Merge)
+	}
+	if lunch, _ := menuService.GetSchoolLunchForDate(date); lunch == nil || lunch.MainDish != "うどん" {
+		t.Errorf("Expected the existing menu to be kept, got %+v", lunch)
+	}
+	if stored, _ := processor.GetReview(review.ID); stored.Merge == nil || stored.Merge.Status != models.DayMergeConflict {
+		t.Errorf("Expected the outcome to be recorded on the review, got %+v", stored.Merge)
+	}
+}
+
+func TestCorrectReviewDateAndNutrition(t *testing.T) {
+	menuService, processor, doc := newReviewTestProcessor(t)
+	id := doc.ReviewIDs[0]
+
+	review, err := processor.CorrectReview(id, func(menu *models.SchoolLunchMenu) error {
+		menu.Date = time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+		menu.Nutrition.Calories = 640
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, field := range []string{"date", "nutrition.calories"} {
+		if f := review.Fields[field]; !f.Corrected || f.Confidence != 1.0 {
+			t.Errorf("%s: expected corrected field to be marked, got %+v", field, f)
+		}
+	}
+
+	if _, err := processor.ApproveReview(id, "mom"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lunch, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil || lunch.Nutrition.Calories != 640 {
+		t.Errorf("Expected the corrected date and calories to be stored, got %v, %v", lunch, err)
+	}
+}
+
+func TestReviewFieldImage(t *testing.T) {
+	_, processor, doc := newReviewTestProcessor(t)
+
+	data, err := processor.ReviewFieldImage(doc.ReviewIDs[0], "main_dish")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected PNG output: %v", err)
+	}
+	// Box 10,80 100x20 padded by 4 and clipped to the 200x100 image
+	if b := img.Bounds(); b.Dx() != 108 || b.Dy() != 24 {
+		t.Errorf("Expected 108x24 crop, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	if _, err := processor.ReviewFieldImage("missing", "main_dish"); !errors.Is(err, ErrReviewNotFound) {
+		t.Errorf("Expected ErrReviewNotFound, got %v", err)
+	}
+}
+
+func TestReviewsReleaseOriginals(t *testing.T) {
+	_, processor, doc := newReviewTestProcessor(t)
+	id := doc.ReviewIDs[0]
+
+	// The uploaded bytes are kept only while a review is open
+	if _, err := processor.RejectReview(id, "mom"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := processor.originals[doc.ID]; ok {
+		t.Error("Expected the original to be dropped when its last review closed")
+	}
+	if _, err := processor.ReviewFieldImage(id, "main_dish"); !errors.Is(err, ErrImageUnavailable) {
+		t.Errorf("Expected ErrImageUnavailable for a closed review, got %v", err)
+	}
+
+	// Forgetting a document forgets its reviews and original
+	_, processor, doc = newReviewTestProcessor(t)
+	processor.mu.Lock()
+	processor.forgetDocumentLocked(doc.ID)
+	processor.mu.Unlock()
+	if len(processor.originals) != 0 || len(processor.reviews) != 0 {
+		t.Errorf("Expected the document's reviews and original to be forgotten, got %d and %d", len(processor.reviews), len(processor.originals))
+	}
+}
+
+func TestReviewFieldImageTIFFPage(t *testing.T) {
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+	processor.SetOCREngine(&pagedOCR{pages: [][]models.TextLine{
+		{
+			{Text: "2025年1月", Confidence: 0.98},
+			{Text: "13日(月)", Confidence: 0.97, Box: &models.BoundingBox{X: 0, Y: 0, Width: 6, Height: 2}},
+			{Text: "主菜: 鶏肉の照り焼き", Confidence: 0.93, Box: &models.BoundingBox{X: 0, Y: 2, Width: 8, Height: 2}},
+		},
+		{
+			{Text: "14日(火)", Confidence: 0.96, Box: &models.BoundingBox{X: 0, Y: 0, Width: 6, Height: 2}},
+			{Text: "主菜: 魚のフヲイ", Confidence: 0.41, Box: &models.BoundingBox{X: 0, Y: 2, Width: 8, Height: 2}},
+		},
+	}})
+	// A black first page and a white second page
+	black, white := make([]byte, 16*8), bytes.Repeat([]byte{255}, 16*8)
+	scan := buildTIFF(
+		tiffTestPage{width: 16, height: 8, bits: 8, photometric: 1, data: black},
+		tiffTestPage{width: 16, height: 8, bits: 8, photometric: 1, data: white},
+	)
+	doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+		File:   &mockFileBytes{bytes.NewReader(scan)},
+		Header: &multipart.FileHeader{Filename: "scan.tiff"},
+	})
+	if err != nil || len(doc.ReviewIDs) != 1 {
+		t.Fatalf("Expected one review, got %+v (%v)", doc, err)
+	}
+
+	data, err := processor.ReviewFieldImage(doc.ReviewIDs[0], "main_dish")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected PNG output: %v", err)
+	}
+	if r, _, _, _ := img.At(img.Bounds().Min.X, img.Bounds().Min.Y).RGBA(); r != 0xFFFF {
+		t.Errorf("Expected the crop to come from the second page")
+	}
+	// Box 0,2 8x2 padded by 4 and clipped to the 16x8 page
+	if b := img.Bounds(); b.Dx() != 12 || b.Dy() != 8 {
+		t.Errorf("Expected 12x8 crop, got %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestDecodePageImage(t *testing.T) {
+	if _, err := decodePageImage(testPNG(t, 4, 4), 1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	unavailable := map[string]struct {
+		data []byte
+		page int
+	}{
+		"missing page": {testPNG(t, 4, 4), 2},
+		"BMP":          {append([]byte("BM"), make([]byte, 30)...), 1},
+		"PDF":          {[]byte("%PDF-1.4\n"), 1},
+	}
+	for name, test := range unavailable {
+		if _, err := decodePageImage(test.data, test.page); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// SchoolCalendar tells which days the school serves lunch: weekdays that are
+// not national holidays, apart from the closures and extra school days it is
+// given
+type SchoolCalendar struct {
+	mu       sync.RWMutex
+	calendar models.SchoolCalendar
+}
+
+// NewSchoolCalendar creates a calendar with lunch on every weekday that is not a holiday
+func NewSchoolCalendar() *SchoolCalendar {
+	return &SchoolCalendar{}
+}
+
+// Set replaces the closures and extra school days
+func (c *SchoolCalendar) Set(calendar models.SchoolCalendar) error {
+	if err := calendar.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calendar = calendar
+	return nil
+}
+
+// Get returns the closures and extra school days
+func (c *SchoolCalendar) Get() models.SchoolCalendar {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.calendar
+}
+
+// LoadCalendar reads closures and extra school days from a JSON file
+func (c *SchoolCalendar) LoadCalendar(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read school calendar: %w", err)
+	}
+	var calendar models.SchoolCalendar
+	if err := json.Unmarshal(data, &calendar); err != nil {
+		return fmt.Errorf("failed to parse school calendar: %w", err)
+	}
+	return c.Set(calendar)
+}
+
+// DayOff reports whether the school serves no lunch on date, and why
+func (c *SchoolCalendar) DayOff(date time.Time) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	day := dateKey(date)
+	for _, d := range c.calendar.SchoolDays {
+		if d == day {
+			return "", false
+		}
+	}
+	for _, closure := range c.calendar.Closures {
+		if closure.From <= day && day <= closure.To {
+			return closure.Name, true
+		}
+	}
+	if name, ok := nationalHolidays(date.Year())[day]; ok {
+		return name, true
+	}
+	switch date.Weekday() {
+	case time.Saturday:
+		return "土曜日", true
+	case time.Sunday:
+		return "日曜日", true
+	}
+	return "", false
+}
+
+// nationalHolidays returns the national holidays of Japan in year by date,
+// following the rules in force since 2022
+func nationalHolidays(year int) map[string]string {
+	holidays := make(map[string]string)
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	add := func(month time.Month, d int, name string) {
+		holidays[dateKey(day(month, d))] = name
+	}
+	// nthMonday returns the day of the month of its nth Monday
+	nthMonday := func(month time.Month, n int) int {
+		first := day(month, 1).Weekday()
+		return 1 + (int(time.Monday)-int(first)+7)%7 + 7*(n-1)
+	}
+	// Equinoxes, by the approximation valid from 1980 to 2099
+	shift := 0.242194 * float64(year-1980)
+	leaps := (year - 1980) / 4
+
+	add(time.January, 1, "元日")
+	add(time.January, nthMonday(time.January, 2), "成人の日")
+	add(time.February, 11, "建国記念の日")
+	add(time.February, 23, "天皇誕生日")
+	add(time.March, int(20.8431+shift)-leaps, "春分の日")
+	add(time.April, 29, "昭和の日")
+	add(time.May, 3, "憲法記念日")
+	add(time.May, 4, "みどりの日")
+	add(time.May, 5, "こどもの日")
+	add(time.July, nthMonday(time.July, 3), "海の日")
+	add(time.August, 11, "山の日")
+	add(time.September, nthMonday(time.September, 3), "敬老の日")
+	add(time.September, int(23.2488+shift)-leaps, "秋分の日")
+	add(time.October, nthMonday(time.October, 2), "スポーツの日")
+	add(time.November, 3, "文化の日")
+	add(time.November, 23, "勤労感謝の日")
+
+	dates := make([]string, 0, len(holidays))
+	for d := range holidays {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+	for _, d := range dates {
+		date, _ := time.Parse("2006-01-02", d)
+		// A day between two holidays is a holiday too
+		next, after := dateKey(date.AddDate(0, 0, 1)), dateKey(date.AddDate(0, 0, 2))
+		if _, ok := holidays[next]; !ok && holidays[after] != "" && date.AddDate(0, 0, 1).Weekday() != time.Sunday {
+			holidays[next] = "国民の休日"
+		}
+	}
+	for _, d := range dates {
+		date, _ := time.Parse("2006-01-02", d)
+		if date.Weekday() != time.Sunday {
+			continue
+		}
+		// A holiday on a Sunday moves to the next day that is not a holiday
+		for {
+			date = date.AddDate(0, 0, 1)
+			if _, ok := holidays[dateKey(date)]; !ok {
+				holidays[dateKey(date)] = "振替休日"
+				break
+			}
+		}
+	}
+	return holidays
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestNationalHolidays(t *testing.T) {
+	tests := map[string]string{
+		"2025-01-13": "成人の日",
+		"2025-03-20": "春分の日",
+		"2025-05-06": "振替休日", // 5/4 fell on a Sunday
+		"2025-07-21": "海の日",
+		"2025-09-23": "秋分の日",
+		"2025-11-24": "振替休日",
+		"2026-09-22": "国民の休日", // Between 敬老の日 and 秋分の日
+		"2026-10-12": "スポーツの日",
+	}
+	for day, name := range tests {
+		date, _ := time.Parse("2006-01-02", day)
+		if got := nationalHolidays(date.Year())[day]; got != name {
+			t.Errorf("%s: expected %s, got %q", day, name, got)
+		}
+	}
+	if len(nationalHolidays(2025)) != 19 {
+		t.Errorf("Expected 19 holidays in 2025, got %v", nationalHolidays(2025))
+	}
+}
+
+func TestSchoolCalendarDayOff(t *testing.T) {
+	calendar := NewSchoolCalendar()
+	path := filepath.Join(t.TempDir(), "calendar.json")
+	os.WriteFile(path, []byte(`{
+		"closures": [{"name": "夏休み", "from": "2025-07-19", "to": "2025-08-31"}],
+		"school_days": ["2025-06-14"]
+	}`), 0o644)
+	if err := calendar.LoadCalendar(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		day    string
+		off    bool
+		reason string
+	}{
+		{"2025-06-13", false, ""},
+		{"2025-06-14", false, ""}, // Saturday classes
+		{"2025-06-15", true, "日曜日"},
+		{"2025-07-21", true, "夏休み"},
+		{"2025-09-15", true, "敬老の日"},
+	}
+	for _, test := range tests {
+		date, _ := time.Parse("2006-01-02", test.day)
+		if reason, off := calendar.DayOff(date); off != test.off || reason != test.reason {
+			t.Errorf("%s: expected %v %q, got %v %q", test.day, test.off, test.reason, off, reason)
+		}
+	}
+
+	if err := calendar.Set(models.SchoolCalendar{Closures: []models.SchoolClosure{{Name: "休校", From: "2025-09-02", To: "2025-09-01"}}}); err == nil {
+		t.Error("Expected a closure ending before it starts to be refused")
+	}
+}
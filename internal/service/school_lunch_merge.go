@@ -0,0 +1,208 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// MergeOptions controls how uploaded menus are merged into the stored ones
+type MergeOptions struct {
+	Policy  models.MergePolicy
+	DryRun  bool
+	Context models.ChangeContext
+	// DocumentDate is the effective date of the uploaded document
+	DocumentDate time.Time
+	// SourceDate returns the effective date of a previously processed document,
+	// used by MergePolicyNewerDocumentWins. Days whose source is unknown are
+	// dated by their last change instead.
+	SourceDate func(sourceID string) (time.Time, bool)
+}
+
+// MergeSchoolLunchMenus merges uploaded menus into the stored ones according to
+// opts.Policy and reports the outcome per day. With opts.DryRun nothing is stored.
+func (s *MenuAdvisorService) MergeSchoolLunchMenus(menus []models.SchoolLunchMenu, opts MergeOptions) *models.MergeReport {
+	if opts.Policy == "" {
+		opts.Policy = models.MergePolicyReplace
+	}
+	report := &models.MergeReport{
+		Policy:  opts.Policy,
+		DryRun:  opts.DryRun,
+		Days:    []models.DayMergeResult{},
+		Summary: map[models.DayMergeStatus]int{},
+	}
+
+	// A day listed more than once with different menus is not merged, as there
+	// is no telling which copy is right; identical copies are merged once
+	first := make(map[string]int)
+	repeated := make(map[string][]models.FieldChange)
+	for i := range menus {
+		key := dateKey(menus[i].Date)
+		if j, ok := first[key]; ok {
+			repeated[key] = append(repeated[key], DiffSchoolLunchMenus(&menus[j], &menus[i])...)
+		} else {
+			first[key] = i
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for n, menu := range menus {
+		day := models.DayMergeResult{Date: dateKey(menu.Date)}
+		if first[day.Date] != n {
+			continue
+		}
+		if conflicts := repeated[day.Date]; len(conflicts) > 0 {
+			day.Status = models.DayMergeConflict
+			day.Conflicts = conflicts
+			report.Add(day)
+			continue
+		}
+		if err := menu.Validate(); err != nil {
+			day.Status = models.DayMergeInvalid
+			day.Message = err.Error()
+			report.Add(day)
+			continue
+		}
+
+		i := s.indexOfLocked(menu.Date)
+		if i < 0 {
+			day.Status = models.DayMergeAdded
+			day.Changes = DiffSchoolLunchMenus(nil, &menu)
+			if !opts.DryRun {
+				s.putLocked(menu, opts.Context)
+			}
+			report.Add(day)
+			continue
+		}
+
+		existing := s.schoolLunches[i]
+		merged, status, conflicts := s.mergeDayLocked(&existing, &menu, opts)
+		day.Status = status
+		day.Conflicts = conflicts
+		day.Changes = DiffSchoolLunchMenus(&existing, merged)
+		if len(day.Changes) > 0 && !opts.DryRun {
+			s.putLocked(*merged, opts.Context)
+		}
+		report.Add(day)
+	}
+	return report
+}
+
+// mergeDayLocked decides the resulting menu for a day that already exists.
+// Caller must hold s.mu.
+func (s *MenuAdvisorService) mergeDayLocked(existing, incoming *models.SchoolLunchMenu, opts MergeOptions) (*models.SchoolLunchMenu, models.DayMergeStatus, []models.FieldChange) {
+	diff := DiffSchoolLunchMenus(existing, incoming)
+	if len(diff) == 0 {
+		return existing, models.DayMergeUnchanged, nil
+	}
+
+	switch opts.Policy {
+	case models.MergePolicyKeepExisting:
+		return existing, models.DayMergeConflict, diff
+	case models.MergePolicyMergeFields:
+		return mergeFields(existing, incoming, diff)
+	case models.MergePolicyNewerDocumentWins:
+		if opts.DocumentDate.After(s.lastChangeDateLocked(existing.Date, opts.SourceDate)) {
+			return incoming, models.DayMergeUpdated, nil
+		}
+		return existing, models.DayMergeConflict, diff
+	default:
+		return incoming, models.DayMergeUpdated, nil
+	}
+}
+
+// lastChangeDateLocked returns the effective date of the latest change to a
+// day: the date of its source document when known, otherwise the time of the
+// change itself. Caller must hold s.mu.
+func (s *MenuAdvisorService) lastChangeDateLocked(date time.Time, sourceDate func(string) (time.Time, bool)) time.Time {
+	versions := s.versions[dateKey(date)]
+	if len(versions) == 0 {
+		return time.Time{}
+	}
+	latest := versions[len(versions)-1]
+	if latest.SourceID != "" && sourceDate != nil {
+		if d, ok := sourceDate(latest.SourceID); ok {
+			return d
+		}
+	}
+	return latest.CreatedAt
+}
+
+// mergeFields fills fields that are empty in existing from incoming and
+// reports fields where both have different values as conflicts. A day with
+// both is partial: the filled fields are applied and the rest reported.
+func mergeFields(existing, incoming *models.SchoolLunchMenu, diff []models.FieldChange) (*models.SchoolLunchMenu, models.DayMergeStatus, []models.FieldChange) {
+	var conflicts []models.FieldChange
+	base := toJSONMap(existing)
+	src := toJSONMap(incoming)
+	filled := false
+	for _, change := range diff {
+		switch {
+		case change.New == nil:
+			// Incoming has nothing for this field; keep existing
+		case change.Old == nil:
+			setJSONPath(base, change.Field, getJSONPath(src, change.Field))
+			filled = true
+		default:
+			conflicts = append(conflicts, change)
+		}
+	}
+
+	merged := existing
+	if filled {
+		data, _ := json.Marshal(base)
+		var m models.SchoolLunchMenu
+		if err := json.Unmarshal(data, &m); err == nil {
+			merged = &m
+		}
+	}
+
+	switch {
+	case len(conflicts) > 0 && filled:
+		return merged, models.DayMergePartial, conflicts
+	case len(conflicts) > 0:
+		return merged, models.DayMergeConflict, conflicts
+	case filled:
+		return merged, models.DayMergeUpdated, nil
+	default:
+		return merged, models.DayMergeUnchanged, nil
+	}
+}
+
+func toJSONMap(menu *models.SchoolLunchMenu) map[string]interface{} {
+	data, _ := json.Marshal(menu)
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	return m
+}
+
+// getJSONPath reads a dotted path such as "nutrition.calories" from a decoded JSON object
+func getJSONPath(m map[string]interface{}, path string) interface{} {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		m = next
+	}
+	return m[parts[len(parts)-1]]
+}
+
+// setJSONPath writes a dotted path into a decoded JSON object, creating objects as needed
+func setJSONPath(m map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[p] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func newMergeTestService() *MenuAdvisorService {
+	service := NewMenuAdvisorService()
+	service.now = func() time.Time { return time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC) }
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{
+		Date:       time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC),
+		MainDish:   "鶏肉の照り焼き",
+		SideDishes: []string{"野菜炒め", "白米"},
+	})
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{
+		Date:     time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC),
+		MainDish: "魚のフライ",
+		Soup:     "野菜スープ",
+	})
+	return service
+}
+
+// overlapMenus is a change notice touching two existing days and adding one
+func overlapMenus() []models.SchoolLunchMenu {
+	return []models.SchoolLunchMenu{
+		{Date: time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC), MainDish: "鶏肉の照り焼き", SideDishes: []string{"野菜炒め", "白米"}, Soup: "味噌汁"},
+		{Date: time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC), MainDish: "カレーライス", Soup: "野菜スープ"},
+		{Date: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), MainDish: "ハンバーグ"},
+	}
+}
+
+func statuses(report *models.MergeReport) map[string]models.DayMergeStatus {
+	result := make(map[string]models.DayMergeStatus)
+	for _, day := range report.Days {
+		result[day.Date] = day.Status
+	}
+	return result
+}
+
+func TestMergeSchoolLunchMenusPolicies(t *testing.T) {
+	tests := []struct {
+		policy   models.MergePolicy
+		expected map[string]models.DayMergeStatus
+		main14   string
+		soup13   string
+	}{
+		{models.MergePolicyReplace, map[string]models.DayMergeStatus{
+			"2025-01-13": models.DayMergeUpdated, "2025-01-14": models.DayMergeUpdated, "2025-01-15": models.DayMergeAdded,
+		}, "カレーライス", "味噌汁"},
+		{models.MergePolicyKeepExisting, map[string]models.DayMergeStatus{
+			"2025-01-13": models.DayMergeConflict, "2025-01-14": models.DayMergeConflict, "2025-01-15": models.DayMergeAdded,
+		}, "魚のフライ", ""},
+		{models.MergePolicyMergeFields, map[string]models.DayMergeStatus{
+			"2025-01-13": models.DayMergeUpdated, "2025-01-14": models.DayMergeConflict, "2025-01-15": models.DayMergeAdded,
+		}, "魚のフライ", "味噌汁"},
+	}
+
+	for _, test := range tests {
+		service := newMergeTestService()
+		report := service.MergeSchoolLunchMenus(overlapMenus(), MergeOptions{Policy: test.policy})
+
+		got := statuses(report)
+		for date, status := range test.expected {
+			if got[date] != status {
+				t.Errorf("%s: expected %s to be %s, got %s", test.policy, date, status, got[date])
+			}
+		}
+		lunch14, _ := service.GetSchoolLunchForDate(time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC))
+		if lunch14.MainDish != test.main14 {
+			t.Errorf("%s: expected 14th main dish %s, got %s", test.policy, test.main14, lunch14.MainDish)
+		}
+		lunch13, _ := service.GetSchoolLunchForDate(time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC))
+		if lunch13.Soup != test.soup13 {
+			t.Errorf("%s: expected 13th soup %q, got %q", test.policy, test.soup13, lunch13.Soup)
+		}
+	}
+}
+
+func TestMergeFieldsReportsPartialDays(t *testing.T) {
+	service := newMergeTestService()
+	date := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	menus := []models.SchoolLunchMenu{{Date: date, MainDish: "カレーライス", Dessert: "みかん"}}
+
+	report := service.MergeSchoolLunchMenus(menus, MergeOptions{Policy: models.MergePolicyMergeFields})
+	day := report.Days[0]
+	if day.Status != models.DayMergePartial {
+		t.Fatalf("Expected a partial merge, got %+v", day)
+	}
+	if len(day.Changes) != 1 || day.Changes[0].Field != "dessert" {
+		t.Errorf("Expected the filled dessert as the change, got %+v", day.Changes)
+	}
+	if len(day.Conflicts) != 1 || day.Conflicts[0].Field != "main_dish" {
+		t.Errorf("Expected the differing main dish as the conflict, got %+v", day.Conflicts)
+	}
+	lunch, _ := service.GetSchoolLunchForDate(date)
+	if lunch.Dessert != "みかん" || lunch.MainDish != "魚のフライ" {
+		t.Errorf("Expected only the blank field to be filled, got %+v", lunch)
+	}
+	if days := committedDays(report); len(days) != 1 || days[0] != "2025-01-14" {
+		t.Errorf("Expected the partial day to count as committed, got %v", days)
+	}
+}
+
+func TestMergeSchoolLunchMenusDryRun(t *testing.T) {
+	service := newMergeTestService()
+	report := service.MergeSchoolLunchMenus(overlapMenus(), MergeOptions{DryRun: true})
+
+	if !report.DryRun || report.Summary[models.DayMergeUpdated] != 2 || report.Summary[models.DayMergeAdded] != 1 {
+		t.Errorf("Unexpected dry run report: %+v", report)
+	}
+	if len(service.GetAllSchoolLunches()) != 2 {
+		t.Error("Expected dry run not to add any menu")
+	}
+}
+
+func TestMergeSchoolLunchMenusNewerDocumentWins(t *testing.T) {
+	service := newMergeTestService()
+	date := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+
+	// A document printed before the last change loses
+	older := service.MergeSchoolLunchMenus(overlapMenus()[1:2], MergeOptions{
+		Policy:       models.MergePolicyNewerDocumentWins,
+		DocumentDate: time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC),
+	})
+	if older.Days[0].Status != models.DayMergeConflict {
+		t.Errorf("Expected older document to conflict, got %s", older.Days[0].Status)
+	}
+
+	// A change notice printed later wins
+	newer := service.MergeSchoolLunchMenus(overlapMenus()[1:2], MergeOptions{
+		Policy:       models.MergePolicyNewerDocumentWins,
+		DocumentDate: time.Date(2025, 1, 12, 0, 0, 0, 0, time.UTC),
+		Context:      models.ChangeContext{SourceID: "notice"},
+	})
+	if newer.Days[0].Status != models.DayMergeUpdated {
+		t.Errorf("Expected newer document to win, got %s", newer.Days[0].Status)
+	}
+
+	// The stored day is now dated by its source document, not by the change time
+	sourceDate := func(id string) (time.Time, bool) {
+		return time.Date(2025, 1, 12, 0, 0, 0, 0, time.UTC), id == "notice"
+	}
+	again := service.MergeSchoolLunchMenus([]models.SchoolLunchMenu{{Date: date, MainDish: "焼きそば"}}, MergeOptions{
+		Policy:       models.MergePolicyNewerDocumentWins,
+		DocumentDate: time.Date(2025, 1, 11, 0, 0, 0, 0, time.UTC),
+		SourceDate:   sourceDate,
+	})
+	if again.Days[0].Status != models.DayMergeConflict {
+		t.Errorf("Expected document older than the change notice to conflict, got %s", again.Days[0].Status)
+	}
+}
+
+func TestMergeSchoolLunchMenusRejectsInvalidAndRepeatedDays(t *testing.T) {
+	service := newMergeTestService()
+	menus := []models.SchoolLunchMenu{
+		{Date: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), MainDish: "ハンバーグ"},
+		{Date: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), MainDish: "カレーライス"},
+		{Date: time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC), MainDish: "うどん"},
+		{Date: time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC), MainDish: "うどん"},
+		{Date: time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC), MainDish: ""},
+	}
+
+	report := service.MergeSchoolLunchMenus(menus, MergeOptions{})
+	expected := map[string]models.DayMergeStatus{
+		"2025-01-15": models.DayMergeConflict,
+		"2025-01-16": models.DayMergeAdded,
+		"2025-01-17": models.DayMergeInvalid,
+	}
+	if got := statuses(report); len(report.Days) != len(expected) || len(got) != len(expected) {
+		t.Fatalf("Expected one result per day, got %+v", report.Days)
+	} else {
+		for day, status := range expected {
+			if got[day] != status {
+				t.Errorf("%s: expected %s, got %s", day, status, got[day])
+			}
+		}
+	}
+	if conflicts := report.Days[0].Conflicts; len(conflicts) != 1 || conflicts[0].Field != "main_dish" {
+		t.Errorf("Expected the differing main dish as the conflict, got %+v", conflicts)
+	}
+	if message := report.Days[2].Message; message == "" {
+		t.Error("Expected the invalid day to say why")
+	}
+
+	for _, day := range []int{15, 17} {
+		if _, err := service.GetSchoolLunchForDate(time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC)); err == nil {
+			t.Errorf("Expected nothing stored for 2025-01-%d", day)
+		}
+	}
+}
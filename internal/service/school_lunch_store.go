@@ -0,0 +1,276 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+var (
+	// ErrSchoolLunchExists is returned when creating a menu for a date that already has one
+	ErrSchoolLunchExists = errors.New("school lunch already exists")
+	// ErrPreconditionFailed is returned when an If-Match ETag does not match the stored menu
+	ErrPreconditionFailed = errors.New("school lunch was modified by someone else")
+	// ErrPreconditionRequired is returned when a change to a stored menu has no If-Match ETag
+	ErrPreconditionRequired = errors.New("If-Match is required to change a school lunch")
+)
+
+// defaultActor is recorded in the audit log when a change has no known actor
+const defaultActor = "system"
+
+// SchoolLunchETag returns a strong ETag identifying the current content of a menu
+func SchoolLunchETag(menu *models.SchoolLunchMenu) string {
+	data, _ := json.Marshal(menu)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// checkIfMatch verifies an If-Match header value against the stored menu. The
+// value is a comma-separated list of ETags or "*", which matches any menu.
+// Weak ETags are compared by their opaque tag, since proxies that compress
+// responses weaken the strong ETags this service sends.
+func checkIfMatch(ifMatch string, current *models.SchoolLunchMenu) error {
+	if strings.TrimSpace(ifMatch) == "" {
+		return ErrPreconditionRequired
+	}
+	etag := SchoolLunchETag(current)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: ETag %s does not match", ErrPreconditionFailed, ifMatch)
+}
+
+// CreateSchoolLunchMenu validates and stores a menu for a date that has none yet
+func (s *MenuAdvisorService) CreateSchoolLunchMenu(menu models.SchoolLunchMenu, ctx models.ChangeContext) (*models.SchoolLunchMenu, error) {
+	if err := menu.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexOfLocked(menu.Date) >= 0 {
+		return nil, fmt.Errorf("%w for date: %s", ErrSchoolLunchExists, dateKey(menu.Date))
+	}
+	s.putLocked(menu, ctx)
+	return &menu, nil
+}
+
+// UpdateSchoolLunchMenu replaces the menu stored for date. ifMatch must match
+// the current ETag of the stored menu.
+func (s *MenuAdvisorService) UpdateSchoolLunchMenu(date time.Time, menu models.SchoolLunchMenu, ifMatch string, ctx models.ChangeContext) (*models.SchoolLunchMenu, error) {
+	return s.PatchSchoolLunchMenu(date, func(current *models.SchoolLunchMenu) error {
+		*current = menu
+		return nil
+	}, ifMatch, ctx)
+}
+
+// PatchSchoolLunchMenu applies patch to a copy of the menu stored for date and
+// stores the result after validation. ifMatch is checked as in UpdateSchoolLunchMenu.
+func (s *MenuAdvisorService) PatchSchoolLunchMenu(date time.Time, patch func(*models.SchoolLunchMenu) error, ifMatch string, ctx models.ChangeContext) (*models.SchoolLunchMenu, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOfLocked(date)
+	if i < 0 {
+		return nil, fmt.Errorf("%w for date: %s", ErrSchoolLunchNotFound, dateKey(date))
+	}
+	current := s.schoolLunches[i]
+	if err := checkIfMatch(ifMatch, &current); err != nil {
+		return nil, err
+	}
+
+	updated := cloneSchoolLunchMenu(current)
+	if err := patch(&updated); err != nil {
+		return nil, err
+	}
+	if updated.Date.IsZero() {
+		updated.Date = current.Date
+	}
+	if dateKey(updated.Date) != dateKey(date) {
+		return nil, models.ValidationErrors{{Field: "date", Message: "must match the date in the URL"}}
+	}
+	if err := updated.Validate(); err != nil {
+		return nil, err
+	}
+	pruneDishIDs(&updated)
+
+	s.putLocked(updated, ctx)
+	return &updated, nil
+}
+
+// pruneDishIDs forgets the dish IDs of names no longer on the menu, such as
+// after a dish was corrected by hand
+func pruneDishIDs(menu *models.SchoolLunchMenu) {
+	if len(menu.DishIDs) == 0 {
+		return
+	}
+	names := append([]string{menu.MainDish, menu.Soup, menu.Dessert}, menu.SideDishes...)
+	ids := make(map[string]string)
+	for name, id := range menu.DishIDs {
+		if slices.Contains(names, name) {
+			ids[name] = id
+		}
+	}
+	menu.DishIDs = ids
+	if len(ids) == 0 {
+		menu.DishIDs = nil
+	}
+}
+
+// DeleteSchoolLunchMenu removes the menu stored for date. ifMatch is checked as
+// in UpdateSchoolLunchMenu.
+func (s *MenuAdvisorService) DeleteSchoolLunchMenu(date time.Time, ifMatch string, ctx models.ChangeContext) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOfLocked(date)
+	if i < 0 {
+		return fmt.Errorf("%w for date: %s", ErrSchoolLunchNotFound, dateKey(date))
+	}
+	current := s.schoolLunches[i]
+	if err := checkIfMatch(ifMatch, &current); err != nil {
+		return err
+	}
+
+	s.schoolLunches = append(s.schoolLunches[:i], s.schoolLunches[i+1:]...)
+	s.recordChangeLocked(ctx, models.AuditActionDelete, current.Date, &current, nil)
+	return nil
+}
+
+// GetAuditLog returns the recorded changes, optionally limited to one date
+func (s *MenuAdvisorService) GetAuditLog(date *time.Time) []models.AuditEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := []models.AuditEntry{}
+	for _, entry := range s.auditLog {
+		if date == nil || entry.Date == dateKey(*date) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// recordChangeLocked appends an audit entry and a new version for a change
+// from oldMenu to newMenu (either may be nil). Caller must hold s.mu.
+func (s *MenuAdvisorService) recordChangeLocked(ctx models.ChangeContext, action models.AuditAction, date time.Time, oldMenu, newMenu *models.SchoolLunchMenu) {
+	s.auditLog = append(s.auditLog, models.AuditEntry{
+		Timestamp:  s.now(),
+		Actor:      actorOrDefault(ctx),
+		ActorLabel: ctx.ActorLabel,
+		SourceID:   ctx.SourceID,
+		Action:     action,
+		Date:       dateKey(date),
+		Changes:    DiffSchoolLunchMenus(oldMenu, newMenu),
+	})
+	if newMenu == nil {
+		s.recordDeletionLocked(ctx, action, date)
+		return
+	}
+	s.recordVersionLocked(ctx, action, newMenu)
+}
+
+func actorOrDefault(ctx models.ChangeContext) string {
+	if ctx.Actor == "" {
+		return defaultActor
+	}
+	return ctx.Actor
+}
+
+// DiffSchoolLunchMenus lists the fields that differ between two menus. A nil
+// menu stands for "no record", so every non-empty field of the other is reported.
+func DiffSchoolLunchMenus(oldMenu, newMenu *models.SchoolLunchMenu) []models.FieldChange {
+	var changes []models.FieldChange
+	diffStruct("", reflect.ValueOf(oldMenu), reflect.ValueOf(newMenu), &changes)
+	return changes
+}
+
+// diffStruct walks the JSON-visible fields of two struct pointers (either may be nil)
+func diffStruct(prefix string, oldV, newV reflect.Value, changes *[]models.FieldChange) {
+	var t reflect.Type
+	switch {
+	case !oldV.IsNil():
+		t = oldV.Elem().Type()
+	case !newV.IsNil():
+		t = newV.Elem().Type()
+	default:
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonFieldName(field)
+		if name == "" || field.Tag.Get("diff") == "-" {
+			continue
+		}
+		var oldF, newF reflect.Value
+		if !oldV.IsNil() {
+			oldF = oldV.Elem().Field(i)
+		}
+		if !newV.IsNil() {
+			newF = newV.Elem().Field(i)
+		}
+
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+			diffStruct(prefix+name+".", addr(oldF), addr(newF), changes)
+			continue
+		}
+
+		oldI, newI := fieldValue(oldF), fieldValue(newF)
+		if reflect.DeepEqual(oldI, newI) {
+			continue
+		}
+		*changes = append(*changes, models.FieldChange{Field: prefix + name, Old: oldI, New: newI})
+	}
+}
+
+// addr returns a pointer to v, or a typed nil pointer when v is invalid
+func addr(v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return reflect.ValueOf((*struct{})(nil))
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p
+}
+
+// fieldValue returns the comparable value of a field, or nil for empty values
+func fieldValue(v reflect.Value) interface{} {
+	if !v.IsValid() || v.IsZero() {
+		return nil
+	}
+	if v.Kind() == reflect.Slice && v.Len() == 0 {
+		return nil
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return dateKey(t)
+	}
+	return v.Interface()
+}
+
+// jsonFieldName returns the JSON name of a struct field, or "" if it is not serialized
+func jsonFieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if tag == "-" {
+		return ""
+	}
+	if tag == "" {
+		return field.Name
+	}
+	return tag
+}
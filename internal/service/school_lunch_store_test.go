@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestCreateSchoolLunchMenu(t *testing.T) {
+	service := NewMenuAdvisorService()
+	date := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	menu := models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照り焼き"}
+
+	if _, err := service.CreateSchoolLunchMenu(menu, models.ChangeContext{Actor: "mom"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := service.CreateSchoolLunchMenu(menu, models.ChangeContext{}); !errors.Is(err, ErrSchoolLunchExists) {
+		t.Errorf("Expected ErrSchoolLunchExists, got: %v", err)
+	}
+
+	invalid := models.SchoolLunchMenu{Date: date.AddDate(0, 0, 1), Nutrition: models.Nutrition{Calories: -1}}
+	_, err := service.CreateSchoolLunchMenu(invalid, models.ChangeContext{})
+	var verrs models.ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("Expected validation errors, got: %v", err)
+	}
+	if len(verrs) != 2 {
+		t.Errorf("Expected 2 field errors (main_dish, calories), got: %v", verrs)
+	}
+
+	audit := service.GetAuditLog(&date)
+	if len(audit) != 1 || audit[0].Actor != "mom" || audit[0].Action != models.AuditActionCreate {
+		t.Errorf("Expected one create entry by 'mom', got: %+v", audit)
+	}
+}
+
+func TestUpdateSchoolLunchMenuIfMatch(t *testing.T) {
+	service := NewMenuAdvisorService()
+	date := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	created, _ := service.CreateSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照り焼き"}, models.ChangeContext{})
+	etag := SchoolLunchETag(created)
+
+	updated, err := service.UpdateSchoolLunchMenu(date, models.SchoolLunchMenu{MainDish: "鶏肉の照り焼き", Soup: "味噌汁"}, etag, models.ChangeContext{Actor: "dad"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !updated.Date.Equal(date) {
+		t.Errorf("Expected date to default to URL date, got %v", updated.Date)
+	}
+
+	// The old ETag is stale now
+	_, err = service.UpdateSchoolLunchMenu(date, models.SchoolLunchMenu{MainDish: "カレー"}, etag, models.ChangeContext{})
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Expected ErrPreconditionFailed, got: %v", err)
+	}
+
+	audit := service.GetAuditLog(&date)
+	last := audit[len(audit)-1]
+	if last.Actor != "dad" || len(last.Changes) != 1 || last.Changes[0].Field != "soup" {
+		t.Errorf("Expected audit of soup change by 'dad', got: %+v", last)
+	}
+}
+
+func TestDeleteSchoolLunchMenu(t *testing.T) {
+	service := NewMenuAdvisorService()
+	date := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照り焼き"})
+
+	if err := service.DeleteSchoolLunchMenu(date, `"stale"`, models.ChangeContext{}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Expected ErrPreconditionFailed, got: %v", err)
+	}
+	if err := service.DeleteSchoolLunchMenu(date, "", models.ChangeContext{}); !errors.Is(err, ErrPreconditionRequired) {
+		t.Errorf("Expected ErrPreconditionRequired, got: %v", err)
+	}
+	if err := service.DeleteSchoolLunchMenu(date, "*", models.ChangeContext{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := service.GetSchoolLunchForDate(date); !errors.Is(err, ErrSchoolLunchNotFound) {
+		t.Errorf("Expected menu to be deleted, got: %v", err)
+	}
+	if err := service.DeleteSchoolLunchMenu(date, "", models.ChangeContext{}); !errors.Is(err, ErrSchoolLunchNotFound) {
+		t.Errorf("Expected ErrSchoolLunchNotFound, got: %v", err)
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	menu := &models.SchoolLunchMenu{MainDish: "鶏肉の照り焼き"}
+	etag := SchoolLunchETag(menu)
+
+	for _, ifMatch := range []string{etag, "W/" + etag, "*", `"other", ` + etag} {
+		if err := checkIfMatch(ifMatch, menu); err != nil {
+			t.Errorf("Expected %s to match, got: %v", ifMatch, err)
+		}
+	}
+	for _, ifMatch := range []string{`"other"`, `W/"other"`, strings.Trim(etag, `"`)} {
+		if err := checkIfMatch(ifMatch, menu); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("Expected %s not to match, got: %v", ifMatch, err)
+		}
+	}
+	if err := checkIfMatch(" ", menu); !errors.Is(err, ErrPreconditionRequired) {
+		t.Errorf("Expected ErrPreconditionRequired, got: %v", err)
+	}
+}
+
+func TestDiffSchoolLunchMenus(t *testing.T) {
+	date := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	a := &models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照り焼き", SideDishes: []string{"白米"}, Nutrition: models.Nutrition{Calories: 650}}
+	b := &models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照り焼き", SideDishes: []string{"白米", "野菜炒め"}, Nutrition: models.Nutrition{Calories: 700}}
+
+	changes := DiffSchoolLunchMenus(a, b)
+	fields := map[string]bool{}
+	for _, c := range changes {
+		fields[c.Field] = true
+	}
+	if len(changes) != 2 || !fields["side_dishes"] || !fields["nutrition.calories"] {
+		t.Errorf("Expected side_dishes and nutrition.calories changes, got: %+v", changes)
+	}
+
+	if changes := DiffSchoolLunchMenus(nil, a); len(changes) != 4 {
+		t.Errorf("Expected 4 fields for a new record, got: %+v", changes)
+	}
+}
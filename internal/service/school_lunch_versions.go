@@ -0,0 +1,186 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// ErrVersionNotFound is returned when a requested menu version does not exist
+var ErrVersionNotFound = errors.New("school lunch version not found")
+
+// GetSchoolLunchVersions returns every recorded version of the menu for date,
+// oldest first. The menus are copies, so callers cannot change the history.
+func (s *MenuAdvisorService) GetSchoolLunchVersions(date time.Time) ([]models.SchoolLunchVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.versions[dateKey(date)]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w for date: %s", ErrSchoolLunchNotFound, dateKey(date))
+	}
+	result := make([]models.SchoolLunchVersion, len(versions))
+	for i, version := range versions {
+		if version.Menu != nil {
+			menu := cloneSchoolLunchMenu(*version.Menu)
+			version.Menu = &menu
+		}
+		result[i] = version
+	}
+	return result, nil
+}
+
+// DiffSchoolLunchVersions compares two versions of the menu for date. A
+// non-positive to means the latest version and a non-positive from means the
+// version before to.
+func (s *MenuAdvisorService) DiffSchoolLunchVersions(date time.Time, from, to int) (*models.SchoolLunchVersionDiff, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.versions[dateKey(date)]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w for date: %s", ErrSchoolLunchNotFound, dateKey(date))
+	}
+	if to <= 0 {
+		to = len(versions)
+	}
+	if from <= 0 {
+		from = to - 1
+	}
+
+	var fromMenu *models.SchoolLunchMenu
+	if from > 0 {
+		v, err := versionLocked(versions, date, from)
+		if err != nil {
+			return nil, err
+		}
+		fromMenu = v.Menu
+	}
+	toVersion, err := versionLocked(versions, date, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := DiffSchoolLunchMenus(fromMenu, toVersion.Menu)
+	if changes == nil {
+		changes = []models.FieldChange{}
+	}
+	return &models.SchoolLunchVersionDiff{
+		Date:    dateKey(date),
+		From:    from,
+		To:      to,
+		Changes: changes,
+	}, nil
+}
+
+// RollbackSchoolLunchMenu restores the menu for date to the content of an
+// earlier version. The rollback itself is recorded as a new version, so it can
+// be undone in turn. ifMatch is checked against the current menu, if the day
+// has one.
+func (s *MenuAdvisorService) RollbackSchoolLunchMenu(date time.Time, version int, ifMatch string, ctx models.ChangeContext) (*models.SchoolLunchMenu, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target, err := versionLocked(s.versions[dateKey(date)], date, version)
+	if err != nil {
+		return nil, err
+	}
+
+	i := s.indexOfLocked(date)
+	var current *models.SchoolLunchMenu
+	if i >= 0 {
+		c := s.schoolLunches[i]
+		current = &c
+		if err := checkIfMatch(ifMatch, current); err != nil {
+			return nil, err
+		}
+	}
+
+	// The menu gets its own copy of the version, so later edits cannot
+	// change the history
+	var restored *models.SchoolLunchMenu
+	if target.Menu != nil {
+		menu := cloneSchoolLunchMenu(*target.Menu)
+		restored = &menu
+	}
+
+	switch {
+	case restored == nil && current == nil:
+		return nil, nil
+	case restored == nil:
+		s.schoolLunches = append(s.schoolLunches[:i], s.schoolLunches[i+1:]...)
+	case current == nil:
+		s.schoolLunches = append(s.schoolLunches, *restored)
+	default:
+		s.schoolLunches[i] = *restored
+	}
+	s.recordChangeLocked(ctx, models.AuditActionRollback, date, current, restored)
+
+	if restored == nil {
+		return nil, nil
+	}
+	result := cloneSchoolLunchMenu(*restored)
+	return &result, nil
+}
+
+// cloneSchoolLunchMenu copies a menu without sharing its side dishes or dish IDs
+func cloneSchoolLunchMenu(menu models.SchoolLunchMenu) models.SchoolLunchMenu {
+	menu.SideDishes = append([]string(nil), menu.SideDishes...)
+	menu.DishIDs = maps.Clone(menu.DishIDs)
+	return menu
+}
+
+// sourceInEffect reports whether every one of days still has the menu the
+// document sourceID gave it, that is none has been edited, deleted, rolled
+// back or replaced by another document since. A document that changed no
+// day is never in effect, so uploading it again can still apply it.
+func (s *MenuAdvisorService) sourceInEffect(sourceID string, days []string) bool {
+	if len(days) == 0 {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, day := range days {
+		versions := s.versions[day]
+		if len(versions) == 0 || versions[len(versions)-1].SourceID != sourceID {
+			return false
+		}
+	}
+	return true
+}
+
+// versionLocked looks up a 1-based version number in a day's history
+func versionLocked(versions []models.SchoolLunchVersion, date time.Time, version int) (*models.SchoolLunchVersion, error) {
+	if version < 1 || version > len(versions) {
+		return nil, fmt.Errorf("%w: %s version %d", ErrVersionNotFound, dateKey(date), version)
+	}
+	return &versions[version-1], nil
+}
+
+// recordVersionLocked appends a snapshot of menu to its day's history. Caller must hold s.mu.
+func (s *MenuAdvisorService) recordVersionLocked(ctx models.ChangeContext, action models.AuditAction, menu *models.SchoolLunchMenu) {
+	snapshot := cloneSchoolLunchMenu(*menu)
+	s.appendVersionLocked(ctx, action, menu.Date, &snapshot)
+}
+
+// recordDeletionLocked appends an empty version marking the day as deleted. Caller must hold s.mu.
+func (s *MenuAdvisorService) recordDeletionLocked(ctx models.ChangeContext, action models.AuditAction, date time.Time) {
+	s.appendVersionLocked(ctx, action, date, nil)
+}
+
+func (s *MenuAdvisorService) appendVersionLocked(ctx models.ChangeContext, action models.AuditAction, date time.Time, menu *models.SchoolLunchMenu) {
+	key := dateKey(date)
+	s.versions[key] = append(s.versions[key], models.SchoolLunchVersion{
+		Version:    len(s.versions[key]) + 1,
+		Date:       key,
+		Action:     action,
+		Actor:      actorOrDefault(ctx),
+		ActorLabel: ctx.ActorLabel,
+		SourceID:   ctx.SourceID,
+		CreatedAt:  s.now(),
+		Menu:       menu,
+	})
+}
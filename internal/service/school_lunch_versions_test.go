@@ -0,0 +1,127 @@
+package service
+
+import (
+	"errors"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestSchoolLunchVersionsFromUploads(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	date := time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)
+
+	upload := func(json string) *models.DocumentSource {
+		doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+			File:   newMockFile(json),
+			Header: &multipart.FileHeader{Filename: "menu.json"},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return doc
+	}
+
+	first := upload(`[{"date": "2025-01-20T00:00:00Z", "main_dish": "ハンバーグ", "soup": "コンソメスープ"}]`)
+	second := upload(`[{"date": "2025-01-20T00:00:00Z", "main_dish": "煮込みハンバーグ", "soup": "コンソメスープ"}]`)
+
+	versions, err := menuService.GetSchoolLunchVersions(date)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(versions))
+	}
+	if versions[0].SourceID != first.ID || versions[1].SourceID != second.ID {
+		t.Errorf("Expected versions to reference their source documents, got %s and %s", versions[0].SourceID, versions[1].SourceID)
+	}
+
+	diff, err := menuService.DiffSchoolLunchVersions(date, 0, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff.From != 1 || diff.To != 2 || len(diff.Changes) != 1 || diff.Changes[0].Field != "main_dish" {
+		t.Errorf("Expected main_dish change between 1 and 2, got %+v", diff)
+	}
+}
+
+func TestRollbackSchoolLunchMenu(t *testing.T) {
+	service := NewMenuAdvisorService()
+	date := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照り焼き", SideDishes: []string{"ごぼうサラダ"}})
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照焼", SideDishes: []string{"ごぼうサラダ"}})
+
+	current, _ := service.GetSchoolLunchForDate(date)
+	if _, err := service.RollbackSchoolLunchMenu(date, 1, "", models.ChangeContext{}); !errors.Is(err, ErrPreconditionRequired) {
+		t.Errorf("Expected ErrPreconditionRequired, got %v", err)
+	}
+	restored, err := service.RollbackSchoolLunchMenu(date, 1, SchoolLunchETag(current), models.ChangeContext{Actor: "mom"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if restored.MainDish != "鶏肉の照り焼き" {
+		t.Errorf("Expected version 1 to be restored, got %s", restored.MainDish)
+	}
+
+	versions, _ := service.GetSchoolLunchVersions(date)
+	if len(versions) != 3 || versions[2].Action != models.AuditActionRollback {
+		t.Errorf("Expected rollback recorded as version 3, got %+v", versions)
+	}
+
+	// Changing the restored menu or a listed version in place leaves the
+	// history alone
+	restored.SideDishes[0] = "ひじきの煮物"
+	service.schoolLunches[service.indexOfLocked(date)].SideDishes[0] = "ひじきの煮物"
+	versions[0].Menu.SideDishes[0] = "ひじきの煮物"
+	versions[1].Menu.MainDish = "ひじきの煮物"
+	versions, _ = service.GetSchoolLunchVersions(date)
+	for _, v := range versions {
+		if v.Menu.SideDishes[0] != "ごぼうサラダ" {
+			t.Errorf("Expected version %d to keep its side dish, got %v", v.Version, v.Menu.SideDishes)
+		}
+	}
+	if versions[1].Menu.MainDish != "鶏肉の照焼" {
+		t.Errorf("Expected version 2 to keep its main dish, got %s", versions[1].Menu.MainDish)
+	}
+
+	if _, err := service.RollbackSchoolLunchMenu(date, 9, "*", models.ChangeContext{}); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Expected ErrVersionNotFound, got %v", err)
+	}
+
+	// Rolling back past a deletion brings the day back
+	if err := service.DeleteSchoolLunchMenu(date, "*", models.ChangeContext{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// No menu is left to overwrite, so no ETag is needed
+	if _, err := service.RollbackSchoolLunchMenu(date, 2, "", models.ChangeContext{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lunch, err := service.GetSchoolLunchForDate(date)
+	if err != nil || lunch.MainDish != "鶏肉の照焼" {
+		t.Errorf("Expected version 2 to be restored after deletion, got %v, %v", lunch, err)
+	}
+}
+
+func TestLoadSchoolLunchDataResetsVersions(t *testing.T) {
+	service := NewMenuAdvisorService()
+	date := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照り焼き"})
+	service.AddSchoolLunchMenu(models.SchoolLunchMenu{Date: date, MainDish: "鶏肉の照焼"})
+
+	path := filepath.Join(t.TempDir(), "lunches.json")
+	if err := os.WriteFile(path, []byte(`[{"date": "2025-01-13T00:00:00Z", "main_dish": "カレーライス"}]`), 0o644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := service.LoadSchoolLunchData(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	versions, err := service.GetSchoolLunchVersions(date)
+	if err != nil || len(versions) != 1 || versions[0].Menu.MainDish != "カレーライス" {
+		t.Errorf("Expected only the loaded version, got %+v, %v", versions, err)
+	}
+}
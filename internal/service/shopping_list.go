@@ -0,0 +1,255 @@
+package service
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// ingredientInfo is what a shopping list needs to know about an ingredient
+type ingredientInfo struct {
+	section models.StoreSection
+	// unit is the unit the ingredient is bought in; empty keeps the recipe's
+	unit string
+	// grams is the weight of one piece or pack, by counted unit
+	grams map[string]float64
+}
+
+// unitSizes are the units every ingredient converts between, as grams or
+// milliliters
+var unitSizes = map[string]struct {
+	base string
+	size float64
+}{
+	"g": {"g", 1}, "kg": {"g", 1000},
+	"ml": {"ml", 1}, "L": {"ml", 1000}, "カップ": {"ml", 200}, "大さじ": {"ml", 15}, "小さじ": {"ml", 5},
+}
+
+// measure returns the base unit and size of one unit of the ingredient
+func (info ingredientInfo) measure(unit string) (string, float64, bool) {
+	if size, ok := unitSizes[unit]; ok {
+		return size.base, size.size, true
+	}
+	if grams, ok := info.grams[unit]; ok {
+		return "g", grams, true
+	}
+	return "", 0, false
+}
+
+// convert returns quantity q of the ingredient in unit from as a quantity in
+// unit to, if one can be converted into the other
+func (info ingredientInfo) convert(q float64, from, to string) (float64, bool) {
+	if from == to {
+		return q, true
+	}
+	baseFrom, sizeFrom, ok := info.measure(from)
+	if !ok {
+		return 0, false
+	}
+	baseTo, sizeTo, ok := info.measure(to)
+	if !ok || baseFrom != baseTo {
+		return 0, false
+	}
+	return q * sizeFrom / sizeTo, true
+}
+
+// ingredientCatalog gives the store section, purchase unit and piece weights
+// of the ingredients of the built-in recipes. Other ingredients are listed
+// under SectionOther in the units their recipes use.
+var ingredientCatalog = map[string]ingredientInfo{
+	// Produce
+	"長ねぎ":    {models.SectionProduce, "本", map[string]float64{"本": 100}},
+	"大根":     {models.SectionProduce, "本", map[string]float64{"本": 1000}},
+	"レモン":    {models.SectionProduce, "個", map[string]float64{"個": 100}},
+	"しょうが":   {models.SectionProduce, "片", map[string]float64{"片": 15}},
+	"レタス":    {models.SectionProduce, "個", map[string]float64{"個": 300}},
+	"トマト":    {models.SectionProduce, "個", map[string]float64{"個": 150}},
+	"ミニトマト":  {models.SectionProduce, "パック", map[string]float64{"個": 15, "パック": 200}},
+	"ピーマン":   {models.SectionProduce, "個", map[string]float64{"個": 35}},
+	"玉ねぎ":    {models.SectionProduce, "個", map[string]float64{"個": 200}},
+	"ほうれん草":  {models.SectionProduce, "束", map[string]float64{"束": 200}},
+	"小松菜":    {models.SectionProduce, "束", map[string]float64{"束": 200}},
+	"三つ葉":    {models.SectionProduce, "袋", map[string]float64{"袋": 30}},
+	"にら":     {models.SectionProduce, "束", map[string]float64{"束": 100}},
+	"ごぼう":    {models.SectionProduce, "本", map[string]float64{"本": 150}},
+	"にんじん":   {models.SectionProduce, "本", map[string]float64{"本": 150}},
+	"れんこん":   {models.SectionProduce, "g", nil},
+	"キャベツ":   {models.SectionProduce, "個", map[string]float64{"個": 1000}},
+	"もやし":    {models.SectionProduce, "袋", map[string]float64{"袋": 200}},
+	"さつまいも":  {models.SectionProduce, "本", map[string]float64{"本": 250}},
+	"なす":     {models.SectionProduce, "本", map[string]float64{"本": 80}},
+	"かぼちゃ":   {models.SectionProduce, "g", nil},
+	"ブロッコリー": {models.SectionProduce, "株", map[string]float64{"株": 250}},
+	"じゃがいも":  {models.SectionProduce, "個", map[string]float64{"個": 150}},
+	"きゅうり":   {models.SectionProduce, "本", map[string]float64{"本": 100}},
+
+	// Meat and fish
+	"鶏もも肉":     {models.SectionMeat, "g", map[string]float64{"枚": 250}},
+	"豚ロース薄切り肉": {models.SectionMeat, "g", nil},
+	"豚こま切れ肉":   {models.SectionMeat, "g", nil},
+	"牛こま切れ肉":   {models.SectionMeat, "g", nil},
+	"あじ":       {models.SectionFish, "尾", nil},
+	"甘塩鮭":      {models.SectionFish, "切れ", nil},
+	"さば":       {models.SectionFish, "切れ", nil},
+	"かれい":      {models.SectionFish, "切れ", nil},
+	"しらす干し":    {models.SectionFish, "g", nil},
+
+	// Eggs, tofu and other chilled foods
+	"卵":     {models.SectionChilled, "個", map[string]float64{"個": 60, "パック": 600}},
+	"納豆":    {models.SectionChilled, "パック", map[string]float64{"パック": 45}},
+	"豆腐":    {models.SectionChilled, "丁", map[string]float64{"丁": 300}},
+	"絹ごし豆腐": {models.SectionChilled, "丁", map[string]float64{"丁": 300}},
+	"こんにゃく": {models.SectionChilled, "枚", map[string]float64{"枚": 250}},
+
+	// Dry goods
+	"かつお節":   {models.SectionDryGoods, "g", nil},
+	"すりごま":   {models.SectionDryGoods, "大さじ", nil},
+	"白ごま":    {models.SectionDryGoods, "", nil},
+	"干ししいたけ": {models.SectionDryGoods, "枚", nil},
+	"乾燥わかめ":  {models.SectionDryGoods, "g", nil},
+	"ホールコーン": {models.SectionDryGoods, "g", nil},
+
+	// Grains
+	"米": {models.SectionGrains, "合", map[string]float64{"合": 150}},
+
+	// Seasonings
+	"砂糖":       {models.SectionSeasoning, "大さじ", nil},
+	"塩":        {models.SectionSeasoning, "", nil},
+	"塩こしょう":    {models.SectionSeasoning, "", nil},
+	"しょうゆ":     {models.SectionSeasoning, "大さじ", nil},
+	"薄口しょうゆ":   {models.SectionSeasoning, "大さじ", nil},
+	"みりん":      {models.SectionSeasoning, "大さじ", nil},
+	"酒":        {models.SectionSeasoning, "大さじ", nil},
+	"みそ":       {models.SectionSeasoning, "大さじ", nil},
+	"だし汁":      {models.SectionSeasoning, "ml", nil},
+	"サラダ油":     {models.SectionSeasoning, "大さじ", nil},
+	"ごま油":      {models.SectionSeasoning, "大さじ", nil},
+	"揚げ油":      {models.SectionSeasoning, "", nil},
+	"片栗粉":      {models.SectionSeasoning, "大さじ", nil},
+	"天ぷら粉":     {models.SectionSeasoning, "大さじ", nil},
+	"鶏がらスープの素": {models.SectionSeasoning, "小さじ", nil},
+	"ポン酢":      {models.SectionSeasoning, "大さじ", nil},
+	"焼肉のたれ":    {models.SectionSeasoning, "大さじ", nil},
+	"天つゆ":      {models.SectionSeasoning, "大さじ", nil},
+	"ドレッシング":   {models.SectionSeasoning, "大さじ", nil},
+	"ごまドレッシング": {models.SectionSeasoning, "大さじ", nil},
+	"マヨネーズ":    {models.SectionSeasoning, "大さじ", nil},
+}
+
+// ingredient returns what is known about an ingredient
+func ingredient(name string) ingredientInfo {
+	if info, ok := ingredientCatalog[name]; ok {
+		return info
+	}
+	return ingredientInfo{section: models.SectionOther}
+}
+
+// roundPurchase rounds a quantity up to what can be bought: whole pieces
+// and packs, half spoons, and whole grams and milliliters
+func roundPurchase(q float64, unit string) float64 {
+	if q <= 0 {
+		return 0
+	}
+	if unit == "大さじ" || unit == "小さじ" {
+		return math.Ceil(q*2-1e-9) / 2
+	}
+	return math.Ceil(q - 1e-9)
+}
+
+// ShoppingList adds up the ingredients of the home meals planned from from
+// to to inclusive, cooked for the household. Quantities of an ingredient are
+// merged into the unit it is bought in, and what the pantry holds is taken
+// off. Seasonings the pantry has any of are not bought.
+func (s *MenuAdvisorService) ShoppingList(from, to time.Time) (*models.ShoppingList, error) {
+	if to.Before(from) {
+		return nil, models.ValidationErrors{{Field: "to", Message: "must not be before from"}}
+	}
+	servings := s.householdServings()
+	list := &models.ShoppingList{From: dateKey(from), To: dateKey(to), Servings: servings, Sections: []models.ShoppingSection{}}
+
+	var items []*models.ShoppingItem
+	add := func(name string, q float64, unit, dish string) {
+		info := ingredient(name)
+		if converted, ok := info.convert(q, unit, info.unit); ok && q > 0 && info.unit != "" {
+			q, unit = converted, info.unit
+		}
+		var item *models.ShoppingItem
+		for _, existing := range items {
+			if existing.Name != name {
+				continue
+			}
+			// An amount "to taste" is covered by any other amount
+			if q == 0 || existing.Needed == 0 {
+				if q > 0 {
+					existing.Unit = unit
+				}
+				item = existing
+				break
+			}
+			if converted, ok := info.convert(q, unit, existing.Unit); ok {
+				item, q = existing, converted
+				break
+			}
+		}
+		if item == nil {
+			item = &models.ShoppingItem{Name: name, Unit: unit}
+			items = append(items, item)
+		}
+		item.Needed += q
+		if !slices.Contains(item.Dishes, dish) {
+			item.Dishes = append(item.Dishes, dish)
+		}
+	}
+
+	for _, meal := range s.HomeMeals(from, to) {
+		list.Meals++
+		for _, dish := range meal.Dishes() {
+			recipe, ok := s.recipes.Find(dish)
+			if !ok {
+				if !slices.Contains(list.NoRecipe, dish) {
+					list.NoRecipe = append(list.NoRecipe, dish)
+				}
+				continue
+			}
+			factor := float64(servings) / float64(max(recipe.Servings, 1))
+			for _, ingredient := range recipe.Ingredients {
+				add(ingredient.Name, ingredient.Quantity*factor, ingredient.Unit, dish)
+			}
+		}
+	}
+
+	pantry := s.Pantry()
+	bySection := make(map[models.StoreSection][]models.ShoppingItem)
+	for _, item := range items {
+		info := ingredient(item.Name)
+		stocked := false
+		for _, p := range pantry {
+			// Items that have run out or expired by the first day do not count
+			if days, ok := p.DaysLeft(from); p.Name != item.Name || p.Quantity <= 0 || (ok && days < 0) {
+				continue
+			}
+			// Any amount of a seasoning lasts more than a week of meals
+			if item.Needed == 0 || info.section == models.SectionSeasoning {
+				stocked = true
+			} else if q, ok := info.convert(p.Quantity, p.Unit, item.Unit); ok {
+				item.InPantry += q
+			}
+		}
+		item.Quantity = roundPurchase(max(item.Needed-item.InPantry, 0), item.Unit)
+		item.Needed = roundPurchase(item.Needed, item.Unit)
+		item.InPantry = math.Round(item.InPantry*10) / 10
+		if stocked || (item.Quantity == 0 && item.Needed > 0) {
+			list.FromPantry = append(list.FromPantry, item.Name)
+			continue
+		}
+		bySection[info.section] = append(bySection[info.section], *item)
+	}
+	for _, section := range models.StoreSections {
+		if len(bySection[section]) > 0 {
+			list.Sections = append(list.Sections, models.ShoppingSection{Section: section, Label: section.Label(), Items: bySection[section]})
+		}
+	}
+	return list, nil
+}
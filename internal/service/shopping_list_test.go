@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// plannedWeek accepts a breakfast and a dinner for a household of four
+func plannedWeek(t *testing.T) (*MenuAdvisorService, time.Time) {
+	t.Helper()
+	service := NewMenuAdvisorService()
+	if err := service.SetHousehold(models.Household{Size: 4}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	monday := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	meals := []models.HomeMeal{
+		{Date: monday, MealType: models.MealTypeBreakfast, MainDish: "卵焼き", SideDishes: []string{"納豆", "のり"}, Soup: "みそ汁"},
+		{Date: monday.AddDate(0, 0, 1), MealType: models.MealTypeDinner, MainDish: "豚しゃぶしゃぶ", SideDishes: []string{"グリーンサラダ"}, Soup: "中華スープ"},
+	}
+	for _, meal := range meals {
+		if _, _, err := service.AcceptHomeMeal(meal); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	return service, monday
+}
+
+// shoppingItems indexes the items of a shopping list by name
+func shoppingItems(list *models.ShoppingList) map[string]models.ShoppingItem {
+	items := make(map[string]models.ShoppingItem)
+	for _, section := range list.Sections {
+		for _, item := range section.Items {
+			items[item.Name] = item
+		}
+	}
+	return items
+}
+
+func TestShoppingList(t *testing.T) {
+	service, monday := plannedWeek(t)
+
+	list, err := service.ShoppingList(monday, monday.AddDate(0, 0, 6))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if list.Meals != 2 || list.Servings != 4 {
+		t.Errorf("Expected 2 meals for 4, got %d for %d", list.Meals, list.Servings)
+	}
+	items := shoppingItems(list)
+
+	// 20g for the natto and 40g for the soup make 0.6 of a leek, bought whole
+	if leek := items["長ねぎ"]; leek.Quantity != 1 || leek.Unit != "本" || !slices.Equal(leek.Dishes, []string{"納豆", "中華スープ"}) {
+		t.Errorf("Unexpected leeks: %+v", leek)
+	}
+	// Eight cherry tomatoes are bought as a pack
+	if tomatoes := items["ミニトマト"]; tomatoes.Quantity != 1 || tomatoes.Unit != "パック" {
+		t.Errorf("Unexpected cherry tomatoes: %+v", tomatoes)
+	}
+	// Six eggs for the omelette and two for the soup
+	if eggs := items["卵"]; eggs.Quantity != 8 || eggs.Amount() != "8個" {
+		t.Errorf("Unexpected eggs: %+v", eggs)
+	}
+	if miso := items["みそ"]; miso.Amount() != "大さじ2" {
+		t.Errorf("Unexpected miso: %+v", miso)
+	}
+	// Oil "to taste" has no quantity
+	if oil := items["サラダ油"]; oil.Quantity != 0 || oil.String() != "サラダ油 少々" {
+		t.Errorf("Unexpected oil: %+v", oil)
+	}
+	if !slices.Equal(list.NoRecipe, []string{"のり"}) {
+		t.Errorf("Expected のり without a recipe, got %v", list.NoRecipe)
+	}
+
+	var sections []models.StoreSection
+	for _, section := range list.Sections {
+		sections = append(sections, section.Section)
+	}
+	if !slices.IsSortedFunc(sections, func(a, b models.StoreSection) int {
+		return slices.Index(models.StoreSections, a) - slices.Index(models.StoreSections, b)
+	}) || sections[0] != models.SectionProduce {
+		t.Errorf("Sections out of store order: %v", sections)
+	}
+
+	// Only the meals in the range are counted
+	list, _ = service.ShoppingList(monday, monday)
+	if list.Meals != 1 || shoppingItems(list)["豚ロース薄切り肉"].Quantity != 0 {
+		t.Errorf("Expected only Monday's breakfast, got %+v", list)
+	}
+
+	var verrs models.ValidationErrors
+	if _, err := service.ShoppingList(monday, monday.AddDate(0, 0, -1)); !errors.As(err, &verrs) {
+		t.Errorf("Expected a validation error for to before from, got %v", err)
+	}
+}
+
+func TestShoppingListPantry(t *testing.T) {
+	service, monday := plannedWeek(t)
+	pantry := []models.PantryItem{
+		{Name: "卵", Quantity: 10, Unit: "個"},
+		{Name: "豚ロース薄切り肉", Quantity: 150, Unit: "g"},
+		{Name: "しょうゆ", Quantity: 1, Unit: "本"},
+		{Name: "サラダ油", Quantity: 1, Unit: "本"},
+	}
+	if err := service.SetPantry(pantry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	list, err := service.ShoppingList(monday, monday.AddDate(0, 0, 6))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	items := shoppingItems(list)
+	if pork := items["豚ロース薄切り肉"]; pork.Quantity != 250 || pork.Needed != 400 || pork.InPantry != 150 {
+		t.Errorf("Expected 250g of pork left to buy, got %+v", pork)
+	}
+	for _, name := range []string{"卵", "しょうゆ", "サラダ油"} {
+		if _, ok := items[name]; ok || !slices.Contains(list.FromPantry, name) {
+			t.Errorf("Expected %s from the pantry, got %v", name, list.FromPantry)
+		}
+	}
+
+	// An empty bottle has to be bought again, even of a seasoning
+	pantry[2].Quantity, pantry[3].Quantity = 0, 0
+	if err := service.SetPantry(pantry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	list, _ = service.ShoppingList(monday, monday.AddDate(0, 0, 6))
+	items = shoppingItems(list)
+	for _, name := range []string{"しょうゆ", "サラダ油"} {
+		if _, ok := items[name]; !ok || slices.Contains(list.FromPantry, name) {
+			t.Errorf("Expected %s to be bought, got %v", name, list.FromPantry)
+		}
+	}
+
+	var verrs models.ValidationErrors
+	if err := service.SetPantry([]models.PantryItem{{Name: "卵", Quantity: -1}, {Name: "卵", Quantity: 2}}); !errors.As(err, &verrs) {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+}
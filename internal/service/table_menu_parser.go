@@ -0,0 +1,222 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// maxHeaderSearchRows bounds how far down a sheet the header row is looked for
+const maxHeaderSearchRows = 20
+
+var (
+	// "2025-01-13", "2025/1/13" or "2025.1.13"
+	fullDatePattern = regexp.MustCompile(`^(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})`)
+	// "1/13"
+	monthDayPattern = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})$`)
+	// Excel counts days from 1899-12-30 (working around the 1900 leap year bug)
+	excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// defaultColumnMapping maps the column headers commonly used by 給食センター
+// spreadsheets. It reuses the labels of printed menus, and also accepts the
+// field names themselves as headers.
+func defaultColumnMapping() models.ColumnMapping {
+	mapping := models.ColumnMapping{
+		"日付":  "date",
+		"月日":  "date",
+		"日にち": "date",
+	}
+	for label, field := range textLabels {
+		mapping[label] = field
+	}
+	for _, field := range models.MenuColumnFields {
+		mapping[field] = field
+	}
+	return mapping
+}
+
+// tableParse is the result of parsing the rows of a CSV or Excel document
+type tableParse struct {
+	HeaderRow int
+	Columns   []models.TableColumn
+	Menus     []parsedMenu
+	Skipped   []models.SkippedRow
+}
+
+// parseTableMenuRows finds the header row, maps its columns onto menu fields
+// and turns each following row into a menu. Columns in mapping take
+// precedence over the default headers. Rows above the header may carry a
+// title such as "2025年1月 献立表", which sets the month for dates without one.
+func parseTableMenuRows(rows [][]string, mapping models.ColumnMapping, ctx textParseContext) (*tableParse, error) {
+	result := &tableParse{}
+	header := -1
+	var fields []string
+	for i := 0; i < len(rows) && i < maxHeaderSearchRows; i++ {
+		if f, ok := mapHeaderRow(rows[i], mapping); ok {
+			header, fields = i, f
+			break
+		}
+		for _, cell := range rows[i] {
+			if m := monthHeaderPattern.FindStringSubmatch(strings.TrimSpace(cell)); m != nil {
+				ctx.Year, _ = strconv.Atoi(m[1])
+				month, _ := strconv.Atoi(m[2])
+				ctx.Month = time.Month(month)
+			}
+		}
+	}
+	if header < 0 {
+		return nil, fmt.Errorf("no header row with a date column and a menu column found")
+	}
+
+	result.HeaderRow = header + 1
+	for i, cell := range rows[header] {
+		result.Columns = append(result.Columns, models.TableColumn{Index: i, Header: strings.TrimSpace(cell), Field: fields[i]})
+	}
+
+	for i := header + 1; i < len(rows); i++ {
+		row := rows[i]
+		if isBlankRow(row) {
+			continue
+		}
+		p, err := parseTableRow(row, fields, ctx)
+		if err != nil {
+			result.Skipped = append(result.Skipped, models.SkippedRow{Row: i + 1, Reason: err.Error()})
+			continue
+		}
+		result.Menus = append(result.Menus, *p)
+	}
+
+	if len(result.Menus) == 0 {
+		return nil, fmt.Errorf("no menu rows found below the header on row %d", result.HeaderRow)
+	}
+	return result, nil
+}
+
+// mapHeaderRow assigns a field to each cell of a candidate header row. ok is
+// true when the row has a date column and at least one other mapped column.
+func mapHeaderRow(row []string, mapping models.ColumnMapping) ([]string, bool) {
+	defaults := defaultColumnMapping()
+	fields := make([]string, len(row))
+	hasDate, hasOther := false, false
+	for i, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		field, ok := matchColumn(cell, mapping)
+		if !ok {
+			field, ok = matchColumn(cell, defaults)
+		}
+		if !ok {
+			continue
+		}
+		if field == "date" {
+			if hasDate {
+				// Only the first date column is used
+				continue
+			}
+			hasDate = true
+		} else {
+			hasOther = true
+		}
+		fields[i] = field
+	}
+	return fields, hasDate && hasOther
+}
+
+// matchColumn returns the field of the longest mapping key the header starts
+// with, so "食塩相当量" is not taken for a shorter key that happens to match
+func matchColumn(header string, mapping models.ColumnMapping) (string, bool) {
+	best := ""
+	for key := range mapping {
+		if strings.HasPrefix(header, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	return mapping[best], true
+}
+
+// parseTableRow builds a menu from one row. Rows whose date cannot be read,
+// or that hold no dishes (holidays), are rejected with the reason.
+func parseTableRow(row []string, fields []string, ctx textParseContext) (*parsedMenu, error) {
+	p := &parsedMenu{Fields: map[string]models.FieldExtraction{}}
+	line := models.TextLine{Confidence: 1.0}
+	dated := false
+	for i, field := range fields {
+		if field == "" || i >= len(row) {
+			continue
+		}
+		value := strings.TrimSpace(row[i])
+		if value == "" {
+			continue
+		}
+		if field == "date" {
+			date, err := parseTableDate(value, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %q: %v", value, err)
+			}
+			p.Menu.Date = date
+			p.record("date", value, line)
+			dated = true
+			continue
+		}
+		if field == "main_dish" && strings.Contains(value, "\n") {
+			// A cell listing several dishes one per line: the first is the main dish
+			dishes := strings.SplitN(value, "\n", 2)
+			p.applyLabel("main_dish", strings.TrimSpace(dishes[0]), line)
+			p.applyLabel("side_dishes", dishes[1], line)
+			continue
+		}
+		p.applyLabel(field, value, line)
+	}
+
+	if !dated {
+		return nil, fmt.Errorf("no date")
+	}
+	if len(p.Fields) == 1 {
+		return nil, fmt.Errorf("no menu items")
+	}
+	return p, nil
+}
+
+// parseTableDate reads the date cell formats seen in menu spreadsheets: ISO
+// and slash dates, "1月13日(月)", a bare day of the month, and Excel serial
+// numbers
+func parseTableDate(value string, ctx textParseContext) (time.Time, error) {
+	if m := fullDatePattern.FindStringSubmatch(value); m != nil {
+		return resolveMenuDate(m[1], m[2], m[3], ctx)
+	}
+	if m := monthDayPattern.FindStringSubmatch(value); m != nil {
+		return resolveMenuDate("", m[1], m[2], ctx)
+	}
+	if m := dayLinePattern.FindStringSubmatch(value); m != nil {
+		return resolveMenuDate(m[1], m[2], m[3], ctx)
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		if n >= 1 && n <= 31 && n == float64(int(n)) {
+			return resolveMenuDate("", "", strconv.Itoa(int(n)), ctx)
+		}
+		if n > 31 && n < 2958466 {
+			// Serial numbers may carry a time of day as a fraction
+			return excelEpoch.AddDate(0, 0, int(n)), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date format")
+}
+
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"bytes"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func TestParseTableMenuRowsCSV(t *testing.T) {
+	rows, err := readCSVRows(readTableFixture(t, "menu.csv"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	table, err := parseTableMenuRows(rows, nil, textParseContext{Year: 2024, Month: time.December})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if table.HeaderRow != 2 {
+		t.Errorf("Expected header on row 2, got %d", table.HeaderRow)
+	}
+
+	fields := map[string]string{}
+	for _, c := range table.Columns {
+		fields[c.Header] = c.Field
+	}
+	expectedFields := map[string]string{
+		"日付": "date", "曜日": "", "主食": "side_dishes", "おかず": "main_dish",
+		"汁物": "soup", "エネルギー(kcal)": "nutrition.calories",
+	}
+	for header, field := range expectedFields {
+		if fields[header] != field {
+			t.Errorf("Column %s: expected %q, got %q", header, field, fields[header])
+		}
+	}
+
+	// The title row sets January 2025 for "15日" and "1/17"
+	if len(table.Menus) != 3 {
+		t.Fatalf("Expected 3 menus, got %d", len(table.Menus))
+	}
+	first := table.Menus[0].Menu
+	if dateKey(first.Date) != "2025-01-14" || first.MainDish != "鶏肉の照り焼き" || first.Soup != "みそ汁" || first.Nutrition.Calories != 650 {
+		t.Errorf("Unexpected first menu: %+v", first)
+	}
+	if strings.Join(first.SideDishes, ",") != "ごはん,牛乳,ごまあえ" {
+		t.Errorf("Expected staples and extra dishes as side dishes, got %v", first.SideDishes)
+	}
+	if dateKey(table.Menus[1].Menu.Date) != "2025-01-15" || dateKey(table.Menus[2].Menu.Date) != "2025-01-17" {
+		t.Errorf("Unexpected dates: %s, %s", dateKey(table.Menus[1].Menu.Date), dateKey(table.Menus[2].Menu.Date))
+	}
+
+	// The holiday and the totals row are reported, not imported
+	if len(table.Skipped) != 2 || table.Skipped[0].Row != 5 || table.Skipped[1].Row != 7 {
+		t.Errorf("Unexpected skipped rows: %+v", table.Skipped)
+	}
+}
+
+func TestParseTableMenuRowsCustomMapping(t *testing.T) {
+	rows := [][]string{
+		{"実施日", "こんだて", "kcal"},
+		{"2025-01-14", "カレーライス", "700"},
+	}
+	if _, err := parseTableMenuRows(rows, nil, textParseContext{}); err == nil {
+		t.Error("Expected unknown headers to fail without a mapping")
+	}
+
+	mapping := models.ColumnMapping{"実施日": "date", "こんだて": "main_dish", "kcal": "nutrition.calories"}
+	table, err := parseTableMenuRows(rows, mapping, textParseContext{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	menu := table.Menus[0].Menu
+	if menu.MainDish != "カレーライス" || menu.Nutrition.Calories != 700 {
+		t.Errorf("Unexpected menu: %+v", menu)
+	}
+}
+
+func TestParseTableDate(t *testing.T) {
+	ctx := textParseContext{Year: 2025, Month: time.January}
+	tests := map[string]string{
+		"2025-01-14": "2025-01-14",
+		"2025/1/14":  "2025-01-14",
+		"1/14":       "2025-01-14",
+		"1月14日(火)":   "2025-01-14",
+		"14日":        "2025-01-14",
+		"14":         "2025-01-14",
+		"45671":      "2025-01-14",
+		"45671.25":   "2025-01-14",
+		"12/26":      "2024-12-26",
+	}
+	for value, expected := range tests {
+		date, err := parseTableDate(value, ctx)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", value, err)
+			continue
+		}
+		if dateKey(date) != expected {
+			t.Errorf("%s: expected %s, got %s", value, expected, dateKey(date))
+		}
+	}
+
+	for _, value := range []string{"合計", "2025/2/30", "0"} {
+		if _, err := parseTableDate(value, ctx); err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+}
+
+func TestProcessDocumentXLSX(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+
+	doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+		File:   &mockFileBytes{bytes.NewReader(readTableFixture(t, "menu.xlsx"))},
+		Header: &multipart.FileHeader{Filename: "献立表.xlsx"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if doc.Type != models.DocumentTypeXLSX || doc.Status != "completed" {
+		t.Errorf("Unexpected document: type=%s status=%s", doc.Type, doc.Status)
+	}
+	if doc.MergeReport.Summary[models.DayMergeAdded] != 2 {
+		t.Errorf("Expected 2 days added, got %+v", doc.MergeReport.Days)
+	}
+
+	menu, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if menu.MainDish != "さばの味噌煮" || menu.Nutrition.Calories != 620 {
+		t.Errorf("Unexpected menu: %+v", menu)
+	}
+}
+
+func TestPreviewTable(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+
+	preview, err := processor.PreviewTable(&models.DocumentProcessingRequest{
+		File:   &mockFileBytes{bytes.NewReader(readTableFixture(t, "menu.csv"))},
+		Header: &multipart.FileHeader{Filename: "menu.csv"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if preview.Type != models.DocumentTypeCSV || len(preview.Menus) != 3 || len(preview.Columns) != 6 {
+		t.Errorf("Unexpected preview: %+v", preview)
+	}
+	if len(menuService.GetAllSchoolLunches()) != 0 {
+		t.Error("Expected preview not to import any menu")
+	}
+
+	_, err = processor.PreviewTable(&models.DocumentProcessingRequest{
+		File:   &mockFileBytes{bytes.NewReader([]byte(`[{"date":"2025-01-14T00:00:00Z"}]`))},
+		Header: &multipart.FileHeader{Filename: "menu.json"},
+	})
+	if err == nil {
+		t.Error("Expected preview of a JSON document to fail")
+	}
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// parsedMenu is a menu produced by a parser together with how each field was read
+type parsedMenu struct {
+	Menu   models.SchoolLunchMenu
+	Fields map[string]models.FieldExtraction
+}
+
+// confidence returns the lowest confidence of any extracted field, or 1 when
+// the menu came from structured data
+func (p *parsedMenu) confidence() float64 {
+	c := 1.0
+	for _, f := range p.Fields {
+		if f.Confidence < c {
+			c = f.Confidence
+		}
+	}
+	return c
+}
+
+var (
+	// "2025年1月" on its own, as printed in a menu's title
+	monthHeaderPattern = regexp.MustCompile(`^(\d{4})\s*年\s*(\d{1,2})\s*月`)
+	// "1月13日(月)" or "13日（月）" at the start of a day block
+	dayLinePattern = regexp.MustCompile(`^(?:(\d{4})\s*年\s*)?(?:(\d{1,2})\s*月\s*)?(\d{1,2})\s*日\s*(?:[(（][月火水木金土日][)）])?\s*(.*)$`)
+	numberPattern  = regexp.MustCompile(`\d+(?:\.\d+)?`)
+	dishSeparator  = regexp.MustCompile(`[、，,・/／\s]+`)
+)
+
+// textLabels maps the labels printed on Japanese school menus onto menu fields
+var textLabels = map[string]string{
+	"主菜":     "main_dish",
+	"おかず":    "main_dish",
+	"メイン":    "main_dish",
+	"主食":     "side_dishes",
+	"副菜":     "side_dishes",
+	"汁物":     "soup",
+	"スープ":    "soup",
+	"デザート":   "dessert",
+	"果物":     "dessert",
+	"エネルギー":  "nutrition.calories",
+	"熱量":     "nutrition.calories",
+	"たんぱく質":  "nutrition.protein_g",
+	"脂質":     "nutrition.fat_g",
+	"炭水化物":   "nutrition.carbs_g",
+	"食物繊維":   "nutrition.fiber_g",
+	"ナトリウム":  "nutrition.sodium_mg",
+	"食塩相当量":  "nutrition.salt_g",
+	"塩分":     "nutrition.salt_g",
+	"カルシウム":  "nutrition.calcium_mg",
+	"鉄":      "nutrition.iron_mg",
+	"マグネシウム": "nutrition.magnesium_mg",
+	"亜鉛":     "nutrition.zinc_mg",
+	"ビタミンA":  "nutrition.vitamin_a_ug",
+	"ビタミンB1": "nutrition.vitamin_b1_mg",
+	"ビタミンB2": "nutrition.vitamin_b2_mg",
+	"ビタミンC":  "nutrition.vitamin_c_mg",
+}
+
+// textParseContext supplies the year and month for dates printed without them
+type textParseContext struct {
+	Year  int
+	Month time.Month
+}
+
+// parseTextMenuLines parses line-oriented menu text, as produced by OCR or PDF
+// text extraction, into menus. Each day starts with a date line such as
+// "1月13日(月)" followed by labelled lines ("主菜: 鶏肉の照り焼き") or by the
+// dishes on the date line itself.
+func parseTextMenuLines(lines []models.TextLine, ctx textParseContext) ([]parsedMenu, error) {
+	var menus []parsedMenu
+	var current *parsedMenu
+
+	for _, line := range lines {
+		text := strings.TrimSpace(line.Text)
+		if text == "" {
+			continue
+		}
+
+		if m := monthHeaderPattern.FindStringSubmatch(text); m != nil && !dayLinePattern.MatchString(text) {
+			ctx.Year, _ = strconv.Atoi(m[1])
+			month, _ := strconv.Atoi(m[2])
+			ctx.Month = time.Month(month)
+			continue
+		}
+
+		if m := dayLinePattern.FindStringSubmatch(text); m != nil {
+			date, err := resolveMenuDate(m[1], m[2], m[3], ctx)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %q: %w", text, err)
+			}
+			menus = append(menus, parsedMenu{
+				Menu:   models.SchoolLunchMenu{Date: date},
+				Fields: map[string]models.FieldExtraction{},
+			})
+			current = &menus[len(menus)-1]
+			current.record("date", text, line)
+			if rest := strings.TrimSpace(m[4]); rest != "" {
+				current.applyDishList(rest, line)
+			}
+			continue
+		}
+
+		if current == nil {
+			// Titles, school names and notes before the first day
+			continue
+		}
+		if field, value, ok := splitLabel(text); ok {
+			current.applyLabel(field, value, line)
+		}
+	}
+
+	if len(menus) == 0 {
+		return nil, fmt.Errorf("no menu days found in text")
+	}
+	return menus, nil
+}
+
+// splitLabel matches lines such as "主菜: 鶏肉の照り焼き" or "エネルギー650kcal"
+// against the known labels and returns the field and the value after the label
+func splitLabel(text string) (field, value string, ok bool) {
+	for label, f := range textLabels {
+		if !strings.HasPrefix(text, label) {
+			continue
+		}
+		value = strings.TrimLeft(text[len(label):], " \t　:：")
+		if value == "" {
+			return "", "", false
+		}
+		return f, value, true
+	}
+	return "", "", false
+}
+
+// resolveMenuDate builds a date from the parts of a day line, filling in the
+// year and month from the parse context when the line omits them
+func resolveMenuDate(yearStr, monthStr, dayStr string, ctx textParseContext) (time.Time, error) {
+	year, month := ctx.Year, ctx.Month
+	if monthStr != "" {
+		m, _ := strconv.Atoi(monthStr)
+		month = time.Month(m)
+		// A menu for December may list "1月6日" meaning January of the next year
+		if yearStr == "" && ctx.Month != 0 {
+			switch diff := int(month) - int(ctx.Month); {
+			case diff <= -6:
+				year++
+			case diff >= 6:
+				year--
+			}
+		}
+	}
+	if yearStr != "" {
+		year, _ = strconv.Atoi(yearStr)
+	}
+	day, _ := strconv.Atoi(dayStr)
+	if year == 0 || month == 0 {
+		return time.Time{}, fmt.Errorf("year and month are unknown")
+	}
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, fmt.Errorf("out of range")
+	}
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if date.Month() != month {
+		return time.Time{}, fmt.Errorf("no such day")
+	}
+	return date, nil
+}
+
+// record notes the source line of a field, keeping the lowest confidence
+// when several lines contribute to the same field
+func (p *parsedMenu) record(field, text string, line models.TextLine) {
+	if existing, ok := p.Fields[field]; ok {
+		existing.Text += "\n" + text
+		if line.Confidence < existing.Confidence {
+			existing.Confidence = line.Confidence
+		}
+		p.Fields[field] = existing
+		return
+	}
+	p.Fields[field] = models.FieldExtraction{
+		Field:      field,
+		Text:       text,
+		Confidence: line.Confidence,
+		Box:        line.Box,
+	}
+}
+
+// applyLabel stores the value of a labelled line in the menu
+func (p *parsedMenu) applyLabel(field, value string, line models.TextLine) {
+	menu := &p.Menu
+	switch field {
+	case "main_dish":
+		menu.MainDish = value
+	case "side_dishes":
+		menu.SideDishes = append(menu.SideDishes, splitDishes(value)...)
+	case "soup":
+		menu.Soup = value
+	case "dessert":
+		menu.Dessert = value
+	case "salt_g", "nutrition.salt_g":
+		v, ok := parseNumber(value)
+		if !ok {
+			return
+		}
+		field = "nutrition.salt_g"
+		menu.Nutrition.Salt = v
+		// Sodium is still given for menus that print only the salt equivalent
+		if menu.Nutrition.Sodium == 0 {
+			menu.Nutrition.Sodium = models.SaltToSodium(v)
+		}
+	default:
+		v, ok := parseNumber(value)
+		if !ok {
+			return
+		}
+		setNutrition(&menu.Nutrition, field, v)
+	}
+	p.record(field, value, line)
+}
+
+// applyDishList interprets dishes printed on the date line itself: soups are
+// recognized by name, the first other dish that is not a staple is the main
+// dish, and the rest are side dishes
+func (p *parsedMenu) applyDishList(text string, line models.TextLine) {
+	for _, dish := range splitDishes(text) {
+		switch {
+		case isSoup(dish) && p.Menu.Soup == "":
+			p.Menu.Soup = dish
+			p.record("soup", dish, line)
+		case !isStaple(dish) && p.Menu.MainDish == "":
+			p.Menu.MainDish = dish
+			p.record("main_dish", dish, line)
+		default:
+			p.Menu.SideDishes = append(p.Menu.SideDishes, dish)
+			p.record("side_dishes", dish, line)
+		}
+	}
+}
+
+// setNutrition sets the nutrient named by a field such as "nutrition.iron_mg"
+func setNutrition(n *models.Nutrition, field string, v float64) {
+	if nutrient, ok := models.NutrientByKey(strings.TrimPrefix(field, "nutrition.")); ok {
+		nutrient.SetValue(n, v)
+	}
+}
+
+func parseNumber(s string) (float64, bool) {
+	m := numberPattern.FindString(s)
+	if m == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(m, 64)
+	return v, err == nil
+}
+
+func splitDishes(s string) []string {
+	var dishes []string
+	for _, d := range dishSeparator.Split(s, -1) {
+		if d = strings.TrimSpace(d); d != "" {
+			dishes = append(dishes, d)
+		}
+	}
+	return dishes
+}
+
+func isSoup(dish string) bool {
+	return strings.Contains(dish, "汁") || strings.Contains(dish, "スープ")
+}
+
+func isStaple(dish string) bool {
+	for _, s := range []string{"ごはん", "ご飯", "白米", "パン", "牛乳", "麦ごはん"} {
+		if dish == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func textLines(text string, confidence float64) []models.TextLine {
+	var lines []models.TextLine
+	for _, l := range strings.Split(text, "\n") {
+		lines = append(lines, models.TextLine{Text: l, Confidence: confidence})
+	}
+	return lines
+}
+
+func TestParseTextMenuLinesLabelled(t *testing.T) {
+	text := `2025年1月 給食献立表
+○○小学校
+13日(月)
+主菜: 鶏肉の照り焼き
+副菜: 野菜炒め、白米
+汁物: 味噌汁（わかめ）
+エネルギー 650kcal
+たんぱく質 28.5g
+食塩相当量 2.2g
+14日（火）
+主菜：魚のフライ
+副菜：ひじきの煮物`
+
+	menus, err := parseTextMenuLines(textLines(text, 0.95), textParseContext{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(menus) != 2 {
+		t.Fatalf("Expected 2 menus, got %d", len(menus))
+	}
+
+	first := menus[0].Menu
+	if !first.Date.Equal(time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 2025-01-13, got %v", first.Date)
+	}
+	if first.MainDish != "鶏肉の照り焼き" || first.Soup != "味噌汁（わかめ）" {
+		t.Errorf("Unexpected dishes: %+v", first)
+	}
+	if len(first.SideDishes) != 2 {
+		t.Errorf("Expected 2 side dishes, got %v", first.SideDishes)
+	}
+	if first.Nutrition.Calories != 650 || first.Nutrition.Protein != 28.5 {
+		t.Errorf("Unexpected nutrition: %+v", first.Nutrition)
+	}
+	if first.Nutrition.Sodium < 860 || first.Nutrition.Sodium > 870 {
+		t.Errorf("Expected salt 2.2g to convert to about 866mg sodium, got %f", first.Nutrition.Sodium)
+	}
+	if menus[1].Menu.MainDish != "魚のフライ" {
+		t.Errorf("Expected full-width colon to be accepted, got %q", menus[1].Menu.MainDish)
+	}
+}
+
+func TestParseTextMenuLinesMinerals(t *testing.T) {
+	text := `13日(月)
+主菜: 鶏肉の照り焼き
+カルシウム 352mg
+鉄 2.8mg
+マグネシウム 78mg
+亜鉛 2.4mg
+ビタミンA 190μgRAE
+ビタミンB1 0.42mg
+ビタミンB2 0.46mg
+ビタミンC 24mg
+塩分 2.0g
+ナトリウム 780mg`
+
+	menus, err := parseTextMenuLines(textLines(text, 0.95), textParseContext{Year: 2025, Month: time.January})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := models.Nutrition{Calcium: 352, Iron: 2.8, Magnesium: 78, Zinc: 2.4, VitaminA: 190,
+		VitaminB1: 0.42, VitaminB2: 0.46, VitaminC: 24, Salt: 2, Sodium: 780}
+	if got := menus[0].Menu.Nutrition; got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+	if _, ok := menus[0].Fields["nutrition.iron_mg"]; !ok {
+		t.Errorf("Expected the iron line to be recorded, got %v", menus[0].Fields)
+	}
+}
+
+func TestNutritionJSONCompatibility(t *testing.T) {
+	// Menus written before the minerals and vitamins were added read and write the same
+	old := `{"calories":650,"protein_g":28.5,"carbs_g":0,"fat_g":0,"fiber_g":0,"sodium_mg":866,"vegetables_servings":0}`
+	var n models.Nutrition
+	if err := json.Unmarshal([]byte(old), &n); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, _ := json.Marshal(n); string(data) != old {
+		t.Errorf("Expected %s, got %s", old, data)
+	}
+	n.Calcium = 350
+	if data, _ := json.Marshal(n); !strings.Contains(string(data), `"calcium_mg":350`) {
+		t.Errorf("Expected calcium in %s", data)
+	}
+}
+
+func TestParseTextMenuLinesDishesOnDateLine(t *testing.T) {
+	menus, err := parseTextMenuLines(textLines("1月15日(水) ごはん 牛乳 カレーライス 海藻サラダ わかめスープ", 1), textParseContext{Year: 2025})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	menu := menus[0].Menu
+	if menu.MainDish != "カレーライス" || menu.Soup != "わかめスープ" {
+		t.Errorf("Unexpected dishes: %+v", menu)
+	}
+	if len(menu.SideDishes) != 3 {
+		t.Errorf("Expected staples and salad as side dishes, got %v", menu.SideDishes)
+	}
+}
+
+func TestParseTextMenuLinesFieldConfidence(t *testing.T) {
+	lines := []models.TextLine{
+		{Text: "2025年1月13日(月)", Confidence: 0.99},
+		{Text: "主菜: 鶏肉の照リ焼き", Confidence: 0.42, Box: &models.BoundingBox{X: 10, Y: 40, Width: 200, Height: 20}},
+	}
+	menus, err := parseTextMenuLines(lines, textParseContext{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	field := menus[0].Fields["main_dish"]
+	if field.Confidence != 0.42 || field.Box == nil || field.Box.Y != 40 {
+		t.Errorf("Expected main_dish to carry its line's confidence and box, got %+v", field)
+	}
+	if menus[0].confidence() != 0.42 {
+		t.Errorf("Expected menu confidence to be the lowest field confidence, got %f", menus[0].confidence())
+	}
+}
+
+func TestParseTextMenuLinesErrors(t *testing.T) {
+	if _, err := parseTextMenuLines(textLines("献立表\nお知らせ", 1), textParseContext{Year: 2025, Month: 1}); err == nil {
+		t.Error("Expected error for text without any day")
+	}
+	if _, err := parseTextMenuLines(textLines("13日(月)\n主菜: カレー", 1), textParseContext{}); err == nil {
+		t.Error("Expected error when year and month cannot be resolved")
+	}
+	if _, err := parseTextMenuLines(textLines("2月30日\n主菜: カレー", 1), textParseContext{Year: 2025}); err == nil {
+		t.Error("Expected error for a day that does not exist")
+	}
+}
+
+func TestParseTextMenuLinesYearRollover(t *testing.T) {
+	menus, err := parseTextMenuLines(textLines("12月19日(金)\n主菜: ハンバーグ\n1月8日(木)\n主菜: 雑煮", 1), textParseContext{Year: 2024, Month: time.December})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if menus[0].Menu.Date.Year() != 2024 || menus[1].Menu.Date.Year() != 2025 {
+		t.Errorf("Expected January to roll over into the next year, got %v and %v", menus[0].Menu.Date, menus[1].Menu.Date)
+	}
+}
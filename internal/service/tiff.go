@@ -0,0 +1,257 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// maxTIFFPages bounds how many pages are read from a single TIFF
+const maxTIFFPages = 500
+
+// tiffIFDOffsets follows the chain of image file directories (one per page)
+// and returns their offsets together with the file's byte order
+func tiffIFDOffsets(data []byte) ([]uint32, binary.ByteOrder, error) {
+	if len(data) < 8 {
+		return nil, nil, fmt.Errorf("TIFF header is truncated")
+	}
+	var order binary.ByteOrder
+	switch string(data[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, nil, fmt.Errorf("not a TIFF file")
+	}
+	if order.Uint16(data[2:4]) != 42 {
+		// BigTIFF (43) uses 64-bit offsets and is not supported
+		return nil, nil, fmt.Errorf("unsupported TIFF version %d", order.Uint16(data[2:4]))
+	}
+
+	var offsets []uint32
+	seen := map[uint32]bool{}
+	for off := order.Uint32(data[4:8]); off != 0; {
+		if seen[off] {
+			return nil, nil, fmt.Errorf("TIFF page chain loops at offset %d", off)
+		}
+		if len(offsets) >= maxTIFFPages {
+			return nil, nil, fmt.Errorf("TIFF has more than %d pages", maxTIFFPages)
+		}
+		next, err := tiffNextIFDPosition(data, off, order)
+		if err != nil {
+			return nil, nil, err
+		}
+		seen[off] = true
+		offsets = append(offsets, off)
+		off = order.Uint32(data[next : next+4])
+	}
+	if len(offsets) == 0 {
+		return nil, nil, fmt.Errorf("TIFF has no pages")
+	}
+	return offsets, order, nil
+}
+
+// tiffNextIFDPosition returns where the "next IFD" pointer of the directory
+// at off is stored
+func tiffNextIFDPosition(data []byte, off uint32, order binary.ByteOrder) (int, error) {
+	if int64(off)+2 > int64(len(data)) {
+		return 0, fmt.Errorf("TIFF directory offset %d is out of range", off)
+	}
+	count := int(order.Uint16(data[off : off+2]))
+	next := int(off) + 2 + count*12
+	if next+4 > len(data) {
+		return 0, fmt.Errorf("TIFF directory at offset %d is truncated", off)
+	}
+	return next, nil
+}
+
+// forEachTIFFPage calls fn with each page of a TIFF, in order, as a
+// single-page TIFF. The pages of a multi-page TIFF share one copy of the file
+// whose header is pointed at the page's directory and whose directory chain
+// is cut after it, so fn must not keep the page once it returns.
+func forEachTIFFPage(data []byte, fn func(i int, page []byte) error) error {
+	offsets, order, err := tiffIFDOffsets(data)
+	if err != nil {
+		return err
+	}
+	if len(offsets) == 1 {
+		return fn(0, data)
+	}
+
+	page := append([]byte(nil), data...)
+	for i, off := range offsets {
+		next, _ := tiffNextIFDPosition(data, off, order)
+		order.PutUint32(page[4:8], off)
+		order.PutUint32(page[next:next+4], 0)
+		err := fn(i, page)
+		copy(page[next:next+4], data[next:next+4])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tiffTagValues reads every value of a BYTE, SHORT or LONG tag of the
+// directory at off, such as the offsets of an image's strips
+func tiffTagValues(data []byte, off uint32, order binary.ByteOrder, tag uint16) ([]int, error) {
+	count := int(order.Uint16(data[off : off+2]))
+	for i := 0; i < count; i++ {
+		entry := data[int(off)+2+i*12 : int(off)+2+(i+1)*12]
+		if order.Uint16(entry[0:2]) != tag {
+			continue
+		}
+		size := map[uint16]int{1: 1, 3: 2, 4: 4}[order.Uint16(entry[2:4])]
+		n := int64(order.Uint32(entry[4:8]))
+		if size == 0 {
+			return nil, fmt.Errorf("TIFF tag %d has an unsupported type", tag)
+		}
+		raw := entry[8:12]
+		if total := n * int64(size); total > 4 {
+			at := int64(order.Uint32(entry[8:12]))
+			if at+total > int64(len(data)) {
+				return nil, fmt.Errorf("TIFF tag %d is out of range", tag)
+			}
+			raw = data[at : at+total]
+		}
+		values := make([]int, n)
+		for j := range values {
+			switch size {
+			case 1:
+				values[j] = int(raw[j])
+			case 2:
+				values[j] = int(order.Uint16(raw[j*2:]))
+			case 4:
+				values[j] = int(order.Uint32(raw[j*4:]))
+			}
+		}
+		return values, nil
+	}
+	return nil, nil
+}
+
+// decodeTIFF decodes the page whose directory is at off, as returned by
+// tiffIFDOffsets, of a baseline TIFF: a bilevel, 8-bit grayscale or 8-bit
+// RGB image stored in strips, uncompressed or PackBits compressed. Other
+// compressions, such as the CCITT fax coding of many scanners, are not
+// supported.
+func decodeTIFF(data []byte, off uint32, order binary.ByteOrder) (image.Image, error) {
+	tag := func(id uint16, fallback int) int {
+		if v := tiffTagValue(data, off, order, id); v != 0 {
+			return v
+		}
+		return fallback
+	}
+	width, height := tag(256, 0), tag(257, 0)
+	if width <= 0 || height <= 0 || int64(width)*int64(height) > DefaultUploadLimits().MaxImagePixels {
+		return nil, fmt.Errorf("TIFF size %dx%d is not supported", width, height)
+	}
+	compression, photometric, samples := tag(259, 1), tiffTagValue(data, off, order, 262), tag(277, 1)
+	if compression != 1 && compression != 32773 {
+		return nil, fmt.Errorf("TIFF compression %d is not supported", compression)
+	}
+	if tag(284, 1) != 1 {
+		return nil, fmt.Errorf("planar TIFF images are not supported")
+	}
+	bits, err := tiffTagValues(data, off, order, 258)
+	if err != nil {
+		return nil, err
+	}
+	if len(bits) == 0 {
+		bits = []int{1}
+	}
+
+	var rowBytes int
+	switch {
+	case photometric <= 1 && samples == 1 && bits[0] == 1:
+		rowBytes = (width + 7) / 8
+	case photometric <= 1 && samples == 1 && bits[0] == 8:
+		rowBytes = width
+	case photometric == 2 && (samples == 3 || samples == 4) && bits[0] == 8:
+		rowBytes = width * samples
+	default:
+		return nil, fmt.Errorf("TIFF with photometric interpretation %d and %d samples of %d bits is not supported", photometric, samples, bits[0])
+	}
+
+	stripOffsets, err := tiffTagValues(data, off, order, 273)
+	if err != nil {
+		return nil, err
+	}
+	stripCounts, err := tiffTagValues(data, off, order, 279)
+	if err != nil {
+		return nil, err
+	}
+	if len(stripOffsets) == 0 || len(stripOffsets) != len(stripCounts) {
+		return nil, fmt.Errorf("TIFF strips are missing")
+	}
+	need := rowBytes * height
+	pix := make([]byte, 0, need)
+	for i, at := range stripOffsets {
+		if int64(at)+int64(stripCounts[i]) > int64(len(data)) {
+			return nil, fmt.Errorf("TIFF strip %d is out of range", i)
+		}
+		strip := data[at : at+stripCounts[i]]
+		if compression == 32773 {
+			strip = unpackBits(strip, need-len(pix))
+		}
+		pix = append(pix, strip...)
+		if len(pix) >= need {
+			break
+		}
+	}
+	if len(pix) < need {
+		return nil, fmt.Errorf("TIFF image data is truncated")
+	}
+
+	if photometric == 2 {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				p := pix[y*rowBytes+x*samples:]
+				img.SetRGBA(x, y, color.RGBA{p[0], p[1], p[2], 0xFF})
+			}
+		}
+		return img, nil
+	}
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := pix[y*rowBytes : (y+1)*rowBytes]
+		for x := 0; x < width; x++ {
+			var v byte
+			if bits[0] == 1 {
+				v = (row[x/8] >> (7 - x%8) & 1) * 0xFF
+			} else {
+				v = row[x]
+			}
+			// WhiteIsZero images store ink as the higher value
+			if photometric == 0 {
+				v = 0xFF - v
+			}
+			img.Pix[y*img.Stride+x] = v
+		}
+	}
+	return img, nil
+}
+
+// unpackBits expands PackBits compressed data, stopping after limit bytes
+func unpackBits(data []byte, limit int) []byte {
+	var out []byte
+	for i := 0; i < len(data) && len(out) < limit; {
+		n := int(int8(data[i]))
+		i++
+		switch {
+		case n >= 0:
+			end := min(i+n+1, len(data))
+			out = append(out, data[i:end]...)
+			i = end
+		case n != -128 && i < len(data):
+			for j := 0; j < 1-n; j++ {
+				out = append(out, data[i])
+			}
+			i++
+		}
+	}
+	return out
+}
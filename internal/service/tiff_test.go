@@ -0,0 +1,242 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"mime/multipart"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// pagedOCR returns the lines of one page per call, in order
+type pagedOCR struct {
+	pages [][]models.TextLine
+	seen  [][]byte
+}
+
+func (p *pagedOCR) Recognize(data []byte, format models.DocumentType) ([]models.TextLine, error) {
+	p.seen = append(p.seen, data)
+	lines := p.pages[len(p.seen)-1]
+	return lines, nil
+}
+
+func TestForEachTIFFPage(t *testing.T) {
+	data := readFixture(t, "scan.tiff")
+	offsets, _, _ := tiffIFDOffsets(data)
+
+	var pages int
+	err := forEachTIFFPage(data, func(i int, page []byte) error {
+		pages++
+		pageOffsets, _, err := tiffIFDOffsets(page)
+		if err != nil {
+			t.Fatalf("Page %d is not a valid TIFF: %v", i+1, err)
+		}
+		if len(pageOffsets) != 1 || pageOffsets[0] != offsets[i] {
+			t.Errorf("Page %d: expected single directory at %d, got %v", i+1, offsets[i], pageOffsets)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pages != 2 {
+		t.Fatalf("Expected 2 pages, got %d", pages)
+	}
+
+	// The original is left untouched
+	if again, _, _ := tiffIFDOffsets(data); len(again) != 2 {
+		t.Errorf("Expected original to keep 2 pages, got %d", len(again))
+	}
+}
+
+func TestForEachTIFFPageRejectsBrokenFiles(t *testing.T) {
+	data := readFixture(t, "scan.tiff")
+
+	// Point the second page back at the first
+	looped := append([]byte(nil), data...)
+	offsets, order, _ := tiffIFDOffsets(looped)
+	next, _ := tiffNextIFDPosition(looped, offsets[1], order)
+	binary.LittleEndian.PutUint32(looped[next:], offsets[0])
+
+	truncated := data[:20]
+
+	for name, data := range map[string][]byte{"loop": looped, "truncated": truncated, "not tiff": []byte("GIF89a....")} {
+		if err := forEachTIFFPage(data, func(int, []byte) error { return nil }); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestProcessDocumentMultiPageTIFF(t *testing.T) {
+	menuService := NewMenuAdvisorService()
+	processor := NewDocumentProcessor(menuService)
+	ocr := &pagedOCR{pages: [][]models.TextLine{
+		{
+			{Text: "2025年1月", Confidence: 0.98},
+			{Text: "13日(月)", Confidence: 0.97},
+			{Text: "主菜: 鶏肉の照り焼き", Confidence: 0.95},
+		},
+		{
+			// The day continues on the next page
+			{Text: "汁物: みそ汁", Confidence: 0.95, Box: &models.BoundingBox{X: 0, Y: 0, Width: 80, Height: 20}},
+			{Text: "14日(火)", Confidence: 0.96},
+			{Text: "主菜: さばの味噌煮", Confidence: 0.94},
+		},
+	}}
+	processor.SetOCREngine(ocr)
+
+	doc, err := processor.ProcessDocument(&models.DocumentProcessingRequest{
+		File:   &mockFileBytes{bytes.NewReader(readFixture(t, "scan.tiff"))},
+		Header: &multipart.FileHeader{Filename: "scan.tif"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(ocr.seen) != 2 {
+		t.Fatalf("Expected OCR to run once per page, ran %d times", len(ocr.seen))
+	}
+	if doc.ContentType != "image/tiff" {
+		t.Errorf("Expected image/tiff, got %s", doc.ContentType)
+	}
+	if doc.MergeReport.Summary[models.DayMergeAdded] != 2 {
+		t.Errorf("Expected 2 days added, got %+v", doc.MergeReport.Days)
+	}
+
+	menu, err := menuService.GetSchoolLunchForDate(time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if menu.MainDish != "鶏肉の照り焼き" || menu.Soup != "みそ汁" {
+		t.Errorf("Expected day split across pages to be merged, got %+v", menu)
+	}
+}
+
+func TestExtractWithOCRRecordsPages(t *testing.T) {
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+	ocr := &pagedOCR{pages: [][]models.TextLine{
+		{{Text: "a", Confidence: 0.9, Box: &models.BoundingBox{X: 1}}},
+		{{Text: "b", Confidence: 0.8, Box: &models.BoundingBox{X: 2}}},
+	}}
+	processor.SetOCREngine(ocr)
+
+	data, err := processor.extractWithOCR(&mockFileBytes{bytes.NewReader(readFixture(t, "scan.tiff"))}, "doc", models.DocumentTypeImage)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data.Metadata["pages"] != "2" || data.Confidence != 0.8 {
+		t.Errorf("Unexpected extraction: pages=%s confidence=%v", data.Metadata["pages"], data.Confidence)
+	}
+	for i, line := range data.Lines {
+		if line.Box.Page != i+1 {
+			t.Errorf("Line %q: expected page %d, got %d", line.Text, i+1, line.Box.Page)
+		}
+	}
+	if ocr.pages[0][0].Box.Page != 0 {
+		t.Error("Expected the engine's boxes not to be modified")
+	}
+}
+
+// tiffTestPage is one page of a TIFF built by buildTIFF, stored in one strip
+type tiffTestPage struct {
+	width, height, bits, samples, photometric, compression int
+	data                                                   []byte
+}
+
+// buildTIFF builds a little-endian TIFF with one directory per page
+func buildTIFF(pages ...tiffTestPage) []byte {
+	le := binary.LittleEndian
+	buf := []byte("II*\x00\x00\x00\x00\x00")
+	next := 4
+	for _, p := range pages {
+		samples := max(p.samples, 1)
+		dataAt := len(buf)
+		buf = append(buf, p.data...)
+		if len(buf)%2 == 1 {
+			buf = append(buf, 0)
+		}
+		bits := uint32(p.bits)
+		if samples > 2 {
+			bits = uint32(len(buf))
+			for i := 0; i < samples; i++ {
+				buf = le.AppendUint16(buf, uint16(p.bits))
+			}
+		}
+		le.PutUint32(buf[next:], uint32(len(buf)))
+		entries := [][4]uint32{
+			{256, 4, 1, uint32(p.width)}, {257, 4, 1, uint32(p.height)}, {258, 3, uint32(samples), bits},
+			{259, 3, 1, uint32(max(p.compression, 1))}, {262, 3, 1, uint32(p.photometric)}, {273, 4, 1, uint32(dataAt)},
+			{277, 3, 1, uint32(samples)}, {278, 4, 1, uint32(p.height)}, {279, 4, 1, uint32(len(p.data))},
+		}
+		buf = le.AppendUint16(buf, uint16(len(entries)))
+		for _, e := range entries {
+			buf = le.AppendUint16(buf, uint16(e[0]))
+			buf = le.AppendUint16(buf, uint16(e[1]))
+			buf = le.AppendUint32(buf, e[2])
+			buf = le.AppendUint32(buf, e[3])
+		}
+		next = len(buf)
+		buf = le.AppendUint32(buf, 0)
+	}
+	return buf
+}
+
+func TestDecodeTIFF(t *testing.T) {
+	gray := func(img image.Image) []uint8 {
+		var values []uint8
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				values = append(values, color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+			}
+		}
+		return values
+	}
+
+	tests := []struct {
+		name     string
+		page     tiffTestPage
+		expected []uint8
+	}{
+		{"grayscale", tiffTestPage{width: 3, height: 2, bits: 8, photometric: 1, data: []byte{0, 128, 255, 10, 20, 30}}, []uint8{0, 128, 255, 10, 20, 30}},
+		{"white is zero", tiffTestPage{width: 2, height: 1, bits: 8, photometric: 0, data: []byte{0, 255}}, []uint8{255, 0}},
+		{"bilevel", tiffTestPage{width: 10, height: 1, bits: 1, photometric: 1, data: []byte{0b10100000, 0b01000000}}, []uint8{255, 0, 255, 0, 0, 0, 0, 0, 0, 255}},
+		{"PackBits", tiffTestPage{width: 4, height: 1, bits: 8, photometric: 1, compression: 32773, data: []byte{0xFD, 7}}, []uint8{7, 7, 7, 7}},
+		{"RGB", tiffTestPage{width: 2, height: 1, bits: 8, samples: 3, photometric: 2, data: []byte{255, 255, 255, 0, 0, 0}}, []uint8{255, 0}},
+	}
+	for _, test := range tests {
+		img, err := decodeFirstTIFFPage(buildTIFF(test.page))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got := gray(img); !bytes.Equal(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+
+	if img, err := decodeFirstTIFFPage(readFixture(t, "scan.tiff")); err != nil || img.Bounds().Dx() != 1 {
+		t.Errorf("Expected the fixture to decode, got %v (%v)", img, err)
+	}
+	broken := map[string]tiffTestPage{
+		"fax compression": {width: 8, height: 1, bits: 1, photometric: 0, compression: 4, data: []byte{0}},
+		"truncated":       {width: 3, height: 2, bits: 8, photometric: 1, data: []byte{1, 2}},
+		"16-bit":          {width: 1, height: 1, bits: 16, photometric: 1, data: []byte{1, 2}},
+	}
+	for name, page := range broken {
+		if _, err := decodeFirstTIFFPage(buildTIFF(page)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func decodeFirstTIFFPage(data []byte) (image.Image, error) {
+	offsets, order, err := tiffIFDOffsets(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTIFF(data, offsets[0], order)
+}
@@ -0,0 +1,347 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+var (
+	// ErrDocumentTooLarge is returned when a document exceeds the upload limits
+	ErrDocumentTooLarge = errors.New("document exceeds upload limits")
+	// ErrQuotaExceeded is returned when a household has used up its upload quota
+	ErrQuotaExceeded = errors.New("upload quota exceeded")
+)
+
+// maxFilenameBytes bounds the length of a stored file name
+const maxFilenameBytes = 200
+
+// DefaultUploadLimits returns limits that comfortably fit a school's monthly
+// menus while rejecting decompression bombs
+func DefaultUploadLimits() models.UploadLimits {
+	return models.UploadLimits{
+		MaxBodyBytes: 64 << 20,
+		MaxFileBytes: map[models.DocumentType]int64{
+			models.DocumentTypeJSON:     2 << 20,
+			models.DocumentTypeCSV:      5 << 20,
+			models.DocumentTypeHTML:     5 << 20,
+			models.DocumentTypeXLSX:     16 << 20,
+			models.DocumentTypeImage:    20 << 20,
+			models.DocumentTypePDFText:  32 << 20,
+			models.DocumentTypePDFImage: 32 << 20,
+		},
+		MaxImagePixels:      100_000_000,
+		MaxPages:            100,
+		MaxArchiveEntries:   maxArchiveEntries,
+		MaxArchiveBytes:     128 << 20,
+		MaxCompressionRatio: 100,
+	}
+}
+
+// DefaultUploadQuota returns the quota applied to each household
+func DefaultUploadQuota() models.UploadQuota {
+	return models.UploadQuota{MaxDocuments: 100, MaxBytes: 512 << 20, Window: 24 * time.Hour}
+}
+
+// SetUploadLimits changes the limits applied to uploaded documents
+func (dp *DocumentProcessor) SetUploadLimits(limits models.UploadLimits) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	dp.limits = limits
+}
+
+// UploadLimits returns a copy of the limits applied to uploaded documents
+func (dp *DocumentProcessor) UploadLimits() models.UploadLimits {
+	dp.mu.RLock()
+	defer dp.mu.RUnlock()
+	limits := dp.limits
+	limits.MaxFileBytes = maps.Clone(dp.limits.MaxFileBytes)
+	return limits
+}
+
+// checkLimits rejects a document whose size, page count or pixel count
+// exceeds the limits for its type
+func (dp *DocumentProcessor) checkLimits(docType models.DocumentType, detection contentDetection) error {
+	limits := dp.UploadLimits()
+	if max, ok := limits.MaxFileBytes[docType]; ok && max > 0 && detection.Size > max {
+		return fmt.Errorf("%w: %s documents may be at most %d bytes, got %d", ErrDocumentTooLarge, docType, max, detection.Size)
+	}
+	if limits.MaxPages > 0 && detection.PagesUnknown {
+		return fmt.Errorf("%w: the page count of the PDF could not be read", ErrDocumentTooLarge)
+	}
+	if limits.MaxPages > 0 && detection.Pages > limits.MaxPages {
+		return fmt.Errorf("%w: at most %d pages are allowed, got %d", ErrDocumentTooLarge, limits.MaxPages, detection.Pages)
+	}
+	if limits.MaxImagePixels > 0 && detection.Pixels > limits.MaxImagePixels {
+		return fmt.Errorf("%w: images may have at most %d pixels, got %d", ErrDocumentTooLarge, limits.MaxImagePixels, detection.Pixels)
+	}
+	return nil
+}
+
+// imagePixels returns the largest width times height declared by an image's
+// headers, without decoding its pixels. For a multi-page TIFF it is the
+// largest page. ok is false when the dimensions cannot be read.
+func imagePixels(data []byte, format string) (width, height int, pixels int64, ok bool) {
+	switch format {
+	case ImageFormatJPEG:
+		width, height, ok = jpegDimensions(data)
+		if !ok {
+			return 0, 0, 0, false
+		}
+	case ImageFormatPNG:
+		if len(data) < 24 || string(data[12:16]) != "IHDR" {
+			return 0, 0, 0, false
+		}
+		width, height = int(binary.BigEndian.Uint32(data[16:20])), int(binary.BigEndian.Uint32(data[20:24]))
+	case ImageFormatGIF:
+		if len(data) < 10 {
+			return 0, 0, 0, false
+		}
+		width, height = int(binary.LittleEndian.Uint16(data[6:8])), int(binary.LittleEndian.Uint16(data[8:10]))
+	case ImageFormatBMP:
+		width = int(int32(binary.LittleEndian.Uint32(data[18:22])))
+		height = int(int32(binary.LittleEndian.Uint32(data[22:26])))
+		// Top-down bitmaps have a negative height
+		height = max(height, -height)
+	case ImageFormatWebP:
+		width, height, ok = webpDimensions(data)
+		if !ok {
+			return 0, 0, 0, false
+		}
+	case ImageFormatHEIC:
+		return heifDimensions(data)
+	case ImageFormatTIFF:
+		return tiffDimensions(data)
+	default:
+		return 0, 0, 0, false
+	}
+	if width < 0 {
+		return 0, 0, 0, false
+	}
+	return width, height, int64(width) * int64(height), true
+}
+
+// jpegDimensions reads the frame size from a JPEG's start-of-frame segment
+func jpegDimensions(data []byte) (int, int, bool) {
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return 0, 0, false
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte
+			pos++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD9):
+			// Markers without a segment
+			pos += 2
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		// SOF0-SOF15, except DHT (C4), JPG (C8) and DAC (CC)
+		if marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC {
+			if pos+9 > len(data) {
+				return 0, 0, false
+			}
+			return int(binary.BigEndian.Uint16(data[pos+7 : pos+9])), int(binary.BigEndian.Uint16(data[pos+5 : pos+7])), true
+		}
+		pos += 2 + length
+	}
+	return 0, 0, false
+}
+
+// webpDimensions reads the canvas size of a lossy, lossless or extended WebP
+func webpDimensions(data []byte) (int, int, bool) {
+	if len(data) < 30 {
+		return 0, 0, false
+	}
+	switch string(data[12:16]) {
+	case "VP8 ":
+		return int(binary.LittleEndian.Uint16(data[26:28]) & 0x3fff), int(binary.LittleEndian.Uint16(data[28:30]) & 0x3fff), true
+	case "VP8L":
+		bits := binary.LittleEndian.Uint32(data[21:25])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, true
+	case "VP8X":
+		w := int(data[24]) | int(data[25])<<8 | int(data[26])<<16
+		h := int(data[27]) | int(data[28])<<8 | int(data[29])<<16
+		return w + 1, h + 1, true
+	}
+	return 0, 0, false
+}
+
+// heifDimensions returns the largest image spatial extent ("ispe") property
+// of a HEIC/HEIF file; grid images declare their full size there
+func heifDimensions(data []byte) (int, int, int64, bool) {
+	var width, height int
+	var pixels int64
+	found := false
+	for pos := 0; ; {
+		i := bytes.Index(data[pos:], []byte("ispe"))
+		if i < 0 {
+			break
+		}
+		at := pos + i + 4 + 4 // Skip the box type and its version and flags
+		pos += i + 4
+		if at+8 > len(data) {
+			break
+		}
+		w, h := int(binary.BigEndian.Uint32(data[at:at+4])), int(binary.BigEndian.Uint32(data[at+4:at+8]))
+		if p := int64(w) * int64(h); p >= pixels {
+			width, height, pixels, found = w, h, p, true
+		}
+	}
+	return width, height, pixels, found
+}
+
+// tiffDimensions returns the size of the largest page of a TIFF
+func tiffDimensions(data []byte) (int, int, int64, bool) {
+	offsets, order, err := tiffIFDOffsets(data)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	var width, height int
+	var pixels int64
+	for _, off := range offsets {
+		w, h := tiffTagValue(data, off, order, 256), tiffTagValue(data, off, order, 257)
+		if p := int64(w) * int64(h); p >= pixels {
+			width, height, pixels = w, h, p
+		}
+	}
+	return width, height, pixels, true
+}
+
+// tiffTagValue reads a SHORT or LONG tag of the directory at off, or 0
+func tiffTagValue(data []byte, off uint32, order binary.ByteOrder, tag uint16) int {
+	count := int(order.Uint16(data[off : off+2]))
+	for i := 0; i < count; i++ {
+		entry := data[int(off)+2+i*12 : int(off)+2+(i+1)*12]
+		if order.Uint16(entry[0:2]) != tag {
+			continue
+		}
+		switch order.Uint16(entry[2:4]) {
+		case 3: // SHORT
+			return int(order.Uint16(entry[8:10]))
+		case 4: // LONG
+			return int(order.Uint32(entry[8:12]))
+		}
+	}
+	return 0
+}
+
+// sanitizeFilename reduces a client-supplied file name to a safe base name:
+// directories, control and bidirectional formatting characters are removed
+// and the name is shortened, keeping its extension
+func sanitizeFilename(name string) string {
+	name = strings.ToValidUTF8(name, "")
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return "document"
+	}
+
+	if len(name) > maxFilenameBytes {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		base := name[:maxFilenameBytes-len(ext)]
+		for !utf8.ValidString(base) {
+			base = base[:len(base)-1]
+		}
+		name = base + ext
+	}
+	return name
+}
+
+// QuotaTracker counts what each household uploads in a fixed window and
+// refuses uploads beyond its quota
+type QuotaTracker struct {
+	mu    sync.Mutex
+	quota models.UploadQuota
+	usage map[string]*models.QuotaUsage
+	now   func() time.Time
+}
+
+// NewQuotaTracker creates a tracker that applies quota to every household
+func NewQuotaTracker(quota models.UploadQuota) *QuotaTracker {
+	return &QuotaTracker{
+		quota: quota,
+		usage: make(map[string]*models.QuotaUsage),
+		now:   time.Now,
+	}
+}
+
+// SetQuota changes the quota; usage already recorded is kept
+func (q *QuotaTracker) SetQuota(quota models.UploadQuota) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.quota = quota
+}
+
+// Reserve records an upload of documents totalling size bytes. Nothing is
+// recorded and ErrQuotaExceeded is returned when it would exceed the quota.
+func (q *QuotaTracker) Reserve(household string, documents int, size int64) (models.QuotaUsage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Windows that have ended are forgotten, so the map only holds those
+	// who uploaded within the last window
+	now := q.now()
+	for key, usage := range q.usage {
+		if !now.Before(usage.ResetAt) {
+			delete(q.usage, key)
+		}
+	}
+
+	usage := q.currentLocked(household)
+	if q.quota.MaxDocuments > 0 && usage.Documents+documents > q.quota.MaxDocuments {
+		return *usage, fmt.Errorf("%w: at most %d documents may be uploaded per %s", ErrQuotaExceeded, q.quota.MaxDocuments, q.quota.Window)
+	}
+	if q.quota.MaxBytes > 0 && usage.Bytes+size > q.quota.MaxBytes {
+		return *usage, fmt.Errorf("%w: at most %d bytes may be uploaded per %s", ErrQuotaExceeded, q.quota.MaxBytes, q.quota.Window)
+	}
+	usage.Documents += documents
+	usage.Bytes += size
+	return *usage, nil
+}
+
+// Usage returns what a household has uploaded in its current window
+func (q *QuotaTracker) Usage(household string) models.QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if usage, ok := q.usage[household]; ok && q.now().Before(usage.ResetAt) {
+		return *usage
+	}
+	return models.QuotaUsage{HouseholdID: household, ResetAt: q.now().Add(q.quota.Window)}
+}
+
+// currentLocked returns the household's usage, starting a new window when
+// the previous one has ended. Callers must hold q.mu.
+func (q *QuotaTracker) currentLocked(household string) *models.QuotaUsage {
+	now := q.now()
+	usage, ok := q.usage[household]
+	if !ok || !now.Before(usage.ResetAt) {
+		usage = &models.QuotaUsage{HouseholdID: household, ResetAt: now.Add(q.quota.Window)}
+		q.usage[household] = usage
+	}
+	return usage
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+func readHostileFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "hostile", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture %s: %v", name, err)
+	}
+	return data
+}
+
+func TestProcessDocumentRejectsBombs(t *testing.T) {
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+
+	for _, fixture := range []string{
+		"pixel-bomb.png", "pixel-bomb.jpg", "pixel-bomb.tiff", "pixel-bomb.webp", "pixel-bomb.heic",
+		"many-pages.pdf", "image-bomb.pdf", "objstm-pages.pdf",
+	} {
+		doc, err := processor.ProcessDocument(batchRequest(fixture, readHostileFixture(t, fixture)))
+		if !errors.Is(err, ErrDocumentTooLarge) {
+			t.Errorf("%s: expected ErrDocumentTooLarge, got %v", fixture, err)
+			continue
+		}
+		if doc.Status != "error" || doc.ErrorMessage == "" {
+			t.Errorf("%s: expected the document to be recorded as failed, got %+v", fixture, doc)
+		}
+	}
+}
+
+func TestInspectPDFCountsObjectStreamPages(t *testing.T) {
+	// The page tree and all 150 pages are compressed in one object stream
+	if info := inspectPDF(readHostileFixture(t, "objstm-pages.pdf")); info.Pages != 150 || !info.PagesKnown {
+		t.Errorf("Expected 150 pages, got %+v", info)
+	}
+
+	// Without any page found, or with an object stream that cannot be
+	// inflated, the page count is unknown and the PDF is rejected
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+	for name, pdf := range map[string]string{
+		"no pages.pdf":       "%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n",
+		"corrupt stream.pdf": "%PDF-1.5\n1 0 obj\n<< /Type /Page >>\nendobj\n2 0 obj\n<< /Type /ObjStm /Filter /FlateDecode >>\nstream\nnot zlib\nendstream\nendobj\n",
+	} {
+		if info := inspectPDF([]byte(pdf)); info.PagesKnown {
+			t.Errorf("%s: expected the page count to be unknown, got %+v", name, info)
+		}
+		if _, err := processor.ProcessDocument(batchRequest(name, []byte(pdf))); !errors.Is(err, ErrDocumentTooLarge) {
+			t.Errorf("%s: expected ErrDocumentTooLarge, got %v", name, err)
+		}
+	}
+}
+
+func TestInspectPDFLimitsInflating(t *testing.T) {
+	// 40 small streams that each inflate to 1 MB, then a text stream
+	info := inspectPDF(readHostileFixture(t, "flate-bomb.pdf"))
+	if info.InflatedBytes > maxPDFInflatedBytes || info.InflatedStreams != 16 {
+		t.Errorf("Expected inflating to stop at the byte budget, got %d streams, %d bytes", info.InflatedStreams, info.InflatedBytes)
+	}
+	if info.HasText {
+		t.Error("Expected no text to be looked for past the budget")
+	}
+
+	// Many tiny streams stop at the stream count
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	var stream bytes.Buffer
+	zw := zlib.NewWriter(&stream)
+	zw.Write([]byte("q Q"))
+	zw.Close()
+	for i := 0; i < maxPDFFlateStreams+1; i++ {
+		fmt.Fprintf(&pdf, "%d 0 obj\n<< /Filter /FlateDecode >>\nstream\n%s\nendstream\nendobj\n", i+1, stream.Bytes())
+	}
+	pdf.WriteString("0 0 obj\n<< >>\nstream\nBT (x) Tj ET\nendstream\nendobj\n")
+	if info := inspectPDF(pdf.Bytes()); info.InflatedStreams != maxPDFFlateStreams || info.HasText {
+		t.Errorf("Expected inflating to stop at %d streams, got %+v", maxPDFFlateStreams, info)
+	}
+}
+
+func TestImagePixels(t *testing.T) {
+	// The HEIC fixture carries no item properties, so its size is unknown
+	for _, fixture := range []string{"photo.jpg", "photo.png", "photo.gif", "photo.bmp", "photo.webp", "scan.tiff"} {
+		detection, _ := detectDocumentContent(readFixture(t, fixture))
+		if detection.Pixels <= 0 || detection.Metadata["width"] == "" {
+			t.Errorf("%s: expected the image size to be read, got %+v", fixture, detection)
+		}
+	}
+
+	width, height, pixels, ok := imagePixels(readHostileFixture(t, "pixel-bomb.png"), ImageFormatPNG)
+	if !ok || width != 60000 || height != 60000 || pixels != 3_600_000_000 {
+		t.Errorf("Unexpected PNG size: %dx%d (%d, %v)", width, height, pixels, ok)
+	}
+}
+
+func TestProcessDocumentTypeSizeLimit(t *testing.T) {
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+	limits := processor.UploadLimits()
+	limits.MaxFileBytes[models.DocumentTypeJSON] = 1024
+	processor.SetUploadLimits(limits)
+
+	large := `[{"date":"2025-04-08T00:00:00Z","main_dish":"` + strings.Repeat("カレー", 200) + `"}]`
+	if _, err := processor.ProcessDocument(batchRequest("menu.json", []byte(large))); !errors.Is(err, ErrDocumentTooLarge) {
+		t.Errorf("Expected JSON over its limit to be refused, got %v", err)
+	}
+	small := `[{"date":"2025-04-08T00:00:00Z","main_dish":"カレーライス"}]`
+	if _, err := processor.ProcessDocument(batchRequest("menu.json", []byte(small))); err != nil {
+		t.Errorf("Expected JSON within its limit to be processed, got %v", err)
+	}
+}
+
+func TestProcessBatchRejectsZipBomb(t *testing.T) {
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+	bomb := readHostileFixture(t, "zip-bomb.zip")
+
+	// The inflating entry fails on its own; the rest of the archive is processed
+	result := processor.ProcessBatch([]*models.DocumentProcessingRequest{batchRequest("bomb.zip", bomb)})
+	if result.Total != 2 || result.Files[0].Success || result.Files[1].Success != true {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+	if !strings.Contains(result.Files[0].Error, ErrDocumentTooLarge.Error()) {
+		t.Errorf("Expected the bomb to be refused for its size, got %q", result.Files[0].Error)
+	}
+
+	// An archive that expands beyond the total limit is refused as a whole
+	limits := processor.UploadLimits()
+	limits.MaxCompressionRatio = 0
+	limits.MaxArchiveBytes = 1 << 20
+	processor.SetUploadLimits(limits)
+	result = processor.ProcessBatch([]*models.DocumentProcessingRequest{batchRequest("bomb.zip", bomb)})
+	if result.Total != 1 || result.Files[0].Filename != "bomb.zip" || !strings.Contains(result.Files[0].Error, "expands to more than") {
+		t.Errorf("Expected the archive to be refused, got %+v", result)
+	}
+
+	// So is one with too many files
+	files := map[string]string{}
+	var names []string
+	for _, n := range []string{"a.json", "b.json", "c.json"} {
+		files[n] = "[]"
+		names = append(names, n)
+	}
+	limits.MaxArchiveEntries = 2
+	processor.SetUploadLimits(limits)
+	result = processor.ProcessBatch([]*models.DocumentProcessingRequest{batchRequest("many.zip", zipArchive(t, files, names...))})
+	if result.Total != 1 || !strings.Contains(result.Files[0].Error, "more than 2 files") {
+		t.Errorf("Expected the archive to be refused, got %+v", result)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"献立表.pdf", "献立表.pdf"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\mama\Desktop\menu.json`, "menu.json"},
+		{"menu\x00.json", "menu.json"},
+		{"invoice\u202egnp.exe", "invoicegnp.exe"}, // Right-to-left override
+		{"line\r\nbreak.csv", "linebreak.csv"},
+		{"  ..hidden.. ", "hidden"},
+		{"../", "document"},
+		{"", "document"},
+		{"bad\xffutf8.json", "badutf8.json"},
+		{strings.Repeat("あ", 100) + ".pdf", strings.Repeat("あ", 65) + ".pdf"},
+	}
+	for _, test := range tests {
+		if got := sanitizeFilename(test.name); got != test.expected {
+			t.Errorf("sanitizeFilename(%q) = %q, expected %q", test.name, got, test.expected)
+		}
+	}
+
+	processor := NewDocumentProcessor(NewMenuAdvisorService())
+	doc, _ := processor.ProcessDocument(&models.DocumentProcessingRequest{
+		File:   newMockFile(`[]`),
+		Header: &multipart.FileHeader{Filename: "../../../../tmp/<script>.json"},
+	})
+	if doc.OriginalName != "<script>.json" {
+		t.Errorf("Expected the stored name to be sanitized, got %q", doc.OriginalName)
+	}
+}
+
+func TestQuotaTracker(t *testing.T) {
+	now := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	quota := NewQuotaTracker(models.UploadQuota{MaxDocuments: 3, MaxBytes: 1000, Window: time.Hour})
+	quota.now = func() time.Time { return now }
+
+	if _, err := quota.Reserve("tanaka", 2, 600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := quota.Reserve("tanaka", 2, 100); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected the document quota to be exceeded, got %v", err)
+	}
+	if _, err := quota.Reserve("tanaka", 1, 500); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected the byte quota to be exceeded, got %v", err)
+	}
+	// Refused uploads are not counted
+	if usage := quota.Usage("tanaka"); usage.Documents != 2 || usage.Bytes != 600 {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+	// Households are counted separately
+	if _, err := quota.Reserve("suzuki", 3, 1000); err != nil {
+		t.Errorf("Expected another household's quota to be separate, got %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	usage, err := quota.Reserve("tanaka", 3, 900)
+	if err != nil || usage.Documents != 3 || !usage.ResetAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected a new window, got %+v (%v)", usage, err)
+	}
+	// Ended windows are forgotten
+	if _, ok := quota.usage["suzuki"]; ok || len(quota.usage) != 1 {
+		t.Errorf("Expected ended windows to be evicted, got %v", quota.usage)
+	}
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Limits on reading a workbook. Rows are padded out to the column of each
+// cell, so the column and cell counts bound the memory a small sheet can
+// expand into.
+const (
+	maxXLSXPartSize = 32 << 20 // Bytes decompressed from one workbook part
+	maxXLSXColumns  = 256      // Columns of a sheet (A to IV)
+	maxXLSXCells    = 1 << 20  // Cells of a sheet, counting the padding
+)
+
+// Parts of the SpreadsheetML package read by readXLSXRows
+type (
+	xlsxWorkbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	xlsxRelationships struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	// xlsxRichText is a shared or inline string. Phonetic guides (rPh) are
+	// deliberately not decoded so furigana does not leak into dish names.
+	xlsxRichText struct {
+		T    string `xml:"t"`
+		Runs []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	}
+	xlsxSharedStrings struct {
+		Items []xlsxRichText `xml:"si"`
+	}
+	xlsxWorksheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string        `xml:"r,attr"`
+				Type   string        `xml:"t,attr"`
+				Value  string        `xml:"v"`
+				Inline *xlsxRichText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+)
+
+func (t xlsxRichText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	b.WriteString(t.T)
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+// isXLSX reports whether data is a zip package containing an Excel workbook
+func isXLSX(data []byte) bool {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return false
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return false
+	}
+	for _, f := range zr.File {
+		if f.Name == "xl/workbook.xml" {
+			return true
+		}
+	}
+	return false
+}
+
+// readXLSXRows returns the cell values of the first worksheet of an Excel
+// workbook as text. Numbers, including dates, are returned as stored, so a
+// date cell holds its serial number.
+func readXLSXRows(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an Excel workbook: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeXLSXPart(f, &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("worksheet %s is missing", sheetPath)
+	}
+	var sheet xlsxWorksheet
+	if err := decodeXLSXPart(f, &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	cells := 0
+	for _, r := range sheet.Rows {
+		var row []string
+		for _, c := range r.Cells {
+			col := len(row)
+			if c.Ref != "" {
+				if i, ok := xlsxColumnIndex(c.Ref); ok {
+					col = i
+				}
+			}
+			if col >= maxXLSXColumns {
+				return nil, fmt.Errorf("cell %s is beyond the %d columns a sheet may have", c.Ref, maxXLSXColumns)
+			}
+			if col >= len(row) {
+				if cells += col + 1 - len(row); cells > maxXLSXCells {
+					return nil, fmt.Errorf("sheet has more than %d cells", maxXLSXCells)
+				}
+				row = append(row, make([]string, col+1-len(row))...)
+			}
+
+			value := c.Value
+			switch c.Type {
+			case "s":
+				i, err := strconv.Atoi(c.Value)
+				if err != nil || i < 0 || i >= len(shared.Items) {
+					return nil, fmt.Errorf("cell %s refers to a missing shared string", c.Ref)
+				}
+				value = shared.Items[i].String()
+			case "inlineStr":
+				if c.Inline != nil {
+					value = c.Inline.String()
+				}
+			}
+			row[col] = value
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// firstSheetPath resolves the file of the first worksheet listed in the workbook
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var wb xlsxWorkbook
+	if err := decodeXLSXPart(files["xl/workbook.xml"], &wb); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", fmt.Errorf("workbook has no sheets")
+	}
+
+	var rels xlsxRelationships
+	if f, ok := files["xl/_rels/workbook.xml.rels"]; ok {
+		if err := decodeXLSXPart(f, &rels); err != nil {
+			return "", err
+		}
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != wb.Sheets[0].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "xl/worksheets/sheet1.xml", nil
+}
+
+func decodeXLSXPart(f *zip.File, v interface{}) error {
+	if f == nil {
+		return fmt.Errorf("workbook part is missing")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, maxXLSXPartSize)).Decode(v); err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// xlsxColumnIndex converts the column letters of a cell reference such as
+// "AB12" into a 0-based index
+func xlsxColumnIndex(ref string) (int, bool) {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return 0, false
+	}
+	return col - 1, true
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readTableFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "table", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture %s: %v", name, err)
+	}
+	return data
+}
+
+func TestReadXLSXRows(t *testing.T) {
+	rows, err := readXLSXRows(readTableFixture(t, "menu.xlsx"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := [][]string{
+		{"2025年1月 献立表"},
+		{"日付", "主食", "おかず", "汁物", "エネルギー"},
+		{"45671", "ごはん 牛乳", "鶏肉の照り焼き", "みそ汁", "650"},
+		// The phonetic guide of さばの味噌煮 is dropped and the missing soup cell kept empty
+		{"45672.5", "ごはん 牛乳", "さばの味噌煮", "", "620"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Unexpected rows:\n got %q\nwant %q", rows, expected)
+	}
+}
+
+func TestReadXLSXRowsRejectsOtherFiles(t *testing.T) {
+	if _, err := readXLSXRows([]byte("日付,おかず\n")); err == nil {
+		t.Error("Expected an error for a file that is not a workbook")
+	}
+	if isXLSX(readTableFixture(t, "menu.csv")) {
+		t.Error("Expected CSV not to be taken for a workbook")
+	}
+	if !isXLSX(readTableFixture(t, "menu.xlsx")) {
+		t.Error("Expected workbook to be recognized")
+	}
+}
+
+// buildXLSX packs a workbook whose first sheet holds the given rows
+func buildXLSX(t *testing.T, rows string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"xl/workbook.xml":          `<workbook><sheets><sheet name="献立"/></sheets></workbook>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` + rows + `</sheetData></worksheet>`,
+	} {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to build workbook: %v", err)
+		}
+		fw.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestReadXLSXRowsBoundsSheetSize(t *testing.T) {
+	rows, err := readXLSXRows(buildXLSX(t, `<row><c r="IV1"><v>1</v></c></row>`))
+	if err != nil || len(rows[0]) != maxXLSXColumns || rows[0][maxXLSXColumns-1] != "1" {
+		t.Errorf("Expected the last column to be read, got %d cells (%v)", len(rows), err)
+	}
+
+	// A cell far to the right would pad its row with thousands of cells
+	if _, err := readXLSXRows(buildXLSX(t, `<row><c r="ZZZ1"><v>1</v></c></row>`)); err == nil {
+		t.Error("Expected a cell beyond the last column to be rejected")
+	}
+
+	// Each row is small, but together they pad out to too many cells
+	var many strings.Builder
+	for i := 1; i <= maxXLSXCells/maxXLSXColumns+1; i++ {
+		fmt.Fprintf(&many, `<row><c r="IV%d"/></row>`, i)
+	}
+	if _, err := readXLSXRows(buildXLSX(t, many.String())); err == nil || !strings.Contains(err.Error(), "cells") {
+		t.Errorf("Expected too many cells to be rejected, got %v", err)
+	}
+}
+
+func TestXLSXColumnIndex(t *testing.T) {
+	tests := map[string]int{"A1": 0, "E12": 4, "Z3": 25, "AA1": 26, "AB12": 27}
+	for ref, expected := range tests {
+		if i, ok := xlsxColumnIndex(ref); !ok || i != expected {
+			t.Errorf("%s: expected %d, got %d (%v)", ref, expected, i, ok)
+		}
+	}
+	if _, ok := xlsxColumnIndex("12"); ok {
+		t.Error("Expected reference without letters to be rejected")
+	}
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// DishesHandler serves GET /api/dishes, the canonical dish dictionary
+func (h *Handler) DishesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.menuService.Dishes().Dishes())
+}
+
+// DishMatchHandler serves GET /api/dishes/match?name=..., the canonical dish
+// a printed dish name is recognized as
+func (h *Handler) DishMatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeServiceError(w, r, models.ValidationErrors{{Field: "name", Message: "required"}}, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.menuService.Dishes().Match(name))
+}
+
+// RecipesHandler serves GET /api/recipes, every recipe for one serving
+func (h *Handler) RecipesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.menuService.Recipes().Recipes())
+}
+
+// RecipeHandler serves GET /api/recipes/{dish}?servings=N, the recipe of a
+// dish given by its ID or name, for the household unless servings is given
+func (h *Handler) RecipeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	var verrs models.ValidationErrors
+	servings := queryInt(r, "servings", &verrs)
+	if err := verrs.Err(); err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	recipe, err := h.menuService.Recipe(r.PathValue("dish"), servings)
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, recipe)
+}
@@ -0,0 +1,20 @@
+package web
+
+import (
+	"net/http"
+)
+
+// DocumentTraceHandler serves GET /api/documents/{id}/trace, what each
+// pipeline stage produced for an uploaded document and how long it took
+func (h *Handler) DocumentTraceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	trace, err := h.documentProcessor.GetTrace(r.PathValue("id"))
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, trace)
+}
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+	"github.com/habuka036/menu-advisor/internal/service"
+)
+
+// Error codes used in the JSON error envelope
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeUnsupportedDocument  = "unsupported_document"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeQuotaExceeded        = "quota_exceeded"
+	CodeProcessingFailed     = "processing_failed"
+	CodeInternal             = "internal_error"
+)
+
+// RequestIDHeader is the header used to propagate request IDs
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// APIError is the error body returned by every API endpoint
+type APIError struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id"`
+}
+
+// errorResponse wraps APIError so that clients can always read response.error
+type errorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// WithRequestID assigns a request ID to each request, reusing the client's
+// X-Request-ID header when present, and echoes it in the response
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the ID assigned by WithRequestID, generating one for
+// handlers that are invoked without the middleware
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		return id
+	}
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" {
+		id = newRequestID()
+	}
+	w.Header().Set(RequestIDHeader, id)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// writeError writes a JSON error envelope with the given status
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string, details interface{}) {
+	resp := errorResponse{Error: APIError{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: requestID(w, r),
+	}}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeMethodNotAllowed reports an unsupported HTTP method
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	for _, m := range allowed {
+		w.Header().Add("Allow", m)
+	}
+	writeError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed", nil)
+}
+
+// writeServiceError maps errors returned by the service layer onto status codes
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error, details interface{}) {
+	var verrs models.ValidationErrors
+	switch {
+	case errors.As(err, &verrs):
+		writeError(w, r, http.StatusBadRequest, CodeValidationFailed, err.Error(), verrs)
+	case errors.Is(err, service.ErrSchoolLunchNotFound), errors.Is(err, service.ErrVersionNotFound),
+		errors.Is(err, service.ErrReviewNotFound), errors.Is(err, service.ErrImageUnavailable),
+		errors.Is(err, service.ErrFetchSourceNotFound), errors.Is(err, service.ErrDocumentNotFound),
+		errors.Is(err, service.ErrChildNotFound), errors.Is(err, service.ErrHomeMealNotFound),
+		errors.Is(err, service.ErrRecipeNotFound), errors.Is(err, service.ErrPantryItemNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, err.Error(), details)
+	case errors.Is(err, service.ErrSchoolLunchExists), errors.Is(err, service.ErrReviewClosed):
+		writeError(w, r, http.StatusConflict, CodeConflict, err.Error(), details)
+	case errors.Is(err, service.ErrPreconditionFailed):
+		writeError(w, r, http.StatusPreconditionFailed, CodePreconditionFailed, err.Error(), details)
+	case errors.Is(err, service.ErrPreconditionRequired):
+		writeError(w, r, http.StatusPreconditionRequired, CodePreconditionRequired, err.Error(), details)
+	case errors.Is(err, service.ErrUnsupportedDocumentType):
+		writeError(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedDocument, err.Error(), details)
+	case errors.Is(err, service.ErrDocumentTooLarge):
+		writeError(w, r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, err.Error(), details)
+	case errors.Is(err, service.ErrQuotaExceeded):
+		writeError(w, r, http.StatusTooManyRequests, CodeQuotaExceeded, err.Error(), details)
+	default:
+		writeError(w, r, http.StatusInternalServerError, CodeInternal, err.Error(), details)
+	}
+}
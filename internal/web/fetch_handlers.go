@@ -0,0 +1,30 @@
+package web
+
+import (
+	"net/http"
+)
+
+// FetchSourcesHandler serves GET /api/fetch-sources, the configured menu URLs
+// and the result of their last checks
+func (h *Handler) FetchSourcesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.fetchScheduler.Status())
+}
+
+// FetchSourceFetchHandler serves POST /api/fetch-sources/{name}/fetch, which
+// checks a source immediately instead of waiting for the next scheduled run
+func (h *Handler) FetchSourceFetchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	status, err := h.fetchScheduler.Fetch(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
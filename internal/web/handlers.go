@@ -2,9 +2,12 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"html/template"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/habuka036/menu-advisor/internal/models"
@@ -15,6 +18,10 @@ import (
 type Handler struct {
 	menuService       *service.MenuAdvisorService
 	documentProcessor *service.DocumentProcessor
+	fetchScheduler    *service.FetchScheduler
+	uploadQuota       *service.QuotaTracker
+	uploadClients     uploadClients
+	idempotency       *idempotencyStore
 	templates         *template.Template
 }
 
@@ -27,17 +34,31 @@ func NewHandler(menuService *service.MenuAdvisorService) *Handler {
 		tmpl = template.New("base")
 	}
 
+	documentProcessor := service.NewDocumentProcessor(menuService)
 	return &Handler{
 		menuService:       menuService,
-		documentProcessor: service.NewDocumentProcessor(menuService),
+		documentProcessor: documentProcessor,
+		fetchScheduler:    service.NewFetchScheduler(documentProcessor, nil),
+		uploadQuota:       service.NewQuotaTracker(service.DefaultUploadQuota()),
+		idempotency:       newIdempotencyStore(),
 		templates:         tmpl,
 	}
 }
 
+// DocumentProcessor returns the processor that handles uploaded documents
+func (h *Handler) DocumentProcessor() *service.DocumentProcessor {
+	return h.documentProcessor
+}
+
+// FetchScheduler returns the scheduler that downloads menus from configured URLs
+func (h *Handler) FetchScheduler() *service.FetchScheduler {
+	return h.fetchScheduler
+}
+
 // HomeHandler serves the main page
 func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, r, http.MethodGet)
 		return
 	}
 
@@ -86,8 +107,8 @@ func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
                 <div class="side-dishes">🥬 副菜: %s</div>
                 <div class="soup">🍲 汁物: %s</div>
             </div>
-        `, lunch.Date.Format("2006年01月02日"), lunch.MainDish, 
-		   joinSlice(lunch.SideDishes), lunch.Soup)
+        `, lunch.Date.Format("2006年01月02日"), html.EscapeString(lunch.MainDish), 
+		   html.EscapeString(joinSlice(lunch.SideDishes)), html.EscapeString(lunch.Soup))
 	}
 
 	htmlResponse += `
@@ -95,12 +116,21 @@ func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
         
         <div class="form-section">
             <h2>給食メニュー文書のアップロード</h2>
-            <p>PDF、画像ファイル、JSONファイルから給食メニューを読み込むことができます。</p>
+            <p>PDF、画像ファイル、Excel・CSV、JSONファイルから給食メニューを読み込むことができます。複数のファイルやzipファイルもまとめてアップロードできます。</p>
             <form id="uploadForm" enctype="multipart/form-data">
-                <input type="file" id="document" name="document" accept=".pdf,.jpg,.jpeg,.png,.json" required>
+                <input type="file" id="document" name="document" accept=".pdf,.jpg,.jpeg,.png,.heic,.heif,.webp,.tif,.tiff,.csv,.xlsx,.html,.htm,.json,.zip" multiple required>
+                <select id="mergePolicy" name="merge_policy">
+                    <option value="replace">既存の日を上書き</option>
+                    <option value="keep_existing">既存の日を残す</option>
+                    <option value="merge_fields">空欄のみ補完</option>
+                    <option value="newer_document_wins">新しい文書を優先</option>
+                </select>
+                <input type="month" id="targetMonth" name="target_month" title="年の記載がない献立表の年月">
+                <label><input type="checkbox" id="dryRun" name="dry_run" value="true"> 確認のみ (反映しない)</label>
                 <button type="submit">文書をアップロード</button>
             </form>
             <div id="uploadResult"></div>
+            <p><small><a href="/review">読み取り結果の確認待ち一覧</a></small></p>
         </div>
         
         <div class="form-section">
@@ -120,20 +150,33 @@ func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
     </div>
 
     <script>
+        function escapeHTML(s) {
+            return String(s ?? '').replace(/[&<>"']/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'}[c]));
+        }
+
         // Handle document upload form
         document.getElementById('uploadForm').addEventListener('submit', async function(e) {
             e.preventDefault();
             
             const fileInput = document.getElementById('document');
-            const file = fileInput.files[0];
             
-            if (!file) {
+            if (fileInput.files.length === 0) {
                 alert('ファイルを選択してください');
                 return;
             }
             
             const formData = new FormData();
-            formData.append('document', file);
+            for (const file of fileInput.files) {
+                formData.append('document', file);
+            }
+            formData.append('merge_policy', document.getElementById('mergePolicy').value);
+            formData.append('dry_run', document.getElementById('dryRun').checked ? 'true' : 'false');
+            const targetMonth = document.getElementById('targetMonth').value;
+            if (targetMonth) {
+                const [year, month] = targetMonth.split('-');
+                formData.append('year', year);
+                formData.append('month', String(parseInt(month, 10)));
+            }
             
             try {
                 document.getElementById('uploadResult').innerHTML = '<p>アップロード中...</p>';
@@ -144,26 +187,42 @@ func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
                 });
                 
                 const data = await response.json();
+                const batch = data.result && data.result.files ? data.result : (data.error && data.error.details && data.error.details.files ? data.error.details : null);
                 
-                if (data.success) {
+                if (batch) {
+                    const files = batch.files.map(f => ` + "`" + `<li>${f.archive ? escapeHTML(f.archive) + ' / ' : ''}${escapeHTML(f.filename)}: ${f.success ? '✅ ' + escapeHTML(f.document.status) : '❌ ' + escapeHTML(f.error)}</li>` + "`" + `).join('');
+                    document.getElementById('uploadResult').innerHTML = ` + "`" + `
+                        <div class="suggestion">
+                            <h3>${batch.success ? '✅' : '⚠️'} ${batch.succeeded} / ${batch.total} 件を処理しました</h3>
+                            <ul>${files}</ul>
+                        </div>
+                    ` + "`" + `;
+                } else if (response.ok && data.success) {
+                    const report = data.result.merge_report;
+                    const statusLabels = {added: '追加', updated: '更新', unchanged: '変更なし', conflict: '競合', partial: '一部更新 (残りは競合)', needs_review: '要確認', out_of_range: '対象期間外', invalid: '不正'};
+                    const days = report ? report.days.map(d => ` + "`" + `<li>${escapeHTML(d.date)}: ${statusLabels[d.status]}${d.message ? ' (' + escapeHTML(d.message) + ')' : ''}</li>` + "`" + `).join('') : '';
                     document.getElementById('uploadResult').innerHTML = ` + "`" + `
                         <div class="suggestion">
                             <h3>✅ アップロード成功</h3>
                             <p>${data.message}</p>
                             <p><small>文書ID: ${data.result.id}</small></p>
                             <p><small>処理状況: ${data.result.status}</small></p>
+                            <ul>${days}</ul>
+                            ${data.result.review_ids ? '<p><a href="/review">読み取り結果を確認する</a></p>' : ''}
                         </div>
                     ` + "`" + `;
                     
                     // Reload the page to show new menu data
-                    setTimeout(() => {
-                        window.location.reload();
-                    }, 2000);
+                    if ((!report || !report.dry_run) && !data.result.review_ids) {
+                        setTimeout(() => {
+                            window.location.reload();
+                        }, 2000);
+                    }
                 } else {
                     document.getElementById('uploadResult').innerHTML = ` + "`" + `
                         <div style="color: red; background: #ffebee; padding: 10px; border-radius: 5px;">
                             <h3>❌ アップロードエラー</h3>
-                            <p>${data.error}</p>
+                            <p>${escapeHTML(data.error.message)}</p>
                         </div>
                     ` + "`" + `;
                 }
@@ -196,18 +255,18 @@ func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
                     document.getElementById('result').innerHTML = ` + "`" + `
                         <div class="suggestion">
                             <h3>🌟 ${data.meal_type === 'breakfast' ? '朝食' : '夕食'}の提案</h3>
-                            <p><strong>メイン:</strong> ${data.main_dish}</p>
-                            <p><strong>副菜:</strong> ${data.side_dishes.join(', ')}</p>
-                            ${data.soup ? ` + "`<p><strong>汁物:</strong> ${data.soup}</p>`" + ` : ''}
-                            <p><strong>理由:</strong> ${data.reason}</p>
-                            <p><small>参考給食: ${data.school_lunch_ref}</small></p>
+                            <p><strong>メイン:</strong> ${escapeHTML(data.main_dish)}</p>
+                            <p><strong>副菜:</strong> ${escapeHTML(data.side_dishes.join(', '))}</p>
+                            ${data.soup ? ` + "`<p><strong>汁物:</strong> ${escapeHTML(data.soup)}</p>`" + ` : ''}
+                            <p><strong>理由:</strong> ${escapeHTML(data.reason)}</p>
+                            <p><small>参考給食: ${escapeHTML(data.school_lunch_ref)}</small></p>
                         </div>
                     ` + "`" + `;
                 } else {
-                    document.getElementById('result').innerHTML = ` + "`" + `<div style="color: red;">エラー: ${data.error}</div>` + "`" + `;
+                    document.getElementById('result').innerHTML = ` + "`" + `<div style="color: red;">エラー: ${escapeHTML(data.error.message)}</div>` + "`" + `;
                 }
             } catch (error) {
-                document.getElementById('result').innerHTML = ` + "`" + `<div style="color: red;">エラーが発生しました: ${error.message}</div>` + "`" + `;
+                document.getElementById('result').innerHTML = ` + "`" + `<div style="color: red;">エラーが発生しました: ${escapeHTML(error.message)}</div>` + "`" + `;
             }
         });
     </script>
@@ -222,29 +281,42 @@ func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
 // SuggestHandler provides menu suggestions via API
 func (h *Handler) SuggestHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, r, http.MethodGet)
 		return
 	}
 
 	dateStr := r.URL.Query().Get("date")
-	mealType := r.URL.Query().Get("meal_type")
+	mealTypeStr := r.URL.Query().Get("meal_type")
 
-	if dateStr == "" || mealType == "" {
-		http.Error(w, "Missing required parameters: date and meal_type", http.StatusBadRequest)
-		return
+	var verrs models.ValidationErrors
+	var date time.Time
+	var mealType models.MealType
+	if dateStr == "" {
+		verrs.Add("date", "required")
+	} else if d, err := time.Parse("2006-01-02", dateStr); err != nil {
+		verrs.Add("date", "invalid date format, use YYYY-MM-DD")
+	} else {
+		date = d
 	}
-
-	date, err := time.Parse("2006-01-02", dateStr)
+	if mealTypeStr == "" {
+		verrs.Add("meal_type", "required")
+	} else if m, err := models.ParseMealType(mealTypeStr); err != nil {
+		verrs.Merge("meal_type", err)
+	} else {
+		mealType = m
+	}
+	grade, err := models.ParseGradeBand(r.URL.Query().Get("grade"))
 	if err != nil {
-		http.Error(w, "Invalid date format. Use YYYY-MM-DD", http.StatusBadRequest)
+		verrs.Merge("grade", err)
+	}
+	if err := verrs.Err(); err != nil {
+		writeServiceError(w, r, err, nil)
 		return
 	}
 
-	suggestion, err := h.menuService.GenerateHomeMenuSuggestion(date, mealType)
+	suggestion, err := h.menuService.GenerateHomeMenuSuggestionWithOptions(date, mealType, service.SuggestionOptions{Grade: grade, ChildID: r.URL.Query().Get("child_id")})
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		writeServiceError(w, r, err, map[string]string{"date": dateStr})
 		return
 	}
 
@@ -253,10 +325,14 @@ func (h *Handler) SuggestHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(suggestion)
 }
 
-// SchoolLunchHandler returns school lunch data
+// SchoolLunchHandler returns school lunch data and creates new menus
 func (h *Handler) SchoolLunchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		h.createSchoolLunch(w, r)
+		return
+	}
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
 		return
 	}
 
@@ -284,50 +360,209 @@ func joinSlice(slice []string) string {
 // UploadHandler handles document uploads
 func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, r, http.MethodPost)
 		return
 	}
+	h.idempotent(w, r, h.upload)
+}
 
+// upload processes the documents of an upload form
+func (h *Handler) upload(w http.ResponseWriter, r *http.Request) {
 	// Parse multipart form
-	err := r.ParseMultipartForm(32 << 20) // 32MB max
-	if err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+	if !h.parseUploadForm(w, r) {
 		return
 	}
 
-	// Get uploaded file
-	file, header, err := r.FormFile("document")
-	if err != nil {
-		http.Error(w, "Failed to get uploaded file", http.StatusBadRequest)
+	// Get uploaded files
+	headers := r.MultipartForm.File["document"]
+	if len(headers) == 0 {
+		writeError(w, r, http.StatusBadRequest, CodeValidationFailed, "Failed to get uploaded file",
+			models.ValidationErrors{{Field: "document", Message: "required"}})
 		return
 	}
-	defer file.Close()
 
-	// Create processing request
-	req := &models.DocumentProcessingRequest{
-		File:   file,
-		Header: header,
+	// Create processing requests sharing the form's options
+	actor := h.changeContext(r)
+	options := models.DocumentProcessingRequest{Actor: actor.Actor, ActorLabel: actor.ActorLabel}
+	if err := parseUploadOptions(r, &options); err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	reqs := make([]*models.DocumentProcessingRequest, 0, len(headers))
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Failed to read uploaded file", nil)
+			return
+		}
+		defer file.Close()
+		req := options
+		req.File, req.Header = file, header
+		reqs = append(reqs, &req)
+	}
+	if !h.reserveUploadQuota(w, r, reqs) {
+		return
 	}
 
+	// Several files, or an archive of them, are processed as a batch
+	if archive, _ := service.IsZipArchive(reqs[0].File); len(reqs) > 1 || archive {
+		h.writeBatchResult(w, r, h.documentProcessor.ProcessBatch(reqs))
+		return
+	}
+	req := reqs[0]
+
 	// Process document
 	result, err := h.documentProcessor.ProcessDocument(req)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-			"result":  result,
-		})
+		if errors.Is(err, service.ErrUnsupportedDocumentType) || errors.Is(err, service.ErrDocumentTooLarge) {
+			writeServiceError(w, r, err, result)
+			return
+		}
+		writeError(w, r, http.StatusUnprocessableEntity, CodeProcessingFailed, err.Error(), result)
 		return
 	}
 
 	// Return success response
+	message := "Document processed successfully"
+	if req.DryRun {
+		message = "Document parsed; no menus were changed (dry run)"
+	} else if result.Duplicate {
+		message = "Document was already processed; returning the earlier result"
+	}
+	if v := result.Validation; v != nil && !result.Duplicate {
+		message += fmt.Sprintf(" (validation found %d warning(s) and %d error(s))", len(v.Warnings), len(v.Errors))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"message": "Document processed successfully",
+		"message": message,
 		"result":  result,
 	})
+}
+
+// writeBatchResult reports a batch upload: 200 when every file was
+// processed, 207 when some failed and 422 when none could be processed
+func (h *Handler) writeBatchResult(w http.ResponseWriter, r *http.Request, result *models.BatchUploadResult) {
+	message := fmt.Sprintf("%d of %d documents processed", result.Succeeded, result.Total)
+	if result.Succeeded == 0 {
+		writeError(w, r, http.StatusUnprocessableEntity, CodeProcessingFailed, "No document could be processed", result)
+		return
+	}
+	status := http.StatusOK
+	if !result.Success {
+		status = http.StatusMultiStatus
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"success": result.Success,
+		"message": message,
+		"result":  result,
+	})
+}
+
+// UploadPreviewHandler shows how a CSV or Excel upload would be mapped onto
+// menus without importing it
+func (h *Handler) UploadPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	if !h.parseUploadForm(w, r) {
+		return
+	}
+	file, header, err := r.FormFile("document")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeValidationFailed, "Failed to get uploaded file",
+			models.ValidationErrors{{Field: "document", Message: "required"}})
+		return
+	}
+	defer file.Close()
+
+	req := &models.DocumentProcessingRequest{File: file, Header: header}
+	if err := parseUploadOptions(r, req); err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+
+	preview, err := h.documentProcessor.PreviewTable(req)
+	if err != nil {
+		if errors.Is(err, service.ErrUnsupportedDocumentType) || errors.Is(err, service.ErrDocumentTooLarge) {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		writeError(w, r, http.StatusUnprocessableEntity, CodeProcessingFailed, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, preview)
+}
+
+// parseUploadOptions reads the optional merge settings of an upload form
+func parseUploadOptions(r *http.Request, req *models.DocumentProcessingRequest) error {
+	var verrs models.ValidationErrors
+
+	policy, err := models.ParseMergePolicy(r.FormValue("merge_policy"))
+	if err != nil {
+		verrs.Merge("merge_policy", err)
+	}
+	req.MergePolicy = policy
+
+	if v := r.FormValue("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			verrs.Add("dry_run", "must be true or false")
+		}
+		req.DryRun = dryRun
+	}
+
+	req.DateFrom = formDate(r, "date_from", &verrs)
+	req.DateTo = formDate(r, "date_to", &verrs)
+	if req.DateFrom != nil && req.DateTo != nil && req.DateTo.Before(*req.DateFrom) {
+		verrs.Add("date_to", "must not be before date_from")
+	}
+
+	if v := r.FormValue("year"); v != "" {
+		year, err := strconv.Atoi(v)
+		if err != nil || year < 2000 || year > 2100 {
+			verrs.Add("year", "must be a four-digit year")
+		}
+		req.Year = year
+	}
+	if v := r.FormValue("month"); v != "" {
+		month, err := strconv.Atoi(v)
+		if err != nil || month < 1 || month > 12 {
+			verrs.Add("month", "must be between 1 and 12")
+		}
+		req.Month = time.Month(month)
+	}
+
+	req.IssuedAt = formDate(r, "issued_at", &verrs)
+
+	req.HTMLProfile = r.FormValue("html_profile")
+
+	if v := r.FormValue("column_mapping"); v != "" {
+		var mapping models.ColumnMapping
+		if err := json.Unmarshal([]byte(v), &mapping); err != nil {
+			verrs.Add("column_mapping", "must be a JSON object of column header to field")
+		} else if err := mapping.Validate(); err != nil {
+			verrs.Merge("column_mapping", err)
+		}
+		req.ColumnMapping = mapping
+	}
+
+	return verrs.Err()
+}
+
+// formDate parses an optional YYYY-MM-DD form value, recording a field error on failure
+func formDate(r *http.Request, name string, verrs *models.ValidationErrors) *time.Time {
+	v := r.FormValue(name)
+	if v == "" {
+		return nil
+	}
+	date, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		verrs.Add(name, "invalid date format, use YYYY-MM-DD")
+		return nil
+	}
+	return &date
 }
\ No newline at end of file
@@ -0,0 +1,1018 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+	"github.com/habuka036/menu-advisor/internal/service"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	menuService := service.NewMenuAdvisorService()
+	menuService.AddSchoolLunchMenu(models.SchoolLunchMenu{
+		Date:       time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC),
+		MainDish:   "鶏肉の照り焼き",
+		SideDishes: []string{"野菜炒め", "白米"},
+	})
+	return NewHandler(menuService)
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) APIError {
+	t.Helper()
+	var resp errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode error envelope: %v", err)
+	}
+	return resp.Error
+}
+
+func TestSuggestHandlerErrors(t *testing.T) {
+	handler := newTestHandler(t)
+
+	tests := []struct {
+		query  string
+		status int
+		code   string
+	}{
+		{"date=2025-01-13&meal_type=lunch", http.StatusBadRequest, CodeValidationFailed},
+		{"meal_type=breakfast", http.StatusBadRequest, CodeValidationFailed},
+		{"date=13-01-2025&meal_type=dinner", http.StatusBadRequest, CodeValidationFailed},
+		{"date=2025-01-20&meal_type=dinner", http.StatusNotFound, CodeNotFound},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/suggest?"+test.query, nil)
+		req.Header.Set(RequestIDHeader, "req-123")
+		rec := httptest.NewRecorder()
+		handler.SuggestHandler(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.query, test.status, rec.Code)
+		}
+		apiErr := decodeError(t, rec)
+		if apiErr.Code != test.code {
+			t.Errorf("%s: expected code %s, got %s", test.query, test.code, apiErr.Code)
+		}
+		if apiErr.RequestID != "req-123" {
+			t.Errorf("%s: expected request ID 'req-123', got '%s'", test.query, apiErr.RequestID)
+		}
+	}
+}
+
+func TestSuggestHandlerSuccess(t *testing.T) {
+	handler := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/suggest?date=2025-01-13&meal_type=dinner", nil)
+	rec := httptest.NewRecorder()
+	handler.SuggestHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var suggestion models.HomeMenuSuggestion
+	if err := json.NewDecoder(rec.Body).Decode(&suggestion); err != nil {
+		t.Fatalf("Failed to decode suggestion: %v", err)
+	}
+	if suggestion.MealType != models.MealTypeDinner {
+		t.Errorf("Expected meal type dinner, got %s", suggestion.MealType)
+	}
+}
+
+func TestMethodNotAllowedUsesEnvelope(t *testing.T) {
+	handler := newTestHandler(t)
+
+	rec := httptest.NewRecorder()
+	WithRequestID(http.HandlerFunc(handler.SchoolLunchHandler)).ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/school-lunches", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status 405, got %d", rec.Code)
+	}
+	apiErr := decodeError(t, rec)
+	if apiErr.Code != CodeMethodNotAllowed {
+		t.Errorf("Expected code %s, got %s", CodeMethodNotAllowed, apiErr.Code)
+	}
+	if apiErr.RequestID == "" || apiErr.RequestID != rec.Header().Get(RequestIDHeader) {
+		t.Errorf("Expected generated request ID to match response header")
+	}
+}
+
+func TestHomeHandlerEscapesMenus(t *testing.T) {
+	menuService := service.NewMenuAdvisorService()
+	menuService.AddSchoolLunchMenu(models.SchoolLunchMenu{
+		Date:       time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC),
+		MainDish:   `<img src=x onerror="alert(1)">`,
+		SideDishes: []string{"<b>白米</b>"},
+		Soup:       "みそ汁 & 漬物",
+	})
+	handler := NewHandler(menuService)
+
+	rec := httptest.NewRecorder()
+	handler.HomeHandler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+	if strings.Contains(body, "<img src=x") || strings.Contains(body, "<b>白米") {
+		t.Errorf("Expected menu names to be escaped, got %s", body)
+	}
+	if !strings.Contains(body, "&lt;img src=x onerror=&#34;alert(1)&#34;&gt;") || !strings.Contains(body, "みそ汁 &amp; 漬物") {
+		t.Errorf("Expected escaped menu names in the page")
+	}
+}
+
+func TestReviewPageLabelsEveryNutrient(t *testing.T) {
+	handler := newTestHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.ReviewPageHandler(rec, httptest.NewRequest(http.MethodGet, "/review", nil))
+	body := rec.Body.String()
+	if strings.Contains(body, "NUTRIENT_LABELS") {
+		t.Fatal("Expected the nutrient labels to be filled in")
+	}
+	for _, n := range models.Nutrients {
+		if !strings.Contains(body, `"nutrition.`+n.Key+`":"`+n.Label+`"`) {
+			t.Errorf("Expected a label for nutrition.%s", n.Key)
+		}
+	}
+}
+
+func newTestMux(handler *Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/school-lunches", handler.SchoolLunchHandler)
+	mux.HandleFunc("/api/school-lunches/{date}", handler.SchoolLunchItemHandler)
+	mux.HandleFunc("/api/audit", handler.AuditHandler)
+	return WithRequestID(mux)
+}
+
+func TestSchoolLunchCRUD(t *testing.T) {
+	mux := newTestMux(newTestHandler(t))
+
+	do := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/school-lunches", `{"date":"2025-01-14T00:00:00Z","main_dish":"魚のフライ"}`, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	etag := rec.Header().Get("ETag")
+
+	rec = do(http.MethodPost, "/api/school-lunches", `{"date":"2025-01-15T00:00:00Z","main_dish":""}`, nil)
+	if rec.Code != http.StatusBadRequest || decodeError(t, rec).Code != CodeValidationFailed {
+		t.Errorf("POST empty main dish: expected 400 validation_failed, got %d", rec.Code)
+	}
+
+	rec = do(http.MethodPost, "/api/school-lunches", `{"date":"2025-01-15T00:00:00Z","main_dish":"`+strings.Repeat("あ", 1<<19)+`"}`, nil)
+	if rec.Code != http.StatusRequestEntityTooLarge || decodeError(t, rec).Code != CodePayloadTooLarge {
+		t.Errorf("POST oversized body: expected 413 payload_too_large, got %d", rec.Code)
+	}
+
+	rec = do(http.MethodPatch, "/api/school-lunches/2025-01-14", `{"soup":"野菜スープ"}`, map[string]string{"If-Match": etag, ActorHeader: "mom"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PATCH: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	patchedETag := rec.Header().Get("ETag")
+	var patched models.SchoolLunchMenu
+	json.NewDecoder(rec.Body).Decode(&patched)
+	if patched.Soup != "野菜スープ" || patched.MainDish != "魚のフライ" {
+		t.Errorf("PATCH: unexpected result %+v", patched)
+	}
+
+	rec = do(http.MethodPut, "/api/school-lunches/2025-01-14", `{"main_dish":"カレーライス"}`, map[string]string{"If-Match": etag})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT with stale ETag: expected 412, got %d", rec.Code)
+	}
+
+	rec = do(http.MethodGet, "/api/audit?date=2025-01-14", "", nil)
+	var audit []models.AuditEntry
+	json.NewDecoder(rec.Body).Decode(&audit)
+	if len(audit) != 2 || audit[1].Actor != "mom" {
+		t.Errorf("Expected create and update audit entries, got %+v", audit)
+	}
+
+	rec = do(http.MethodPut, "/api/school-lunches/2025-01-14", `{"main_dish":"カレーライス"}`, nil)
+	if rec.Code != http.StatusPreconditionRequired || decodeError(t, rec).Code != CodePreconditionRequired {
+		t.Errorf("PUT without If-Match: expected 428 precondition_required, got %d", rec.Code)
+	}
+
+	rec = do(http.MethodDelete, "/api/school-lunches/2025-01-14", "", nil)
+	if rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("DELETE without If-Match: expected 428, got %d", rec.Code)
+	}
+	rec = do(http.MethodDelete, "/api/school-lunches/2025-01-14", "", map[string]string{"If-Match": "W/" + patchedETag})
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE: expected 204, got %d", rec.Code)
+	}
+	rec = do(http.MethodGet, "/api/school-lunches/2025-01-14", "", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET after delete: expected 404, got %d", rec.Code)
+	}
+}
+
+// newUploadRequest builds a multipart upload of a single document with extra form fields
+func newUploadRequest(t *testing.T, path, filename string, content []byte, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	fw, err := mw.CreateFormFile("document", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	fw.Write(content)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadPreviewHandler(t *testing.T) {
+	handler := newTestHandler(t)
+	csv := []byte("実施日,こんだて,エネルギー\n2025-01-14,カレーライス,700\n2025-01-15,,\n")
+
+	rec := httptest.NewRecorder()
+	handler.UploadPreviewHandler(rec, newUploadRequest(t, "/api/upload/preview", "menu.csv", csv,
+		map[string]string{"column_mapping": `{"実施日": "date", "こんだて": "main_dish"}`}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var preview models.TablePreview
+	if err := json.NewDecoder(rec.Body).Decode(&preview); err != nil {
+		t.Fatalf("Failed to decode preview: %v", err)
+	}
+	if len(preview.Menus) != 1 || preview.Menus[0].MainDish != "カレーライス" || preview.Menus[0].Nutrition.Calories != 700 {
+		t.Errorf("Unexpected menus: %+v", preview.Menus)
+	}
+	if len(preview.Skipped) != 1 || preview.Skipped[0].Row != 3 {
+		t.Errorf("Expected the empty day to be skipped, got %+v", preview.Skipped)
+	}
+	if _, err := handler.menuService.GetSchoolLunchForDate(time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("Expected preview not to import the menu")
+	}
+
+	// Unknown fields in the mapping are rejected
+	rec = httptest.NewRecorder()
+	handler.UploadPreviewHandler(rec, newUploadRequest(t, "/api/upload/preview", "menu.csv", csv,
+		map[string]string{"column_mapping": `{"こんだて": "dish"}`}))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	if apiErr := decodeError(t, rec); apiErr.Code != CodeValidationFailed {
+		t.Errorf("Expected %s, got %s", CodeValidationFailed, apiErr.Code)
+	}
+
+	// Only spreadsheets can be previewed
+	rec = httptest.NewRecorder()
+	handler.UploadPreviewHandler(rec, newUploadRequest(t, "/api/upload/preview", "menu.json", []byte(`[]`), nil))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected 415, got %d", rec.Code)
+	}
+}
+
+// newBatchUploadRequest builds a multipart upload of several documents,
+// given as alternating file names and contents
+func newBatchUploadRequest(t *testing.T, files ...string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i := 0; i+1 < len(files); i += 2 {
+		fw, err := mw.CreateFormFile("document", files[i])
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		fw.Write([]byte(files[i+1]))
+	}
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadHandlerBatch(t *testing.T) {
+	handler := newTestHandler(t)
+
+	// A single document keeps the single-document response
+	rec := httptest.NewRecorder()
+	handler.UploadHandler(rec, newBatchUploadRequest(t, "april.json", `[{"date":"2025-04-08T00:00:00Z","main_dish":"カレーライス"}]`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var single struct {
+		Result models.DocumentSource `json:"result"`
+	}
+	json.NewDecoder(rec.Body).Decode(&single)
+	if single.Result.OriginalName != "april.json" || single.Result.Status != "completed" {
+		t.Errorf("Unexpected single result: %+v", single.Result)
+	}
+
+	// One bad file does not abort the others
+	rec = httptest.NewRecorder()
+	handler.UploadHandler(rec, newBatchUploadRequest(t,
+		"may.json", `[{"date":"2025-05-08T00:00:00Z","main_dish":"ハヤシライス"}]`,
+		"broken.json", `[{"date":`,
+		"june.json", `[{"date":"2025-06-10T00:00:00Z","main_dish":"親子丼"}]`))
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("Expected 207, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var batch struct {
+		Success bool                     `json:"success"`
+		Result  models.BatchUploadResult `json:"result"`
+	}
+	json.NewDecoder(rec.Body).Decode(&batch)
+	if batch.Success || batch.Result.Succeeded != 2 || batch.Result.Failed != 1 || batch.Result.Files[1].Success {
+		t.Errorf("Unexpected batch result: %+v", batch)
+	}
+	if _, err := handler.menuService.GetSchoolLunchForDate(time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected the file after the bad one to be imported: %v", err)
+	}
+
+	// Nothing could be processed
+	rec = httptest.NewRecorder()
+	handler.UploadHandler(rec, newBatchUploadRequest(t, "a.txt", "x", "b.txt", "y"))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if apiErr := decodeError(t, rec); apiErr.Code != CodeProcessingFailed {
+		t.Errorf("Unexpected error: %+v", apiErr)
+	}
+
+	// Missing file
+	rec = httptest.NewRecorder()
+	handler.UploadHandler(rec, newBatchUploadRequest(t))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+}
+
+func TestUploadHandlerLimits(t *testing.T) {
+	handler := newTestHandler(t)
+	menu := `[{"date":"2025-04-08T00:00:00Z","main_dish":"カレーライス"}]`
+
+	// The whole body is bounded
+	limits := handler.DocumentProcessor().UploadLimits()
+	limits.MaxBodyBytes = 1024
+	handler.DocumentProcessor().SetUploadLimits(limits)
+	rec := httptest.NewRecorder()
+	handler.UploadHandler(rec, newBatchUploadRequest(t, "big.json", "["+strings.Repeat(" ", 2048)+"]"))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected 413, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if apiErr := decodeError(t, rec); apiErr.Code != CodePayloadTooLarge {
+		t.Errorf("Unexpected error: %+v", apiErr)
+	}
+
+	// So is each document type
+	limits.MaxBodyBytes = 1 << 20
+	limits.MaxFileBytes[models.DocumentTypeJSON] = 64
+	handler.DocumentProcessor().SetUploadLimits(limits)
+	rec = httptest.NewRecorder()
+	handler.UploadHandler(rec, newBatchUploadRequest(t, "menu.json", menu))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected 413, got %d: %s", rec.Code, rec.Body.String())
+	}
+	handler.DocumentProcessor().SetUploadLimits(service.DefaultUploadLimits())
+
+	// Client file names are not trusted
+	rec = httptest.NewRecorder()
+	handler.UploadHandler(rec, newBatchUploadRequest(t, `..\..\windows\menu.json`, menu))
+	var single struct {
+		Result models.DocumentSource `json:"result"`
+	}
+	json.NewDecoder(rec.Body).Decode(&single)
+	if single.Result.OriginalName != "menu.json" {
+		t.Errorf("Expected a sanitized file name, got %q", single.Result.OriginalName)
+	}
+
+	// Each client address has its own quota
+	handler.UploadQuota().SetQuota(models.UploadQuota{MaxDocuments: 2, Window: time.Hour})
+	upload := func(addr string, files ...string) *httptest.ResponseRecorder {
+		req := newBatchUploadRequest(t, files...)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		handler.UploadHandler(rec, req)
+		return rec
+	}
+	if rec := upload("198.51.100.7:4000", "a.json", menu, "b.json", menu); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = upload("198.51.100.7:4001", "c.json", menu)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected 429 with Retry-After, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if apiErr := decodeError(t, rec); apiErr.Code != CodeQuotaExceeded {
+		t.Errorf("Unexpected error: %+v", apiErr)
+	}
+	if rec := upload("198.51.100.8:4000", "c.json", menu); rec.Code != http.StatusOK {
+		t.Errorf("Expected another client to be allowed, got %d", rec.Code)
+	}
+
+	// A household header chosen by the client does not give a fresh quota
+	req := newBatchUploadRequest(t, "c.json", menu)
+	req.RemoteAddr = "198.51.100.7:4002"
+	req.Header.Set(HouseholdHeader, "someone-else")
+	rec = httptest.NewRecorder()
+	handler.UploadHandler(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the client's quota to stay used up, got %d", rec.Code)
+	}
+
+	// Each document of an archive counts
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, name := range []string{"a.json", "b.json", "c.json"} {
+		fw, _ := zw.Create(name)
+		fw.Write([]byte(menu))
+	}
+	zw.Close()
+	if rec := upload("198.51.100.9:4000", "menus.zip", archive.String()); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected an archive of 3 documents to exceed the quota, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestIdempotencyStoreEvictsOldest(t *testing.T) {
+	store := newIdempotencyStore()
+	store.maxEntries = 2
+	now := time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	record := func(key string) {
+		if e, _ := store.begin(key, "upload"); e != nil {
+			t.Fatalf("Expected %s to be new", key)
+		}
+		store.finish(key, &responseRecorder{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK})
+		now = now.Add(time.Minute)
+	}
+
+	record("a")
+	record("b")
+	record("c")
+	if len(store.entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(store.entries))
+	}
+	if _, ok := store.entries["a"]; ok {
+		t.Error("Expected the oldest response to be evicted")
+	}
+	if e, _ := store.begin("c", "upload"); e == nil {
+		t.Error("Expected the newest response to be replayed")
+	}
+}
+
+func TestUploadQuotaKey(t *testing.T) {
+	handler := newTestHandler(t)
+	err := handler.SetUploadClients(models.UploadClients{
+		Households:     map[string]string{"tanaka-token": "tanaka"},
+		TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{"token", "198.51.100.7:4000", http.Header{"Authorization": {"Bearer tanaka-token"}}, "household:tanaka"},
+		{"unknown token", "198.51.100.7:4000", http.Header{"Authorization": {"Bearer guess"}}, "addr:198.51.100.7"},
+		{"household header", "198.51.100.7:4000", http.Header{HouseholdHeader: {"tanaka"}}, "addr:198.51.100.7"},
+		{"untrusted forwarder", "198.51.100.7:4000", http.Header{"X-Forwarded-For": {"203.0.113.5"}}, "addr:198.51.100.7"},
+		{"trusted proxy", "10.1.2.3:4000", http.Header{"X-Forwarded-For": {"203.0.113.5"}}, "addr:203.0.113.5"},
+		{"proxy chain", "192.0.2.1:4000", http.Header{"X-Forwarded-For": {"6.6.6.6, 203.0.113.5, 10.9.9.9"}}, "addr:203.0.113.5"},
+		{"proxy without header", "10.1.2.3:4000", nil, "addr:10.1.2.3"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/upload", nil)
+		req.RemoteAddr = tt.remoteAddr
+		for k, v := range tt.header {
+			req.Header[k] = v
+		}
+		if got := handler.uploadQuotaKey(req); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	if err := handler.SetUploadClients(models.UploadClients{TrustedProxies: []string{"proxy.local"}}); err == nil {
+		t.Error("Expected an invalid proxy address to be rejected")
+	}
+}
+
+func TestChangeContextActor(t *testing.T) {
+	handler := newTestHandler(t)
+	if err := handler.SetUploadClients(models.UploadClients{Households: map[string]string{"tanaka-token": "tanaka"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   models.ChangeContext
+	}{
+		{"token", http.Header{"Authorization": {"Bearer tanaka-token"}}, models.ChangeContext{Actor: "household:tanaka"}},
+		{"token and actor", http.Header{"Authorization": {"Bearer tanaka-token"}, ActorHeader: {"suzuki"}}, models.ChangeContext{Actor: "household:tanaka", ActorLabel: "suzuki"}},
+		{"unknown token", http.Header{"Authorization": {"Bearer guess"}, ActorHeader: {"mom"}}, models.ChangeContext{Actor: "mom"}},
+		{"actor only", http.Header{ActorHeader: {"mom"}}, models.ChangeContext{Actor: "mom"}},
+		{"claimed household", http.Header{ActorHeader: {"household:tanaka"}}, models.ChangeContext{ActorLabel: "household:tanaka"}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/api/school-lunches/2025-01-14", nil)
+		for k, v := range tt.header {
+			req.Header[k] = v
+		}
+		if got := handler.changeContext(req); got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+
+	// The audit log keeps the label beside the verified actor
+	req := httptest.NewRequest(http.MethodPost, "/api/school-lunches", strings.NewReader(`{"date":"2025-01-14T00:00:00Z","main_dish":"カレーライス"}`))
+	req.Header.Set("Authorization", "Bearer tanaka-token")
+	req.Header.Set(ActorHeader, "suzuki")
+	rec := httptest.NewRecorder()
+	newTestMux(handler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	date := time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)
+	audit := handler.menuService.GetAuditLog(&date)
+	if len(audit) != 1 || audit[0].Actor != "household:tanaka" || audit[0].ActorLabel != "suzuki" {
+		t.Errorf("Unexpected audit log: %+v", audit)
+	}
+}
+
+func TestUploadHandlerIdempotencyKey(t *testing.T) {
+	handler := newTestHandler(t)
+	upload := func(key, filename, content string) *httptest.ResponseRecorder {
+		req := newBatchUploadRequest(t, filename, content)
+		req.Header.Set(IdempotencyKeyHeader, key)
+		rec := httptest.NewRecorder()
+		handler.UploadHandler(rec, req)
+		return rec
+	}
+	menu := `[{"date":"2025-04-08T00:00:00Z","main_dish":"カレーライス"}]`
+
+	first := upload("retry-1", "menu.json", menu)
+	if first.Code != http.StatusOK || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("Expected 200, got %d: %s", first.Code, first.Body.String())
+	}
+	retry := upload("retry-1", "menu.json", menu)
+	if retry.Code != http.StatusOK || retry.Header().Get("Idempotent-Replayed") != "true" || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the first response to be replayed, got %d %q", retry.Code, retry.Body.String())
+	}
+
+	// The key cannot be reused for another upload
+	other := upload("retry-1", "menu.json", `[{"date":"2025-04-09T00:00:00Z","main_dish":"親子丼"}]`)
+	if other.Code != http.StatusConflict {
+		t.Errorf("Expected 409, got %d: %s", other.Code, other.Body.String())
+	}
+
+	// Another client has keys of its own
+	req := newBatchUploadRequest(t, "menu.json", `[{"date":"2025-04-10T00:00:00Z","main_dish":"親子丼"}]`)
+	req.Header.Set(IdempotencyKeyHeader, "retry-1")
+	req.RemoteAddr = "198.51.100.99:4000"
+	elsewhere := httptest.NewRecorder()
+	handler.UploadHandler(elsewhere, req)
+	if elsewhere.Code != http.StatusOK || elsewhere.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("Expected another client's key to be separate, got %d: %s", elsewhere.Code, elsewhere.Body.String())
+	}
+
+	// Failed uploads are replayed as well
+	failed := upload("retry-2", "broken.json", `[{"date":`)
+	if failed.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d", failed.Code)
+	}
+	if again := upload("retry-2", "broken.json", `[{"date":`); again.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected the failed response to be replayed")
+	}
+
+	// Without a key, the same file returns the document made the first time
+	rec := httptest.NewRecorder()
+	handler.UploadHandler(rec, newBatchUploadRequest(t, "copy.json", menu))
+	var body struct {
+		Message string                `json:"message"`
+		Result  models.DocumentSource `json:"result"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusOK || !body.Result.Duplicate || !strings.Contains(body.Message, "already processed") {
+		t.Errorf("Expected the earlier document, got %d %+v", rec.Code, body)
+	}
+}
+
+func TestFetchSourceHandlers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`[{"date":"2025-01-20T00:00:00Z","main_dish":"カレーライス"}]`))
+	}))
+	defer ts.Close()
+
+	handler := newTestHandler(t)
+	if err := handler.FetchScheduler().AddSource(models.FetchSource{Name: "center", URL: ts.URL + "/menu.json"}); err != nil {
+		t.Fatalf("Failed to add source: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/fetch-sources", handler.FetchSourcesHandler)
+	mux.HandleFunc("/api/fetch-sources/{name}/fetch", handler.FetchSourceFetchHandler)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/fetch-sources/center/fetch", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var status models.FetchStatus
+	json.NewDecoder(rec.Body).Decode(&status)
+	if status.Outcome != models.FetchOutcomeNew || status.ETag != `"v1"` {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if _, err := handler.menuService.GetSchoolLunchForDate(time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected fetched menu to be stored: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/fetch-sources", nil))
+	var statuses []models.FetchStatus
+	json.NewDecoder(rec.Body).Decode(&statuses)
+	if len(statuses) != 1 || statuses[0].LastCheckedAt == nil {
+		t.Errorf("Unexpected sources: %+v", statuses)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/fetch-sources/missing/fetch", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
+
+func TestDocumentTraceHandler(t *testing.T) {
+	handler := newTestHandler(t)
+	rec := httptest.NewRecorder()
+	handler.UploadHandler(rec, newBatchUploadRequest(t, "menu.json", `[{"date":"2025-04-08T00:00:00Z","main_dish":"カレーライス"}]`))
+	var body struct {
+		Result models.DocumentSource `json:"result"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/documents/{id}/trace", handler.DocumentTraceHandler)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/documents/"+body.Result.ID+"/trace", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var trace models.DocumentTrace
+	json.NewDecoder(rec.Body).Decode(&trace)
+	if trace.DocumentID != body.Result.ID || len(trace.Stages) != len(models.PipelineStageNames) {
+		t.Errorf("Unexpected trace: %+v", trace)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/documents/doc_missing/trace", nil))
+	if rec.Code != http.StatusNotFound || decodeError(t, rec).Code != CodeNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
+
+func TestUploadHandlerValidation(t *testing.T) {
+	handler := newTestHandler(t)
+	rec := httptest.NewRecorder()
+	handler.UploadHandler(rec, newBatchUploadRequest(t, "menu.json", `[
+		{"date":"2025-06-13T00:00:00Z","main_dish":"カレーライス","nutrition":{"calories":6500}},
+		{"date":"2025-06-15T00:00:00Z","main_dish":"焼き魚"}
+	]`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Message string                `json:"message"`
+		Result  models.DocumentSource `json:"result"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	v := body.Result.Validation
+	if v == nil || len(v.Errors) != 1 || v.Errors[0].Field != "nutrition.calories" || len(v.Warnings) != 1 || v.Warnings[0].Code != models.IssueNoSchool {
+		t.Fatalf("Unexpected validation: %+v", v)
+	}
+	if !strings.Contains(body.Message, "1 warning(s) and 1 error(s)") {
+		t.Errorf("Expected the message to mention the issues, got %q", body.Message)
+	}
+}
+
+func TestSchoolLunchNutritionHandler(t *testing.T) {
+	handler := newTestHandler(t)
+	handler.menuService.AddSchoolLunchMenu(models.SchoolLunchMenu{
+		Date: time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC), MainDish: "カレーライス", SideDishes: []string{"牛乳"},
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/school-lunches/{date}/nutrition", handler.SchoolLunchNutritionHandler)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/school-lunches/2025-06-10/nutrition?grade=elementary_low", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var nutrition models.LunchNutrition
+	json.NewDecoder(rec.Body).Decode(&nutrition)
+	if nutrition.Grade != models.GradeElementaryLow || nutrition.Source != models.NutritionEstimated || len(nutrition.Dishes) != 2 {
+		t.Errorf("Unexpected nutrition: %+v", nutrition)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/school-lunches/2025-06-10/nutrition?grade=college", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/school-lunches/2025-06-11/nutrition", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
+
+func TestDishHandlers(t *testing.T) {
+	handler := newTestHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.DishMatchHandler(rec, httptest.NewRequest(http.MethodGet, "/api/dishes/match?name="+url.QueryEscape("味噌汁（わかめ）"), nil))
+	var match models.DishMatch
+	json.NewDecoder(rec.Body).Decode(&match)
+	if rec.Code != http.StatusOK || match.DishID != "miso_soup" || match.Variant != "わかめ" {
+		t.Errorf("Unexpected match: %d %+v", rec.Code, match)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.DishMatchHandler(rec, httptest.NewRequest(http.MethodGet, "/api/dishes/match", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a name, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.DishesHandler(rec, httptest.NewRequest(http.MethodGet, "/api/dishes", nil))
+	var dishes []models.Dish
+	json.NewDecoder(rec.Body).Decode(&dishes)
+	if rec.Code != http.StatusOK || len(dishes) == 0 {
+		t.Errorf("Expected the dictionary, got %d with %d dishes", rec.Code, len(dishes))
+	}
+}
+
+func TestNutritionDashboardHandlers(t *testing.T) {
+	handler := newTestHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/household", handler.HouseholdHandler)
+	mux.HandleFunc("/api/meals", handler.HomeMealsHandler)
+	mux.HandleFunc("/api/meals/{id}", handler.HomeMealItemHandler)
+	mux.HandleFunc("/api/nutrition/daily", handler.DailyNutritionHandler)
+	mux.HandleFunc("/api/nutrition/weekly", handler.WeeklyNutritionHandler)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := serve(http.MethodPut, "/api/household", `{"size": 3, "children": [{"id": "taro", "name": "太郎", "grade": "junior_high"}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodPut, "/api/household", `{"children": [{"id": "taro", "grade": "college"}]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown grade, got %d", rec.Code)
+	}
+
+	// Accept the dinner suggestion for the child
+	rec := serve(http.MethodPost, "/api/meals", `{"date": "2025-01-13", "meal_type": "dinner", "child_id": "taro"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var meal models.HomeMeal
+	json.NewDecoder(rec.Body).Decode(&meal)
+	if meal.MainDish == "" || rec.Header().Get("Location") != "/api/meals/"+meal.ID {
+		t.Errorf("Unexpected meal: %+v", meal)
+	}
+	if rec := serve(http.MethodPost, "/api/meals", `{"date": "2025-01-13", "meal_type": "breakfast", "main_dish": "納豆", "side_dishes": ["白米"]}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// The Location of an accepted meal can be fetched
+	rec = serve(http.MethodGet, "/api/meals/"+meal.ID, "")
+	var fetched models.HomeMeal
+	json.NewDecoder(rec.Body).Decode(&fetched)
+	if rec.Code != http.StatusOK || fetched.ID != meal.ID || fetched.MainDish != meal.MainDish {
+		t.Errorf("Unexpected meal: %d %+v", rec.Code, fetched)
+	}
+	// Accepting the same day and meal type again replaces the meal
+	rec = serve(http.MethodPost, "/api/meals", `{"date": "2025-01-13", "meal_type": "breakfast", "main_dish": "卵焼き"}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("Location") != "" {
+		t.Errorf("Expected 200 without a Location for a replaced meal, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = serve(http.MethodGet, "/api/meals?from=2025-01-13&to=2025-01-13", "")
+	var meals []models.HomeMeal
+	json.NewDecoder(rec.Body).Decode(&meals)
+	if len(meals) != 2 {
+		t.Errorf("Expected 2 meals, got %d", len(meals))
+	}
+
+	rec = serve(http.MethodGet, "/api/nutrition/daily?date=2025-01-13&child_id=taro", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var day models.DailyNutrition
+	json.NewDecoder(rec.Body).Decode(&day)
+	if day.Grade != models.GradeJuniorHigh || len(day.Meals) != 3 || day.Total.Calories == 0 || len(day.Nutrients) != len(models.Nutrients) {
+		t.Errorf("Unexpected daily nutrition: %+v", day)
+	}
+
+	rec = serve(http.MethodGet, "/api/nutrition/weekly?date=2025-01-15&child_id=taro", "")
+	var week models.WeeklyNutrition
+	json.NewDecoder(rec.Body).Decode(&week)
+	if rec.Code != http.StatusOK || week.From != "2025-01-13" || week.Total.Calories != day.Total.Calories {
+		t.Errorf("Unexpected weekly nutrition: %d %+v", rec.Code, week)
+	}
+
+	if rec := serve(http.MethodGet, "/api/nutrition/daily?date=2025-01-13&child_id=jiro", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown child, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/api/nutrition/daily?date=13-01-2025", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad date, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodDelete, "/api/meals/"+meal.ID, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodDelete, "/api/meals/"+meal.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 once deleted, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/api/meals/"+meal.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 once deleted, got %d", rec.Code)
+	}
+}
+
+func TestRecipeHandlers(t *testing.T) {
+	handler := newTestHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/recipes", handler.RecipesHandler)
+	mux.HandleFunc("/api/recipes/{dish}", handler.RecipeHandler)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/recipes/"+url.PathEscape("筑前煮")+"?servings=4", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var recipe models.Recipe
+	json.NewDecoder(rec.Body).Decode(&recipe)
+	if recipe.DishID != "chikuzenni" || recipe.Servings != 4 || recipe.Ingredients[0].Quantity != 200 || len(recipe.Steps) == 0 {
+		t.Errorf("Unexpected recipe: %+v", recipe)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/recipes/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/recipes/rice?servings=two", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/recipes", nil))
+	var recipes []models.Recipe
+	json.NewDecoder(rec.Body).Decode(&recipes)
+	if len(recipes) == 0 {
+		t.Error("Expected the built-in recipes")
+	}
+}
+
+func TestShoppingListHandler(t *testing.T) {
+	handler := newTestHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/household", handler.HouseholdHandler)
+	mux.HandleFunc("/api/meals", handler.HomeMealsHandler)
+	mux.HandleFunc("/api/pantry", handler.PantryHandler)
+	mux.HandleFunc("/api/shopping-list", handler.ShoppingListHandler)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	serve(http.MethodPut, "/api/household", `{"size": 2}`)
+	if rec := serve(http.MethodPost, "/api/meals", `{"date": "2025-01-13", "meal_type": "breakfast", "main_dish": "納豆", "soup": "みそ汁"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodPut, "/api/pantry", `[{"name": "みそ", "quantity": 500, "unit": "g"}]`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodPut, "/api/pantry", `[{"name": "", "quantity": 1}]`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an item without a name, got %d", rec.Code)
+	}
+
+	rec := serve(http.MethodGet, "/api/shopping-list?from=2025-01-13&to=2025-01-19", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list models.ShoppingList
+	json.NewDecoder(rec.Body).Decode(&list)
+	if list.Meals != 1 || len(list.Sections) == 0 || len(list.FromPantry) != 1 || list.FromPantry[0] != "みそ" {
+		t.Errorf("Unexpected shopping list: %+v", list)
+	}
+
+	rec = serve(http.MethodGet, "/api/shopping-list?from=2025-01-13&to=2025-01-19&format=text", "")
+	if body := rec.Body.String(); !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") ||
+		!strings.Contains(body, "【卵・豆腐・乳製品】\n・納豆 2パック\n") {
+		t.Errorf("Unexpected text list: %s", body)
+	}
+	rec = serve(http.MethodGet, "/api/shopping-list?from=2025-01-13&to=2025-01-19&format=markdown", "")
+	if body := rec.Body.String(); !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/markdown") ||
+		!strings.HasPrefix(body, "# 買い物リスト 2025-01-13〜2025-01-19 (2人分)") || !strings.Contains(body, "- [ ] 納豆 2パック\n") {
+		t.Errorf("Unexpected Markdown list: %s", body)
+	}
+
+	if rec := serve(http.MethodGet, "/api/shopping-list?format=pdf", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown format, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/api/shopping-list?from=2025-01-19&to=2025-01-13", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for to before from, got %d", rec.Code)
+	}
+}
+
+func TestPantryHandlers(t *testing.T) {
+	handler := newTestHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/pantry", handler.PantryHandler)
+	mux.HandleFunc("/api/pantry/{id}", handler.PantryItemHandler)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/api/pantry", `{"name": "小松菜", "quantity": 1, "unit": "束", "storage": "fridge", "expires_on": "2025-01-14"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var item models.PantryItem
+	json.NewDecoder(rec.Body).Decode(&item)
+	if item.ID == "" || rec.Header().Get("Location") != "/api/pantry/"+item.ID || item.Storage != models.StorageFridge {
+		t.Errorf("Unexpected item: %+v", item)
+	}
+	if rec := serve(http.MethodPost, "/api/pantry", `{"name": "卵", "quantity": 6, "expires_on": "2025/01/20"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad expiry date, got %d", rec.Code)
+	}
+
+	rec = serve(http.MethodPut, "/api/pantry/"+item.ID, `{"name": "小松菜", "quantity": 0.5, "unit": "束", "storage": "fridge", "expires_on": "2025-01-14"}`)
+	json.NewDecoder(rec.Body).Decode(&item)
+	if rec.Code != http.StatusOK || item.Quantity != 0.5 {
+		t.Errorf("Unexpected update: %d %+v", rec.Code, item)
+	}
+	rec = serve(http.MethodGet, "/api/pantry", "")
+	var items []models.PantryItem
+	json.NewDecoder(rec.Body).Decode(&items)
+	if len(items) != 1 || items[0].ID != item.ID {
+		t.Errorf("Unexpected pantry: %+v", items)
+	}
+
+	if rec := serve(http.MethodDelete, "/api/pantry/"+item.ID, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/api/pantry/"+item.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 once deleted, got %d", rec.Code)
+	}
+}
+
+func TestSuggestTimeBudget(t *testing.T) {
+	handler := newTestHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/household", handler.HouseholdHandler)
+	mux.HandleFunc("/api/suggest", handler.SuggestHandler)
+
+	body := `{"size": 2, "skill": "basic", "time_budgets": [{"days": ["mon", "tue", "wed", "thu", "fri"], "meal_type": "breakfast", "max_minutes": 10}]}`
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/household", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/household", strings.NewReader(`{"equipment": ["oven", "kamado"]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown equipment, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/suggest?date=2025-01-13&meal_type=breakfast", nil))
+	var suggestion models.HomeMenuSuggestion
+	json.NewDecoder(rec.Body).Decode(&suggestion)
+	if rec.Code != http.StatusOK || suggestion.TimeBudget == nil || suggestion.TimeBudget.MaxMinutes != 10 || suggestion.TotalMinutes > 10 {
+		t.Errorf("Unexpected suggestion: %d %+v", rec.Code, suggestion)
+	}
+}
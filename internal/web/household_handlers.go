@@ -0,0 +1,125 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+	"github.com/habuka036/menu-advisor/internal/service"
+)
+
+// HouseholdHandler serves GET/PUT /api/household, the family the home meals
+// are cooked for and its children
+func (h *Handler) HouseholdHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, h.menuService.Household())
+	case http.MethodPut:
+		var household models.Household
+		if !decodeJSONBody(w, r, &household) {
+			return
+		}
+		if err := h.menuService.SetHousehold(household); err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, h.menuService.Household())
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPut)
+	}
+}
+
+// acceptMealRequest is the body of POST /api/meals. Without a main dish the
+// suggestion for the date and meal type is accepted.
+type acceptMealRequest struct {
+	Date       string            `json:"date"`
+	MealType   models.MealType   `json:"meal_type"`
+	ChildID    string            `json:"child_id,omitempty"` // Whose suggestion to accept
+	Grade      models.GradeBand  `json:"grade,omitempty"`
+	MainDish   string            `json:"main_dish,omitempty"`
+	SideDishes []string          `json:"side_dishes,omitempty"`
+	Soup       string            `json:"soup,omitempty"`
+	Nutrition  *models.Nutrition `json:"nutrition,omitempty"`
+}
+
+// HomeMealsHandler serves GET /api/meals?from=...&to=..., the accepted home
+// meals, and POST /api/meals, which accepts one
+func (h *Handler) HomeMealsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var verrs models.ValidationErrors
+		from := queryDate(r, "from", &verrs)
+		to := queryDate(r, "to", &verrs)
+		if err := verrs.Err(); err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		if to.IsZero() {
+			to = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+		}
+		writeJSON(w, http.StatusOK, h.menuService.HomeMeals(from, to))
+	case http.MethodPost:
+		h.acceptHomeMeal(w, r)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// acceptHomeMeal handles POST /api/meals
+func (h *Handler) acceptHomeMeal(w http.ResponseWriter, r *http.Request) {
+	var body acceptMealRequest
+	if !decodeJSONBody(w, r, &body) {
+		return
+	}
+	date, err := time.Parse("2006-01-02", body.Date)
+	if err != nil {
+		writeServiceError(w, r, models.ValidationErrors{{Field: "date", Message: "invalid date format, use YYYY-MM-DD"}}, nil)
+		return
+	}
+
+	var meal *models.HomeMeal
+	var replaced bool
+	if body.MainDish == "" {
+		meal, replaced, err = h.menuService.AcceptHomeMenuSuggestion(date, body.MealType, service.SuggestionOptions{Grade: body.Grade, ChildID: body.ChildID})
+	} else {
+		meal, replaced, err = h.menuService.AcceptHomeMeal(models.HomeMeal{
+			Date:       date,
+			MealType:   body.MealType,
+			MainDish:   body.MainDish,
+			SideDishes: body.SideDishes,
+			Soup:       body.Soup,
+			Nutrition:  body.Nutrition,
+		})
+	}
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	if replaced {
+		writeJSON(w, http.StatusOK, meal)
+		return
+	}
+	w.Header().Set("Location", "/api/meals/"+meal.ID)
+	writeJSON(w, http.StatusCreated, meal)
+}
+
+// HomeMealItemHandler serves GET and DELETE /api/meals/{id}
+func (h *Handler) HomeMealItemHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		meal, err := h.menuService.HomeMeal(r.PathValue("id"))
+		if err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, meal)
+	case http.MethodDelete:
+		if err := h.menuService.DeleteHomeMeal(r.PathValue("id")); err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodDelete)
+	}
+}
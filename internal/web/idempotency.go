@@ -0,0 +1,210 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader lets a client retry an upload safely: a repeated
+// request with the same key replays the first response instead of
+// processing the upload again
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyTTL is how long a response is kept for replay
+const idempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength bounds the length of a client's key
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotencyEntries bounds the recorded responses; when it is reached the
+// one closest to expiring is evicted. Uploads still running are not evicted.
+const maxIdempotencyEntries = 1000
+
+// idempotentResponse is a response recorded for a key, or a placeholder
+// while the first request with the key is still running
+type idempotentResponse struct {
+	fingerprint string
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+	expiresAt   time.Time
+}
+
+// idempotencyStore remembers responses by scoped key, up to maxEntries of them
+type idempotencyStore struct {
+	mu         sync.Mutex
+	entries    map[string]*idempotentResponse
+	maxEntries int
+	now        func() time.Time
+}
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{
+		entries:    make(map[string]*idempotentResponse),
+		maxEntries: maxIdempotencyEntries,
+		now:        time.Now,
+	}
+}
+
+// begin looks up a key. It returns the recorded response to replay, or nil
+// when the caller should handle the request and then call finish. A key
+// reused for a different request, or for one still running, is a conflict.
+func (s *idempotencyStore) begin(key, fingerprint string) (*idempotentResponse, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, e := range s.entries {
+		if e.done && !now.Before(e.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+
+	e, ok := s.entries[key]
+	switch {
+	case !ok:
+		if len(s.entries) >= s.maxEntries {
+			s.evictOldest()
+		}
+		s.entries[key] = &idempotentResponse{fingerprint: fingerprint}
+		return nil, ""
+	case e.fingerprint != fingerprint:
+		return nil, "Idempotency-Key was already used for a different upload"
+	case !e.done:
+		return nil, "An upload with this Idempotency-Key is still being processed"
+	}
+	return e, ""
+}
+
+// evictOldest forgets the recorded response that expires first
+func (s *idempotencyStore) evictOldest() {
+	oldest := ""
+	for k, e := range s.entries {
+		if e.done && (oldest == "" || e.expiresAt.Before(s.entries[oldest].expiresAt)) {
+			oldest = k
+		}
+	}
+	if oldest != "" {
+		delete(s.entries, oldest)
+	}
+}
+
+// finish records the response for a key. Responses that a retry could
+// change, such as server errors or an exhausted quota, are forgotten so that
+// the client can try again with the same key.
+func (s *idempotencyStore) finish(key string, rec *responseRecorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec.status == 0 || rec.status >= http.StatusInternalServerError || rec.status == http.StatusTooManyRequests {
+		delete(s.entries, key)
+		return
+	}
+	e := s.entries[key]
+	e.done = true
+	e.status = rec.status
+	e.header = rec.Header().Clone()
+	e.body = rec.body.Bytes()
+	e.expiresAt = s.now().Add(idempotencyTTL)
+}
+
+// responseRecorder passes a response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// uploadFingerprint hashes an upload's form values and file contents, so
+// that a key reused for a different upload can be told apart from a retry
+func uploadFingerprint(r *http.Request) (string, error) {
+	h := sha256.New()
+	form := r.MultipartForm
+	keys := make([]string, 0, len(form.Value))
+	for k := range form.Value {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range form.Value[k] {
+			io.WriteString(h, k+"="+v+"\n")
+		}
+	}
+	for _, header := range form.File["document"] {
+		f, err := header.Open()
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(h, header.Filename+"\n")
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// idempotent runs an upload handler under the request's Idempotency-Key,
+// replaying the recorded response for a retry. Requests without a key are
+// handled directly.
+func (h *Handler) idempotent(w http.ResponseWriter, r *http.Request, handle func(http.ResponseWriter, *http.Request)) {
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		handle(w, r)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Idempotency-Key is too long", nil)
+		return
+	}
+	if !h.parseUploadForm(w, r) {
+		return
+	}
+	fingerprint, err := uploadFingerprint(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Failed to read uploaded file", nil)
+		return
+	}
+
+	// Keys are scoped like upload quotas, so that other clients can neither
+	// replay nor occupy a household's keys
+	scoped := h.uploadQuotaKey(r) + "\x00" + key
+	recorded, conflict := h.idempotency.begin(scoped, fingerprint)
+	switch {
+	case conflict != "":
+		writeError(w, r, http.StatusConflict, CodeConflict, conflict, nil)
+	case recorded != nil:
+		for k, v := range recorded.header {
+			if k != RequestIDHeader {
+				w.Header()[k] = v
+			}
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(recorded.status)
+		w.Write(recorded.body)
+	default:
+		rec := &responseRecorder{ResponseWriter: w}
+		defer h.idempotency.finish(scoped, rec)
+		handle(rec, r)
+	}
+}
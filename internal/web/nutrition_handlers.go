@@ -0,0 +1,50 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// DailyNutritionHandler serves GET /api/nutrition/daily?date=...&child_id=...,
+// what a child eats in a day against the daily targets
+func (h *Handler) DailyNutritionHandler(w http.ResponseWriter, r *http.Request) {
+	h.nutritionSummary(w, r, func(date time.Time, childID string, grade models.GradeBand) (any, error) {
+		return h.menuService.DailyNutrition(date, childID, grade)
+	})
+}
+
+// WeeklyNutritionHandler serves GET /api/nutrition/weekly?date=...&child_id=...,
+// the same for the week from Monday to Sunday that includes date
+func (h *Handler) WeeklyNutritionHandler(w http.ResponseWriter, r *http.Request) {
+	h.nutritionSummary(w, r, func(date time.Time, childID string, grade models.GradeBand) (any, error) {
+		return h.menuService.WeeklyNutrition(date, childID, grade)
+	})
+}
+
+// nutritionSummary reads the parameters shared by the nutrition dashboards:
+// the date, today when omitted, and the child_id or grade
+func (h *Handler) nutritionSummary(w http.ResponseWriter, r *http.Request, summarize func(time.Time, string, models.GradeBand) (any, error)) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	var verrs models.ValidationErrors
+	date := queryDate(r, "date", &verrs)
+	if err := verrs.Err(); err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	if date.IsZero() {
+		now := time.Now()
+		date = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	summary, err := summarize(date, r.URL.Query().Get("child_id"), models.GradeBand(r.URL.Query().Get("grade")))
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
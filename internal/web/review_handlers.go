@@ -0,0 +1,276 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// ReviewsHandler serves GET /api/reviews?status=pending|approved|rejected
+func (h *Handler) ReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	status := models.ReviewStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", models.ReviewStatusPending, models.ReviewStatusApproved, models.ReviewStatusRejected:
+	default:
+		writeServiceError(w, r, models.ValidationErrors{{Field: "status", Message: "must be pending, approved or rejected"}}, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.documentProcessor.ListReviews(status))
+}
+
+// ReviewItemHandler serves GET and PATCH /api/reviews/{id}. PATCH takes a JSON
+// merge patch of the extracted menu, e.g. {"main_dish": "鶏肉の照り焼き"}.
+func (h *Handler) ReviewItemHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		review, err := h.documentProcessor.GetReview(id)
+		if err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, review)
+	case http.MethodPatch:
+		var patch json.RawMessage
+		if !decodeJSONBody(w, r, &patch) {
+			return
+		}
+		review, err := h.documentProcessor.CorrectReview(id, func(menu *models.SchoolLunchMenu) error {
+			return applyMergePatch(menu, patch)
+		})
+		if err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, review)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPatch)
+	}
+}
+
+// ReviewApproveHandler serves POST /api/reviews/{id}/approve, storing the menu
+func (h *Handler) ReviewApproveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	review, err := h.documentProcessor.ApproveReview(r.PathValue("id"), h.changeContext(r).Actor)
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, review)
+}
+
+// ReviewRejectHandler serves POST /api/reviews/{id}/reject, discarding the menu
+func (h *Handler) ReviewRejectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	review, err := h.documentProcessor.RejectReview(r.PathValue("id"), h.changeContext(r).Actor)
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, review)
+}
+
+// ReviewFieldImageHandler serves GET /api/reviews/{id}/fields/{field}/image,
+// the region of the original image a field was read from
+func (h *Handler) ReviewFieldImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	data, err := h.documentProcessor.ReviewFieldImage(r.PathValue("id"), r.PathValue("field"))
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// ReviewPageHandler serves the page for checking and correcting low-confidence extractions
+func (h *Handler) ReviewPageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(reviewPage))
+}
+
+// reviewPage is reviewPageHTML with the labels of models.Nutrients filled in,
+// so that every nutrient the service reads is labelled on the page
+var reviewPage = strings.Replace(reviewPageHTML, "NUTRIENT_LABELS", nutrientLabelsJSON(), 1)
+
+// nutrientLabelsJSON returns a JSON object from the review field name of each
+// nutrient, such as "nutrition.protein_g", to its Japanese label
+func nutrientLabelsJSON() string {
+	labels := make(map[string]string, len(models.Nutrients))
+	for _, n := range models.Nutrients {
+		labels["nutrition."+n.Key] = n.Label
+	}
+	data, _ := json.Marshal(labels)
+	return string(data)
+}
+
+const reviewPageHTML = `
+<!DOCTYPE html>
+<html lang="ja">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>読み取り結果の確認 - 学校給食メニューアドバイザー</title>
+    <style>
+        body { font-family: Arial, sans-serif; margin: 40px; background-color: #f5f5f5; }
+        .container { max-width: 900px; margin: 0 auto; background: white; padding: 30px; border-radius: 10px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
+        h1 { color: #2c3e50; text-align: center; margin-bottom: 30px; }
+        .review { background: #ecf0f1; padding: 15px; margin: 15px 0; border-radius: 5px; }
+        .field { display: flex; align-items: center; gap: 10px; margin: 8px 0; }
+        .field img { max-width: 320px; max-height: 80px; border: 1px solid #ccc; background: white; }
+        .field label { width: 120px; font-weight: bold; color: #34495e; }
+        .low { border-left: 4px solid #e74c3c; padding-left: 6px; }
+        input, button { padding: 8px; border: 1px solid #ddd; border-radius: 3px; }
+        button { background: #3498db; color: white; border: none; cursor: pointer; }
+        button.reject { background: #95a5a6; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>🔍 読み取り結果の確認</h1>
+        <p>読み取りの信頼度が低かった給食メニューです。元の画像と見比べて修正し、承認すると登録されます。</p>
+        <div id="reviews"></div>
+        <p><a href="/">← トップへ戻る</a></p>
+    </div>
+
+    <script>
+        const labels = Object.assign({
+            'date': '日付', 'main_dish': '主菜', 'side_dishes': '副菜', 'soup': '汁物', 'dessert': 'デザート'
+        }, NUTRIENT_LABELS);
+        const dishFields = ['date', 'main_dish', 'side_dishes', 'soup', 'dessert'];
+
+        // fieldNames lists the fields shown for correction: the date and
+        // dishes, and every nutrition value that was read from the document
+        function fieldNames(review) {
+            const nutrition = Object.keys(review.fields || {}).filter(name => name.startsWith('nutrition.')).sort();
+            return dishFields.concat(nutrition);
+        }
+
+        function fieldValue(review, name) {
+            if (name === 'date') return review.menu.date.substring(0, 10);
+            if (name === 'side_dishes') return (review.menu.side_dishes || []).join('、');
+            if (name.startsWith('nutrition.')) return (review.menu.nutrition || {})[name.substring('nutrition.'.length)] ?? '';
+            return review.menu[name];
+        }
+
+        function escapeHTML(s) {
+            return String(s ?? '').replace(/[&<>"']/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'}[c]));
+        }
+
+        async function loadReviews() {
+            const response = await fetch('/api/reviews?status=pending');
+            const reviews = await response.json();
+            const container = document.getElementById('reviews');
+            if (reviews.length === 0) {
+                container.innerHTML = '<p>確認待ちのメニューはありません。</p>';
+                return;
+            }
+            container.innerHTML = reviews.map(renderReview).join('');
+        }
+
+        function renderReview(review) {
+            const fields = fieldNames(review).map(name => {
+                const f = review.fields[name] || {};
+                const type = name === 'date' ? 'date' : name.startsWith('nutrition.') ? 'number' : 'text';
+                const low = f.confidence !== undefined && f.confidence < 0.8 ? 'low' : '';
+                return ` + "`" + `
+                    <div class="field ${low}">
+                        <label>${escapeHTML(labels[name] || name)}</label>
+                        <img src="/api/reviews/${encodeURIComponent(review.id)}/fields/${encodeURIComponent(name)}/image" alt="" onerror="this.style.display='none'">
+                        <input type="${type}" step="any" data-field="${escapeHTML(name)}" value="${escapeHTML(fieldValue(review, name))}">
+                        <small>${f.confidence !== undefined ? Math.round(f.confidence * 100) + '%' : ''}</small>
+                    </div>` + "`" + `;
+            }).join('');
+            return ` + "`" + `
+                <div class="review" id="review-${escapeHTML(review.id)}">
+                    <h3>📅 ${review.menu.date.substring(0, 10)} <small>(信頼度 ${Math.round(review.confidence * 100)}%)</small></h3>
+                    ${fields}
+                    <button onclick="approve('${escapeHTML(review.id)}')">修正を保存して承認</button>
+                    <button class="reject" onclick="reject('${escapeHTML(review.id)}')">破棄</button>
+                    <div class="message"></div>
+                </div>` + "`" + `;
+        }
+
+        async function approve(id) {
+            const el = document.getElementById('review-' + id);
+            const patch = {};
+            el.querySelectorAll('input[data-field]').forEach(input => {
+                const name = input.dataset.field;
+                if (name === 'date') {
+                    patch.date = input.value + 'T00:00:00Z';
+                } else if (name.startsWith('nutrition.')) {
+                    patch.nutrition = patch.nutrition || {};
+                    patch.nutrition[name.substring('nutrition.'.length)] = input.value === '' ? null : Number(input.value);
+                } else {
+                    patch[name] = name === 'side_dishes'
+                        ? input.value.split(/[、,]/).map(s => s.trim()).filter(s => s)
+                        : input.value;
+                }
+            });
+            let response = await fetch('/api/reviews/' + encodeURIComponent(id), {
+                method: 'PATCH', headers: {'Content-Type': 'application/json'}, body: JSON.stringify(patch)
+            });
+            if (response.ok) {
+                response = await fetch('/api/reviews/' + encodeURIComponent(id) + '/approve', {method: 'POST'});
+            }
+            if (response.ok) {
+                // Under the upload's merge policy the stored day may be kept
+                const review = await response.clone().json();
+                const status = review.merge && review.merge.status;
+                if (status === 'conflict' || status === 'partial') {
+                    el.querySelector('button').disabled = true;
+                    el.querySelector('.message').innerHTML = '<p style="color: #e67e22;">承認しましたが、登録済みのメニューと異なるため ' +
+                        escapeHTML(review.merge_policy) + ' に従って' + (status === 'partial' ? '一部の項目しか' : '') + '反映されませんでした。</p>';
+                    return;
+                }
+            }
+            await finish(el, response);
+        }
+
+        async function reject(id) {
+            const el = document.getElementById('review-' + id);
+            const response = await fetch('/api/reviews/' + encodeURIComponent(id) + '/reject', {method: 'POST'});
+            await finish(el, response);
+        }
+
+        async function finish(el, response) {
+            if (response.ok) {
+                el.remove();
+                if (!document.querySelector('.review')) loadReviews();
+                return;
+            }
+            const data = await response.json();
+            el.querySelector('.message').innerHTML = '<p style="color: red;">エラー: ' + escapeHTML(data.error.message) + '</p>';
+        }
+
+        loadReviews();
+    </script>
+</body>
+</html>`
@@ -0,0 +1,349 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+	"github.com/habuka036/menu-advisor/internal/service"
+)
+
+// ActorHeader names the person making a change. It is not verified: with a
+// configured API token the audit log records the token's household as the
+// actor and this name only as its label.
+const ActorHeader = "X-Actor"
+
+// householdActor prefixes the household of an API token in audit actors
+const householdActor = "household:"
+
+// SchoolLunchItemHandler serves GET/PUT/PATCH/DELETE /api/school-lunches/{date}
+func (h *Handler) SchoolLunchItemHandler(w http.ResponseWriter, r *http.Request) {
+	date, ok := pathDate(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		lunch, err := h.menuService.GetSchoolLunchForDate(date)
+		if err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		writeSchoolLunch(w, http.StatusOK, lunch)
+	case http.MethodPut:
+		var menu models.SchoolLunchMenu
+		if !decodeJSONBody(w, r, &menu) {
+			return
+		}
+		lunch, err := h.menuService.UpdateSchoolLunchMenu(date, menu, r.Header.Get("If-Match"), h.changeContext(r))
+		if err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		writeSchoolLunch(w, http.StatusOK, lunch)
+	case http.MethodPatch:
+		var patch json.RawMessage
+		if !decodeJSONBody(w, r, &patch) {
+			return
+		}
+		lunch, err := h.menuService.PatchSchoolLunchMenu(date, func(menu *models.SchoolLunchMenu) error {
+			return applyMergePatch(menu, patch)
+		}, r.Header.Get("If-Match"), h.changeContext(r))
+		if err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		writeSchoolLunch(w, http.StatusOK, lunch)
+	case http.MethodDelete:
+		if err := h.menuService.DeleteSchoolLunchMenu(date, r.Header.Get("If-Match"), h.changeContext(r)); err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+// SchoolLunchVersionsHandler serves GET /api/school-lunches/{date}/versions
+func (h *Handler) SchoolLunchVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	date, ok := pathDate(w, r)
+	if !ok {
+		return
+	}
+
+	versions, err := h.menuService.GetSchoolLunchVersions(date)
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, versions)
+}
+
+// SchoolLunchNutritionHandler serves GET /api/school-lunches/{date}/nutrition?grade=...
+func (h *Handler) SchoolLunchNutritionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	date, ok := pathDate(w, r)
+	if !ok {
+		return
+	}
+	grade, err := models.ParseGradeBand(r.URL.Query().Get("grade"))
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+
+	nutrition, err := h.menuService.LunchNutrition(date, grade)
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, nutrition)
+}
+
+// SchoolLunchVersionDiffHandler serves GET /api/school-lunches/{date}/versions/diff?from=N&to=M
+func (h *Handler) SchoolLunchVersionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	date, ok := pathDate(w, r)
+	if !ok {
+		return
+	}
+
+	var verrs models.ValidationErrors
+	from := queryInt(r, "from", &verrs)
+	to := queryInt(r, "to", &verrs)
+	if err := verrs.Err(); err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+
+	diff, err := h.menuService.DiffSchoolLunchVersions(date, from, to)
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, diff)
+}
+
+// SchoolLunchRollbackHandler serves POST /api/school-lunches/{date}/rollback
+// with a body of {"version": N}
+func (h *Handler) SchoolLunchRollbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	date, ok := pathDate(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Version int `json:"version"`
+	}
+	if !decodeJSONBody(w, r, &body) {
+		return
+	}
+
+	lunch, err := h.menuService.RollbackSchoolLunchMenu(date, body.Version, r.Header.Get("If-Match"), h.changeContext(r))
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	if lunch == nil {
+		// The restored version is a deletion
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeSchoolLunch(w, http.StatusOK, lunch)
+}
+
+// AuditHandler returns the audit log, optionally filtered by ?date=YYYY-MM-DD
+func (h *Handler) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	var date *time.Time
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		d, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			writeServiceError(w, r, models.ValidationErrors{{Field: "date", Message: "invalid date format, use YYYY-MM-DD"}}, nil)
+			return
+		}
+		date = &d
+	}
+
+	writeJSON(w, http.StatusOK, h.menuService.GetAuditLog(date))
+}
+
+// createSchoolLunch handles POST /api/school-lunches
+func (h *Handler) createSchoolLunch(w http.ResponseWriter, r *http.Request) {
+	var menu models.SchoolLunchMenu
+	if !decodeJSONBody(w, r, &menu) {
+		return
+	}
+
+	lunch, err := h.menuService.CreateSchoolLunchMenu(menu, h.changeContext(r))
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	w.Header().Set("Location", "/api/school-lunches/"+lunch.Date.Format("2006-01-02"))
+	writeSchoolLunch(w, http.StatusCreated, lunch)
+}
+
+// writeSchoolLunch writes a single menu along with its ETag
+func writeSchoolLunch(w http.ResponseWriter, status int, lunch *models.SchoolLunchMenu) {
+	w.Header().Set("ETag", service.SchoolLunchETag(lunch))
+	writeJSON(w, status, lunch)
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// queryInt parses an optional integer query parameter, recording a field error on failure
+func queryInt(r *http.Request, name string, verrs *models.ValidationErrors) int {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		verrs.Add(name, "must be an integer")
+	}
+	return n
+}
+
+// queryDate parses an optional YYYY-MM-DD query parameter, recording a field error on failure
+func queryDate(r *http.Request, name string, verrs *models.ValidationErrors) time.Time {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return time.Time{}
+	}
+	date, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		verrs.Add(name, "invalid date format, use YYYY-MM-DD")
+	}
+	return date
+}
+
+// pathDate parses the {date} path value, writing a validation error on failure
+func pathDate(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	date, err := time.Parse("2006-01-02", r.PathValue("date"))
+	if err != nil {
+		writeServiceError(w, r, models.ValidationErrors{{Field: "date", Message: "invalid date format, use YYYY-MM-DD"}}, nil)
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// maxJSONBodyBytes bounds the size of a JSON request body
+const maxJSONBodyBytes = 1 << 20
+
+// decodeJSONBody decodes the request body into v, writing an error on failure
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	if _, ok := v.(*json.RawMessage); !ok {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+				fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit), nil)
+			return false
+		}
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Invalid JSON body: %v", err), nil)
+		return false
+	}
+	return true
+}
+
+// changeContext builds the audit context for a request. A request with a
+// configured API token is recorded as made by the token's household, and
+// ActorHeader, which the client sets as it likes, only as a label beside it.
+// Without a token ActorHeader is all there is, but it cannot pass itself off
+// as a household.
+func (h *Handler) changeContext(r *http.Request) models.ChangeContext {
+	label := r.Header.Get(ActorHeader)
+	if household, ok := h.tokenHousehold(r); ok {
+		return models.ChangeContext{Actor: householdActor + household, ActorLabel: label}
+	}
+	if strings.HasPrefix(label, householdActor) {
+		return models.ChangeContext{ActorLabel: label}
+	}
+	return models.ChangeContext{Actor: label}
+}
+
+// applyMergePatch applies an RFC 7386 JSON merge patch to menu
+func applyMergePatch(menu *models.SchoolLunchMenu, patch json.RawMessage) error {
+	current, err := json.Marshal(menu)
+	if err != nil {
+		return err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return err
+	}
+	var p map[string]interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return models.ValidationErrors{{Field: "body", Message: "merge patch must be a JSON object"}}
+	}
+	mergeObjects(doc, p)
+
+	merged, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var result models.SchoolLunchMenu
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&result); err != nil {
+		return models.ValidationErrors{{Field: "body", Message: err.Error()}}
+	}
+	*menu = result
+	return nil
+}
+
+// mergeObjects merges patch into doc following RFC 7386 semantics
+func mergeObjects(doc, patch map[string]interface{}) {
+	for k, v := range patch {
+		if v == nil {
+			delete(doc, k)
+			continue
+		}
+		if pv, ok := v.(map[string]interface{}); ok {
+			dv, ok := doc[k].(map[string]interface{})
+			if !ok {
+				dv = map[string]interface{}{}
+			}
+			mergeObjects(dv, pv)
+			doc[k] = dv
+			continue
+		}
+		doc[k] = v
+	}
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+)
+
+// ShoppingListHandler serves GET /api/shopping-list?from=...&to=...&format=...,
+// the ingredients to buy for the home meals planned between the dates. The
+// dates default to the current week from Monday to Sunday, and format is
+// json (the default), text or markdown.
+func (h *Handler) ShoppingListHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	var verrs models.ValidationErrors
+	from := queryDate(r, "from", &verrs)
+	to := queryDate(r, "to", &verrs)
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json", "text", "markdown":
+	default:
+		verrs.Add("format", fmt.Sprintf("unsupported format %q (expected json, text or markdown)", format))
+	}
+	if err := verrs.Err(); err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	if from.IsZero() {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		from = today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	}
+	if to.IsZero() {
+		to = from.AddDate(0, 0, 6)
+	}
+
+	list, err := h.menuService.ShoppingList(from, to)
+	if err != nil {
+		writeServiceError(w, r, err, nil)
+		return
+	}
+	switch format {
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, list.Text())
+	case "markdown":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		fmt.Fprint(w, list.Markdown())
+	default:
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// PantryHandler serves GET /api/pantry, the ingredients the household
+// already has by expiry date, PUT /api/pantry, which replaces them, and
+// POST /api/pantry, which adds one
+func (h *Handler) PantryHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, h.menuService.Pantry())
+	case http.MethodPut:
+		var items []models.PantryItem
+		if !decodeJSONBody(w, r, &items) {
+			return
+		}
+		if err := h.menuService.SetPantry(items); err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, h.menuService.Pantry())
+	case http.MethodPost:
+		var item models.PantryItem
+		if !decodeJSONBody(w, r, &item) {
+			return
+		}
+		added, err := h.menuService.AddPantryItem(item)
+		if err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		w.Header().Set("Location", "/api/pantry/"+added.ID)
+		writeJSON(w, http.StatusCreated, added)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodPost)
+	}
+}
+
+// PantryItemHandler serves GET/PUT/DELETE /api/pantry/{id}, one ingredient
+// the household has
+func (h *Handler) PantryItemHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	switch r.Method {
+	case http.MethodGet:
+		item, err := h.menuService.PantryItem(id)
+		if err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	case http.MethodPut:
+		var item models.PantryItem
+		if !decodeJSONBody(w, r, &item) {
+			return
+		}
+		updated, err := h.menuService.UpdatePantryItem(id, item)
+		if err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	case http.MethodDelete:
+		if err := h.menuService.DeletePantryItem(id); err != nil {
+			writeServiceError(w, r, err, nil)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
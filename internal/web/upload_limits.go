@@ -0,0 +1,197 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/habuka036/menu-advisor/internal/models"
+	"github.com/habuka036/menu-advisor/internal/service"
+)
+
+// HouseholdHeader names the household an upload claims to be for. It is
+// chosen by the client, so neither upload quotas nor idempotency keys use it;
+// both are scoped by the household of an API token or the client address.
+const HouseholdHeader = "X-Household-ID"
+
+// maxUploadMemory is how much of an upload form is kept in memory; larger
+// files are spooled to disk
+const maxUploadMemory = 32 << 20
+
+// UploadQuota returns the tracker that enforces per-household upload quotas
+func (h *Handler) UploadQuota() *service.QuotaTracker {
+	return h.uploadQuota
+}
+
+// uploadClients identifies who an upload counts against
+type uploadClients struct {
+	mu         sync.RWMutex
+	households map[string]string // By API token
+	proxies    []netip.Prefix
+}
+
+// SetUploadClients changes the API tokens of households and the reverse
+// proxies trusted to tell the client address
+func (h *Handler) SetUploadClients(config models.UploadClients) error {
+	var proxies []netip.Prefix
+	for _, p := range config.TrustedProxies {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(p)
+			if aerr != nil {
+				return fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	for token, household := range config.Households {
+		if token == "" || household == "" {
+			return fmt.Errorf("household tokens and IDs must not be empty")
+		}
+	}
+
+	h.uploadClients.mu.Lock()
+	defer h.uploadClients.mu.Unlock()
+	h.uploadClients.households = maps.Clone(config.Households)
+	h.uploadClients.proxies = proxies
+	return nil
+}
+
+// LoadUploadClients reads the household API tokens and trusted proxies from
+// a JSON file
+func (h *Handler) LoadUploadClients(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read upload clients: %w", err)
+	}
+	var config models.UploadClients
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse upload clients: %w", err)
+	}
+	return h.SetUploadClients(config)
+}
+
+// parseUploadForm parses a multipart upload, refusing bodies larger than the
+// upload limit. It writes the error response and returns false on failure.
+func (h *Handler) parseUploadForm(w http.ResponseWriter, r *http.Request) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, h.documentProcessor.UploadLimits().MaxBodyBytes)
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+				fmt.Sprintf("Upload is larger than %d bytes", tooLarge.Limit), nil)
+			return false
+		}
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Failed to parse form", nil)
+		return false
+	}
+	return true
+}
+
+// reserveUploadQuota counts the documents of an upload, with those of zip
+// archives counted one by one, against the household or client address. It
+// writes the error response and returns false when the quota is used up.
+func (h *Handler) reserveUploadQuota(w http.ResponseWriter, r *http.Request, reqs []*models.DocumentProcessingRequest) bool {
+	var documents int
+	var size int64
+	for _, req := range reqs {
+		count, err := service.DocumentCount(req.File)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Failed to read uploaded file", nil)
+			return false
+		}
+		documents += count
+		size += req.Header.Size
+	}
+	usage, err := h.uploadQuota.Reserve(h.uploadQuotaKey(r), documents, size)
+	if err != nil {
+		retry := math.Ceil(time.Until(usage.ResetAt).Seconds())
+		w.Header().Set("Retry-After", strconv.Itoa(max(int(retry), 1)))
+		writeServiceError(w, r, err, usage)
+		return false
+	}
+	return true
+}
+
+// uploadQuotaKey returns who an upload counts against: the household of a
+// configured API token, or else the client's address. Headers such as
+// HouseholdHeader are set by the client and are not used, since a new value
+// on each request would give a fresh quota; X-Forwarded-For is only believed
+// from trusted proxies.
+func (h *Handler) uploadQuotaKey(r *http.Request) string {
+	if household, ok := h.tokenHousehold(r); ok {
+		return householdActor + household
+	}
+	c := &h.uploadClients
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return "addr:" + c.clientAddr(r)
+}
+
+// tokenHousehold returns the household of the configured API token the
+// request carries as "Authorization: Bearer <token>"
+func (h *Handler) tokenHousehold(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", false
+	}
+	c := &h.uploadClients
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for known, household := range c.households {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(known)) == 1 {
+			return household, true
+		}
+	}
+	return "", false
+}
+
+// clientAddr returns the address of the client, taken from X-Forwarded-For
+// when the request comes through trusted proxies: the rightmost address
+// that is not itself a trusted proxy
+func (c *uploadClients) clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !c.trusted(addr) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Whatever is left of the header was written by the client
+			break
+		}
+		addr = hop.Unmap()
+		if !c.trusted(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+// trusted reports whether addr is one of the trusted proxies
+func (c *uploadClients) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return slices.ContainsFunc(c.proxies, func(p netip.Prefix) bool { return p.Contains(addr) })
+}